@@ -1,18 +1,38 @@
 package pilot
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s-pilot/internal/config"
+	"k8s-pilot/internal/logger"
 	"k8s-pilot/pkg/diagnose"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
 )
 
 var (
 	resourceType string
 	resourceName string
 	allNamespaces bool
+	failOn string
+	watch bool
+	debounce time.Duration
+	fromDump string
 )
 
+// diagnoseFormats adds the CI report formats to the document formats
+var diagnoseFormats = append(append([]output.Format{}, documentFormats...), output.FormatSARIF, output.FormatJUnit)
+
+// watchFormats are the formats --watch can stream changes in
+var watchFormats = []output.Format{output.FormatText, output.FormatJSON, output.FormatYAML}
+
 var diagnoseCmd = &cobra.Command{
 	Use:   "diagnose [resource-type] [resource-name]",
 	Short: "Diagnose Kubernetes cluster issues",
@@ -25,54 +45,324 @@ var diagnoseCmd = &cobra.Command{
   • Network problems
 
 The diagnostics engine aggregates logs, events, and resource state to provide
-root-cause hypotheses and ranked remediation suggestions.
+root-cause hypotheses and ranked remediation suggestions. Any resource the
+cluster serves, including custom resources, can be named by kind, plural or
+short name; their status conditions (Ready=False, Degraded=True, ...) are checked.
+Custom rules from the rules directory in the config file (default
+~/.k8s-pilot/rules) are evaluated alongside the built-in checks; see
+"kubectl-pilot rules --help".
+
+The health score weighs each issue by severity and by the criticality of its
+resource (kube-system and "tier=critical" count double by default), and is
+normalized by the number of resources examined, so a few bad pods in a large
+cluster still score well. The report breaks it down per category and per
+namespace; weights are set under "scoring" in the config file.
+
+Use -o json or -o yaml for a versioned document with every issue's details,
+or -o markdown to paste into an incident ticket. Text output drops emoji when
+it is not written to a terminal. For CI, -o sarif and -o junit report every
+issue as a result or failing test case, and --fail-on <severity> exits with
+code 2 when issues at or above that severity are found.
+
+With --watch, diagnose keeps informers open and runs the same checks again
+whenever pods, events or nodes change, printing only the issues that
+appeared, escalated or were resolved, with timestamps. A change is only
+printed once it has lasted for --debounce, so flapping resources stay quiet.
+Use -o json or -o yaml to stream the changes as documents.
+
+With --from-dump, diagnose reads a bundle written by "kubectl-pilot dump", or
+a directory or .tar.gz of "kubectl get -o yaml" output with container logs
+under logs/<namespace>/<pod>/<container>[.previous].log, and runs the same
+checks without an API server. Resources missing from the bundle are reported
+as not inspected.
 
 Examples:
   kubectl-pilot diagnose pod myapp-pod
   kubectl-pilot diagnose deployment myapp -n production
-  kubectl-pilot diagnose --all-namespaces`,
+  kubectl-pilot diagnose statefulset db -n data
+  kubectl-pilot diagnose cronjob nightly-report -n batch
+  kubectl-pilot diagnose service api -n payments
+  kubectl-pilot diagnose ingress storefront -n shop
+  kubectl-pilot diagnose pvc data-db-0 -n data
+  kubectl-pilot diagnose node worker-3 --all-namespaces
+  kubectl-pilot diagnose certificate api-tls -n payments
+  kubectl-pilot diagnose rollouts.argoproj.io -n production
+  kubectl-pilot diagnose --all-namespaces
+  kubectl-pilot diagnose --contexts prod-eu,prod-us -n payments
+  kubectl-pilot diagnose -n payments -o json | jq '.issues[].resource'
+  kubectl-pilot diagnose deployment api -o markdown > incident.md
+  kubectl-pilot diagnose -n payments -o junit --fail-on high > diagnose.xml
+  kubectl-pilot diagnose deployment api -n payments --watch
+  kubectl-pilot diagnose -A --watch --debounce 1m
+  kubectl-pilot diagnose --from-dump k8s-pilot-dump-20240501-120000.tar.gz
+  kubectl-pilot diagnose deployment api -n payments --from-dump ./support-bundle/`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		engine := diagnose.NewEngine(namespace, allNamespaces)
-		
-		var report *diagnose.Report
-		var err error
-		
-		if len(args) >= 2 {
-			// Diagnose specific resource
-			report, err = engine.DiagnoseResource(args[0], args[1])
-		} else if len(args) == 1 {
-			// Diagnose resource type
-			report, err = engine.DiagnoseResourceType(args[0])
+		format, err := selectedFormat(diagnoseFormats...)
+		if err != nil {
+			return err
+		}
+		threshold, err := failOnSeverity()
+		if err != nil {
+			return err
+		}
+		contexts, err := selectedContexts()
+		if err != nil {
+			return err
+		}
+		if fromDump != "" && (watch || len(contexts) > 0) {
+			return fmt.Errorf("--from-dump can't be combined with --watch or --contexts")
+		}
+		if watch {
+			if len(contexts) > 0 {
+				return fmt.Errorf("--watch follows a single cluster and can't be combined with --contexts")
+			}
+			if threshold != "" {
+				return fmt.Errorf("--fail-on can't be combined with --watch")
+			}
+			if format, err = output.ParseFormat(string(format), watchFormats...); err != nil {
+				return fmt.Errorf("--watch: %w", err)
+			}
+		}
+		ruleSet, err := loadRules()
+		if err != nil {
+			return err
+		}
+		weights := scoreWeights()
+
+		if len(contexts) > 0 {
+			merged := diagnose.DiagnoseContexts(contexts, maxParallel, func(name string) (*diagnose.Report, error) {
+				engine, err := diagnose.NewEngineWithOptions(namespace, allNamespaces, kubeOptions().WithContext(name))
+				if err != nil {
+					return nil, err
+				}
+				engine.SetRules(ruleSet)
+				if err := engine.SetScoreWeights(weights); err != nil {
+					return nil, err
+				}
+				return runDiagnostics(engine, args)
+			})
+
+			if format != output.FormatText {
+				if err := writeDocument(format, multiClusterDocument(format, merged), merged.Markdown); err != nil {
+					return err
+				}
+			} else {
+				fmt.Fprintln(output.Stdout, "\n🔍 Multi-Cluster Diagnostic Report:")
+				fmt.Fprintln(output.Stdout, "═══════════════════════════════════")
+				merged.Display()
+			}
+			// A cluster that could not be diagnosed is a failure, not a
+			// finding, so it exits 1 even when the others are clean
+			if err := clusterFailures(merged); err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			return checkFailOn(cmd, merged.CountAtLeast(threshold), threshold)
+		}
+
+		var engine *diagnose.Engine
+		if fromDump != "" {
+			engine, err = dumpEngine(fromDump)
 		} else {
-			// Diagnose entire namespace/cluster
-			report, err = engine.DiagnoseCluster()
+			engine, err = diagnose.NewEngine(namespace, allNamespaces)
 		}
-		
+		if err != nil {
+			return fmt.Errorf("failed to create diagnostics engine: %w", err)
+		}
+		engine.SetRules(ruleSet)
+		if err := engine.SetScoreWeights(weights); err != nil {
+			return fmt.Errorf("invalid scoring config: %w", err)
+		}
+
+		if watch {
+			return watchDiagnostics(engine, args, format)
+		}
+
+		report, err := runDiagnostics(engine, args)
 		if err != nil {
 			return fmt.Errorf("diagnostics failed: %w", err)
 		}
-		
+
+		if format != output.FormatText {
+			if err := writeDocument(format, reportDocument(format, report), report.Markdown); err != nil {
+				return err
+			}
+			return checkFailOn(cmd, report.CountAtLeast(threshold), threshold)
+		}
+
 		// Display diagnostic report
-		fmt.Println("\n🔍 Diagnostic Report:")
-		fmt.Println("══════════════════════")
+		fmt.Fprintln(output.Stdout, "\n🔍 Diagnostic Report:")
+		fmt.Fprintln(output.Stdout, "══════════════════════")
 		report.Display()
-		
+
 		// Show recommended fixes
-		if len(report.Remediations) > 0 {
-			fmt.Println("\n💡 Recommended Fixes:")
-			fmt.Println("────────────────────")
-			for i, remedy := range report.Remediations {
-				fmt.Printf("\n%d. %s (Confidence: %s)\n", i+1, remedy.Title, remedy.Confidence)
-				fmt.Printf("   %s\n", remedy.Description)
-				fmt.Printf("   Command: %s\n", remedy.Command)
-			}
-		}
+		report.DisplayRemediations()
 		
-		return nil
+		if verbose {
+			displayAPIUsage(engine)
+		}
+
+		return checkFailOn(cmd, report.CountAtLeast(threshold), threshold)
 	},
 }
 
+// failOnSeverity parses --fail-on, returning "" when it isn't set
+func failOnSeverity() (diagnose.Severity, error) {
+	if failOn == "" || strings.EqualFold(failOn, "none") {
+		return "", nil
+	}
+	return diagnose.ParseSeverity(failOn)
+}
+
+// checkFailOn fails the command when issues at or above the --fail-on
+// threshold were found
+func checkFailOn(cmd *cobra.Command, found int, threshold diagnose.Severity) error {
+	if threshold == "" || found == 0 {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return &findingsError{found: found, threshold: threshold}
+}
+
+// clusterFailures returns an error naming the contexts that could not be
+// diagnosed, if any
+func clusterFailures(merged *diagnose.MultiClusterReport) error {
+	var failed []string
+	for _, cluster := range merged.Clusters {
+		if cluster.Err != nil {
+			failed = append(failed, cluster.Context)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to diagnose %d of %d cluster(s): %s", len(failed), len(merged.Clusters), strings.Join(failed, ", "))
+}
+
+// findingsError reports issues at or above the --fail-on threshold; the
+// process exits with code 2 so CI can tell findings from failures
+type findingsError struct {
+	found     int
+	threshold diagnose.Severity
+}
+
+func (e *findingsError) Error() string {
+	return fmt.Sprintf("found %d issue(s) at or above severity %s", e.found, e.threshold)
+}
+
+// reportDocument returns the document a structured format prints for a report
+func reportDocument(format output.Format, report *diagnose.Report) interface{} {
+	switch format {
+	case output.FormatSARIF:
+		return report.SARIF()
+	case output.FormatJUnit:
+		return report.JUnit()
+	}
+	return report.Document()
+}
+
+// multiClusterDocument returns the document a structured format prints for
+// a multi-cluster report
+func multiClusterDocument(format output.Format, merged *diagnose.MultiClusterReport) interface{} {
+	switch format {
+	case output.FormatSARIF:
+		return merged.SARIF()
+	case output.FormatJUnit:
+		return merged.JUnit()
+	}
+	return merged.Document()
+}
+
+// scoreWeights returns the health score weights from the config file
+func scoreWeights() diagnose.ScoreWeights {
+	scoring := config.Get().Scoring
+	configured := diagnose.ScoreWeights{
+		Severity:   map[diagnose.Severity]float64{},
+		Categories: scoring.Categories,
+		Namespaces: scoring.Namespaces,
+		Labels:     scoring.Labels,
+	}
+	for severity, weight := range scoring.Severity {
+		configured.Severity[diagnose.Severity(strings.ToLower(severity))] = weight
+	}
+	return diagnose.DefaultScoreWeights().Merge(configured)
+}
+
+// runDiagnostics dispatches to the engine based on the positional arguments
+func runDiagnostics(engine *diagnose.Engine, args []string) (*diagnose.Report, error) {
+	if len(args) >= 2 {
+		// Diagnose specific resource
+		return engine.DiagnoseResource(args[0], args[1])
+	} else if len(args) == 1 {
+		// Diagnose resource type
+		return engine.DiagnoseResourceType(args[0])
+	}
+
+	// Diagnose entire namespace/cluster
+	return engine.DiagnoseCluster()
+}
+
+// dumpEngine creates an engine that diagnoses a dump instead of the cluster
+func dumpEngine(path string) (*diagnose.Engine, error) {
+	dump, err := k8s.LoadDump(path)
+	if err != nil {
+		return nil, err
+	}
+	return diagnose.NewEngineFromDump(dump, namespace, allNamespaces)
+}
+
+// watchDiagnostics diagnoses again whenever pods, events or nodes change and
+// prints the issues that appeared, escalated or were resolved, until
+// interrupted
+func watchDiagnostics(engine *diagnose.Engine, args []string, format output.Format) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if format == output.FormatText {
+		fmt.Fprintf(output.Stdout, "👀 Watching for issue changes (debounce %s, Ctrl-C to stop)...\n\n", debounce)
+	}
+	first := true
+	return engine.Watch(ctx, diagnose.WatchOptions{
+		Diagnose: func() (*diagnose.Report, error) { return runDiagnostics(engine, args) },
+		Debounce: debounce,
+		OnChanges: func(changes []diagnose.IssueChange) {
+			for _, change := range changes {
+				if format == output.FormatText {
+					change.Display()
+					continue
+				}
+				write := writeNextDocument
+				if first {
+					write, first = writeDocument, false
+				}
+				if err := write(format, change.Document(), nil); err != nil {
+					logger.Error("Failed to write change: %v", err)
+				}
+			}
+		},
+		OnError: func(err error) {
+			logger.Warn("Diagnostics failed: %v", err)
+		},
+	})
+}
+
+// displayAPIUsage prints the snapshot size and the API calls it took
+func displayAPIUsage(engine *diagnose.Engine) {
+	fmt.Fprintln(output.Stdout, "\n📊 API Usage:")
+	fmt.Fprintln(output.Stdout, "─────────────")
+	if snapshot := engine.Snapshot(); snapshot != nil {
+		fmt.Fprintf(output.Stdout, "  Snapshot: %s (%s)\n", snapshot.Summary(), snapshot.Duration.Round(time.Millisecond))
+	}
+	engine.APIMetrics().Display()
+}
+
 func init() {
 	rootCmd.AddCommand(diagnoseCmd)
 	diagnoseCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "diagnose across all namespaces")
+	addFanOutFlags(diagnoseCmd)
+	addOutputFlag(diagnoseCmd, diagnoseFormats...)
+	diagnoseCmd.Flags().BoolVarP(&watch, "watch", "w", false, "keep watching and print issues as they appear, escalate or resolve")
+	diagnoseCmd.Flags().DurationVar(&debounce, "debounce", 15*time.Second, "how long a change must last before --watch prints it")
+	diagnoseCmd.Flags().StringVar(&fromDump, "from-dump", "", "diagnose a dump directory or .tar.gz instead of the cluster")
+	diagnoseCmd.Flags().StringVar(&failOn, "fail-on", "", "exit with code 2 when issues at or above this severity are found (critical|high|medium|low)")
 }
@@ -0,0 +1,95 @@
+package pilot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	dumpFile     string
+	dumpLogLines int64
+)
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Capture cluster state into a bundle for offline diagnosis",
+	Long: `Capture the resources diagnostics read (pods, events, nodes, workloads,
+storage, services, endpoints, ingresses, network policies and the secrets
+they reference) and the recent logs of containers that restarted or are not
+ready, into a gzipped tarball. Secrets keep their metadata only: their data
+and the last-applied-configuration annotation are dropped.
+
+The bundle can be diagnosed anywhere, without access to the cluster:
+
+  kubectl-pilot diagnose --from-dump k8s-pilot-dump-20240501-120000.tar.gz
+
+Examples:
+  kubectl-pilot dump -n payments
+  kubectl-pilot dump -A -f incident-4211.tar.gz
+  kubectl-pilot dump -n payments --log-lines 0`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := k8s.NewClientWithOptions(namespace, kubeOptions())
+		if err != nil {
+			return fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		dumpNamespace := client.Namespace()
+		if allNamespaces {
+			dumpNamespace = metav1.NamespaceAll
+		}
+
+		path := dumpFile
+		if path == "" {
+			path = fmt.Sprintf("k8s-pilot-dump-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create dump: %w", err)
+		}
+
+		metadata, err := client.WriteDump(context.Background(), dumpNamespace, f, k8s.DumpOptions{LogLines: dumpLogLines})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return fmt.Errorf("failed to dump cluster state: %w", err)
+		}
+
+		scope := "all namespaces"
+		if dumpNamespace != metav1.NamespaceAll {
+			scope = "namespace " + dumpNamespace
+		}
+		fmt.Fprintf(output.Stdout, "📦 Dumped %s to %s (%d resource types, %d container logs)\n",
+			scope, path, len(metadata.Resources), metadata.Logs)
+		if len(metadata.Errors) > 0 {
+			fmt.Fprintln(output.Stdout, "\n⚠️  Not captured:")
+			resources := make([]string, 0, len(metadata.Errors))
+			for resource := range metadata.Errors {
+				resources = append(resources, resource)
+			}
+			sort.Strings(resources)
+			for _, resource := range resources {
+				fmt.Fprintf(output.Stdout, "  • %s: %s\n", resource, metadata.Errors[resource])
+			}
+		}
+		fmt.Fprintf(output.Stdout, "\n💡 Diagnose it with: kubectl-pilot diagnose --from-dump %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "dump every namespace")
+	dumpCmd.Flags().StringVarP(&dumpFile, "file", "f", "", "bundle to write (default k8s-pilot-dump-<timestamp>.tar.gz)")
+	dumpCmd.Flags().Int64Var(&dumpLogLines, "log-lines", 200, "log lines kept per container; 0 skips logs")
+}
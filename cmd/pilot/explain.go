@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"k8s-pilot/pkg/explain"
+	"k8s-pilot/pkg/output"
 )
 
 var explainCmd = &cobra.Command{
@@ -19,9 +20,14 @@ Examples:
   kubectl-pilot explain logs mypod
   kubectl-pilot explain events in payments namespace
   kubectl-pilot explain "why is my pod pending"
-  kubectl-pilot explain deployment myapp`,
+  kubectl-pilot explain deployment myapp
+  kubectl-pilot explain logs mypod -o json`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := selectedFormat(documentFormats...)
+		if err != nil {
+			return err
+		}
 		query := strings.Join(args, " ")
 		
 		explainer := explain.NewExplainer(namespace)
@@ -32,23 +38,27 @@ Examples:
 			return fmt.Errorf("failed to generate explanation: %w", err)
 		}
 		
+		if format != output.FormatText {
+			return writeDocument(format, explanation.Document(), explanation.Markdown)
+		}
+		
 		// Display explanation
-		fmt.Println("\n📚 Explanation:")
-		fmt.Println("═══════════════")
+		fmt.Fprintln(output.Stdout, "\n📚 Explanation:")
+		fmt.Fprintln(output.Stdout, "═══════════════")
 		explanation.Display()
 		
 		// Show related commands if any
 		if len(explanation.RelatedCommands) > 0 {
-			fmt.Println("\n🔧 Related kubectl Commands:")
-			fmt.Println("────────────────────────────")
+			fmt.Fprintln(output.Stdout, "\n🔧 Related kubectl Commands:")
+			fmt.Fprintln(output.Stdout, "────────────────────────────")
 			for _, cmd := range explanation.RelatedCommands {
-				fmt.Printf("  • %s\n", cmd)
+				fmt.Fprintf(output.Stdout, "  • %s\n", cmd)
 			}
 		}
 		
 		// Show educational tips
 		if explanation.Tip != "" {
-			fmt.Printf("\n💡 Tip: %s\n", explanation.Tip)
+			fmt.Fprintf(output.Stdout, "\n💡 Tip: %s\n", explanation.Tip)
 		}
 		
 		return nil
@@ -57,4 +67,5 @@ Examples:
 
 func init() {
 	rootCmd.AddCommand(explainCmd)
+	addOutputFlag(explainCmd, documentFormats...)
 }
@@ -0,0 +1,141 @@
+package pilot
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+	"k8s-pilot/pkg/plan"
+)
+
+var (
+	contextList []string
+	allContexts bool
+	maxParallel int
+)
+
+// addFanOutFlags registers the multi-cluster flags on a command
+func addFanOutFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&contextList, "contexts", nil, "comma-separated kubeconfig contexts to run against concurrently")
+	cmd.Flags().BoolVar(&allContexts, "all-contexts", false, "run against every context in the kubeconfig")
+	cmd.Flags().IntVar(&maxParallel, "max-parallel", 4, "maximum number of clusters to query at once")
+	cmd.MarkFlagsMutuallyExclusive("contexts", "all-contexts")
+}
+
+// selectedContexts returns the contexts requested with --contexts or
+// --all-contexts, or nil for a single-cluster run
+func selectedContexts() ([]string, error) {
+	if allContexts {
+		contexts, err := k8s.Contexts(kubeOptions())
+		if err != nil {
+			return nil, err
+		}
+		if len(contexts) == 0 {
+			return nil, fmt.Errorf("no contexts found in kubeconfig")
+		}
+		return contexts, nil
+	}
+	return contextList, nil
+}
+
+// contextPlanResult is the outcome of a read-only plan on one cluster
+type contextPlanResult struct {
+	plan   *plan.Plan
+	result *plan.Result
+}
+
+// runPlanOnContexts executes a read-only plan against several clusters
+// concurrently and prints the results grouped by cluster
+func runPlanOnContexts(executionPlan *plan.Plan, contexts []string, format output.Format) error {
+	if !executionPlan.IsReadOnly() {
+		return fmt.Errorf("multi-cluster run only supports read-only plans; target a single --context to make changes")
+	}
+
+	text := format == output.FormatText
+	if !text {
+		if err := writeDocument(format, executionPlan.Document(), executionPlan.Markdown); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(output.Stdout, "\n📋 Execution Plan:")
+		fmt.Fprintln(output.Stdout, "─────────────────")
+		executionPlan.Display()
+	}
+
+	results := k8s.FanOut(contexts, maxParallel, func(name string) (*contextPlanResult, error) {
+		ctx := context.Background()
+
+		client, err := k8s.NewClientWithOptions(namespace, kubeOptions().WithContext(name))
+		if err != nil {
+			return nil, err
+		}
+
+		clusterPlan := executionPlan.ForContext(name)
+		target := client.Target()
+		clusterPlan.Target = &target
+		// Read-only commands are safe to run without --apply
+		clusterPlan.SetDryRun(false)
+
+		if err := plan.NewPlanner(client.Namespace(), false).CheckPermissions(ctx, clusterPlan, client); err != nil {
+			return nil, fmt.Errorf("permission check failed: %w", err)
+		}
+		if denied := clusterPlan.DeniedCommands(); len(denied) > 0 {
+			return &contextPlanResult{plan: clusterPlan}, fmt.Errorf("%d step(s) not permitted", len(denied))
+		}
+
+		result, err := clusterPlan.Execute()
+		auditPlan(clusterPlan, result, err)
+		return &contextPlanResult{plan: clusterPlan, result: result}, err
+	})
+
+	if !text {
+		return writeContextResults(format, results)
+	}
+
+	failed := 0
+	for _, r := range results {
+		fmt.Fprintf(output.Stdout, "\n☸️  Cluster: %s\n", r.Context)
+		fmt.Fprintln(output.Stdout, "────────────────────────────")
+		if r.Value != nil && r.Value.plan.Target != nil {
+			fmt.Fprintf(output.Stdout, "🎯 Target: %s\n", r.Value.plan.Target)
+		}
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(output.Stdout, "❌ Failed: %v\n", r.Err)
+			continue
+		}
+		r.Value.result.Display()
+	}
+
+	fmt.Fprintf(output.Stdout, "\n✓ Ran on %d cluster(s), %d failed\n", len(results), failed)
+	return nil
+}
+
+// writeContextResults prints each cluster's result as a document, with the
+// context it ran against and why it failed
+func writeContextResults(format output.Format, results []k8s.ContextResult[*contextPlanResult]) error {
+	for _, r := range results {
+		result := &plan.Result{}
+		if r.Value != nil && r.Value.result != nil {
+			result.ExecutedCommands = r.Value.result.ExecutedCommands
+			result.Errors = append(result.Errors, r.Value.result.Errors...)
+		}
+		if r.Err != nil {
+			result.Errors = append(result.Errors, r.Err.Error())
+		}
+
+		doc := result.Document()
+		doc.Context = r.Context
+		markdown := func(w io.Writer) {
+			fmt.Fprintf(w, "\n## Cluster: %s\n", r.Context)
+			result.Markdown(w)
+		}
+		if err := writeNextDocument(format, doc, markdown); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package pilot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s-pilot/pkg/history"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+)
+
+var (
+	historyStatus string
+	historySince  string
+	historyUntil  string
+	historyLimit  int
+
+	allowContextMismatch bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the history of generated and executed plans",
+	Long: `Every plan generated by run or runbook run is recorded locally together with
+its query, results, user, cluster, timestamps and undo commands.
+
+Examples:
+  kubectl-pilot history list -n payments --status applied --since 24h
+  kubectl-pilot history show 42
+  kubectl-pilot history rerun 42 --apply`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded plans",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilter()
+		if err != nil {
+			return err
+		}
+
+		store, err := openHistory()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		records, err := store.List(filter)
+		if err != nil {
+			return fmt.Errorf("failed to list history: %w", err)
+		}
+
+		if len(records) == 0 {
+			fmt.Fprintln(output.Stdout, "No history records found.")
+			return nil
+		}
+
+		fmt.Fprintln(output.Stdout, "\n🕘 Plan History:")
+		fmt.Fprintln(output.Stdout, "════════════════")
+		for _, r := range records {
+			fmt.Fprintf(output.Stdout, "\n#%d  %s  [%s]  %s/%s\n", r.ID, r.CreatedAt.Local().Format("2006-01-02 15:04:05"), r.Status, orNone(r.Cluster), r.Namespace)
+			fmt.Fprintf(output.Stdout, "    %s (by %s)\n", r.Query, r.User)
+		}
+
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show a recorded plan and its results",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		record, err := loadHistoryRecord(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(output.Stdout, "\n🕘 History #%d\n", record.ID)
+		fmt.Fprintln(output.Stdout, "═══════════════")
+		fmt.Fprintf(output.Stdout, "Query:     %s\n", record.Query)
+		fmt.Fprintf(output.Stdout, "Source:    %s\n", record.Source)
+		fmt.Fprintf(output.Stdout, "Status:    %s\n", record.Status)
+		fmt.Fprintf(output.Stdout, "User:      %s\n", record.User)
+		fmt.Fprintf(output.Stdout, "Context:   %s\n", orNone(record.Context))
+		fmt.Fprintf(output.Stdout, "Cluster:   %s\n", orNone(record.Cluster))
+		fmt.Fprintf(output.Stdout, "Namespace: %s\n", record.Namespace)
+		fmt.Fprintf(output.Stdout, "Created:   %s\n", record.CreatedAt.Local().Format(time.RFC3339))
+		fmt.Fprintf(output.Stdout, "Updated:   %s\n", record.UpdatedAt.Local().Format(time.RFC3339))
+
+		if record.Plan != nil {
+			record.Plan.Display()
+		}
+
+		if record.Result != nil {
+			record.Result.Display()
+		}
+
+		if len(record.Undo) > 0 {
+			fmt.Fprintln(output.Stdout, "\n↩️  Undo commands:")
+			for i, undo := range record.Undo {
+				fmt.Fprintf(output.Stdout, "%d. %s\n", i+1, undo)
+			}
+		}
+
+		return nil
+	},
+}
+
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun [id]",
+	Short: "Run a recorded plan again",
+	Long: `Run a recorded plan again against the context it was recorded for. Rerunning
+it against a different context or cluster requires --allow-context-mismatch.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		record, err := loadHistoryRecord(args[0])
+		if err != nil {
+			return err
+		}
+
+		if record.Plan == nil {
+			return fmt.Errorf("history record %d has no plan", record.ID)
+		}
+
+		// Replay the stored plan rather than regenerating it, honouring the
+		// current dry-run/apply flags
+		executionPlan := record.Plan
+		executionPlan.SetDryRun(dryRun)
+		executionPlan.Target = nil
+		if namespace == "" {
+			namespace = record.Namespace
+		}
+
+		opts, err := rerunOptions(record, kubeOptions(), cmd.Flags().Changed("context"))
+		if err != nil {
+			return err
+		}
+		k8s.SetDefaultOptions(opts)
+		if err := checkRerunCluster(record); err != nil {
+			return err
+		}
+
+		return processPlan(executionPlan, output.FormatText)
+	},
+}
+
+// rerunOptions targets the context a record was planned against. A different
+// --context is refused unless the mismatch is confirmed.
+func rerunOptions(record *history.Record, opts k8s.Options, overridden bool) (k8s.Options, error) {
+	if record.Context == "" {
+		return opts, nil
+	}
+	if !overridden {
+		opts.Context = record.Context
+		return opts, nil
+	}
+	if opts.Context != record.Context && !allowContextMismatch {
+		return opts, fmt.Errorf("history record %d was planned against context %q, not %q; pass --allow-context-mismatch to rerun it there",
+			record.ID, record.Context, opts.Context)
+	}
+	return opts, nil
+}
+
+// checkRerunCluster refuses to rerun a record against a different cluster
+// than it was planned for, such as a context that now points elsewhere
+func checkRerunCluster(record *history.Record) error {
+	if record.Cluster == "" || allowContextMismatch {
+		return nil
+	}
+	client, err := k8s.NewClient(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the cluster: %w", err)
+	}
+	if cluster := client.Target().Cluster; cluster != record.Cluster {
+		return fmt.Errorf("history record %d was planned against cluster %q, not %q; pass --allow-context-mismatch to rerun it there",
+			record.ID, record.Cluster, cluster)
+	}
+	return nil
+}
+
+// orNone returns value, or "<none>" when it is empty
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+// loadHistoryRecord loads a record by its string ID
+func loadHistoryRecord(arg string) (*history.Record, error) {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid history id %q", arg)
+	}
+
+	store, err := openHistory()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return store.Get(id)
+}
+
+// historyFilter builds a history filter from the command-line flags
+func historyFilter() (history.Filter, error) {
+	filter := history.Filter{
+		Namespace: namespace,
+		Status:    history.Status(historyStatus),
+		Limit:     historyLimit,
+	}
+
+	var err error
+	if filter.Since, err = parseHistoryTime(historySince); err != nil {
+		return filter, fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseHistoryTime(historyUntil); err != nil {
+		return filter, fmt.Errorf("invalid --until: %w", err)
+	}
+
+	return filter, nil
+}
+
+// parseHistoryTime accepts a relative duration (24h), a date (2006-01-02)
+// or an RFC 3339 timestamp
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyRerunCmd)
+	historyListCmd.Flags().StringVar(&historyStatus, "status", "", "filter by status (planned, blocked, applied, failed)")
+	historyListCmd.Flags().StringVar(&historySince, "since", "", "only show plans after this time (e.g. 24h, 2024-01-31)")
+	historyListCmd.Flags().StringVar(&historyUntil, "until", "", "only show plans before this time")
+	historyListCmd.Flags().IntVar(&historyLimit, "limit", 20, "maximum number of records to show")
+	historyRerunCmd.Flags().BoolVar(&applyChanges, "apply", false, "apply the replayed plan (disables dry-run)")
+	historyRerunCmd.Flags().BoolVar(&allowContextMismatch, "allow-context-mismatch", false, "rerun against a different context or cluster than the plan was recorded for")
+}
@@ -0,0 +1,57 @@
+package pilot
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s-pilot/internal/logger"
+	"k8s-pilot/pkg/output"
+)
+
+var outputFormat string
+
+// documentFormats are the formats of commands that print a report, plan or
+// explanation
+var documentFormats = []output.Format{output.FormatText, output.FormatJSON, output.FormatYAML, output.FormatMarkdown}
+
+// addOutputFlag registers -o/--output with the formats a command supports
+func addOutputFlag(cmd *cobra.Command, formats ...output.Format) {
+	names := make([]string, len(formats))
+	for i, format := range formats {
+		names[i] = string(format)
+	}
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", string(output.FormatText), "output format: "+strings.Join(names, "|"))
+}
+
+// selectedFormat parses --output. Anything but text moves log messages to
+// stderr so standard output can be piped into other tools.
+func selectedFormat(formats ...output.Format) (output.Format, error) {
+	format, err := output.ParseFormat(outputFormat, formats...)
+	if err != nil {
+		return "", err
+	}
+	if format != output.FormatText {
+		logger.SetOutput(os.Stderr)
+	}
+	return format, nil
+}
+
+// writeNextDocument prints a document following another one: JSON documents
+// form a stream and YAML documents are separated by "---"
+func writeNextDocument(format output.Format, document interface{}, markdown func(io.Writer)) error {
+	if format == output.FormatYAML {
+		os.Stdout.WriteString("---\n")
+	}
+	return writeDocument(format, document, markdown)
+}
+
+// writeDocument prints a document as markdown, JSON or YAML
+func writeDocument(format output.Format, document interface{}, markdown func(io.Writer)) error {
+	if format == output.FormatMarkdown {
+		markdown(os.Stdout)
+		return nil
+	}
+	return output.Encode(os.Stdout, format, document)
+}
@@ -0,0 +1,232 @@
+package pilot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+
+	"k8s-pilot/internal/config"
+	"k8s-pilot/internal/logger"
+	"k8s-pilot/pkg/history"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+	"k8s-pilot/pkg/plan"
+	"k8s-pilot/pkg/policy"
+)
+
+// processPlan sends a plan through policy validation, display and, when
+// --apply is set, audited execution. AI-generated plans and runbooks share
+// this pipeline. Structured formats print the plan, then the result when it
+// is applied, as documents.
+func processPlan(executionPlan *plan.Plan, format output.Format) error {
+	ctx := context.Background()
+
+	client, err := k8s.NewClient(namespace)
+	if err != nil {
+		logger.Warn("Cluster unavailable, skipping permission checks: %v", err)
+	} else {
+		target := client.Target()
+		executionPlan.Target = &target
+		checkPermissions(ctx, executionPlan, client)
+	}
+
+	allowed, err := validatePlan(ctx, executionPlan)
+	if err != nil {
+		return err
+	}
+
+	record := recordPlan(executionPlan, allowed)
+
+	text := format == output.FormatText
+	if !text {
+		if err := writeDocument(format, executionPlan.Document(), executionPlan.Markdown); err != nil {
+			return err
+		}
+	} else {
+		// Display the plan
+		fmt.Fprintln(output.Stdout, "\n📋 Execution Plan:")
+		fmt.Fprintln(output.Stdout, "─────────────────")
+		executionPlan.Display()
+	}
+
+	if dryRun && !applyChanges {
+		if text {
+			fmt.Fprintln(output.Stdout, "\n✓ Dry-run complete. Use --apply to execute the plan.")
+		}
+		return nil
+	}
+
+	if !applyChanges {
+		if text {
+			fmt.Fprintln(output.Stdout, "\n✓ Preview complete. Use --apply to execute.")
+		}
+		return nil
+	}
+
+	if !allowed {
+		return fmt.Errorf("plan blocked by policy violations")
+	}
+
+	if denied := executionPlan.DeniedCommands(); len(denied) > 0 {
+		return fmt.Errorf("plan contains %d step(s) you are not permitted to run; see the suggested alternatives", len(denied))
+	}
+
+	// Execute the plan
+	if text {
+		fmt.Fprintln(output.Stdout, "\n⚡ Executing plan...")
+	}
+	result, err := executionPlan.Execute()
+	auditPlan(executionPlan, result, err)
+	recordResult(record, result, err)
+	if err != nil {
+		return fmt.Errorf("execution failed: %w", err)
+	}
+
+	if !text {
+		return writeNextDocument(format, result.Document(), result.Markdown)
+	}
+	fmt.Fprintln(output.Stdout, "\n✅ Execution complete:")
+	result.Display()
+
+	return nil
+}
+
+// checkPermissions annotates steps the current user is not allowed to run.
+// When access can't be reviewed the check is skipped with a warning.
+func checkPermissions(ctx context.Context, executionPlan *plan.Plan, client *k8s.Client) {
+	planner := plan.NewPlanner(client.Namespace(), dryRun)
+	if err := planner.CheckPermissions(ctx, executionPlan, client); err != nil {
+		logger.Warn("Skipping permission checks: %v", err)
+		executionPlan.AddWarning(plan.PermissionsUnverifiedWarning)
+	}
+}
+
+// validatePlan checks every command against policy, adding warnings and
+// violations to the plan. It reports whether the plan may be applied.
+func validatePlan(ctx context.Context, executionPlan *plan.Plan) (bool, error) {
+	validator := policy.NewValidator(config.Get().Policy.Enabled)
+
+	allowed := true
+	for _, cmd := range executionPlan.AllCommands() {
+		result, err := validator.ValidateCommand(ctx, cmd.Command)
+		if err != nil {
+			return false, fmt.Errorf("policy validation failed: %w", err)
+		}
+
+		for _, warning := range result.Warnings {
+			executionPlan.AddWarning(fmt.Sprintf("%s (%s)", warning, cmd.Command))
+		}
+		for _, violation := range result.Violations {
+			executionPlan.AddWarning(fmt.Sprintf("Policy %s violated: %s (%s)", violation.Policy, violation.Message, cmd.Command))
+		}
+		if !result.Allowed {
+			allowed = false
+		}
+	}
+
+	return allowed, nil
+}
+
+// recordPlan stores a newly generated plan in the history database. History
+// is best effort: failures are logged and never block the plan.
+func recordPlan(executionPlan *plan.Plan, allowed bool) *history.Record {
+	cfg := config.Get()
+	if cfg.History.Disabled {
+		return nil
+	}
+
+	status := history.StatusPlanned
+	if !allowed {
+		status = history.StatusBlocked
+	}
+
+	ns := namespace
+	if ns == "" {
+		ns = "default"
+	}
+
+	record := &history.Record{
+		Query:     executionPlan.Query,
+		Source:    executionPlan.Source,
+		User:      currentUser(),
+		Namespace: ns,
+		Status:    status,
+		Plan:      executionPlan,
+		Undo:      executionPlan.UndoCommands(),
+	}
+	if target := executionPlan.Target; target != nil {
+		record.Context = target.Context
+		record.Cluster = target.Cluster
+		record.Namespace = target.Namespace
+	}
+
+	if err := saveRecord(record); err != nil {
+		logger.Warn("Failed to record plan history: %v", err)
+		return nil
+	}
+	logger.Debug("Recorded plan as history entry %d", record.ID)
+	return record
+}
+
+// recordResult updates a history record with the outcome of execution
+func recordResult(record *history.Record, result *plan.Result, execErr error) {
+	if record == nil {
+		return
+	}
+
+	record.Result = result
+	record.Status = history.StatusApplied
+	if execErr != nil || (result != nil && len(result.Errors) > 0) {
+		record.Status = history.StatusFailed
+	}
+
+	if err := saveRecord(record); err != nil {
+		logger.Warn("Failed to record plan result: %v", err)
+	}
+}
+
+// saveRecord opens the history store just long enough to save a record
+func saveRecord(record *history.Record) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Save(record)
+}
+
+// openHistory opens the configured history store
+func openHistory() (*history.Store, error) {
+	return history.Open(config.Get().HistoryPath(history.DefaultPath()))
+}
+
+// auditPlan writes an audit record for each executed command
+func auditPlan(executionPlan *plan.Plan, result *plan.Result, execErr error) {
+	action := "run"
+	if executionPlan.Source != "" {
+		action = executionPlan.Source
+	}
+
+	username := currentUser()
+	if result == nil {
+		logger.Audit(action, username, executionPlan.Summary, false)
+		return
+	}
+
+	for _, cmd := range result.ExecutedCommands {
+		logger.Audit(action, username, cmd, execErr == nil)
+	}
+}
+
+// currentUser returns the local user name for audit records
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
@@ -3,6 +3,7 @@ package pilot
 import (
 	"fmt"
 
+	"k8s-pilot/pkg/output"
 	"k8s-pilot/pkg/plugins"
 
 	"github.com/spf13/cobra"
@@ -23,15 +24,15 @@ var pluginListCmd = &cobra.Command{
 		pluginList := manager.List()
 
 		if len(pluginList) == 0 {
-			fmt.Println("No plugins installed.")
+			fmt.Fprintln(output.Stdout, "No plugins installed.")
 			return nil
 		}
 
-		fmt.Println("\n🔌 Installed Plugins:")
-		fmt.Println("═══════════════════")
+		fmt.Fprintln(output.Stdout, "\n🔌 Installed Plugins:")
+		fmt.Fprintln(output.Stdout, "═══════════════════")
 		for _, p := range pluginList {
-			fmt.Printf("\n• %s (v%s)\n", p.Name(), p.Version())
-			fmt.Printf("  %s\n", p.Description())
+			fmt.Fprintf(output.Stdout, "\n• %s (v%s)\n", p.Name(), p.Version())
+			fmt.Fprintf(output.Stdout, "  %s\n", p.Description())
 		}
 
 		return nil
@@ -45,13 +46,13 @@ var pluginInstallCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		manager := plugins.NewManager()
 
-		fmt.Printf("Installing plugin: %s...\n", args[0])
+		fmt.Fprintf(output.Stdout, "Installing plugin: %s...\n", args[0])
 
 		if err := manager.InstallByName(args[0]); err != nil {
 			return fmt.Errorf("failed to install plugin: %w", err)
 		}
 
-		fmt.Println("✅ Plugin installed successfully!")
+		fmt.Fprintln(output.Stdout, "✅ Plugin installed successfully!")
 		return nil
 	},
 }
@@ -67,7 +68,7 @@ var pluginUninstallCmd = &cobra.Command{
 			return fmt.Errorf("failed to uninstall plugin: %w", err)
 		}
 
-		fmt.Println("✅ Plugin uninstalled successfully!")
+		fmt.Fprintln(output.Stdout, "✅ Plugin uninstalled successfully!")
 		return nil
 	},
 }
@@ -1,19 +1,25 @@
 package pilot
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"k8s-pilot/internal/config"
 	"k8s-pilot/internal/logger"
+	"k8s-pilot/pkg/k8s"
 )
 
 var (
-	cfgFile   string
-	dryRun    bool
-	verbose   bool
-	namespace string
+	cfgFile    string
+	dryRun     bool
+	verbose    bool
+	namespace  string
+	kubeconfig string
+	kubeCtx    string
+	asUser     string
+	asGroups   []string
 )
 
 var rootCmd = &cobra.Command{
@@ -35,6 +41,7 @@ Features:
 		if cfgFile != "" {
 			config.Load(cfgFile)
 		}
+		k8s.SetDefaultOptions(kubeOptions())
 	},
 }
 
@@ -42,13 +49,37 @@ Features:
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var findings *findingsError
+		if errors.As(err, &findings) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
 
+// kubeOptions builds Kubernetes client options from the global flags,
+// falling back to the context set in the config file
+func kubeOptions() k8s.Options {
+	context := kubeCtx
+	if context == "" {
+		context = config.Get().Kube.Context
+	}
+	
+	return k8s.Options{
+		Kubeconfig:        kubeconfig,
+		Context:           context,
+		Impersonate:       asUser,
+		ImpersonateGroups: asGroups,
+	}
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.k8s-pilot.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", true, "preview changes without applying them")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "kubernetes namespace")
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "path to the kubeconfig file (default uses KUBECONFIG or ~/.kube/config)")
+	rootCmd.PersistentFlags().StringVar(&kubeCtx, "context", "", "kubeconfig context to use")
+	rootCmd.PersistentFlags().StringVar(&asUser, "as", "", "username to impersonate for the operation")
+	rootCmd.PersistentFlags().StringArrayVar(&asGroups, "as-group", nil, "group to impersonate for the operation (repeatable)")
 }
@@ -0,0 +1,162 @@
+package pilot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s-pilot/internal/config"
+	"k8s-pilot/pkg/output"
+	"k8s-pilot/pkg/rules"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List and test custom diagnostic rules",
+	Long: `Custom rules are YAML checks loaded from the rules directory in the config
+file (default ~/.k8s-pilot/rules) and evaluated by "diagnose" alongside the
+built-in checks. A rule matches objects of one kind with a JSONPath
+expression evaluated over the object, with its events under .events; each
+value it selects reports an issue with the rule's severity, type, templated
+message and remediation commands.
+
+  name: frequent-restarts
+  match:
+    kind: Pod
+    jsonpath: .status.containerStatuses[?(@.restartCount > 5)].name
+  severity: medium
+  type: FrequentRestarts
+  message: Container {{ .Value }} of pod {{ .Name }} restarted more than five times
+  remediations:
+    - title: Read the previous logs of {{ .Value }}
+      command: kubectl logs {{ .Name }} -n {{ .Namespace }} -c {{ .Value }} --previous
+      safe: true
+  tests:
+    - fixture: fixtures/crashing-pod.yaml
+      expect: [api-7d9f8-x2k4p]
+
+Templates can use .Name, .Namespace, .Kind, .Value and .Object (the full
+object). Test fixtures are resolved relative to the rule file.
+
+Examples:
+  kubectl-pilot rules list
+  kubectl-pilot rules test
+  kubectl-pilot rules test pods.yaml events.yaml`,
+}
+
+var rulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List custom rules",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ruleSet, err := loadRules()
+		if err != nil {
+			return err
+		}
+
+		list := ruleSet.List()
+		if len(list) == 0 {
+			fmt.Fprintf(output.Stdout, "No rules found in %s\n", config.Get().RulesDir())
+			return nil
+		}
+
+		fmt.Fprintln(output.Stdout, "\n📏 Rules:")
+		fmt.Fprintln(output.Stdout, "═════════")
+		for _, rule := range list {
+			fmt.Fprintf(output.Stdout, "\n• %s [%s] %s on %s\n", rule.Name, rule.Severity, rule.Type, rule.Match.Kind)
+			if rule.Description != "" {
+				fmt.Fprintf(output.Stdout, "  %s\n", rule.Description)
+			}
+		}
+
+		return nil
+	},
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test [fixture-files...]",
+	Short: "Run the rules' tests, or evaluate the rules against fixture files",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ruleSet, err := loadRules()
+		if err != nil {
+			return err
+		}
+		if len(ruleSet.List()) == 0 {
+			fmt.Fprintf(output.Stdout, "No rules found in %s\n", config.Get().RulesDir())
+			return nil
+		}
+
+		if len(args) > 0 {
+			return evaluateFixtures(ruleSet, args)
+		}
+
+		failed, total := 0, 0
+		fmt.Fprintln(output.Stdout, "\n🧪 Rule Tests:")
+		fmt.Fprintln(output.Stdout, "══════════════")
+		for _, rule := range ruleSet.List() {
+			for _, result := range rule.RunTests() {
+				total++
+				if result.Passed() {
+					fmt.Fprintf(output.Stdout, "✅ PASS %s (%s)\n", rule.Name, result.Fixture)
+					continue
+				}
+				failed++
+				fmt.Fprintf(output.Stdout, "❌ FAIL %s (%s)\n", rule.Name, result.Fixture)
+				if result.Err != nil {
+					fmt.Fprintf(output.Stdout, "     error: %v\n", result.Err)
+				} else {
+					fmt.Fprintf(output.Stdout, "     expected: [%s]\n", strings.Join(result.Expected, ", "))
+					fmt.Fprintf(output.Stdout, "     matched:  [%s]\n", strings.Join(result.Matched, ", "))
+				}
+			}
+		}
+
+		fmt.Fprintf(output.Stdout, "\n%d/%d test(s) passed\n", total-failed, total)
+		if failed > 0 {
+			return fmt.Errorf("%d rule test(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// evaluateFixtures prints the findings of all rules on the given files
+func evaluateFixtures(ruleSet *rules.Set, paths []string) error {
+	objects, err := rules.LoadFixtures(paths...)
+	if err != nil {
+		return fmt.Errorf("failed to load fixtures: %w", err)
+	}
+
+	findings, err := rules.EvaluateObjects(ruleSet.List(), objects)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(output.Stdout, "\n📏 %d finding(s) on %d object(s):\n", len(findings), len(objects))
+	fmt.Fprintln(output.Stdout, "═════════════════════════════")
+	for _, finding := range findings {
+		resource := fmt.Sprintf("%s/%s", strings.ToLower(finding.Kind), finding.Name)
+		if finding.Namespace != "" {
+			resource += " -n " + finding.Namespace
+		}
+		fmt.Fprintf(output.Stdout, "\n[%s] %s: %s (rule %s)\n", strings.ToUpper(finding.Rule.Severity), finding.Rule.Type, resource, finding.Rule.Name)
+		fmt.Fprintf(output.Stdout, "  %s\n", finding.Message)
+		for _, remediation := range finding.Remediations {
+			fmt.Fprintf(output.Stdout, "  → %s\n", remediation.Command)
+		}
+	}
+	return nil
+}
+
+// loadRules loads the custom rules from the configured directory
+func loadRules() (*rules.Set, error) {
+	ruleSet, err := rules.LoadDir(config.Get().RulesDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules: %w", err)
+	}
+	return ruleSet, nil
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+	rulesCmd.AddCommand(rulesListCmd)
+	rulesCmd.AddCommand(rulesTestCmd)
+}
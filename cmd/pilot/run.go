@@ -1,11 +1,17 @@
 package pilot
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s-pilot/internal/config"
+	"k8s-pilot/internal/logger"
+	"k8s-pilot/pkg/k8s"
 	"k8s-pilot/pkg/plan"
+	"k8s-pilot/pkg/runbook"
 )
 
 var (
@@ -21,49 +27,66 @@ By default, runs in dry-run mode. Use --apply to execute the plan.
 Examples:
   kubectl-pilot run "restart failing pods in payments namespace"
   kubectl-pilot run "scale deployment api to 5 replicas" --apply
-  kubectl-pilot run "list pods with high memory usage"`,
+  kubectl-pilot run "list pods with high memory usage"
+  kubectl-pilot run "list pods in CrashLoopBackOff" --all-contexts
+  kubectl-pilot run "restart the api deployment" -o json`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := selectedFormat(documentFormats...)
+		if err != nil {
+			return err
+		}
 		query := strings.Join(args, " ")
-		
+
 		planner := plan.NewPlanner(namespace, dryRun)
+
+		// Suggest a matching runbook when one is available
+		if catalog, err := runbook.LoadDir(config.Get().RunbookDir()); err != nil {
+			logger.Warn("Skipping runbook suggestions: %v", err)
+		} else {
+			planner.SetRunbooks(catalog)
+		}
+
+		// Tailor cluster operations to the distribution (eksctl, gcloud, az)
+		if clusterType, err := detectClusterType(); err != nil {
+			logger.Debug("Skipping cluster type detection: %v", err)
+		} else {
+			planner.SetClusterType(clusterType)
+		}
 		
 		// Generate execution plan from natural language
 		executionPlan, err := planner.Generate(query)
 		if err != nil {
 			return fmt.Errorf("failed to generate plan: %w", err)
 		}
-		
-		// Display the plan
-		fmt.Println("\n📋 Execution Plan:")
-		fmt.Println("─────────────────")
-		executionPlan.Display()
-		
-		if dryRun && !applyChanges {
-			fmt.Println("\n✓ Dry-run complete. Use --apply to execute the plan.")
-			return nil
-		}
-		
-		if !applyChanges {
-			fmt.Println("\n✓ Preview complete. Use --apply to execute.")
-			return nil
-		}
-		
-		// Execute the plan
-		fmt.Println("\n⚡ Executing plan...")
-		result, err := executionPlan.Execute()
+
+		contexts, err := selectedContexts()
 		if err != nil {
-			return fmt.Errorf("execution failed: %w", err)
+			return err
 		}
-		
-		fmt.Println("\n✅ Execution complete:")
-		result.Display()
-		
-		return nil
+		if len(contexts) > 0 {
+			return runPlanOnContexts(executionPlan, contexts, format)
+		}
+
+		return processPlan(executionPlan, format)
 	},
 }
 
+// detectClusterType identifies the distribution of the target cluster
+func detectClusterType() (k8s.ClusterType, error) {
+	client, err := k8s.NewClient(namespace)
+	if err != nil {
+		return k8s.ClusterTypeUnknown, err
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return client.DetectClusterType(ctx)
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().BoolVar(&applyChanges, "apply", false, "apply the generated plan (disables dry-run)")
+	addFanOutFlags(runCmd)
+	addOutputFlag(runCmd, documentFormats...)
 }
@@ -0,0 +1,184 @@
+package pilot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s-pilot/internal/config"
+	"k8s-pilot/pkg/output"
+	"k8s-pilot/pkg/runbook"
+)
+
+var runbookValues []string
+
+var runbookCmd = &cobra.Command{
+	Use:   "runbook",
+	Short: "List, inspect and run parameterized runbooks",
+	Long: `Runbooks are reusable YAML procedures with parameters, preconditions and
+verifications, loaded from the runbook directory in the config file
+(default ~/.k8s-pilot/runbooks). Running a runbook renders it into an
+execution plan that goes through the same policy, dry-run and audit
+pipeline as AI-generated plans.
+
+Examples:
+  kubectl-pilot runbook list
+  kubectl-pilot runbook show drain-and-replace-node
+  kubectl-pilot runbook run drain-and-replace-node --set node=worker-3 --apply`,
+}
+
+var runbookListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available runbooks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		catalog, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+
+		runbooks := catalog.List()
+		if len(runbooks) == 0 {
+			fmt.Fprintf(output.Stdout, "No runbooks found in %s\n", config.Get().RunbookDir())
+			return nil
+		}
+
+		fmt.Fprintln(output.Stdout, "\n📓 Runbooks:")
+		fmt.Fprintln(output.Stdout, "═══════════")
+		for _, rb := range runbooks {
+			fmt.Fprintf(output.Stdout, "\n• %s\n", rb.Name)
+			if rb.Description != "" {
+				fmt.Fprintf(output.Stdout, "  %s\n", rb.Description)
+			}
+		}
+
+		return nil
+	},
+}
+
+var runbookShowCmd = &cobra.Command{
+	Use:   "show [runbook-name]",
+	Short: "Show a runbook's parameters and steps",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		catalog, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+
+		rb, err := catalog.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(output.Stdout, "\n📓 %s\n", rb.Name)
+		fmt.Fprintln(output.Stdout, "═══════════════")
+		if rb.Description != "" {
+			fmt.Fprintf(output.Stdout, "%s\n", rb.Description)
+		}
+		fmt.Fprintf(output.Stdout, "Source: %s\n", rb.Path)
+
+		if len(rb.Parameters) > 0 {
+			fmt.Fprintln(output.Stdout, "\nParameters:")
+			for _, param := range rb.Parameters {
+				required := ""
+				if param.Required {
+					required = " (required)"
+				}
+				fmt.Fprintf(output.Stdout, "  • %s%s: %s", param.Name, required, param.Description)
+				if param.Default != "" {
+					fmt.Fprintf(output.Stdout, " [default: %s]", param.Default)
+				}
+				fmt.Fprintln(output.Stdout)
+			}
+		}
+
+		showSteps("Preconditions", rb.Preconditions)
+		showSteps("Steps", rb.Steps)
+		showSteps("Verifications", rb.Verifications)
+
+		return nil
+	},
+}
+
+var runbookRunCmd = &cobra.Command{
+	Use:   "run [runbook-name]",
+	Short: "Render a runbook into an execution plan and run it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		catalog, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+
+		rb, err := catalog.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		values, err := parseSetValues(runbookValues)
+		if err != nil {
+			return err
+		}
+
+		executionPlan, err := rb.Render(values, namespace, dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to render runbook: %w", err)
+		}
+		executionPlan.Query = strings.TrimSpace(fmt.Sprintf("runbook run %s %s", rb.Name, formatSetValues(runbookValues)))
+
+		return processPlan(executionPlan, output.FormatText)
+	},
+}
+
+// loadRunbooks loads the runbook catalog from the configured directory
+func loadRunbooks() (*runbook.Catalog, error) {
+	catalog, err := runbook.LoadDir(config.Get().RunbookDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runbooks: %w", err)
+	}
+	return catalog, nil
+}
+
+// parseSetValues parses repeated --set key=value flags
+func parseSetValues(pairs []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --set value %q (expected key=value)", pair)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// formatSetValues formats --set values for display in plan history
+func formatSetValues(pairs []string) string {
+	var parts []string
+	for _, pair := range pairs {
+		parts = append(parts, "--set "+pair)
+	}
+	return strings.Join(parts, " ")
+}
+
+// showSteps prints a titled list of runbook steps
+func showSteps(title string, steps []runbook.Step) {
+	if len(steps) == 0 {
+		return
+	}
+
+	fmt.Fprintf(output.Stdout, "\n%s:\n", title)
+	for i, step := range steps {
+		fmt.Fprintf(output.Stdout, "  %d. %s\n", i+1, step.Description)
+		fmt.Fprintf(output.Stdout, "     %s\n", step.Command)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(runbookCmd)
+	runbookCmd.AddCommand(runbookListCmd)
+	runbookCmd.AddCommand(runbookShowCmd)
+	runbookCmd.AddCommand(runbookRunCmd)
+	runbookRunCmd.Flags().StringArrayVar(&runbookValues, "set", nil, "set a runbook parameter (key=value, repeatable)")
+	runbookRunCmd.Flags().BoolVar(&applyChanges, "apply", false, "apply the rendered plan (disables dry-run)")
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,6 +15,10 @@ type Config struct {
 	Kube     KubeConfig     `yaml:"kubernetes"`
 	Policy   PolicyConfig   `yaml:"policy"`
 	Logging  LoggingConfig  `yaml:"logging"`
+	Runbooks RunbookConfig  `yaml:"runbooks"`
+	Rules    RuleConfig     `yaml:"rules"`
+	Scoring  ScoringConfig  `yaml:"scoring"`
+	History  HistoryConfig  `yaml:"history"`
 	Plugins  []string       `yaml:"plugins"`
 }
 
@@ -44,6 +50,77 @@ type LoggingConfig struct {
 	Format string `yaml:"format"`
 }
 
+// RunbookConfig contains runbook configuration
+type RunbookConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+// DefaultRunbookDir returns the default runbook directory ($HOME/.k8s-pilot/runbooks)
+func DefaultRunbookDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".k8s-pilot", "runbooks")
+	}
+	return filepath.Join(home, ".k8s-pilot", "runbooks")
+}
+
+// RunbookDir returns the configured runbook directory, expanding a leading ~
+func (c *Config) RunbookDir() string {
+	return expandHome(c.Runbooks.Dir, DefaultRunbookDir())
+}
+
+// RuleConfig contains custom diagnostic rule configuration
+type RuleConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+// DefaultRulesDir returns the default rule directory ($HOME/.k8s-pilot/rules)
+func DefaultRulesDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".k8s-pilot", "rules")
+	}
+	return filepath.Join(home, ".k8s-pilot", "rules")
+}
+
+// RulesDir returns the configured rule directory, expanding a leading ~
+func (c *Config) RulesDir() string {
+	return expandHome(c.Rules.Dir, DefaultRulesDir())
+}
+
+// ScoringConfig contains health score weights; entries override the
+// built-in defaults
+type ScoringConfig struct {
+	Severity   map[string]float64 `yaml:"severity"`
+	Categories map[string]float64 `yaml:"categories"`
+	Namespaces map[string]float64 `yaml:"namespaces"`
+	Labels     map[string]float64 `yaml:"labels"`
+}
+
+// HistoryConfig contains plan history configuration
+type HistoryConfig struct {
+	Disabled bool   `yaml:"disabled"`
+	Path     string `yaml:"path"`
+}
+
+// HistoryPath returns the configured history database path, expanding a leading ~
+func (c *Config) HistoryPath(fallback string) string {
+	return expandHome(c.History.Path, fallback)
+}
+
+// expandHome expands a leading ~ in path, returning fallback when path is empty
+func expandHome(path, fallback string) string {
+	if path == "" {
+		return fallback
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
 var globalConfig *Config
 
 // Load loads configuration from a file
@@ -88,6 +165,12 @@ func defaultConfig() *Config {
 			Level:  "info",
 			Format: "text",
 		},
+		Runbooks: RunbookConfig{
+			Dir: DefaultRunbookDir(),
+		},
+		Rules: RuleConfig{
+			Dir: DefaultRulesDir(),
+		},
 		Plugins: []string{},
 	}
 }
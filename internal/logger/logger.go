@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 )
@@ -31,6 +32,12 @@ func Init(verboseMode bool) {
 	log.SetFlags(log.Ltime)
 }
 
+// SetOutput redirects log messages, e.g. to stderr when standard output
+// carries machine-readable output
+func SetOutput(w io.Writer) {
+	log.SetOutput(w)
+}
+
 // Debug logs a debug message
 func Debug(format string, args ...interface{}) {
 	if currentLevel <= LevelDebug {
@@ -0,0 +1,201 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// healthyWhenTrue lists condition types that signal a problem when False
+var healthyWhenTrue = map[string]Severity{
+	"Ready":     SeverityHigh,
+	"Available": SeverityHigh,
+	"Healthy":   SeverityHigh,
+	"Synced":    SeverityMedium,
+}
+
+// healthyWhenFalse lists condition types that signal a problem when True
+var healthyWhenFalse = map[string]Severity{
+	"Degraded": SeverityMedium,
+	"Failed":   SeverityHigh,
+	"Stalled":  SeverityHigh,
+}
+
+// Condition is a status condition as found on most Kubernetes objects
+type Condition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// objectConditions reads status.conditions from any object
+func objectConditions(obj *unstructured.Unstructured) []Condition {
+	items, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+
+	var conditions []Condition
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condition := Condition{}
+		condition.Type, _ = fields["type"].(string)
+		condition.Status, _ = fields["status"].(string)
+		condition.Reason, _ = fields["reason"].(string)
+		condition.Message, _ = fields["message"].(string)
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// analyzeConditions flags unhealthy status conditions such as Ready=False or
+// Degraded=True on any object
+func analyzeConditions(obj *unstructured.Unstructured, resource string) []Issue {
+	var issues []Issue
+	for _, condition := range objectConditions(obj) {
+		severity, unhealthy := conditionSeverity(condition)
+		if !unhealthy {
+			continue
+		}
+
+		description := fmt.Sprintf("%s=%s", condition.Type, condition.Status)
+		if condition.Reason != "" {
+			description += " (" + condition.Reason + ")"
+		}
+		if condition.Message != "" {
+			description += ": " + condition.Message
+		}
+
+		issues = append(issues, Issue{
+			Severity:    severity,
+			Type:        IssueUnhealthyCondition,
+			Resource:    resource,
+			Description: description,
+			Details: map[string]interface{}{
+				"condition": condition.Type,
+				"status":    condition.Status,
+				"reason":    condition.Reason,
+			},
+		})
+	}
+	return issues
+}
+
+// conditionSeverity reports whether a condition is unhealthy and how severe
+func conditionSeverity(condition Condition) (Severity, bool) {
+	if severity, ok := healthyWhenTrue[condition.Type]; ok && condition.Status == "False" {
+		return severity, true
+	}
+	if severity, ok := healthyWhenFalse[condition.Type]; ok && condition.Status == "True" {
+		return severity, true
+	}
+	return "", false
+}
+
+// diagnoseObject diagnoses any resource, including custom resources, from
+// its status conditions and events
+func (e *Engine) diagnoseObject(ctx context.Context, mapping *meta.RESTMapping, name string) (*Report, error) {
+	obj, err := e.k8sClient.GetObject(ctx, mapping, name, e.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := mapping.GroupVersionKind.Kind
+	resource := fmt.Sprintf("%s/%s", strings.ToLower(kind), name)
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for %s: %s", strings.ToLower(kind), name),
+		Issues:       analyzeConditions(obj, resource),
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+
+	if len(report.Issues) > 0 {
+		if snapshot, err := e.loadSnapshot(ctx); err == nil {
+			if events := e.warningEvents(snapshot, report, kind, obj.GetName(), obj.GetNamespace()); len(events) > 0 {
+				for i := range report.Issues {
+					report.Issues[i].Details["events"] = events
+				}
+			}
+		}
+		report.Remediations = objectRemediations(mapping, obj)
+	}
+
+	return report, nil
+}
+
+// diagnoseObjects diagnoses every object of a resource type from their
+// status conditions
+func (e *Engine) diagnoseObjects(ctx context.Context, mapping *meta.RESTMapping) (*Report, error) {
+	namespace := e.namespace
+	if e.allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	objects, err := e.k8sClient.ListObjects(ctx, mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := strings.ToLower(mapping.GroupVersionKind.Kind)
+	report := &Report{
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		report.Issues = append(report.Issues, analyzeConditions(obj, fmt.Sprintf("%s/%s", kind, obj.GetName()))...)
+		report.examined = append(report.examined, ref{kind: kind, namespace: obj.GetNamespace(), name: obj.GetName()})
+	}
+
+	report.Summary = fmt.Sprintf("Found %d issue(s) across %d %s(s)", len(report.Issues), len(objects), kind)
+	return report, nil
+}
+
+// objectRemediations suggests read-only next steps for any object
+func objectRemediations(mapping *meta.RESTMapping, obj *unstructured.Unstructured) []Remediation {
+	resource := mapping.Resource.Resource
+	if mapping.Resource.Group != "" {
+		resource += "." + mapping.Resource.Group
+	}
+	namespace := ""
+	if obj.GetNamespace() != "" {
+		namespace = " -n " + obj.GetNamespace()
+	}
+
+	return []Remediation{
+		{
+			Title:       fmt.Sprintf("Describe %s", strings.ToLower(mapping.GroupVersionKind.Kind)),
+			Description: "Inspect status conditions and recent events",
+			Command:     fmt.Sprintf("kubectl describe %s %s%s", resource, obj.GetName(), namespace),
+			Confidence:  "High",
+			Safe:        true,
+		},
+		{
+			Title:       "Inspect full status",
+			Description: "Review the controller-reported status fields",
+			Command:     fmt.Sprintf("kubectl get %s %s%s -o yaml", resource, obj.GetName(), namespace),
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+}
+
+// resolveKind maps a resource type argument to its API resource
+func (e *Engine) resolveKind(resourceType string) (*meta.RESTMapping, error) {
+	mapping, err := e.k8sClient.ResolveResource(resourceType)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported resource type: %s: %w", resourceType, err)
+	}
+	return mapping, nil
+}
+
+// isResource reports whether a mapping is the given core or apps resource
+func isResource(mapping *meta.RESTMapping, group, resource string) bool {
+	return mapping.Resource.Group == group && mapping.Resource.Resource == resource
+}
@@ -0,0 +1,77 @@
+package diagnose
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAnalyzeConditions(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+				map[string]interface{}{"type": "Degraded", "status": "True", "reason": "RolloutAborted", "message": "canary failed"},
+				map[string]interface{}{"type": "Progressing", "status": "False"},
+				map[string]interface{}{"type": "Failed", "status": "False"},
+			},
+		},
+	}}
+
+	issues := analyzeConditions(obj, "rollout/api")
+	if len(issues) != 1 {
+		t.Fatalf("issues = %+v, want only Degraded=True", issues)
+	}
+	if issues[0].Type != IssueUnhealthyCondition || issues[0].Severity != SeverityMedium {
+		t.Errorf("issue = %+v", issues[0])
+	}
+	if issues[0].Description != "Degraded=True (RolloutAborted): canary failed" {
+		t.Errorf("Description = %q", issues[0].Description)
+	}
+}
+
+func TestDiagnoseCustomResource(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/certificates.yaml")
+
+	report, err := engine.DiagnoseResource("cert", "api-tls")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Details["condition"] != "Ready" {
+		t.Fatalf("issues = %+v, want Ready=False", report.Issues)
+	}
+	if events, _ := report.Issues[0].Details["events"].([]string); len(events) != 1 {
+		t.Errorf("events = %v, want the IssuerNotFound warning", events)
+	}
+	if len(report.Remediations) == 0 || report.Remediations[0].Command != "kubectl describe certificates.cert-manager.io api-tls -n payments" {
+		t.Errorf("remediations = %+v", report.Remediations)
+	}
+
+	healthy, err := engine.DiagnoseResource("Certificate", "web-tls")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if len(healthy.Issues) != 0 || healthy.HealthScore != 100 {
+		t.Errorf("healthy certificate reported %+v", healthy.Issues)
+	}
+}
+
+func TestDiagnoseCustomResourceType(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/certificates.yaml")
+
+	report, err := engine.DiagnoseResourceType("certificates")
+	if err != nil {
+		t.Fatalf("DiagnoseResourceType: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Resource != "certificate/api-tls" {
+		t.Errorf("issues = %+v", report.Issues)
+	}
+}
+
+func TestDiagnoseUnknownResourceType(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/certificates.yaml")
+
+	if _, err := engine.DiagnoseResource("widgets", "w"); err == nil {
+		t.Error("expected an error for an unknown resource type")
+	}
+}
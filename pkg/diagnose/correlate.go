@@ -0,0 +1,699 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+)
+
+// IssueMissingReference is a config map or secret that pods reference but
+// that does not exist
+const IssueMissingReference IssueType = "MissingReference"
+
+// RootCause is a probable root cause: the issues on one resource and the
+// resources whose issues it explains
+type RootCause struct {
+	Resource  string
+	Namespace string
+	Issues    []Issue
+	Affected  []AffectedResource
+	// Score ranks hypotheses: the root issue's severity weighted by the
+	// number of resources it explains
+	Score int
+}
+
+// AffectedResource is a resource whose issues are symptoms of a root cause
+type AffectedResource struct {
+	Resource  string
+	Namespace string
+	Issues    []Issue
+}
+
+// Primary returns the most severe issue on the root cause's resource
+func (c RootCause) Primary() Issue {
+	primary := c.Issues[0]
+	for _, issue := range c.Issues[1:] {
+		if severityWeight(issue.Severity) > severityWeight(primary.Severity) {
+			primary = issue
+		}
+	}
+	return primary
+}
+
+// ref identifies a resource in the dependency graph
+type ref struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (r ref) String() string {
+	return r.kind + "/" + r.name
+}
+
+// dependencyGraph links each resource to the resources it depends on: a
+// pod to its node, claims, config maps and secrets, a claim to its volume,
+// a workload to its pods, a service to its endpoints and their pods, and an
+// ingress to its services. A problem on a dependency explains problems on
+// its dependents.
+type dependencyGraph struct {
+	deps   map[ref][]ref
+	owners map[ref]ref
+	// names indexes the resources by kind and name for resolving issues
+	names map[string][]ref
+}
+
+// refKinds maps the kinds used in issue resources to graph kinds
+var refKinds = map[string]string{
+	"pod": "pod", "node": "node",
+	"deployment": "deployment", "replicaset": "replicaset", "statefulset": "statefulset",
+	"daemonset": "daemonset", "job": "job", "cronjob": "cronjob",
+	"service": "service", "endpoints": "endpoints", "ingress": "ingress",
+	"pvc": "pvc", "persistentvolumeclaim": "pvc", "pv": "pv", "persistentvolume": "pv",
+	"configmap": "configmap", "secret": "secret",
+}
+
+// localIssues are node problems that don't affect the pods already running
+// on the node
+var localIssues = map[IssueType]bool{
+	IssueNodeCordoned:  true,
+	IssueNodeTainted:   true,
+	IssueVersionSkew:   true,
+	IssueNodeCapacity:  true,
+	IssueEvictionStorm: true,
+}
+
+// buildGraph builds the dependency graph of the resources in a snapshot
+func buildGraph(snapshot *k8s.Snapshot) *dependencyGraph {
+	g := &dependencyGraph{deps: map[ref][]ref{}, owners: map[ref]ref{}, names: map[string][]ref{}}
+
+	for i := range snapshot.Pods {
+		pod := &snapshot.Pods[i]
+		p := g.add("pod", pod.Namespace, pod.Name)
+		if pod.Spec.NodeName != "" {
+			g.link(p, g.add("node", "", pod.Spec.NodeName))
+		}
+		for _, dep := range podReferences(pod) {
+			g.link(p, g.add(dep.kind, pod.Namespace, dep.name))
+		}
+		g.own(pod, p)
+	}
+	for i := range snapshot.ReplicaSets {
+		rs := &snapshot.ReplicaSets[i]
+		g.own(rs, g.add("replicaset", rs.Namespace, rs.Name))
+	}
+	for i := range snapshot.Jobs {
+		job := &snapshot.Jobs[i]
+		g.own(job, g.add("job", job.Namespace, job.Name))
+	}
+	for i := range snapshot.PVCs {
+		claim := &snapshot.PVCs[i]
+		c := g.add("pvc", claim.Namespace, claim.Name)
+		if claim.Spec.VolumeName != "" {
+			g.link(c, g.add("pv", "", claim.Spec.VolumeName))
+		}
+	}
+
+	for i := range snapshot.Services {
+		service := &snapshot.Services[i]
+		g.link(g.add("service", service.Namespace, service.Name), g.add("endpoints", service.Namespace, service.Name))
+	}
+	for i := range snapshot.Endpoints {
+		endpoints := &snapshot.Endpoints[i]
+		ep := g.add("endpoints", endpoints.Namespace, endpoints.Name)
+		for _, subset := range endpoints.Subsets {
+			for _, address := range append(subset.Addresses, subset.NotReadyAddresses...) {
+				if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+					g.link(ep, g.add("pod", endpoints.Namespace, address.TargetRef.Name))
+				}
+			}
+		}
+	}
+	for i := range snapshot.EndpointSlices {
+		slice := &snapshot.EndpointSlices[i]
+		service := slice.Labels["kubernetes.io/service-name"]
+		if service == "" {
+			continue
+		}
+		ep := g.add("endpoints", slice.Namespace, service)
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				g.link(ep, g.add("pod", slice.Namespace, endpoint.TargetRef.Name))
+			}
+		}
+	}
+	for i := range snapshot.Ingresses {
+		ingress := &snapshot.Ingresses[i]
+		ing := g.add("ingress", ingress.Namespace, ingress.Name)
+		if backend := ingress.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+			g.link(ing, g.add("service", ingress.Namespace, backend.Service.Name))
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil {
+					g.link(ing, g.add("service", ingress.Namespace, path.Backend.Service.Name))
+				}
+			}
+		}
+	}
+	return g
+}
+
+// add registers a resource and returns its reference
+func (g *dependencyGraph) add(kind, namespace, name string) ref {
+	r := ref{kind: kind, namespace: namespace, name: name}
+	key := kind + "/" + name
+	for _, existing := range g.names[key] {
+		if existing == r {
+			return r
+		}
+	}
+	g.names[key] = append(g.names[key], r)
+	return r
+}
+
+// link records that from depends on to
+func (g *dependencyGraph) link(from, to ref) {
+	for _, existing := range g.deps[from] {
+		if existing == to {
+			return
+		}
+	}
+	g.deps[from] = append(g.deps[from], to)
+}
+
+// own links an object's controller to it; the controller's health depends
+// on the objects it manages
+func (g *dependencyGraph) own(obj metav1.Object, r ref) {
+	controller := metav1.GetControllerOf(obj)
+	if controller == nil {
+		return
+	}
+	kind, ok := refKinds[strings.ToLower(controller.Kind)]
+	if !ok {
+		return
+	}
+	owner := g.add(kind, obj.GetNamespace(), controller.Name)
+	g.link(owner, r)
+	g.owners[r] = owner
+}
+
+// topOwner returns the outermost controller of a resource, such as the
+// deployment of a pod's replicaset
+func (g *dependencyGraph) topOwner(r ref) (ref, bool) {
+	owner, ok := g.owners[r]
+	if !ok {
+		return ref{}, false
+	}
+	for {
+		next, ok := g.owners[owner]
+		if !ok {
+			return owner, true
+		}
+		owner = next
+	}
+}
+
+// resolve maps an issue to the resource it is about. Issues name their
+// resource as "kind/name", as a bare pod name or as "pod/container".
+func (g *dependencyGraph) resolve(issue Issue, namespace string) (ref, bool) {
+	kind, name := "", ""
+	if prefix, rest, ok := strings.Cut(issue.Resource, "/"); ok {
+		if k, known := refKinds[prefix]; known {
+			name, _, _ = strings.Cut(rest, "/")
+			kind = k
+		}
+	}
+	if kind == "" {
+		if pod, ok := issue.Details["pod"].(string); ok {
+			kind, name = "pod", pod
+		} else {
+			first, _, _ := strings.Cut(issue.Resource, "/")
+			if len(g.names["pod/"+first]) == 0 {
+				return ref{}, false
+			}
+			kind, name = "pod", first
+		}
+	}
+
+	if ns, ok := issue.Details["namespace"].(string); ok && ns != "" {
+		namespace = ns
+	}
+	candidates := g.names[kind+"/"+name]
+	for _, candidate := range candidates {
+		if candidate.namespace == namespace || candidate.namespace == "" {
+			return candidate, true
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], true
+	}
+	if kind == "node" || kind == "pv" {
+		namespace = ""
+	}
+	return g.add(kind, namespace, name), true
+}
+
+// podReferences returns the claims, config maps and secrets a pod needs to
+// start; optional references are skipped
+func podReferences(pod *corev1.Pod) []ref {
+	var refs []ref
+	required := func(optional *bool) bool { return optional == nil || !*optional }
+
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			refs = append(refs, ref{kind: "pvc", name: volume.PersistentVolumeClaim.ClaimName})
+		case volume.ConfigMap != nil && required(volume.ConfigMap.Optional):
+			refs = append(refs, ref{kind: "configmap", name: volume.ConfigMap.Name})
+		case volume.Secret != nil && required(volume.Secret.Optional):
+			refs = append(refs, ref{kind: "secret", name: volume.Secret.SecretName})
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil && required(source.ConfigMap.Optional) {
+					refs = append(refs, ref{kind: "configmap", name: source.ConfigMap.Name})
+				}
+				if source.Secret != nil && required(source.Secret.Optional) {
+					refs = append(refs, ref{kind: "secret", name: source.Secret.Name})
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		for _, source := range container.EnvFrom {
+			if source.ConfigMapRef != nil && required(source.ConfigMapRef.Optional) {
+				refs = append(refs, ref{kind: "configmap", name: source.ConfigMapRef.Name})
+			}
+			if source.SecretRef != nil && required(source.SecretRef.Optional) {
+				refs = append(refs, ref{kind: "secret", name: source.SecretRef.Name})
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if key := env.ValueFrom.ConfigMapKeyRef; key != nil && required(key.Optional) {
+				refs = append(refs, ref{kind: "configmap", name: key.Name})
+			}
+			if key := env.ValueFrom.SecretKeyRef; key != nil && required(key.Optional) {
+				refs = append(refs, ref{kind: "secret", name: key.Name})
+			}
+		}
+	}
+	return refs
+}
+
+// correlate groups the report's issues under their probable root causes
+func (e *Engine) correlate(ctx context.Context, report *Report) {
+	if len(report.Issues) == 0 {
+		return
+	}
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return
+	}
+	report.RootCauses = correlateIssues(snapshot, buildGraph(snapshot), report, e.namespace)
+}
+
+// correlateIssues assigns every issue-bearing resource either to its own
+// root cause or, when a dependency it (transitively) relies on has a
+// problem, as a symptom of that dependency's root cause
+func correlateIssues(snapshot *k8s.Snapshot, g *dependencyGraph, report *Report, namespace string) []RootCause {
+	issues := map[ref][]Issue{}
+	var order []ref
+	var unresolved []Issue
+	for _, issue := range report.Issues {
+		r, ok := g.resolve(issue, namespace)
+		if !ok {
+			unresolved = append(unresolved, issue)
+			continue
+		}
+		if _, seen := issues[r]; !seen {
+			order = append(order, r)
+		}
+		issues[r] = append(issues[r], issue)
+	}
+	for _, missing := range missingReferences(snapshot, g, issues) {
+		r, _ := g.resolve(missing, namespace)
+		report.Issues = append(report.Issues, missing)
+		issues[r] = append(issues[r], missing)
+		order = append(order, r)
+	}
+
+	// A resource's candidate root causes are the failing dependencies
+	// whose own dependencies are healthy
+	candidates := map[ref][]ref{}
+	var findRoots func(r ref, visiting map[ref]bool) []ref
+	findRoots = func(r ref, visiting map[ref]bool) []ref {
+		if roots, done := candidates[r]; done {
+			return roots
+		}
+		visiting[r] = true
+		var roots []ref
+		for _, dep := range g.deps[r] {
+			if visiting[dep] {
+				continue
+			}
+			deeper := findRoots(dep, visiting)
+			switch {
+			case len(deeper) > 0:
+				roots = appendRefs(roots, deeper...)
+			case propagates(issues[dep]):
+				roots = appendRefs(roots, dep)
+			}
+		}
+		delete(visiting, r)
+		candidates[r] = roots
+		return roots
+	}
+
+	coverage := map[ref]int{}
+	for _, r := range order {
+		for _, root := range findRoots(r, map[ref]bool{}) {
+			coverage[root]++
+		}
+	}
+
+	// Each symptom goes to the candidate explaining the most resources
+	causes := map[ref]*RootCause{}
+	var roots []ref
+	for _, r := range order {
+		if len(candidates[r]) == 0 {
+			causes[r] = &RootCause{Resource: r.String(), Namespace: r.namespace, Issues: issues[r]}
+			roots = append(roots, r)
+		}
+	}
+	for _, r := range order {
+		if len(candidates[r]) == 0 {
+			continue
+		}
+		best := candidates[r][0]
+		for _, candidate := range candidates[r][1:] {
+			if coverage[candidate] > coverage[best] ||
+				(coverage[candidate] == coverage[best] && severityWeight(maxSeverity(issues[candidate])) > severityWeight(maxSeverity(issues[best]))) {
+				best = candidate
+			}
+		}
+		// Candidates have no failing dependencies, so they are roots
+		cause := causes[best]
+		cause.Affected = append(cause.Affected, AffectedResource{Resource: r.String(), Namespace: r.namespace, Issues: issues[r]})
+	}
+
+	rootCauses := collapseSiblings(g, roots, causes)
+	for _, issue := range unresolved {
+		rootCauses = append(rootCauses, RootCause{Resource: issue.Resource, Issues: []Issue{issue}})
+	}
+	for i := range rootCauses {
+		cause := &rootCauses[i]
+		sort.SliceStable(cause.Affected, func(a, b int) bool {
+			return cause.Affected[a].Resource < cause.Affected[b].Resource
+		})
+		cause.Score = severityWeight(cause.Primary().Severity) * (1 + len(cause.Affected))
+	}
+	sort.SliceStable(rootCauses, func(i, j int) bool {
+		return rootCauses[i].Score > rootCauses[j].Score
+	})
+	return rootCauses
+}
+
+// collapseSiblings merges the root causes of pods of one workload that fail
+// the same way into a single hypothesis on the workload, e.g. every pod of
+// a deployment in ImagePullBackOff
+func collapseSiblings(g *dependencyGraph, roots []ref, causes map[ref]*RootCause) []RootCause {
+	type group struct {
+		owner     ref
+		issueType IssueType
+	}
+	groupOf := func(r ref) (group, bool) {
+		owner, ok := g.topOwner(r)
+		if r.kind != "pod" || !ok {
+			return group{}, false
+		}
+		return group{owner: owner, issueType: causes[r].Primary().Type}, true
+	}
+	members := map[group]int{}
+	for _, r := range roots {
+		if key, ok := groupOf(r); ok {
+			members[key]++
+		}
+	}
+
+	var rootCauses []RootCause
+	merged := map[ref]int{}
+	for _, r := range roots {
+		cause := causes[r]
+		key, ok := groupOf(r)
+		if !ok || members[key] < 2 {
+			rootCauses = append(rootCauses, *cause)
+			continue
+		}
+
+		index, ok := merged[key.owner]
+		if !ok {
+			primary := cause.Primary()
+			primary.Resource = key.owner.String()
+			primary.Description = fmt.Sprintf("%d pods of %s fail with %s, e.g. %s", members[key], key.owner, primary.Type, primary.Description)
+			rootCauses = append(rootCauses, RootCause{Resource: key.owner.String(), Namespace: key.owner.namespace, Issues: []Issue{primary}})
+			index = len(rootCauses) - 1
+			merged[key.owner] = index
+		}
+		target := &rootCauses[index]
+		target.Affected = append(target.Affected, AffectedResource{Resource: r.String(), Namespace: r.namespace, Issues: cause.Issues})
+		target.Affected = append(target.Affected, cause.Affected...)
+	}
+
+	// The workload's own issues (e.g. unavailable replicas) are symptoms
+	// of its pods' shared failure
+	for owner, index := range merged {
+		cause := &rootCauses[index]
+		var affected []AffectedResource
+		for _, a := range cause.Affected {
+			if refFromString(a.Resource, a.Namespace) == owner {
+				cause.Issues = append(cause.Issues, a.Issues...)
+				continue
+			}
+			affected = append(affected, a)
+		}
+		cause.Affected = affected
+	}
+	return rootCauses
+}
+
+// refFromString parses a "kind/name" resource back into a reference
+func refFromString(resource, namespace string) ref {
+	kind, name, _ := strings.Cut(resource, "/")
+	return ref{kind: kind, namespace: namespace, name: name}
+}
+
+// missingReferences reports the config maps and secrets that failing pods
+// reference but that don't exist, as root causes of their own. A config map
+// is missing when the pod's issues, container statuses or events say so;
+// secrets are checked against the snapshot when they could be fetched.
+func missingReferences(snapshot *k8s.Snapshot, g *dependencyGraph, issues map[ref][]Issue) []Issue {
+	referrers := map[ref][]string{}
+	var order []ref
+
+	for r, podIssues := range issues {
+		if r.kind != "pod" {
+			continue
+		}
+		evidence := strings.ToLower(issueText(podIssues) + "\n" + podEvidence(snapshot, r))
+		for _, dep := range g.deps[r] {
+			if dep.kind != "configmap" && dep.kind != "secret" {
+				continue
+			}
+			missing := strings.Contains(evidence, fmt.Sprintf("%s %q not found", dep.kind, dep.name))
+			if dep.kind == "secret" && snapshot.Secret(dep.namespace, dep.name) == nil && snapshot.SecretErr(dep.namespace, dep.name) == nil {
+				missing = true
+			}
+			if !missing {
+				continue
+			}
+			if _, seen := referrers[dep]; !seen {
+				order = append(order, dep)
+			}
+			referrers[dep] = append(referrers[dep], r.name)
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i].String() < order[j].String() })
+	var missing []Issue
+	for _, dep := range order {
+		pods := referrers[dep]
+		sort.Strings(pods)
+		kind := "ConfigMap"
+		if dep.kind == "secret" {
+			kind = "Secret"
+		}
+		missing = append(missing, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssueMissingReference,
+			Resource:    dep.String(),
+			Description: fmt.Sprintf("%s %s referenced by %d pod(s) does not exist in namespace %s", kind, dep.name, len(pods), dep.namespace),
+			Details:     map[string]interface{}{"namespace": dep.namespace, "name": dep.name, "pods": pods},
+		})
+	}
+	return missing
+}
+
+// issueText joins the descriptions and events of issues for matching
+func issueText(issues []Issue) string {
+	var parts []string
+	for _, issue := range issues {
+		parts = append(parts, issue.Description)
+		if events, ok := issue.Details["events"].([]string); ok {
+			parts = append(parts, events...)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// podEvidence joins a pod's container waiting messages and event messages,
+// which name the config maps and secrets it can't find
+func podEvidence(snapshot *k8s.Snapshot, r ref) string {
+	var parts []string
+	if pod := snapshot.Pod(r.namespace, r.name); pod != nil {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.State.Waiting != nil {
+				parts = append(parts, status.State.Waiting.Message)
+			}
+		}
+	}
+	for _, event := range snapshot.EventsFor("Pod", r.namespace, r.name) {
+		parts = append(parts, event.Message)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// propagates reports whether a resource's issues affect its dependents
+func propagates(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity != SeverityLow && !localIssues[issue.Type] {
+			return true
+		}
+	}
+	return false
+}
+
+// appendRefs appends references that are not already present
+func appendRefs(refs []ref, more ...ref) []ref {
+	for _, r := range more {
+		found := false
+		for _, existing := range refs {
+			if existing == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
+
+// maxSeverity returns the highest severity among issues
+func maxSeverity(issues []Issue) Severity {
+	severity := SeverityLow
+	for _, issue := range issues {
+		if severityWeight(issue.Severity) > severityWeight(severity) {
+			severity = issue.Severity
+		}
+	}
+	return severity
+}
+
+// severityWeight orders severities from low (1) to critical (4)
+func severityWeight(severity Severity) int {
+	switch severity {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// severityEmoji returns the marker shown next to an issue's severity
+func severityEmoji(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "🔴"
+	case SeverityHigh:
+		return "🟠"
+	case SeverityMedium:
+		return "🟡"
+	default:
+		return "ℹ️"
+	}
+}
+
+// correlated reports whether any root cause explains other resources, in
+// which case the report is shown as a tree
+func (r *Report) correlated() bool {
+	for _, cause := range r.RootCauses {
+		if len(cause.Affected) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// displayRootCauses prints the ranked root causes as a tree of the
+// resources each one affects
+func (r *Report) displayRootCauses() {
+	fmt.Fprintln(output.Stdout, "Probable root causes (most likely first):")
+	for i, cause := range r.RootCauses {
+		primary := cause.Primary()
+		fmt.Fprintf(output.Stdout, "\n%d. %s [%s] %s\n", i+1, severityEmoji(primary.Severity), primary.Severity, primary.Type)
+		fmt.Fprintf(output.Stdout, "   Resource: %s\n", cause.Resource)
+		fmt.Fprintf(output.Stdout, "   %s\n", primary.Description)
+		for _, issue := range cause.Issues {
+			if issue.Type != primary.Type || issue.Description != primary.Description {
+				fmt.Fprintf(output.Stdout, "   also: [%s] %s: %s\n", issue.Severity, issue.Type, issue.Description)
+			}
+		}
+		if len(cause.Affected) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(output.Stdout, "   Affects %d resource(s):\n", len(cause.Affected))
+		for j, affected := range cause.Affected {
+			branch := "├──"
+			if j == len(cause.Affected)-1 {
+				branch = "└──"
+			}
+			var types []string
+			for _, issue := range affected.Issues {
+				types = appendUnique(types, string(issue.Type))
+			}
+			fmt.Fprintf(output.Stdout, "   %s %s: %s\n", branch, affected.Resource, strings.Join(types, ", "))
+		}
+	}
+}
+
+// appendUnique appends a string if it is not already present
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
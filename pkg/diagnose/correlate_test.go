@@ -0,0 +1,82 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDiagnoseClusterCorrelatesRootCauses(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/correlation.yaml")
+
+	report, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+	if len(report.RootCauses) != 3 {
+		t.Fatalf("expected 3 root causes, got %+v", report.RootCauses)
+	}
+
+	// The NotReady node explains both cart pods and ranks first
+	node := report.RootCauses[0]
+	if node.Resource != "node/worker-1" || node.Primary().Type != IssueNodeNotReady {
+		t.Errorf("expected the NotReady node first, got %+v", node)
+	}
+	if got := affectedResources(node); len(got) != 2 || got[0] != "pod/cart-5f7c8-a1b2c" || got[1] != "pod/cart-5f7c8-d3e4f" {
+		t.Errorf("expected the cart pods under the node, got %v", got)
+	}
+
+	causes := map[string]RootCause{}
+	for _, cause := range report.RootCauses {
+		causes[cause.Resource] = cause
+	}
+
+	// Both web pods fail the same way, so the deployment is the hypothesis
+	web, ok := causes["deployment/web"]
+	if !ok || web.Primary().Type != IssueImagePullBackOff || len(web.Affected) != 2 {
+		t.Errorf("expected the web pods collapsed under their deployment, got %+v", report.RootCauses)
+	}
+
+	config, ok := causes["configmap/reports-config"]
+	if !ok || config.Primary().Type != IssueMissingReference {
+		t.Fatalf("expected the missing config map as a root cause, got %+v", report.RootCauses)
+	}
+	if got := affectedResources(config); len(got) != 1 || got[0] != "pod/reports-0" {
+		t.Errorf("expected reports-0 under the missing config map, got %v", got)
+	}
+	if !hasIssue(report, IssueMissingReference, "configmap/reports-config", SeverityHigh, "does not exist in namespace shop") {
+		t.Errorf("expected the missing config map in the issues, got %+v", report.Issues)
+	}
+}
+
+func TestCorrelateFollowsServiceEndpoints(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/correlation.yaml")
+	snapshot, err := engine.loadSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("loadSnapshot: %v", err)
+	}
+
+	report := &Report{Issues: []Issue{
+		{Severity: SeverityHigh, Type: IssueBackendNotReady, Resource: "service/cart", Description: "no ready backends"},
+		{Severity: SeverityCritical, Type: IssueNodeNotReady, Resource: "node/worker-1", Description: "Node is NotReady"},
+		{Severity: SeverityLow, Type: IssueVersionSkew, Resource: "node/worker-2", Description: "kubelet is two minor versions behind"},
+	}}
+	causes := correlateIssues(snapshot, buildGraph(snapshot), report, "shop")
+
+	if len(causes) != 2 || causes[0].Resource != "node/worker-1" {
+		t.Fatalf("expected the node to rank first, got %+v", causes)
+	}
+	if got := affectedResources(causes[0]); len(got) != 1 || got[0] != "service/cart" {
+		t.Errorf("expected the service under the node through its endpoints, got %v", got)
+	}
+	if causes[1].Resource != "node/worker-2" || len(causes[1].Affected) != 0 {
+		t.Errorf("expected the version skew on its own, got %+v", causes[1])
+	}
+}
+
+func affectedResources(cause RootCause) []string {
+	var resources []string
+	for _, affected := range cause.Affected {
+		resources = append(resources, affected.Resource)
+	}
+	return resources
+}
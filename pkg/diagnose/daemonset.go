@@ -0,0 +1,223 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// diagnoseDaemonSet diagnoses a daemonset's scheduling and update against
+// the cluster's nodes, and rolls up the diagnostics of its pods
+func (e *Engine) diagnoseDaemonSet(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceDaemonSets); err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+
+	ds := snapshot.DaemonSet(e.namespace, name)
+	if ds == nil {
+		return nil, fmt.Errorf("daemonset %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for daemonset: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	resource := "daemonset/" + name
+
+	checkDaemonSetCounts(report, ds, resource)
+	tainted := checkTaints(snapshot, report, ds, resource)
+
+	podIssues := 0
+	for _, pod := range snapshot.PodsOwnedBy(ds) {
+		before := len(report.Issues)
+		e.analyzePod(ctx, snapshot, report, pod)
+		podIssues += len(report.Issues) - before
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = daemonSetRemediations(ds, report.Issues, tainted)
+	}
+
+	status := ds.Status
+	report.Summary = fmt.Sprintf("Diagnostics for daemonset: %s (%d/%d scheduled, %d available, %d pod issue(s))",
+		name, status.CurrentNumberScheduled, status.DesiredNumberScheduled, status.NumberAvailable, podIssues)
+	return report, nil
+}
+
+// checkDaemonSetCounts compares the controller's scheduling counters
+func checkDaemonSetCounts(report *Report, ds *appsv1.DaemonSet, resource string) {
+	status := ds.Status
+
+	if status.NumberMisscheduled > 0 {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueDaemonSetMisscheduled,
+			Resource:    resource,
+			Description: fmt.Sprintf("%d pod(s) are running on nodes they should not run on", status.NumberMisscheduled),
+			Details:     map[string]interface{}{"misscheduled": status.NumberMisscheduled},
+		})
+	}
+
+	if missing := status.DesiredNumberScheduled - status.CurrentNumberScheduled; missing > 0 {
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityHigh,
+			Type:     IssueDaemonSetUnavailable,
+			Resource: resource,
+			Description: fmt.Sprintf("%d of %d node(s) that should run the daemon have no pod",
+				missing, status.DesiredNumberScheduled),
+		})
+	}
+
+	if status.NumberUnavailable > 0 {
+		severity := SeverityMedium
+		if status.NumberAvailable == 0 {
+			severity = SeverityCritical
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity: severity,
+			Type:     IssueDaemonSetUnavailable,
+			Resource: resource,
+			Description: fmt.Sprintf("%d of %d daemon pod(s) are unavailable",
+				status.NumberUnavailable, status.DesiredNumberScheduled),
+			Details: map[string]interface{}{"unavailable": status.NumberUnavailable},
+		})
+	}
+
+	// With OnDelete, pods are only replaced when deleted by hand, so old pods
+	// are expected rather than a stalled rollout
+	onDelete := ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType
+	if !onDelete && status.ObservedGeneration >= ds.Generation && status.UpdatedNumberScheduled < status.DesiredNumberScheduled {
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityLow,
+			Type:     IssueRolloutStuck,
+			Resource: resource,
+			Description: fmt.Sprintf("Update has reached %d of %d node(s)",
+				status.UpdatedNumberScheduled, status.DesiredNumberScheduled),
+		})
+	}
+}
+
+// checkTaints lists the nodes the daemonset's pods can't run on because of
+// NoSchedule or NoExecute taints they don't tolerate, when pods are missing
+// or unavailable; a healthy daemon that skips tainted nodes, such as the
+// control plane, is left alone. Nodes excluded by the node selector aren't
+// counted. Returns the distinct untolerated taints.
+func checkTaints(snapshot *k8s.Snapshot, report *Report, ds *appsv1.DaemonSet, resource string) []corev1.Taint {
+	if err := snapshot.Err(k8s.ResourceNodes); err != nil {
+		report.AddWarning(fmt.Sprintf("Node taints not inspected: %v", err))
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(ds.Spec.Template.Spec.NodeSelector)
+	tolerations := ds.Spec.Template.Spec.Tolerations
+
+	var nodes []string
+	var taints []corev1.Taint
+	var eligible int32
+	seen := map[string]bool{}
+	for _, node := range snapshot.Nodes {
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+
+		var untolerated []string
+		for _, taint := range node.Spec.Taints {
+			if taint.Effect == corev1.TaintEffectPreferNoSchedule || tolerated(tolerations, &taint) {
+				continue
+			}
+			untolerated = append(untolerated, taint.ToString())
+			if key := taint.ToString(); !seen[key] {
+				seen[key] = true
+				taints = append(taints, taint)
+			}
+		}
+		if len(untolerated) > 0 {
+			nodes = append(nodes, fmt.Sprintf("%s (%s)", node.Name, strings.Join(untolerated, ", ")))
+		} else {
+			eligible++
+		}
+	}
+	status := ds.Status
+	unhealthy := status.NumberUnavailable > 0 || status.CurrentNumberScheduled < status.DesiredNumberScheduled ||
+		status.DesiredNumberScheduled < eligible
+	if len(nodes) == 0 || !unhealthy {
+		return nil
+	}
+
+	sort.Strings(nodes)
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityMedium,
+		Type:        IssueTaintNotTolerated,
+		Resource:    resource,
+		Description: fmt.Sprintf("Daemon pods do not tolerate the taints on %d node(s)", len(nodes)),
+		Details:     map[string]interface{}{"nodes": nodes},
+	})
+	return taints
+}
+
+// tolerated reports whether any toleration tolerates a taint
+func tolerated(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// daemonSetRemediations suggests commands for the issues found
+func daemonSetRemediations(ds *appsv1.DaemonSet, issues []Issue, taints []corev1.Taint) []Remediation {
+	target := fmt.Sprintf("daemonset/%s -n %s", ds.Name, ds.Namespace)
+	remediations := []Remediation{
+		{
+			Title:       "Check rollout status",
+			Description: "See how many nodes are running the current daemon pod",
+			Command:     "kubectl rollout status " + target,
+			Confidence:  "High",
+			Safe:        true,
+		},
+		{
+			Title:       "List daemon pods by node",
+			Description: "Find the nodes where the daemon is missing or failing",
+			Command:     fmt.Sprintf("kubectl get pods -n %s -o wide -l %s", ds.Namespace, labels.FormatLabels(ds.Spec.Template.Labels)),
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	for _, taint := range taints {
+		toleration := fmt.Sprintf(`{"key":%q,"operator":"Exists","effect":%q}`, taint.Key, taint.Effect)
+		remediations = append(remediations, Remediation{
+			Title:       "Tolerate taint " + taint.ToString(),
+			Description: "Add a toleration if the daemon should run on the tainted nodes",
+			Command: fmt.Sprintf(`kubectl patch %s --type=json -p '%s'`,
+				target, tolerationPatch(podSpecPath(target), len(ds.Spec.Template.Spec.Tolerations) > 0, toleration)),
+			Confidence: "Medium",
+			Safe:       false,
+		})
+	}
+	if hasIssueType(issues, IssueDaemonSetMisscheduled, "daemonset/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Compare node selector with node labels",
+			Description: "Misscheduled pods run on nodes whose labels or taints changed after scheduling",
+			Command:     "kubectl get nodes --show-labels",
+			Confidence:  "Medium",
+			Safe:        true,
+		})
+	}
+
+	return remediations
+}
@@ -0,0 +1,433 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// revisionAnnotation records the rollout revision of a deployment's replicasets
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// diagnoseDeployment diagnoses a deployment's rollout, replicas and selector,
+// and rolls up the diagnostics of the pods it owns
+func (e *Engine) diagnoseDeployment(ctx context.Context, deploymentName string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceDeployments); err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	deployment := snapshot.Deployment(e.namespace, deploymentName)
+	if deployment == nil {
+		return nil, fmt.Errorf("deployment %s not found in namespace %s", deploymentName, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for deployment: %s", deploymentName),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	resource := "deployment/" + deploymentName
+
+	checkDeploymentConditions(report, deployment, resource)
+	checkUnavailableReplicas(report, deployment, resource)
+	revisions := e.checkRevisions(snapshot, report, deployment, resource)
+	checkSelector(snapshot, report, deployment, resource, revisions)
+
+	// Roll up pod diagnostics for the pods of every revision
+	if err := snapshot.Err(k8s.ResourceReplicaSets); err != nil {
+		report.AddWarning(fmt.Sprintf("ReplicaSets not inspected: %v", err))
+	}
+	podIssues := 0
+	for _, rs := range revisions {
+		for _, pod := range snapshot.PodsOwnedBy(rs) {
+			before := len(report.Issues)
+			e.analyzePod(ctx, snapshot, report, pod)
+			podIssues += len(report.Issues) - before
+		}
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = e.deploymentRemediations(ctx, deployment, report.Issues, revisions)
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for deployment: %s (%d/%d replicas available, %d revision(s), %d pod issue(s))",
+		deploymentName, deployment.Status.AvailableReplicas, desiredReplicas(deployment), len(revisions), podIssues)
+	return report, nil
+}
+
+// checkDeploymentConditions flags failed Progressing, Available and
+// ReplicaFailure conditions
+func checkDeploymentConditions(report *Report, deployment *appsv1.Deployment, resource string) {
+	for _, condition := range deployment.Status.Conditions {
+		switch {
+		case condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded":
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityCritical,
+				Type:        IssueRolloutStuck,
+				Resource:    resource,
+				Description: fmt.Sprintf("Rollout exceeded its progress deadline: %s", condition.Message),
+				Details:     map[string]interface{}{"condition": string(condition.Type), "reason": condition.Reason},
+			})
+		case condition.Type == appsv1.DeploymentAvailable && condition.Status == corev1.ConditionFalse:
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssueReplicasUnavailable,
+				Resource:    resource,
+				Description: fmt.Sprintf("Deployment does not have minimum availability (%s): %s", condition.Reason, condition.Message),
+				Details:     map[string]interface{}{"condition": string(condition.Type), "reason": condition.Reason},
+			})
+		case condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue:
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssueUnhealthyCondition,
+				Resource:    resource,
+				Description: fmt.Sprintf("ReplicaSet could not create pods (%s): %s", condition.Reason, condition.Message),
+				Details:     map[string]interface{}{"condition": string(condition.Type), "reason": condition.Reason},
+			})
+		}
+	}
+
+	if deployment.Spec.Paused {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueRolloutStuck,
+			Resource:    resource,
+			Description: "Rollout is paused; template changes will not be rolled out",
+		})
+	}
+
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityLow,
+			Type:     IssueRolloutStuck,
+			Resource: resource,
+			Description: fmt.Sprintf("Controller has not observed the latest spec (generation %d, observed %d)",
+				deployment.Generation, deployment.Status.ObservedGeneration),
+		})
+	}
+}
+
+// checkUnavailableReplicas compares unavailable replicas with the rolling
+// update's maxUnavailable budget
+func checkUnavailableReplicas(report *Report, deployment *appsv1.Deployment, resource string) {
+	unavailable := deployment.Status.UnavailableReplicas
+	if unavailable == 0 {
+		return
+	}
+
+	desired := desiredReplicas(deployment)
+	budget := maxUnavailable(deployment)
+	details := map[string]interface{}{
+		"desired":        desired,
+		"available":      deployment.Status.AvailableReplicas,
+		"unavailable":    unavailable,
+		"maxUnavailable": budget,
+	}
+
+	if unavailable > budget {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssueReplicasUnavailable,
+			Resource:    resource,
+			Description: fmt.Sprintf("%d of %d replicas unavailable, exceeding maxUnavailable of %d", unavailable, desired, budget),
+			Details:     details,
+		})
+		return
+	}
+
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityLow,
+		Type:        IssueReplicasUnavailable,
+		Resource:    resource,
+		Description: fmt.Sprintf("%d of %d replicas unavailable, within maxUnavailable of %d", unavailable, desired, budget),
+		Details:     details,
+	})
+}
+
+// desiredReplicas returns spec.replicas, which defaults to 1
+func desiredReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// maxUnavailable resolves the rolling update budget, rounding percentages
+// down like the deployment controller. Recreate deployments allow all
+// replicas to be unavailable.
+func maxUnavailable(deployment *appsv1.Deployment) int32 {
+	desired := desiredReplicas(deployment)
+	if deployment.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		return desired
+	}
+
+	value := intstr.FromString("25%")
+	if rolling := deployment.Spec.Strategy.RollingUpdate; rolling != nil && rolling.MaxUnavailable != nil {
+		value = *rolling.MaxUnavailable
+	}
+
+	budget, err := intstr.GetScaledValueFromIntOrPercent(&value, int(desired), false)
+	if err != nil {
+		return 0
+	}
+	return int32(budget)
+}
+
+// checkRevisions compares the deployment's replicasets across revisions and
+// flags a new revision that is stalled while old ones still serve. Old
+// revisions serving during a rollout that is still within its progress
+// deadline are normal. It returns the replicasets, newest revision first.
+func (e *Engine) checkRevisions(snapshot *k8s.Snapshot, report *Report, deployment *appsv1.Deployment, resource string) []*appsv1.ReplicaSet {
+	revisions := snapshot.ReplicaSetsOwnedBy(deployment)
+	sort.Slice(revisions, func(i, j int) bool {
+		return revision(revisions[i]) > revision(revisions[j])
+	})
+	if len(revisions) < 2 {
+		return revisions
+	}
+
+	newest := revisions[0]
+	var oldServing []string
+	var oldReplicas int32
+	for _, rs := range revisions[1:] {
+		if rs.Status.Replicas > 0 {
+			oldServing = append(oldServing, fmt.Sprintf("%d", revision(rs)))
+			oldReplicas += rs.Status.Replicas
+		}
+	}
+
+	wanted := int32(1)
+	if newest.Spec.Replicas != nil {
+		wanted = *newest.Spec.Replicas
+	}
+	if len(oldServing) == 0 || newest.Status.ReadyReplicas >= wanted || !rolloutStalled(deployment) {
+		return revisions
+	}
+
+	details := map[string]interface{}{
+		"newRevision":  revision(newest),
+		"newReady":     newest.Status.ReadyReplicas,
+		"oldRevisions": oldServing,
+		"oldReplicas":  oldReplicas,
+	}
+	if changes := imageChanges(revisions[1].Spec.Template.Spec, newest.Spec.Template.Spec); len(changes) > 0 {
+		details["imageChanges"] = changes
+	}
+
+	report.Issues = append(report.Issues, Issue{
+		Severity: SeverityHigh,
+		Type:     IssueRolloutStuck,
+		Resource: resource,
+		Description: fmt.Sprintf("New revision %d has %d/%d ready replicas while revision(s) %s still serve %d replica(s)",
+			revision(newest), newest.Status.ReadyReplicas, wanted, strings.Join(oldServing, ", "), oldReplicas),
+		Details: details,
+	})
+	return revisions
+}
+
+// rolloutStalled reports whether the deployment exceeded its progress
+// deadline, or made no progress for longer than it. The Progressing
+// condition is updated on every step, rollback and resume of a rollout.
+func rolloutStalled(deployment *appsv1.Deployment) bool {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type != appsv1.DeploymentProgressing {
+			continue
+		}
+		if condition.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+		if condition.LastUpdateTime.IsZero() {
+			return false
+		}
+
+		deadline := int32(600)
+		if deployment.Spec.ProgressDeadlineSeconds != nil {
+			deadline = *deployment.Spec.ProgressDeadlineSeconds
+		}
+		return now().Sub(condition.LastUpdateTime.Time) > time.Duration(deadline)*time.Second
+	}
+	return false
+}
+
+// revision returns a replicaset's rollout revision, or 0 if unknown
+func revision(rs *appsv1.ReplicaSet) int64 {
+	value, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// imageChanges describes container images that differ between two revisions
+func imageChanges(previous, current corev1.PodSpec) []string {
+	before := map[string]string{}
+	for _, c := range previous.Containers {
+		before[c.Name] = c.Image
+	}
+
+	var changes []string
+	for _, c := range current.Containers {
+		if old, ok := before[c.Name]; ok && old != c.Image {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", c.Name, old, c.Image))
+		}
+	}
+	return changes
+}
+
+// checkSelector flags selectors that don't match the pod template, match
+// no pods, or also match pods owned by something else
+func checkSelector(snapshot *k8s.Snapshot, report *Report, deployment *appsv1.Deployment, resource string, revisions []*appsv1.ReplicaSet) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityCritical,
+			Type:        IssueSelectorMismatch,
+			Resource:    resource,
+			Description: fmt.Sprintf("Invalid selector: %v", err),
+		})
+		return
+	}
+
+	if !selector.Matches(labels.Set(deployment.Spec.Template.Labels)) {
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityCritical,
+			Type:     IssueSelectorMismatch,
+			Resource: resource,
+			Description: fmt.Sprintf("Selector %s does not match the pod template labels %s",
+				selector, labels.Set(deployment.Spec.Template.Labels)),
+		})
+		return
+	}
+
+	owned := map[string]bool{}
+	for _, rs := range revisions {
+		for _, pod := range snapshot.PodsOwnedBy(rs) {
+			owned[pod.Name] = true
+		}
+	}
+
+	var foreign []string
+	matched := 0
+	for i := range snapshot.Pods {
+		pod := &snapshot.Pods[i]
+		if pod.Namespace != deployment.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		matched++
+		if !owned[pod.Name] {
+			foreign = append(foreign, pod.Name)
+		}
+	}
+
+	if len(foreign) > 0 {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueSelectorMismatch,
+			Resource:    resource,
+			Description: fmt.Sprintf("Selector %s also matches %d pod(s) not owned by this deployment", selector, len(foreign)),
+			Details:     map[string]interface{}{"pods": foreign},
+		})
+	}
+
+	if matched == 0 && desiredReplicas(deployment) > 0 {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssueSelectorMismatch,
+			Resource:    resource,
+			Description: fmt.Sprintf("No pods match selector %s", selector),
+		})
+	}
+}
+
+// deploymentRemediations suggests rollout commands for the issues found
+func (e *Engine) deploymentRemediations(ctx context.Context, deployment *appsv1.Deployment, issues []Issue, revisions []*appsv1.ReplicaSet) []Remediation {
+	target := fmt.Sprintf("deployment/%s -n %s", deployment.Name, deployment.Namespace)
+	remediations := []Remediation{
+		{
+			Title:       "Check rollout status",
+			Description: "Watch the rollout progress and see what it is waiting for",
+			Command:     "kubectl rollout status " + target,
+			Confidence:  "High",
+			Safe:        true,
+		},
+		{
+			Title:       "Review rollout history",
+			Description: "Compare the revisions of the pod template",
+			Command:     "kubectl rollout history " + target,
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	hasType := func(issueType IssueType) bool {
+		for _, issue := range issues {
+			if issue.Type == issueType && strings.HasPrefix(issue.Resource, "deployment/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if hasType(IssueRolloutStuck) && len(revisions) > 1 {
+		remediations = append(remediations, Remediation{
+			Title:       "Roll back to the previous revision",
+			Description: "Restore the last revision that was serving traffic",
+			Command:     fmt.Sprintf("kubectl rollout undo %s --to-revision=%d", target, revision(revisions[1])),
+			Confidence:  "Medium",
+			Safe:        false,
+		})
+	}
+	if deployment.Spec.Paused {
+		remediations = append(remediations, Remediation{
+			Title:       "Resume the rollout",
+			Description: "Continue rolling out the paused deployment",
+			Command:     "kubectl rollout resume " + target,
+			Confidence:  "High",
+			Safe:        false,
+		})
+	}
+	if hasType(IssueSelectorMismatch) {
+		remediations = append(remediations, Remediation{
+			Title:       "Compare selector and pod labels",
+			Description: "Make the selector match the template labels and no other workload's pods",
+			Command:     fmt.Sprintf("kubectl get pods -n %s --show-labels", deployment.Namespace),
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+	for _, issue := range issues {
+		if !strings.HasPrefix(issue.Resource, "deployment/") {
+			remediations = append(remediations, Remediation{
+				Title:       "Check pod logs",
+				Description: "Inspect the logs of the deployment's pods for errors",
+				Command:     "kubectl logs " + target + " --all-containers",
+				Confidence:  "High",
+				Safe:        true,
+			})
+			break
+		}
+	}
+	if needsCapacity(issues) {
+		if remediation, ok := capacityRemediation(e.detectClusterType(ctx)); ok {
+			remediations = append(remediations, remediation)
+		}
+	}
+
+	return remediations
+}
@@ -0,0 +1,134 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDiagnoseDeploymentStuckRollout(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/deployment-stuck.yaml")
+
+	report, err := engine.DiagnoseResource("deploy", "api")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	has := func(issueType IssueType, resource string, severity Severity, text string) bool {
+		for _, issue := range report.Issues {
+			if issue.Type == issueType && issue.Resource == resource && issue.Severity == severity &&
+				strings.Contains(issue.Description, text) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !has(IssueRolloutStuck, "deployment/api", SeverityCritical, "progress deadline") {
+		t.Errorf("expected a critical ProgressDeadlineExceeded issue, got %+v", report.Issues)
+	}
+	if !has(IssueReplicasUnavailable, "deployment/api", SeverityHigh, "exceeding maxUnavailable of 0") {
+		t.Errorf("expected unavailable replicas to exceed maxUnavailable, got %+v", report.Issues)
+	}
+	if !has(IssueSelectorMismatch, "deployment/api", SeverityMedium, "1 pod(s) not owned") {
+		t.Errorf("expected the debug pod to be reported as a selector overlap, got %+v", report.Issues)
+	}
+	if !has(IssueCrashLoopBackOff, "api-7f9c8d-bbbbb/api", SeverityCritical, "") {
+		t.Errorf("expected the new revision's crash-looping pod to be rolled up, got %+v", report.Issues)
+	}
+
+	var revisions Issue
+	for _, issue := range report.Issues {
+		if issue.Type == IssueRolloutStuck && issue.Details["newRevision"] != nil {
+			revisions = issue
+		}
+	}
+	if revisions.Details == nil {
+		t.Fatalf("expected a revision comparison issue, got %+v", report.Issues)
+	}
+	if changes, _ := revisions.Details["imageChanges"].([]string); len(changes) != 1 || changes[0] != "api: example.com/api:1.4.2 -> example.com/api:1.5.0" {
+		t.Errorf("imageChanges = %v", revisions.Details["imageChanges"])
+	}
+
+	var undo bool
+	for _, remediation := range report.Remediations {
+		if remediation.Command == "kubectl rollout undo deployment/api -n payments --to-revision=1" {
+			undo = true
+		}
+	}
+	if !undo {
+		t.Errorf("expected a rollback remediation, got %+v", report.Remediations)
+	}
+}
+
+func TestDiagnoseDeploymentRollingUpdate(t *testing.T) {
+	defer func(saved func() time.Time) { now = saved }(now)
+
+	tests := []struct {
+		name    string
+		fixture string
+		now     time.Time
+		stuck   bool
+	}{
+		{name: "within progress deadline", fixture: "testdata/deployment-rolling.yaml", now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{name: "past progress deadline", fixture: "testdata/deployment-rolling.yaml", now: time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC), stuck: true},
+		// Rolling back reuses the old replicaset, created long ago
+		{name: "rolled back", fixture: "testdata/deployment-rollback.yaml", now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{name: "rolled back past progress deadline", fixture: "testdata/deployment-rollback.yaml", now: time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC), stuck: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = func() time.Time { return tt.now }
+			engine := newTestEngine(t, "shop", tt.fixture)
+
+			report, err := engine.DiagnoseResource("deployment", "web")
+			if err != nil {
+				t.Fatalf("DiagnoseResource: %v", err)
+			}
+
+			var stuck bool
+			for _, issue := range report.Issues {
+				if issue.Type == IssueRolloutStuck {
+					stuck = true
+				}
+			}
+			if stuck != tt.stuck {
+				t.Errorf("rollout stuck = %v, want %v: %+v", stuck, tt.stuck, report.Issues)
+			}
+		})
+	}
+}
+
+func TestDiagnoseDeploymentNotFound(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/deployment-stuck.yaml")
+
+	if _, err := engine.DiagnoseResource("deployment", "missing"); err == nil {
+		t.Error("expected an error for a missing deployment")
+	}
+}
+
+func TestMaxUnavailable(t *testing.T) {
+	replicas := int32(10)
+	two := intstr.FromInt(2)
+	percent := intstr.FromString("15%")
+
+	tests := []struct {
+		strategy appsv1.DeploymentStrategy
+		want     int32
+	}{
+		{appsv1.DeploymentStrategy{}, 2},
+		{appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{MaxUnavailable: &two}}, 2},
+		{appsv1.DeploymentStrategy{RollingUpdate: &appsv1.RollingUpdateDeployment{MaxUnavailable: &percent}}, 1},
+		{appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}, 10},
+	}
+
+	for _, tt := range tests {
+		deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: &replicas, Strategy: tt.strategy}}
+		if got := maxUnavailable(deployment); got != tt.want {
+			t.Errorf("maxUnavailable(%+v) = %d, want %d", tt.strategy, got, tt.want)
+		}
+	}
+}
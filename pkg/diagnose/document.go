@@ -0,0 +1,282 @@
+package diagnose
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s-pilot/pkg/output"
+)
+
+// Document kinds
+const (
+	KindReport             = "DiagnosticReport"
+	KindMultiClusterReport = "MultiClusterReport"
+)
+
+// ReportDocument is the versioned machine-readable form of a Report
+type ReportDocument struct {
+	APIVersion   string                `json:"api_version"`
+	Kind         string                `json:"kind"`
+	Summary      string                `json:"summary"`
+	HealthScore  int                   `json:"health_score"`
+	Score        *ScoreDocument        `json:"score,omitempty"`
+	Warnings     []string              `json:"warnings,omitempty"`
+	Issues       []IssueDocument       `json:"issues"`
+	RootCauses   []RootCauseDocument   `json:"root_causes,omitempty"`
+	Remediations []RemediationDocument `json:"remediations"`
+}
+
+// IssueDocument is an issue with its details
+type IssueDocument struct {
+	Severity    Severity               `json:"severity"`
+	Type        IssueType              `json:"type"`
+	Resource    string                 `json:"resource"`
+	Description string                 `json:"description"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// RemediationDocument is a suggested fix
+type RemediationDocument struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+	Confidence  string `json:"confidence"`
+	Safe        bool   `json:"safe"`
+}
+
+// RootCauseDocument is a probable root cause and the resources it affects
+type RootCauseDocument struct {
+	Resource  string                     `json:"resource"`
+	Namespace string                     `json:"namespace,omitempty"`
+	Score     int                        `json:"score"`
+	Issues    []IssueDocument            `json:"issues"`
+	Affected  []AffectedResourceDocument `json:"affected,omitempty"`
+}
+
+// AffectedResourceDocument is a resource failing because of a root cause
+type AffectedResourceDocument struct {
+	Resource  string          `json:"resource"`
+	Namespace string          `json:"namespace,omitempty"`
+	Issues    []IssueDocument `json:"issues"`
+}
+
+// ScoreDocument explains the health score
+type ScoreDocument struct {
+	Categories []ScoreGroupDocument `json:"categories"`
+	Namespaces []ScoreGroupDocument `json:"namespaces"`
+}
+
+// ScoreGroupDocument is the health of a category or namespace
+type ScoreGroupDocument struct {
+	Name      string  `json:"name"`
+	Score     int     `json:"score"`
+	Resources int     `json:"resources"`
+	Affected  int     `json:"affected"`
+	Weight    float64 `json:"weight,omitempty"`
+}
+
+// MultiClusterReportDocument is the machine-readable form of a
+// MultiClusterReport
+type MultiClusterReportDocument struct {
+	APIVersion string                  `json:"api_version"`
+	Kind       string                  `json:"kind"`
+	Clusters   []ClusterReportDocument `json:"clusters"`
+}
+
+// ClusterReportDocument is the report, or the error, for one context
+type ClusterReportDocument struct {
+	Context string          `json:"context"`
+	Error   string          `json:"error,omitempty"`
+	Report  *ReportDocument `json:"report,omitempty"`
+}
+
+// Document returns the report's machine-readable form
+func (r *Report) Document() *ReportDocument {
+	doc := &ReportDocument{
+		APIVersion:   output.APIVersion,
+		Kind:         KindReport,
+		Summary:      r.Summary,
+		HealthScore:  r.HealthScore,
+		Warnings:     r.Warnings,
+		Issues:       issueDocuments(r.Issues),
+		Remediations: remediationDocuments(r.Remediations),
+	}
+	if r.Score != nil {
+		doc.Score = &ScoreDocument{
+			Categories: scoreGroupDocuments(r.Score.Categories),
+			Namespaces: scoreGroupDocuments(r.Score.Namespaces),
+		}
+	}
+	for _, cause := range r.RootCauses {
+		causeDoc := RootCauseDocument{
+			Resource:  cause.Resource,
+			Namespace: cause.Namespace,
+			Score:     cause.Score,
+			Issues:    issueDocuments(cause.Issues),
+		}
+		for _, affected := range cause.Affected {
+			causeDoc.Affected = append(causeDoc.Affected, AffectedResourceDocument{
+				Resource:  affected.Resource,
+				Namespace: affected.Namespace,
+				Issues:    issueDocuments(affected.Issues),
+			})
+		}
+		doc.RootCauses = append(doc.RootCauses, causeDoc)
+	}
+	return doc
+}
+
+// Document returns the merged report's machine-readable form
+func (m *MultiClusterReport) Document() *MultiClusterReportDocument {
+	doc := &MultiClusterReportDocument{APIVersion: output.APIVersion, Kind: KindMultiClusterReport, Clusters: []ClusterReportDocument{}}
+	for _, cluster := range m.Clusters {
+		clusterDoc := ClusterReportDocument{Context: cluster.Context}
+		if cluster.Err != nil {
+			clusterDoc.Error = cluster.Err.Error()
+		} else {
+			clusterDoc.Report = cluster.Report.Document()
+		}
+		doc.Clusters = append(doc.Clusters, clusterDoc)
+	}
+	return doc
+}
+
+// issueDocuments converts issues, including their details
+func issueDocuments(issues []Issue) []IssueDocument {
+	docs := make([]IssueDocument, 0, len(issues))
+	for _, issue := range issues {
+		var details map[string]interface{}
+		if len(issue.Details) > 0 {
+			details = make(map[string]interface{}, len(issue.Details))
+			for key, value := range issue.Details {
+				if remediations, ok := value.([]Remediation); ok {
+					value = remediationDocuments(remediations)
+				}
+				details[key] = value
+			}
+		}
+		docs = append(docs, IssueDocument{
+			Severity:    issue.Severity,
+			Type:        issue.Type,
+			Resource:    issue.Resource,
+			Description: issue.Description,
+			Details:     details,
+		})
+	}
+	return docs
+}
+
+// remediationDocuments converts remediations
+func remediationDocuments(remediations []Remediation) []RemediationDocument {
+	docs := make([]RemediationDocument, 0, len(remediations))
+	for _, r := range remediations {
+		docs = append(docs, RemediationDocument{
+			Title:       r.Title,
+			Description: r.Description,
+			Command:     r.Command,
+			Confidence:  r.Confidence,
+			Safe:        r.Safe,
+		})
+	}
+	return docs
+}
+
+// scoreGroupDocuments converts score groups
+func scoreGroupDocuments(groups []ScoreGroup) []ScoreGroupDocument {
+	docs := make([]ScoreGroupDocument, 0, len(groups))
+	for _, group := range groups {
+		docs = append(docs, ScoreGroupDocument{
+			Name:      group.Name,
+			Score:     group.Score,
+			Resources: group.Resources,
+			Affected:  group.Affected,
+			Weight:    group.Weight,
+		})
+	}
+	return docs
+}
+
+// Markdown writes the report as markdown, e.g. for an incident ticket
+func (r *Report) Markdown(w io.Writer) {
+	fmt.Fprintln(w, "# Diagnostic Report")
+	r.markdown(w, "##")
+}
+
+// Markdown writes the merged report as markdown, one section per cluster
+func (m *MultiClusterReport) Markdown(w io.Writer) {
+	fmt.Fprintln(w, "# Multi-Cluster Diagnostic Report")
+	fmt.Fprintf(w, "\nDiagnosed %d cluster(s): %d issue(s) found, %d cluster(s) failed\n",
+		len(m.Clusters), m.TotalIssues(), m.Failed())
+	for _, cluster := range m.Clusters {
+		fmt.Fprintf(w, "\n## Cluster: %s\n", cluster.Context)
+		if cluster.Err != nil {
+			fmt.Fprintf(w, "\n**Diagnostics failed:** %v\n", cluster.Err)
+			continue
+		}
+		cluster.Report.markdown(w, "###")
+	}
+}
+
+// markdown writes the report's sections at the given heading level
+func (r *Report) markdown(w io.Writer, heading string) {
+	fmt.Fprintf(w, "\n%s\n\n**Health score:** %d/100\n", r.Summary, r.HealthScore)
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintf(w, "\n%s Limited diagnostics\n\n", heading)
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(w, "- %s\n", warning)
+		}
+	}
+
+	if len(r.Issues) == 0 {
+		fmt.Fprintln(w, "\nNo issues detected.")
+		return
+	}
+
+	if r.Score != nil {
+		fmt.Fprintf(w, "\n%s Score breakdown\n\n", heading)
+		fmt.Fprintln(w, "| Category | Score | Affected | Weight |")
+		fmt.Fprintln(w, "|---|---|---|---|")
+		for _, group := range r.Score.Categories {
+			fmt.Fprintf(w, "| %s | %d/100 | %d of %d | %g |\n", group.Name, group.Score, group.Affected, group.Resources, group.Weight)
+		}
+		fmt.Fprintln(w, "\n| Namespace | Score | Affected |")
+		fmt.Fprintln(w, "|---|---|---|")
+		for _, group := range r.Score.Namespaces {
+			fmt.Fprintf(w, "| %s | %d/100 | %d of %d |\n", output.Cell(group.Name), group.Score, group.Affected, group.Resources)
+		}
+	}
+
+	if r.correlated() {
+		fmt.Fprintf(w, "\n%s Probable root causes\n", heading)
+		for i, cause := range r.RootCauses {
+			primary := cause.Primary()
+			fmt.Fprintf(w, "\n%d. **[%s] %s** `%s`: %s\n", i+1, primary.Severity, primary.Type, cause.Resource, primary.Description)
+			for _, affected := range cause.Affected {
+				var types []string
+				for _, issue := range affected.Issues {
+					types = appendUnique(types, string(issue.Type))
+				}
+				fmt.Fprintf(w, "   - `%s`: %s\n", affected.Resource, strings.Join(types, ", "))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\n%s Issues\n\n", heading)
+	fmt.Fprintln(w, "| # | Severity | Type | Resource | Description |")
+	fmt.Fprintln(w, "|---|---|---|---|---|")
+	for i, issue := range r.Issues {
+		fmt.Fprintf(w, "| %d | %s | %s | `%s` | %s |\n", i+1, issue.Severity, issue.Type, output.Cell(issue.Resource), output.Cell(issue.Description))
+	}
+
+	if len(r.Remediations) > 0 {
+		fmt.Fprintf(w, "\n%s Recommended fixes\n", heading)
+		for i, remedy := range r.Remediations {
+			fmt.Fprintf(w, "\n%d. **%s** (confidence: %s)\n\n   %s\n", i+1, remedy.Title, remedy.Confidence, remedy.Description)
+			if remedy.Command != "" {
+				fmt.Fprintf(w, "\n   ```sh\n   %s\n   ```\n", strings.ReplaceAll(remedy.Command, "\n", "\n   "))
+			}
+		}
+	}
+}
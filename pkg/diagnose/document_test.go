@@ -0,0 +1,91 @@
+package diagnose
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/output"
+)
+
+func TestReportDocument(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/correlation.yaml")
+	report, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := output.Encode(&buf, output.FormatJSON, report.Document()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var doc ReportDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if doc.APIVersion != output.APIVersion || doc.Kind != KindReport {
+		t.Errorf("header = %s %s, want %s %s", doc.APIVersion, doc.Kind, output.APIVersion, KindReport)
+	}
+	if len(doc.Issues) != len(report.Issues) || len(doc.RootCauses) != 3 || doc.Score == nil {
+		t.Fatalf("document = %+v, want every issue, root cause and the score", doc)
+	}
+	var missing *IssueDocument
+	for i := range doc.Issues {
+		if doc.Issues[i].Type == IssueMissingReference {
+			missing = &doc.Issues[i]
+		}
+	}
+	if missing == nil || missing.Details["name"] != "reports-config" {
+		t.Errorf("expected the missing config map with its details, got %+v", missing)
+	}
+	if doc.RootCauses[0].Resource != "node/worker-1" || len(doc.RootCauses[0].Affected) != 2 {
+		t.Errorf("root cause = %+v, want the node with its two pods", doc.RootCauses[0])
+	}
+}
+
+// Every fixture's report must encode, whatever its issues' details hold
+func TestReportDocumentsEncode(t *testing.T) {
+	fixtures, _ := filepath.Glob("testdata/*.yaml")
+	for _, fixture := range fixtures {
+		engine := newTestEngine(t, "default", fixture)
+		engine.allNamespaces = true
+		report, err := engine.DiagnoseCluster()
+		if err != nil {
+			t.Fatalf("%s: DiagnoseCluster: %v", fixture, err)
+		}
+		for _, format := range []output.Format{output.FormatJSON, output.FormatYAML} {
+			if err := output.Encode(&bytes.Buffer{}, format, report.Document()); err != nil {
+				t.Errorf("%s: Encode(%s): %v", fixture, format, err)
+			}
+		}
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	report := &Report{
+		Summary:     "Found 1 issue(s) across 2 pods",
+		HealthScore: 75,
+		Issues: []Issue{{
+			Severity: SeverityHigh, Type: IssueCrashLoopBackOff, Resource: "pod/api-0",
+			Description: "Container app | exits with code 1",
+		}},
+		Remediations: []Remediation{{Title: "Check logs", Description: "Inspect the previous logs", Command: "kubectl logs api-0 --previous", Confidence: "High"}},
+	}
+
+	var buf bytes.Buffer
+	report.Markdown(&buf)
+	for _, want := range []string{
+		"# Diagnostic Report",
+		"**Health score:** 75/100",
+		"| 1 | high | CrashLoopBackOff | `pod/api-0` | Container app \\| exits with code 1 |",
+		"1. **Check logs** (confidence: High)",
+		"   kubectl logs api-0 --previous",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("markdown missing %q:\n%s", want, buf.String())
+		}
+	}
+}
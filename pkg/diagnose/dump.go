@@ -0,0 +1,36 @@
+package diagnose
+
+import (
+	"fmt"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewEngineFromDump creates a diagnostics engine that analyzes a dump
+// instead of a live cluster. Without a namespace, the namespace the dump was
+// captured in is diagnosed, or every namespace for cluster-wide dumps.
+func NewEngineFromDump(dump *k8s.Dump, namespace string, allNamespaces bool) (*Engine, error) {
+	if namespace == "" && !allNamespaces {
+		if dump.Metadata != nil {
+			namespace = dump.Metadata.Namespace
+		}
+		allNamespaces = namespace == ""
+	}
+
+	aiProvider, err := ai.NewProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI provider: %w", err)
+	}
+
+	engine := NewEngineWithClient(k8s.NewClientFromDump(dump, namespace), aiProvider, namespace, allNamespaces)
+	snapshotNamespace := engine.namespace
+	if allNamespaces {
+		snapshotNamespace = metav1.NamespaceAll
+	}
+	engine.SetSnapshot(dump.Snapshot(snapshotNamespace))
+	engine.SetClusterType(k8s.ClassifyCluster(dump.ClusterSignals()))
+	return engine, nil
+}
@@ -0,0 +1,64 @@
+package diagnose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+func TestDiagnoseFromDump(t *testing.T) {
+	dir := t.TempDir()
+	fixture, err := os.ReadFile("testdata/crashloop.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := filepath.Join(dir, "logs", "payments", "api-7d9f8b-x2k4p")
+	if err := os.MkdirAll(logs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pods.yaml"), fixture, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logs, "api.previous.log"), []byte("panic: connection refused\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := k8s.LoadDump(dir)
+	if err != nil {
+		t.Fatalf("LoadDump: %v", err)
+	}
+	engine, err := NewEngineFromDump(dump, "payments", false)
+	if err != nil {
+		t.Fatalf("NewEngineFromDump: %v", err)
+	}
+
+	report, err := engine.DiagnoseResource("pod", "api-7d9f8b-x2k4p")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	var crashLoop *Issue
+	for i := range report.Issues {
+		if report.Issues[i].Type == IssueCrashLoopBackOff && report.Issues[i].Severity == SeverityCritical {
+			crashLoop = &report.Issues[i]
+		}
+	}
+	if crashLoop == nil {
+		t.Fatalf("no CrashLoopBackOff issue in %+v", report.Issues)
+	}
+	if events, _ := crashLoop.Details["events"].([]string); len(events) != 1 {
+		t.Errorf("events = %v, want the BackOff warning from the dump", events)
+	}
+	if crashLoop.Details["logs"] != "panic: connection refused" {
+		t.Errorf("logs = %v, want the previous container's logs from the dump", crashLoop.Details["logs"])
+	}
+
+	cluster, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+	if !hasIssue(cluster, IssueCrashLoopBackOff, "pod/api-7d9f8b-x2k4p", SeverityHigh, "Restarts=12") {
+		t.Errorf("cluster report is missing the crash loop: %+v", cluster.Issues)
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"k8s-pilot/pkg/ai"
 	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+	"k8s-pilot/pkg/rules"
 )
 
 // Engine performs diagnostics on Kubernetes resources
@@ -15,22 +20,44 @@ type Engine struct {
 	aiProvider    ai.Provider
 	namespace     string
 	allNamespaces bool
+	snapshot      *k8s.Snapshot
+	clusterType   k8s.ClusterType
+	ruleSet       *rules.Set
+	weights       *ScoreWeights
 }
 
 // NewEngine creates a new diagnostics engine
-func NewEngine(namespace string, allNamespaces bool) *Engine {
-	k8sClient, err := k8s.NewClient(namespace)
+func NewEngine(namespace string, allNamespaces bool) (*Engine, error) {
+	return NewEngineWithOptions(namespace, allNamespaces, k8s.DefaultOptions())
+}
+
+// NewEngineWithOptions creates a diagnostics engine for a specific
+// kubeconfig, context or identity
+func NewEngineWithOptions(namespace string, allNamespaces bool, opts k8s.Options) (*Engine, error) {
+	k8sClient, err := k8s.NewClientWithOptions(namespace, opts)
 	if err != nil {
-		// Handle error appropriately
-		panic(err)
+		return nil, err
 	}
-	
+
 	// Initialize AI provider
 	aiConfig := &ai.Config{
 		Provider: ai.ProviderMock,
 	}
-	aiProvider, _ := ai.NewProvider(aiConfig)
-	
+	aiProvider, err := ai.NewProvider(aiConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI provider: %w", err)
+	}
+
+	return NewEngineWithClient(k8sClient, aiProvider, namespace, allNamespaces), nil
+}
+
+// NewEngineWithClient creates a diagnostics engine on an existing client
+// and AI provider
+func NewEngineWithClient(k8sClient *k8s.Client, aiProvider ai.Provider, namespace string, allNamespaces bool) *Engine {
+	if namespace == "" {
+		namespace = k8sClient.Namespace()
+	}
+
 	return &Engine{
 		k8sClient:     k8sClient,
 		aiProvider:    aiProvider,
@@ -39,12 +66,84 @@ func NewEngine(namespace string, allNamespaces bool) *Engine {
 	}
 }
 
+// SetSnapshot makes the engine analyze an existing snapshot instead of
+// fetching one from the API server
+func (e *Engine) SetSnapshot(snapshot *k8s.Snapshot) {
+	e.snapshot = snapshot
+}
+
+// Snapshot returns the snapshot analyzed by the engine, if one was loaded
+func (e *Engine) Snapshot() *k8s.Snapshot {
+	return e.snapshot
+}
+
+// SetClusterType sets the cluster distribution instead of detecting it
+func (e *Engine) SetClusterType(clusterType k8s.ClusterType) {
+	e.clusterType = clusterType
+}
+
+// detectClusterType identifies the distribution once, so remediations can
+// use its tooling
+func (e *Engine) detectClusterType(ctx context.Context) k8s.ClusterType {
+	if e.clusterType == "" {
+		clusterType, err := e.k8sClient.DetectClusterType(ctx)
+		if err != nil {
+			clusterType = k8s.ClusterTypeUnknown
+		}
+		e.clusterType = clusterType
+	}
+	return e.clusterType
+}
+
+// APIMetrics returns the API calls made by the engine's client
+func (e *Engine) APIMetrics() *k8s.APIMetrics {
+	return e.k8sClient.Metrics()
+}
+
+// loadSnapshot fetches the cluster state once; every analyzer reads from it
+func (e *Engine) loadSnapshot(ctx context.Context) (*k8s.Snapshot, error) {
+	if e.snapshot != nil {
+		return e.snapshot, nil
+	}
+
+	namespace := e.namespace
+	if e.allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	snapshot, err := e.k8sClient.Snapshot(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	e.snapshot = snapshot
+	return snapshot, nil
+}
+
 // Report represents a diagnostic report
 type Report struct {
 	Summary       string
 	Issues        []Issue
 	Remediations  []Remediation
 	HealthScore   int
+	Warnings      []string
+	// RootCauses groups the issues under their probable root causes,
+	// most likely first
+	RootCauses    []RootCause
+	// Score explains how HealthScore was computed
+	Score         *ScoreBreakdown
+	// examined lists the resources checked, issues or not
+	examined      []ref
+}
+
+// AddWarning records a limitation of the diagnostics, such as data that
+// could not be collected, ignoring duplicates
+func (r *Report) AddWarning(warning string) {
+	for _, existing := range r.Warnings {
+		if existing == warning {
+			return
+		}
+	}
+	r.Warnings = append(r.Warnings, warning)
 }
 
 // Issue represents a detected issue
@@ -85,47 +184,214 @@ const (
 	IssueProbeFailure      IssueType = "ProbeFailure"
 	IssuePVCPending        IssueType = "PVCPending"
 	IssueResourceConstraint IssueType = "ResourceConstraint"
+	IssueUnhealthyCondition IssueType = "UnhealthyCondition"
+	IssueRolloutStuck       IssueType = "RolloutStuck"
+	IssueReplicasUnavailable IssueType = "ReplicasUnavailable"
+	IssueSelectorMismatch   IssueType = "SelectorMismatch"
+	IssueOrdinalStalled     IssueType = "OrdinalStalled"
+	IssueDaemonSetUnavailable IssueType = "DaemonSetUnavailable"
+	IssueDaemonSetMisscheduled IssueType = "DaemonSetMisscheduled"
+	IssueTaintNotTolerated  IssueType = "TaintNotTolerated"
+	IssueJobFailed          IssueType = "JobFailed"
+	IssueJobDeadlineExceeded IssueType = "JobDeadlineExceeded"
+	IssueSuspended          IssueType = "Suspended"
+	IssueMissedSchedule     IssueType = "MissedSchedule"
+	IssueConcurrencyPileup  IssueType = "ConcurrencyPileup"
+	IssueNoEndpoints        IssueType = "NoEndpoints"
+	IssueBackendNotReady    IssueType = "BackendNotReady"
+	IssuePortMismatch       IssueType = "PortMismatch"
+	IssueLoadBalancerPending IssueType = "LoadBalancerPending"
+	IssueMissingBackend     IssueType = "MissingBackend"
+	IssueMissingTLSSecret   IssueType = "MissingTLSSecret"
+	IssueNetworkPolicyIsolation IssueType = "NetworkPolicyIsolation"
+	IssueVolumeMountFailure IssueType = "VolumeMountFailure"
+	IssueVolumeNodeConflict IssueType = "VolumeNodeConflict"
+	IssueCSIDriverUnavailable IssueType = "CSIDriverUnavailable"
+	IssueNodeNotReady       IssueType = "NodeNotReady"
+	IssueNodePressure       IssueType = "NodePressure"
+	IssueNodeCordoned       IssueType = "NodeCordoned"
+	IssueNodeTainted        IssueType = "NodeTainted"
+	IssueVersionSkew        IssueType = "VersionSkew"
+	IssueNodeCapacity       IssueType = "NodeCapacity"
+	IssueEvictionStorm      IssueType = "EvictionStorm"
+	IssueFailedScheduling   IssueType = "FailedScheduling"
+	IssueOOMKilled          IssueType = "OOMKilled"
+	IssueContainerTerminated IssueType = "ContainerTerminated"
+	IssueInitContainerFailed IssueType = "InitContainerFailed"
+	IssueInitContainerBlocked IssueType = "InitContainerBlocked"
+	IssueSidecarFailed      IssueType = "SidecarFailed"
+	IssueEphemeralContainerFailed IssueType = "EphemeralContainerFailed"
 )
 
-// DiagnoseResource diagnoses a specific resource
+// DiagnoseResource diagnoses a specific resource. Any resource the cluster
+// serves, including custom resources, can be addressed by kind, plural or
+// short name.
 func (e *Engine) DiagnoseResource(resourceType, resourceName string) (*Report, error) {
-    ctx := context.Background()
-
-    switch strings.ToLower(resourceType) {
-    case "pod", "pods":
-        return e.diagnosePod(ctx, resourceName)
-
-    case "deployment", "deployments":
-        return e.diagnoseDeployment(ctx, resourceName)
+	report, err := e.diagnoseResource(resourceType, resourceName)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if err := e.applyResourceRules(ctx, report, resourceType, resourceName); err != nil {
+		report.AddWarning(fmt.Sprintf("Custom rules not evaluated: %v", err))
+	}
+	e.correlate(ctx, report)
+	e.score(ctx, report, e.resourcePopulation(resourceType, resourceName))
+	return report, nil
+}
 
-    default:
-        return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
-    }
+// diagnoseResource runs the built-in checks for a specific resource
+func (e *Engine) diagnoseResource(resourceType, resourceName string) (*Report, error) {
+	ctx := context.Background()
+	
+	switch strings.ToLower(resourceType) {
+	case "pod", "pods":
+		return e.diagnosePod(ctx, resourceName)
+	case "deployment", "deployments":
+		return e.diagnoseDeployment(ctx, resourceName)
+	case "statefulset", "statefulsets":
+		return e.diagnoseStatefulSet(ctx, resourceName)
+	case "daemonset", "daemonsets":
+		return e.diagnoseDaemonSet(ctx, resourceName)
+	case "job", "jobs":
+		return e.diagnoseJob(ctx, resourceName)
+	case "cronjob", "cronjobs":
+		return e.diagnoseCronJob(ctx, resourceName)
+	case "service", "services", "svc":
+		return e.diagnoseService(ctx, resourceName)
+	case "ingress", "ingresses", "ing":
+		return e.diagnoseIngress(ctx, resourceName)
+	case "pvc", "pvcs", "persistentvolumeclaim", "persistentvolumeclaims":
+		return e.diagnosePVC(ctx, resourceName)
+	case "node", "nodes", "no":
+		return e.diagnoseNode(ctx, resourceName)
+	}
+	
+	mapping, err := e.resolveKind(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	
+	switch {
+	case isResource(mapping, "", "pods"):
+		return e.diagnosePod(ctx, resourceName)
+	case isResource(mapping, "apps", "deployments"):
+		return e.diagnoseDeployment(ctx, resourceName)
+	case isResource(mapping, "apps", "statefulsets"):
+		return e.diagnoseStatefulSet(ctx, resourceName)
+	case isResource(mapping, "apps", "daemonsets"):
+		return e.diagnoseDaemonSet(ctx, resourceName)
+	case isResource(mapping, "batch", "jobs"):
+		return e.diagnoseJob(ctx, resourceName)
+	case isResource(mapping, "batch", "cronjobs"):
+		return e.diagnoseCronJob(ctx, resourceName)
+	case isResource(mapping, "", "services"):
+		return e.diagnoseService(ctx, resourceName)
+	case isResource(mapping, "networking.k8s.io", "ingresses"):
+		return e.diagnoseIngress(ctx, resourceName)
+	case isResource(mapping, "", "persistentvolumeclaims"):
+		return e.diagnosePVC(ctx, resourceName)
+	case isResource(mapping, "", "nodes"):
+		return e.diagnoseNode(ctx, resourceName)
+	default:
+		return e.diagnoseObject(ctx, mapping, resourceName)
+	}
 }
 
 // DiagnoseResourceType diagnoses all resources of a type
 func (e *Engine) DiagnoseResourceType(resourceType string) (*Report, error) {
+	report, err := e.diagnoseResourceType(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if err := e.applyResourceRules(ctx, report, resourceType, ""); err != nil {
+		report.AddWarning(fmt.Sprintf("Custom rules not evaluated: %v", err))
+	}
+	e.correlate(ctx, report)
+	e.score(ctx, report, e.typePopulation(ctx, resourceType))
+	return report, nil
+}
+
+// diagnoseResourceType runs the built-in checks for all resources of a type
+func (e *Engine) diagnoseResourceType(resourceType string) (*Report, error) {
 	ctx := context.Background()
 	
 	switch strings.ToLower(resourceType) {
 	case "pod", "pods":
 		return e.diagnoseAllPods(ctx)
-	default:
-		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
+	case "node", "nodes", "no":
+		return e.diagnoseNodes(ctx)
+	}
+	
+	mapping, err := e.resolveKind(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	
+	switch {
+	case isResource(mapping, "", "pods"):
+		return e.diagnoseAllPods(ctx)
+	case isResource(mapping, "", "nodes"):
+		return e.diagnoseNodes(ctx)
 	}
+	return e.diagnoseObjects(ctx, mapping)
 }
 
-// DiagnoseCluster diagnoses the entire cluster/namespace
+// DiagnoseCluster diagnoses the entire cluster/namespace and groups the
+// issues under their probable root causes
 func (e *Engine) DiagnoseCluster() (*Report, error) {
+	report, err := e.diagnoseCluster()
+	if err != nil {
+		return nil, err
+	}
 	ctx := context.Background()
-	return e.diagnoseAllPods(ctx)
+	e.correlate(ctx, report)
+	e.score(ctx, report, e.clusterPopulation(ctx))
+	return report, nil
+}
+
+// diagnoseCluster runs the built-in checks and custom rules on every pod,
+// claim and node
+func (e *Engine) diagnoseCluster() (*Report, error) {
+	ctx := context.Background()
+	report, err := e.diagnoseAllPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if added := e.applyRules(ctx, snapshot, report, "", e.ruleSet.Kinds()...); added > 0 {
+		refreshSummary(report, added)
+	}
+	
+	// Node problems explain many pod problems, so check them alongside
+	if snapshot.Err(k8s.ResourceNodes) != nil || len(snapshot.Nodes) == 0 {
+		return report, nil
+	}
+	unhealthy := analyzeNodes(snapshot, report)
+	if unhealthy > 0 {
+		report.Remediations = append(report.Remediations, nodeRemediations(report.Issues)...)
+	}
+	
+	report.Summary = fmt.Sprintf("Found %d issue(s) across %d pods and %d nodes",
+		len(report.Issues), len(snapshot.Pods), len(snapshot.Nodes))
+	return report, nil
 }
 
 // diagnosePod diagnoses a specific pod
 func (e *Engine) diagnosePod(ctx context.Context, podName string) (*Report, error) {
-	pod, err := e.k8sClient.GetPod(ctx, podName, e.namespace)
+	snapshot, err := e.loadSnapshot(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pod: %w", err)
+		return nil, err
+	}
+	
+	pod := snapshot.Pod(e.namespace, podName)
+	if pod == nil {
+		return nil, fmt.Errorf("pod %s not found in namespace %s", podName, e.namespace)
 	}
 	
 	report := &Report{
@@ -135,6 +401,23 @@ func (e *Engine) diagnosePod(ctx context.Context, podName string) (*Report, erro
 		HealthScore:  100,
 	}
 	
+	e.analyzePod(ctx, snapshot, report, pod)
+	
+	// Generate remediations using AI
+	if len(report.Issues) > 0 {
+		remediations := e.generateRemediations(ctx, report.Issues, podName)
+		report.Remediations = remediations
+	}
+	
+	return report, nil
+}
+
+// analyzePod adds the issues found on a pod to the report, with logs and
+// warning events attached as evidence
+func (e *Engine) analyzePod(ctx context.Context, snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	podName := pod.Name
+	first := len(report.Issues)
+	
 	// Check pod phase
 	if pod.Status.Phase != "Running" {
 		issue := Issue{
@@ -144,7 +427,6 @@ func (e *Engine) diagnosePod(ctx context.Context, podName string) (*Report, erro
 			Description: fmt.Sprintf("Pod is in %s phase", pod.Status.Phase),
 		}
 		report.Issues = append(report.Issues, issue)
-		report.HealthScore -= 30
 	}
 	
 	// Check container statuses
@@ -159,15 +441,12 @@ func (e *Engine) diagnosePod(ctx context.Context, podName string) (*Report, erro
 			case "CrashLoopBackOff":
 				issueType = IssueCrashLoopBackOff
 				severity = SeverityCritical
-				report.HealthScore -= 40
 			case "ImagePullBackOff", "ErrImagePull":
 				issueType = IssueImagePullBackOff
 				severity = SeverityHigh
-				report.HealthScore -= 35
 			default:
 				issueType = IssueType(reason)
 				severity = SeverityMedium
-				report.HealthScore -= 20
 			}
 			
 			issue := Issue{
@@ -176,6 +455,12 @@ func (e *Engine) diagnosePod(ctx context.Context, podName string) (*Report, erro
 				Resource:    fmt.Sprintf("%s/%s", podName, cs.Name),
 				Description: fmt.Sprintf("Container %s: %s - %s", cs.Name, reason, cs.State.Waiting.Message),
 			}
+			if issueType == IssueCrashLoopBackOff {
+				// The waiting container has no output yet; the crash is in the previous one
+				if logs := e.containerLogs(ctx, report, pod.Namespace, podName, cs.Name, true); logs != "" {
+					issue.Details = map[string]interface{}{"logs": logs}
+				}
+			}
 			report.Issues = append(report.Issues, issue)
 		}
 		
@@ -188,24 +473,78 @@ func (e *Engine) diagnosePod(ctx context.Context, podName string) (*Report, erro
 				Description: fmt.Sprintf("Container has restarted %d times", cs.RestartCount),
 			}
 			report.Issues = append(report.Issues, issue)
-			report.HealthScore -= 15
 		}
 	}
 	
-	// Generate remediations using AI
-	if len(report.Issues) > 0 {
-		remediations := e.generateRemediations(ctx, report.Issues, podName)
-		report.Remediations = remediations
+	e.analyzeInitContainers(ctx, report, pod)
+	analyzeEphemeralContainers(report, pod)
+	e.analyzeTerminations(ctx, snapshot, report, pod)
+	analyzeProbes(snapshot, report, pod)
+	e.analyzePodStorage(snapshot, report, pod)
+	analyzePodNode(snapshot, report, pod)
+	analyzeScheduling(snapshot, report, pod)
+	
+	// Attach warning events as evidence for the detected issues
+	if len(report.Issues) > first {
+		if events := e.warningEvents(snapshot, report, "Pod", podName, pod.Namespace); len(events) > 0 {
+			for i := first; i < len(report.Issues); i++ {
+				if report.Issues[i].Details == nil {
+					report.Issues[i].Details = map[string]interface{}{}
+				}
+				report.Issues[i].Details["events"] = events
+			}
+		}
+	}
+}
+
+// warningEvents returns recent warning events for an object. Events that
+// could not be listed (e.g. RBAC forbids it) are recorded as report warnings.
+func (e *Engine) warningEvents(snapshot *k8s.Snapshot, report *Report, kind, name, namespace string) []string {
+	if err := snapshot.Err(k8s.ResourceEvents); err != nil {
+		if k8s.IsForbidden(err) {
+			report.AddWarning(fmt.Sprintf("Events not inspected: you are not permitted to list events in namespace %s", namespace))
+		} else {
+			report.AddWarning(fmt.Sprintf("Events not inspected: %v", err))
+		}
+		return nil
 	}
 	
-	return report, nil
+	var messages []string
+	for _, event := range snapshot.EventsFor(kind, namespace, name) {
+		if event.Type != "Warning" {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s (x%d)", event.Reason, event.Message, event.Count))
+	}
+	return messages
+}
+
+// containerLogs returns the tail of a container's logs, or of its previous
+// instance's. Failures to read logs (e.g. RBAC forbids pods/log) are
+// recorded as report warnings.
+func (e *Engine) containerLogs(ctx context.Context, report *Report, namespace, podName, container string, previous bool) string {
+	logs, err := e.k8sClient.GetPodLogs(ctx, podName, namespace, k8s.LogOptions{Container: container, TailLines: 20, Previous: previous})
+	if err != nil {
+		if k8s.IsForbidden(err) {
+			report.AddWarning(fmt.Sprintf("Logs not inspected: you are not permitted to read pod logs in namespace %s", namespace))
+		} else {
+			report.AddWarning(fmt.Sprintf("Logs not inspected for %s/%s: %v", podName, container, err))
+		}
+		return ""
+	}
+	return strings.TrimSpace(logs)
 }
 
 // diagnoseAllPods diagnoses all pods in the namespace
 func (e *Engine) diagnoseAllPods(ctx context.Context) (*Report, error) {
-	pods, err := e.k8sClient.GetPods(ctx, e.namespace)
+	snapshot, err := e.loadSnapshot(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %w", err)
+		return nil, err
+	}
+	
+	pods := make([]k8s.PodInfo, 0, len(snapshot.Pods))
+	for i := range snapshot.Pods {
+		pods = append(pods, k8s.NewPodInfo(&snapshot.Pods[i]))
 	}
 	
 	report := &Report{
@@ -215,14 +554,11 @@ func (e *Engine) diagnoseAllPods(ctx context.Context) (*Report, error) {
 		HealthScore:  100,
 	}
 	
-	problemPods := 0
-	
 	for _, pod := range pods {
 		if pod.Phase != "Running" || !pod.Ready || pod.Restarts > 3 {
-			problemPods++
-			
 			issue := Issue{
 				Severity:    SeverityMedium,
+				Type:        podIssueType(pod),
 				Resource:    fmt.Sprintf("pod/%s", pod.Name),
 				Description: fmt.Sprintf("Pod %s: Phase=%s, Ready=%v, Restarts=%d", 
 					pod.Name, pod.Phase, pod.Ready, pod.Restarts),
@@ -236,28 +572,39 @@ func (e *Engine) diagnoseAllPods(ctx context.Context) (*Report, error) {
 		}
 	}
 	
-	if problemPods > 0 {
-		report.HealthScore = 100 - (problemPods * 10)
-		if report.HealthScore < 0 {
-			report.HealthScore = 0
+	// Claims that aren't bound keep their pods Pending, or have no pod yet
+	if snapshot.Err(k8s.ResourcePVCs) == nil {
+		for i := range snapshot.PVCs {
+			e.checkClaim(snapshot, report, &snapshot.PVCs[i])
 		}
 	}
 	
-	report.Summary = fmt.Sprintf("Found %d issue(s) across %d pods. Health score: %d/100", 
-		len(report.Issues), len(pods), report.HealthScore)
+	report.Summary = fmt.Sprintf("Found %d issue(s) across %d pods", len(report.Issues), len(pods))
 	
 	return report, nil
 }
 
-// diagnoseDeployment diagnoses a deployment
-func (e *Engine) diagnoseDeployment(ctx context.Context, deploymentName string) (*Report, error) {
-	// TODO: Implement deployment diagnostics
-	return &Report{
-		Summary: fmt.Sprintf("Diagnostics for deployment: %s (not yet implemented)", deploymentName),
-	}, nil
+// podIssueType classifies an unhealthy pod by its first waiting or failed
+// container state, falling back to its phase
+func podIssueType(pod k8s.PodInfo) IssueType {
+	for _, container := range pod.ContainerInfo {
+		switch container.State {
+		case "running", "", "Completed":
+			continue
+		case "ErrImagePull":
+			return IssueImagePullBackOff
+		default:
+			return IssueType(container.State)
+		}
+	}
+	if pod.Phase != corev1.PodRunning {
+		return IssueType(pod.Phase)
+	}
+	return IssueType("NotReady")
 }
 
-// generateRemediations uses AI to generate remediation suggestions
+// generateRemediations builds remediation suggestions from the analyzers,
+// adding an AI suggestion when the provider is available
 func (e *Engine) generateRemediations(ctx context.Context, issues []Issue, resourceName string) []Remediation {
 	// Build a prompt describing the issues
 	prompt := fmt.Sprintf(`Kubernetes diagnostics for resource: %s
@@ -269,16 +616,14 @@ Detected issues:
 		prompt += fmt.Sprintf("- [%s] %s: %s\n", issue.Severity, issue.Type, issue.Description)
 	}
 	
-	prompt += "\nProvide 3 remediation steps with kubectl commands."
-	
-	// Get AI suggestions
-	response, err := e.aiProvider.Generate(ctx, prompt, ai.DefaultOptions())
-	if err != nil {
-		return []Remediation{}
+	clusterType := e.detectClusterType(ctx)
+	if clusterType.IsKnown() {
+		prompt += fmt.Sprintf("\nCluster type: %s (use its tooling for node and cluster operations)\n", clusterType)
 	}
 	
-	// Parse remediations (simplified)
-	return []Remediation{
+	prompt += "\nProvide 3 remediation steps with kubectl commands."
+	
+	remediations := []Remediation{
 		{
 			Title:       "Check pod logs",
 			Description: "Inspect pod logs for error messages",
@@ -293,14 +638,62 @@ Detected issues:
 			Confidence:  "High",
 			Safe:        true,
 		},
-		{
+	}
+	
+	// The AI suggestion is optional; the analyzers' fixes don't depend on it
+	if response, err := e.aiProvider.Generate(ctx, prompt, ai.DefaultOptions()); err == nil {
+		remediations = append(remediations, Remediation{
 			Title:       response.Content[:min(len(response.Content), 50)] + "...",
 			Description: "AI-suggested remediation",
 			Command:     "# See AI response for details",
 			Confidence:  "Medium",
 			Safe:        true,
-		},
+		})
+	}
+	
+	if needsCapacity(issues) {
+		if remediation, ok := capacityRemediation(clusterType); ok {
+			remediations = append(remediations, remediation)
+		}
+	}
+	remediations = append(remediations, storageRemediations(issues, e.namespace)...)
+	remediations = append(remediations, schedulingRemediations(issues, e.namespace)...)
+	remediations = append(remediations, terminationRemediations(issues, e.namespace)...)
+	remediations = append(remediations, probeRemediations(issues, e.namespace)...)
+	remediations = append(remediations, initContainerRemediations(issues, e.namespace)...)
+	
+	return remediations
+}
+
+// needsCapacity reports whether any issue may be caused by a lack of nodes
+func needsCapacity(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Type == IssueType(corev1.PodPending) || issue.Type == IssueResourceConstraint || issue.Type == IssueNodeCapacity {
+			return true
+		}
 	}
+	return false
+}
+
+// capacityRemediation suggests adding nodes with the distribution's tooling
+func capacityRemediation(clusterType k8s.ClusterType) (Remediation, bool) {
+	command := clusterType.NodeScaleCommand()
+	description := "Scale the node pool if pods cannot be scheduled for lack of resources"
+	if command == "" {
+		hint := clusterType.NodeScaleHint()
+		if hint == "" {
+			return Remediation{}, false
+		}
+		description = hint
+	}
+	
+	return Remediation{
+		Title:       fmt.Sprintf("Add node capacity (%s)", clusterType),
+		Description: description,
+		Command:     command,
+		Confidence:  "Medium",
+		Safe:        false,
+	}, true
 }
 
 func min(a, b int) int {
@@ -312,28 +705,50 @@ func min(a, b int) int {
 
 // Display displays the diagnostic report
 func (r *Report) Display() {
-	fmt.Printf("\n%s\n", r.Summary)
-	fmt.Printf("Health Score: %d/100\n\n", r.HealthScore)
+	fmt.Fprintf(output.Stdout, "\n%s\n", r.Summary)
+	fmt.Fprintf(output.Stdout, "Health Score: %d/100\n\n", r.HealthScore)
+	
+	if len(r.Warnings) > 0 {
+		fmt.Fprintln(output.Stdout, "⚠️  Limited diagnostics:")
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(output.Stdout, "  • %s\n", warning)
+		}
+		fmt.Fprintln(output.Stdout)
+	}
 	
 	if len(r.Issues) == 0 {
-		fmt.Println("✅ No issues detected!")
+		fmt.Fprintln(output.Stdout, "✅ No issues detected!")
+		return
+	}
+	
+	r.displayScore()
+	
+	if r.correlated() {
+		r.displayRootCauses()
 		return
 	}
 	
-	fmt.Println("Issues found:")
+	fmt.Fprintln(output.Stdout, "Issues found:")
 	for i, issue := range r.Issues {
-		severityEmoji := "ℹ️"
-		switch issue.Severity {
-		case SeverityCritical:
-			severityEmoji = "🔴"
-		case SeverityHigh:
-			severityEmoji = "🟠"
-		case SeverityMedium:
-			severityEmoji = "🟡"
+		fmt.Fprintf(output.Stdout, "\n%d. %s [%s] %s\n", i+1, severityEmoji(issue.Severity), issue.Severity, issue.Type)
+		fmt.Fprintf(output.Stdout, "   Resource: %s\n", issue.Resource)
+		fmt.Fprintf(output.Stdout, "   %s\n", issue.Description)
+	}
+}
+
+// DisplayRemediations displays the recommended fixes for the report
+func (r *Report) DisplayRemediations() {
+	if len(r.Remediations) == 0 {
+		return
+	}
+	
+	fmt.Fprintln(output.Stdout, "\n💡 Recommended Fixes:")
+	fmt.Fprintln(output.Stdout, "────────────────────")
+	for i, remedy := range r.Remediations {
+		fmt.Fprintf(output.Stdout, "\n%d. %s (Confidence: %s)\n", i+1, remedy.Title, remedy.Confidence)
+		fmt.Fprintf(output.Stdout, "   %s\n", remedy.Description)
+		if remedy.Command != "" {
+			fmt.Fprintf(output.Stdout, "   Command: %s\n", remedy.Command)
 		}
-		
-		fmt.Printf("\n%d. %s [%s] %s\n", i+1, severityEmoji, issue.Severity, issue.Type)
-		fmt.Printf("   Resource: %s\n", issue.Resource)
-		fmt.Printf("   %s\n", issue.Description)
 	}
-}
\ No newline at end of file
+}
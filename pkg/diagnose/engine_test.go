@@ -0,0 +1,144 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func newTestEngine(t *testing.T, namespace string, fixtures ...string) *Engine {
+	t.Helper()
+
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	return NewEngineWithClient(k8stest.NewClient(t, namespace, fixtures...), provider, namespace, false)
+}
+
+func TestDiagnosePodCrashLoop(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/crashloop.yaml")
+
+	report, err := engine.DiagnoseResource("pod", "api-7d9f8b-x2k4p")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	var crashLoop *Issue
+	for i := range report.Issues {
+		if report.Issues[i].Type == IssueCrashLoopBackOff && report.Issues[i].Severity == SeverityCritical {
+			crashLoop = &report.Issues[i]
+		}
+	}
+	if crashLoop == nil {
+		t.Fatalf("expected a critical CrashLoopBackOff issue, got %+v", report.Issues)
+	}
+
+	events, _ := crashLoop.Details["events"].([]string)
+	if len(events) != 1 || !strings.HasPrefix(events[0], "BackOff:") {
+		t.Errorf("events = %v, want the single BackOff warning for this pod", events)
+	}
+	if _, ok := crashLoop.Details["logs"]; !ok {
+		t.Errorf("expected container logs in issue details")
+	}
+	if report.HealthScore >= 100 {
+		t.Errorf("HealthScore = %d, want it lowered", report.HealthScore)
+	}
+	if len(report.Remediations) == 0 {
+		t.Errorf("expected remediations")
+	}
+}
+
+func TestDiagnosePodNotFound(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/crashloop.yaml")
+
+	if _, err := engine.DiagnoseResource("pod", "missing"); err == nil {
+		t.Fatal("expected an error for a missing pod")
+	}
+}
+
+func TestDiagnoseAllPods(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/crashloop.yaml")
+
+	report, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+
+	if len(report.Issues) != 1 || report.Issues[0].Resource != "pod/api-7d9f8b-x2k4p" {
+		t.Fatalf("issues = %+v, want only the crash-looping pod", report.Issues)
+	}
+	// One of the two pods has a high severity issue
+	if report.HealthScore != 75 {
+		t.Errorf("HealthScore = %d, want 75", report.HealthScore)
+	}
+	if report.Score == nil || len(report.Score.Categories) != 1 || report.Score.Categories[0].Affected != 1 {
+		t.Errorf("score breakdown = %+v, want one affected pod", report.Score)
+	}
+}
+
+func TestDiagnoseAllNamespaces(t *testing.T) {
+	provider, _ := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	client := k8stest.NewClient(t, "default", "testdata/crashloop.yaml")
+
+	report, err := NewEngineWithClient(client, provider, "", true).DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+	if len(report.Issues) != 1 {
+		t.Errorf("issues = %+v, want the crash-looping pod from namespace payments", report.Issues)
+	}
+}
+
+func TestPendingPodSuggestsClusterTooling(t *testing.T) {
+	engine := newTestEngine(t, "jobs", "testdata/pending-gke.yaml")
+
+	report, err := engine.DiagnoseResource("pod", "batch-6f7c9-kq2wz")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	for _, remediation := range report.Remediations {
+		if strings.HasPrefix(remediation.Command, "gcloud container clusters resize") {
+			return
+		}
+	}
+	t.Errorf("expected a gcloud node pool remediation on GKE, got %+v", report.Remediations)
+}
+
+// failingProvider is an AI provider that is never available
+type failingProvider struct{}
+
+func (failingProvider) Generate(ctx context.Context, prompt string, options *ai.Options) (*ai.Response, error) {
+	return nil, fmt.Errorf("provider unavailable")
+}
+
+func (failingProvider) GenerateStructured(ctx context.Context, prompt string, schema interface{}, options *ai.Options) (interface{}, error) {
+	return nil, fmt.Errorf("provider unavailable")
+}
+
+func (failingProvider) Name() string {
+	return "failing"
+}
+
+func TestDiagnosePodWithoutAIProvider(t *testing.T) {
+	client := k8stest.NewClient(t, "checkout", "testdata/pending.yaml")
+	engine := NewEngineWithClient(client, failingProvider{}, "checkout", true)
+
+	report, err := engine.DiagnoseResource("pod", "web-6d8f7c-q9z2x")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasCommand(report, "kubectl set resources deployment/web -n checkout -c web --requests=cpu=400m") {
+		t.Errorf("expected the scheduling remediation without AI, got %+v", report.Remediations)
+	}
+	for _, remediation := range report.Remediations {
+		if remediation.Description == "AI-suggested remediation" {
+			t.Errorf("unexpected AI remediation from a failing provider: %+v", remediation)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package diagnose
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sarifSchema and sarifVersion identify the SARIF format written
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "kubectl-pilot"
+	toolURI      = "https://github.com/dhee2211/k8s-pilot"
+)
+
+// ParseSeverity parses a severity name, as given to --fail-on
+func ParseSeverity(value string) (Severity, error) {
+	switch severity := Severity(strings.ToLower(value)); severity {
+	case SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow:
+		return severity, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (want critical, high, medium or low)", value)
+}
+
+// AtLeast reports whether a severity is as severe as threshold or more
+func (s Severity) AtLeast(threshold Severity) bool {
+	return severityWeight(s) >= severityWeight(threshold)
+}
+
+// CountAtLeast returns the number of issues at or above a severity
+func (r *Report) CountAtLeast(threshold Severity) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Severity.AtLeast(threshold) {
+			count++
+		}
+	}
+	return count
+}
+
+// CountAtLeast returns the number of issues at or above a severity across
+// the clusters that were diagnosed
+func (m *MultiClusterReport) CountAtLeast(threshold Severity) int {
+	count := 0
+	for _, cluster := range m.Clusters {
+		if cluster.Report != nil {
+			count += cluster.Report.CountAtLeast(threshold)
+		}
+	}
+	return count
+}
+
+// SARIFLog is a SARIF 2.1.0 log, as read by code scanning tools
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun is the result of diagnosing one cluster
+type SARIFRun struct {
+	Tool              SARIFTool               `json:"tool"`
+	AutomationDetails *SARIFAutomationDetails `json:"automationDetails,omitempty"`
+	Invocations       []SARIFInvocation       `json:"invocations,omitempty"`
+	Results           []SARIFResult           `json:"results"`
+}
+
+// SARIFTool describes kubectl-pilot and the issue types it reported
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver is the tool component that produced the results
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+// SARIFRule is an issue type
+type SARIFRule struct {
+	ID               string       `json:"id"`
+	ShortDescription SARIFMessage `json:"shortDescription"`
+}
+
+// SARIFAutomationDetails identifies the cluster a run diagnosed
+type SARIFAutomationDetails struct {
+	ID string `json:"id"`
+}
+
+// SARIFInvocation records whether a run could diagnose its cluster
+type SARIFInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []SARIFNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+// SARIFNotification is a problem running the diagnostics
+type SARIFNotification struct {
+	Level   string       `json:"level"`
+	Message SARIFMessage `json:"message"`
+}
+
+// SARIFResult is an issue
+type SARIFResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    SARIFMessage           `json:"message"`
+	Locations  []SARIFLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// SARIFMessage is a plain text message
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFLocation places a result on a Kubernetes resource
+type SARIFLocation struct {
+	LogicalLocations []SARIFLogicalLocation `json:"logicalLocations"`
+}
+
+// SARIFLogicalLocation is a resource, "namespace/kind/name" when the
+// namespace is known
+type SARIFLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// SARIF returns the report as a SARIF log
+func (r *Report) SARIF() *SARIFLog {
+	return &SARIFLog{Schema: sarifSchema, Version: sarifVersion, Runs: []SARIFRun{r.sarifRun()}}
+}
+
+// SARIF returns the merged report as a SARIF log with one run per cluster
+func (m *MultiClusterReport) SARIF() *SARIFLog {
+	log := &SARIFLog{Schema: sarifSchema, Version: sarifVersion, Runs: []SARIFRun{}}
+	for _, cluster := range m.Clusters {
+		run := SARIFRun{Tool: sarifTool(nil), Results: []SARIFResult{}}
+		if cluster.Err != nil {
+			run.Invocations = []SARIFInvocation{{
+				ExecutionSuccessful:        false,
+				ToolExecutionNotifications: []SARIFNotification{{Level: "error", Message: SARIFMessage{Text: cluster.Err.Error()}}},
+			}}
+		} else {
+			run = cluster.Report.sarifRun()
+		}
+		run.AutomationDetails = &SARIFAutomationDetails{ID: cluster.Context + "/"}
+		log.Runs = append(log.Runs, run)
+	}
+	return log
+}
+
+// sarifRun converts the report's issues into results
+func (r *Report) sarifRun() SARIFRun {
+	run := SARIFRun{
+		Tool:        sarifTool(r.Issues),
+		Invocations: []SARIFInvocation{{ExecutionSuccessful: true}},
+		Results:     []SARIFResult{},
+	}
+	for _, warning := range r.Warnings {
+		run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications,
+			SARIFNotification{Level: "warning", Message: SARIFMessage{Text: warning}})
+	}
+
+	for _, issue := range r.Issues {
+		kind, name := issueLocation(issue)
+		qualified := issue.Resource
+		if ns, ok := issue.Details["namespace"].(string); ok && ns != "" {
+			qualified = ns + "/" + issue.Resource
+		}
+		properties := map[string]interface{}{"severity": string(issue.Severity)}
+		for key, value := range issueDocuments([]Issue{issue})[0].Details {
+			properties[key] = value
+		}
+		run.Results = append(run.Results, SARIFResult{
+			RuleID:  string(issue.Type),
+			Level:   sarifLevel(issue.Severity),
+			Message: SARIFMessage{Text: issue.Description},
+			Locations: []SARIFLocation{{LogicalLocations: []SARIFLogicalLocation{{
+				Name:               name,
+				FullyQualifiedName: qualified,
+				Kind:               kind,
+			}}}},
+			Properties: properties,
+		})
+	}
+	return run
+}
+
+// sarifTool describes the tool with a rule per issue type reported
+func sarifTool(issues []Issue) SARIFTool {
+	types := map[string]bool{}
+	for _, issue := range issues {
+		types[string(issue.Type)] = true
+	}
+	rules := []SARIFRule{}
+	for _, id := range sortedKeys(types) {
+		rules = append(rules, SARIFRule{ID: id, ShortDescription: SARIFMessage{Text: id}})
+	}
+	return SARIFTool{Driver: SARIFDriver{Name: toolName, InformationURI: toolURI, Rules: rules}}
+}
+
+// sarifLevel maps a severity to a SARIF level
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// issueLocation splits an issue's resource into its kind and name. Bare
+// pod names and "pod/container" are pods; custom rules and status
+// conditions name any kind.
+func issueLocation(issue Issue) (string, string) {
+	prefix, name, ok := strings.Cut(issue.Resource, "/")
+	if !ok {
+		return "pod", issue.Resource
+	}
+	if _, known := refKinds[prefix]; known {
+		return prefix, name
+	}
+	if _, rule := issue.Details["rule"]; rule {
+		return prefix, name
+	}
+	if _, condition := issue.Details["condition"]; condition {
+		return prefix, name
+	}
+	return "pod", prefix
+}
+
+// JUnitTestSuites is a JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite groups the issues of one cluster
+type JUnitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is an issue, or a passing check when there are none
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Error     *JUnitFailure `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitFailure is why a test case failed
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns the report as JUnit XML, one failing test case per issue
+func (r *Report) JUnit() *JUnitTestSuites {
+	return junitSuites(r.junitSuite("diagnose"))
+}
+
+// JUnit returns the merged report as JUnit XML with a suite per cluster
+func (m *MultiClusterReport) JUnit() *JUnitTestSuites {
+	var suites []JUnitTestSuite
+	for _, cluster := range m.Clusters {
+		if cluster.Err != nil {
+			suites = append(suites, JUnitTestSuite{
+				Name:   cluster.Context,
+				Tests:  1,
+				Errors: 1,
+				Cases: []JUnitTestCase{{
+					Name:      "diagnose",
+					ClassName: cluster.Context,
+					Error:     &JUnitFailure{Message: cluster.Err.Error(), Type: "DiagnosticsFailed"},
+				}},
+			})
+			continue
+		}
+		suites = append(suites, cluster.Report.junitSuite(cluster.Context))
+	}
+	return junitSuites(suites...)
+}
+
+// junitSuites totals the suites
+func junitSuites(suites ...JUnitTestSuite) *JUnitTestSuites {
+	report := &JUnitTestSuites{Name: toolName, Suites: suites}
+	for _, suite := range suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+	}
+	return report
+}
+
+// junitSuite converts the report's issues into failing test cases. A report
+// without issues has a single passing case so the suite is never empty.
+func (r *Report) junitSuite(name string) JUnitTestSuite {
+	suite := JUnitTestSuite{Name: name}
+	for _, issue := range r.Issues {
+		kind, resource := issueLocation(issue)
+		var text strings.Builder
+		text.WriteString(issue.Description)
+		details := issueDocuments([]Issue{issue})[0].Details
+		for _, key := range sortedKeys(details) {
+			fmt.Fprintf(&text, "\n%s: %v", key, details[key])
+		}
+		suite.Cases = append(suite.Cases, JUnitTestCase{
+			Name:      fmt.Sprintf("%s %s", resource, issue.Type),
+			ClassName: kind,
+			Failure:   &JUnitFailure{Message: issue.Description, Type: string(issue.Severity), Text: text.String()},
+		})
+	}
+	if len(suite.Cases) == 0 {
+		suite.Cases = append(suite.Cases, JUnitTestCase{Name: "no issues detected", ClassName: name, SystemOut: r.Summary})
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Failures = len(r.Issues)
+	return suite
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
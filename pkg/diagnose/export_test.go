@@ -0,0 +1,114 @@
+package diagnose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/output"
+)
+
+func exportReport() *Report {
+	return &Report{
+		Summary: "Found 3 issue(s)",
+		Issues: []Issue{
+			{Severity: SeverityCritical, Type: IssueCrashLoopBackOff, Resource: "api-0/app", Description: "Container app is crash looping", Details: map[string]interface{}{"restarts": 12}},
+			{Severity: SeverityMedium, Type: IssueNodeCordoned, Resource: "node/worker-1", Description: "Node is cordoned"},
+			{Severity: SeverityLow, Type: "PrivilegedContainer", Resource: "pod/web-0", Description: "Privileged", Details: map[string]interface{}{"rule": "privileged-container", "namespace": "shop"}},
+		},
+	}
+}
+
+func TestFailOnThreshold(t *testing.T) {
+	report := exportReport()
+	for threshold, want := range map[Severity]int{SeverityCritical: 1, SeverityHigh: 1, SeverityMedium: 2, SeverityLow: 3} {
+		if got := report.CountAtLeast(threshold); got != want {
+			t.Errorf("CountAtLeast(%s) = %d, want %d", threshold, got, want)
+		}
+	}
+	if severity, err := ParseSeverity("HIGH"); err != nil || severity != SeverityHigh {
+		t.Errorf("ParseSeverity(HIGH) = %q, %v", severity, err)
+	}
+	if _, err := ParseSeverity("urgent"); err == nil {
+		t.Error("ParseSeverity(urgent) = nil error, want an error")
+	}
+}
+
+func TestReportSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := output.Encode(&buf, output.FormatSARIF, exportReport().SARIF()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var log SARIFLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 3 {
+		t.Fatalf("log = %+v, want one run with three results", log)
+	}
+	if rules := log.Runs[0].Tool.Driver.Rules; len(rules) != 3 || rules[0].ID != string(IssueCrashLoopBackOff) {
+		t.Errorf("rules = %+v, want one per issue type, sorted", rules)
+	}
+
+	results := log.Runs[0].Results
+	want := []struct{ level, kind, name, qualified string }{
+		{"error", "pod", "api-0", "api-0/app"},
+		{"warning", "node", "worker-1", "node/worker-1"},
+		{"note", "pod", "web-0", "shop/pod/web-0"},
+	}
+	for i, w := range want {
+		location := results[i].Locations[0].LogicalLocations[0]
+		if results[i].Level != w.level || location.Kind != w.kind || location.Name != w.name || location.FullyQualifiedName != w.qualified {
+			t.Errorf("result %d = %s %+v, want %s %s/%s (%s)", i, results[i].Level, location, w.level, w.kind, w.name, w.qualified)
+		}
+	}
+	if results[0].Properties["severity"] != "critical" || results[0].Properties["restarts"] != float64(12) {
+		t.Errorf("properties = %v, want the severity and details", results[0].Properties)
+	}
+}
+
+func TestReportJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := output.Encode(&buf, output.FormatJUnit, exportReport().JUnit()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<testsuites name="kubectl-pilot" tests="3" failures="3" errors="0">`,
+		`<testcase name="api-0 CrashLoopBackOff" classname="pod">`,
+		`<failure message="Container app is crash looping" type="critical">Container app is crash looping&#xA;restarts: 12</failure>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("junit missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	healthy := (&Report{Summary: "Found 0 issue(s)"}).JUnit()
+	if healthy.Tests != 1 || healthy.Failures != 0 {
+		t.Errorf("healthy = %+v, want a single passing case", healthy)
+	}
+}
+
+func TestMultiClusterExport(t *testing.T) {
+	merged := &MultiClusterReport{Clusters: []ClusterReport{
+		{Context: "prod-eu", Report: exportReport()},
+		{Context: "prod-us", Err: errors.New("connection refused")},
+	}}
+
+	if got := merged.CountAtLeast(SeverityHigh); got != 1 {
+		t.Errorf("CountAtLeast = %d, want 1", got)
+	}
+
+	log := merged.SARIF()
+	if len(log.Runs) != 2 || log.Runs[0].AutomationDetails.ID != "prod-eu/" || log.Runs[1].Invocations[0].ExecutionSuccessful {
+		t.Errorf("runs = %+v, want a run per cluster with the failure recorded", log.Runs)
+	}
+
+	junit := merged.JUnit()
+	if junit.Tests != 4 || junit.Failures != 3 || junit.Errors != 1 || junit.Suites[1].Cases[0].Error == nil {
+		t.Errorf("junit = %+v, want the failed cluster as an error", junit)
+	}
+}
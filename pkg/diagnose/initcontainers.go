@@ -0,0 +1,205 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// analyzeInitContainers walks the init containers in order and reports the
+// one blocking startup, with its logs. Native sidecars (init containers
+// with restartPolicy Always) only block until they have started, and keep
+// running alongside the app containers afterwards.
+func (e *Engine) analyzeInitContainers(ctx context.Context, report *Report, pod *corev1.Pod) {
+	total := len(pod.Spec.InitContainers)
+	for i, container := range pod.Spec.InitContainers {
+		status := initContainerStatus(pod, container.Name)
+		position := fmt.Sprintf("%d/%d", i+1, total)
+		sidecar := container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+
+		if sidecar {
+			if !e.checkSidecar(ctx, report, pod, container.Name, position, status) {
+				return
+			}
+			continue
+		}
+		if status == nil {
+			// Not started yet: an earlier container is still in the way
+			return
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode == 0 {
+			continue
+		}
+		e.reportBlockingInit(ctx, report, pod, container.Name, position, status)
+		return
+	}
+}
+
+// reportBlockingInit adds the issue for the init container holding the pod
+// in its Init phase
+func (e *Engine) reportBlockingInit(ctx context.Context, report *Report, pod *corev1.Pod, name, position string, status *corev1.ContainerStatus) {
+	issue := Issue{
+		Resource: fmt.Sprintf("%s/%s", pod.Name, name),
+		Details:  map[string]interface{}{"pod": pod.Name, "container": name, "position": position},
+	}
+	// Containers that never ran, e.g. on ImagePullBackOff, have no logs
+	previous, logs := false, true
+
+	switch {
+	case status.State.Terminated != nil:
+		terminated := status.State.Terminated
+		issue.Severity = SeverityCritical
+		issue.Type = IssueInitContainerFailed
+		issue.Description = fmt.Sprintf("Init container %s (%s) failed with exit code %d (%s): %s",
+			name, position, terminated.ExitCode, terminated.Reason, exitCodeMeaning(terminated))
+		issue.Details["exitCode"] = terminated.ExitCode
+	case status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff":
+		issue.Severity = SeverityCritical
+		issue.Type = IssueInitContainerFailed
+		issue.Description = fmt.Sprintf("Init container %s (%s) is crash looping (Init:CrashLoopBackOff) after %d restart(s)",
+			name, position, status.RestartCount)
+		if last := status.LastTerminationState.Terminated; last != nil {
+			issue.Description += fmt.Sprintf("; it last exited with code %d: %s", last.ExitCode, exitCodeMeaning(last))
+			issue.Details["exitCode"] = last.ExitCode
+		}
+		previous = true
+	case status.State.Waiting != nil && status.State.Waiting.Reason != "PodInitializing":
+		waiting := status.State.Waiting
+		issue.Severity = SeverityHigh
+		issue.Type = IssueInitContainerFailed
+		issue.Description = fmt.Sprintf("Init container %s (%s) can't start: %s - %s", name, position, waiting.Reason, waiting.Message)
+		logs = false
+	case status.State.Running != nil:
+		issue.Severity = SeverityMedium
+		issue.Type = IssueInitContainerBlocked
+		issue.Description = fmt.Sprintf("Init container %s (%s) has been running since %s; app containers start once it completes",
+			name, position, status.State.Running.StartedAt.UTC().Format("2006-01-02T15:04:05Z"))
+	default:
+		return
+	}
+
+	if logs {
+		if output := e.containerLogs(ctx, report, pod.Namespace, pod.Name, name, previous); output != "" {
+			issue.Details["logs"] = output
+		}
+	}
+	issue.Details["previous"] = previous
+	report.Issues = append(report.Issues, issue)
+}
+
+// checkSidecar reports a native sidecar that is not running. It returns
+// whether later containers can start, which requires the sidecar to have
+// started.
+func (e *Engine) checkSidecar(ctx context.Context, report *Report, pod *corev1.Pod, name, position string, status *corev1.ContainerStatus) bool {
+	if status == nil {
+		return false
+	}
+	started := status.Started != nil && *status.Started
+	if status.State.Running != nil {
+		if !started {
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityMedium,
+				Type:        IssueInitContainerBlocked,
+				Resource:    fmt.Sprintf("%s/%s", pod.Name, name),
+				Description: fmt.Sprintf("Sidecar %s (%s) is running but has not passed its startup probe; later containers wait for it", name, position),
+				Details:     map[string]interface{}{"pod": pod.Name, "container": name, "position": position, "sidecar": true},
+			})
+		}
+		return started
+	}
+
+	issue := Issue{
+		Severity: SeverityHigh,
+		Type:     IssueSidecarFailed,
+		Resource: fmt.Sprintf("%s/%s", pod.Name, name),
+		Details:  map[string]interface{}{"pod": pod.Name, "container": name, "position": position, "sidecar": true},
+	}
+	previous := false
+	switch {
+	case status.State.Waiting != nil:
+		issue.Description = fmt.Sprintf("Sidecar %s (%s) is not running: %s - %s", name, position, status.State.Waiting.Reason, status.State.Waiting.Message)
+		previous = status.LastTerminationState.Terminated != nil
+	case status.State.Terminated != nil:
+		terminated := status.State.Terminated
+		issue.Description = fmt.Sprintf("Sidecar %s (%s) exited with code %d: %s", name, position, terminated.ExitCode, exitCodeMeaning(terminated))
+	default:
+		return started
+	}
+	if !started {
+		issue.Severity = SeverityCritical
+		issue.Description += "; the containers after it can't start"
+	}
+	if logs := e.containerLogs(ctx, report, pod.Namespace, pod.Name, name, previous); logs != "" {
+		issue.Details["logs"] = logs
+	}
+	issue.Details["previous"] = previous
+	report.Issues = append(report.Issues, issue)
+	return started
+}
+
+// analyzeEphemeralContainers reports debug containers that failed to start
+// or exited with an error. They don't affect the workload, so they are low
+// severity.
+func analyzeEphemeralContainers(report *Report, pod *corev1.Pod) {
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		var description string
+		switch {
+		case status.State.Waiting != nil && status.State.Waiting.Reason != "ContainerCreating":
+			description = fmt.Sprintf("Ephemeral container %s can't start: %s - %s", status.Name, status.State.Waiting.Reason, status.State.Waiting.Message)
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+			description = fmt.Sprintf("Ephemeral container %s exited with code %d", status.Name, status.State.Terminated.ExitCode)
+		default:
+			continue
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityLow,
+			Type:        IssueEphemeralContainerFailed,
+			Resource:    fmt.Sprintf("%s/%s", pod.Name, status.Name),
+			Description: description,
+		})
+	}
+}
+
+// initContainerStatus returns the status of an init container by name
+func initContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.InitContainerStatuses {
+		if pod.Status.InitContainerStatuses[i].Name == name {
+			return &pod.Status.InitContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// initContainerRemediations suggests reading the blocking container's logs
+func initContainerRemediations(issues []Issue, namespace string) []Remediation {
+	var remediations []Remediation
+	for _, issue := range issues {
+		if issue.Type != IssueInitContainerFailed && issue.Type != IssueInitContainerBlocked && issue.Type != IssueSidecarFailed {
+			continue
+		}
+		pod, _ := issue.Details["pod"].(string)
+		container, _ := issue.Details["container"].(string)
+		command := fmt.Sprintf("kubectl logs %s -n %s -c %s", pod, namespace, container)
+		if previous, _ := issue.Details["previous"].(bool); previous {
+			command += " --previous"
+		}
+		remediations = append(remediations, Remediation{
+			Title:       "Read the logs of " + container,
+			Description: "Init containers run before the app; their output shows what they are waiting for or why they fail",
+			Command:     command,
+			Confidence:  "High",
+			Safe:        true,
+		})
+		if issue.Type == IssueInitContainerBlocked && issue.Details["sidecar"] == nil {
+			remediations = append(remediations, Remediation{
+				Title:       "Inspect what " + container + " runs",
+				Description: "Init containers that wait on a dependency (a database, a DNS name) block until it is reachable",
+				Command:     fmt.Sprintf(`kubectl get pod %s -n %s -o jsonpath='{.spec.initContainers[?(@.name=="%s")].command}'`, pod, namespace, container),
+				Confidence:  "Medium",
+				Safe:        true,
+			})
+		}
+	}
+	return remediations
+}
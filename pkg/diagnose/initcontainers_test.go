@@ -0,0 +1,45 @@
+package diagnose
+
+import "testing"
+
+func TestDiagnosePodInitContainers(t *testing.T) {
+	engine := newTestEngine(t, "orders", "testdata/initcontainers.yaml")
+
+	report, err := engine.DiagnoseResource("pod", "orders-7b9c4-xk2pq")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueInitContainerFailed, "orders-7b9c4-xk2pq/migrate", SeverityCritical,
+		"Init container migrate (3/4) is crash looping (Init:CrashLoopBackOff) after 5 restart(s); it last exited with code 1: application error") {
+		t.Errorf("expected migrate to block startup, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueEphemeralContainerFailed, "orders-7b9c4-xk2pq/debugger", SeverityLow, "ImagePullBackOff") {
+		t.Errorf("expected the debug container to be reported, got %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		switch issue.Resource {
+		case "orders-7b9c4-xk2pq/mesh-proxy", "orders-7b9c4-xk2pq/wait-for-db", "orders-7b9c4-xk2pq/seed":
+			t.Errorf("only the blocking init container should be reported, got %+v", issue)
+		case "orders-7b9c4-xk2pq/migrate":
+			if issue.Details["logs"] != "fake logs" {
+				t.Errorf("expected the init container's logs, got %v", issue.Details["logs"])
+			}
+		}
+	}
+	if !hasCommand(report, "kubectl logs orders-7b9c4-xk2pq -n orders -c migrate --previous") {
+		t.Errorf("expected an init container logs remediation, got %+v", report.Remediations)
+	}
+
+	report, err = engine.DiagnoseResource("pod", "reports-5d8f6-r7wvn")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(report, IssueSidecarFailed, "reports-5d8f6-r7wvn/log-shipper", SeverityCritical,
+		"Sidecar log-shipper (1/2) is not running: CrashLoopBackOff - back-off 40s restarting failed container; the containers after it can't start") {
+		t.Errorf("expected the native sidecar to block startup, got %+v", report.Issues)
+	}
+	if reported(report, "reports-5d8f6-r7wvn/setup") {
+		t.Errorf("setup waits on the sidecar and should not be reported: %+v", report.Issues)
+	}
+}
@@ -0,0 +1,408 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+const (
+	// defaultBackoffLimit is the API default for a job's spec.backoffLimit
+	defaultBackoffLimit = 6
+	// scheduleGrace allows for the cronjob controller's sync delay before a
+	// scheduled run counts as missed
+	scheduleGrace = 2 * time.Minute
+	// maxMissedSchedules caps the count like the cronjob controller does
+	maxMissedSchedules = 100
+)
+
+// now returns the current time; tests replace it
+var now = time.Now
+
+// diagnoseJob diagnoses a job's failures and deadline, and rolls up the
+// diagnostics of its pods
+func (e *Engine) diagnoseJob(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceJobs); err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	job := snapshot.Job(e.namespace, name)
+	if job == nil {
+		return nil, fmt.Errorf("job %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for job: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+
+	checkJob(report, job, "job/"+name)
+	podIssues := e.rollUpJobPods(ctx, snapshot, report, job)
+
+	if len(report.Issues) > 0 {
+		report.Remediations = jobRemediations(job, report.Issues)
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for job: %s (%d succeeded, %d failed, %d active, %d pod issue(s))",
+		name, job.Status.Succeeded, job.Status.Failed, job.Status.Active, podIssues)
+	return report, nil
+}
+
+// checkJob flags jobs that failed on their backoff limit or deadline, and
+// running jobs close to their backoff limit
+func checkJob(report *Report, job *batchv1.Job, resource string) {
+	backoffLimit := int32(defaultBackoffLimit)
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Type != batchv1.JobFailed || condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Reason {
+		case "DeadlineExceeded":
+			var deadline int64
+			if job.Spec.ActiveDeadlineSeconds != nil {
+				deadline = *job.Spec.ActiveDeadlineSeconds
+			}
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssueJobDeadlineExceeded,
+				Resource:    resource,
+				Description: fmt.Sprintf("Job ran longer than its activeDeadlineSeconds of %ds: %s", deadline, condition.Message),
+				Details:     map[string]interface{}{"reason": condition.Reason, "activeDeadlineSeconds": deadline},
+			})
+		default:
+			report.Issues = append(report.Issues, Issue{
+				Severity: SeverityCritical,
+				Type:     IssueJobFailed,
+				Resource: resource,
+				Description: fmt.Sprintf("Job failed (%s) after %d failed pod(s) with backoffLimit %d: %s",
+					condition.Reason, job.Status.Failed, backoffLimit, condition.Message),
+				Details: map[string]interface{}{"reason": condition.Reason, "failed": job.Status.Failed, "backoffLimit": backoffLimit},
+			})
+		}
+		return
+	}
+
+	if job.Status.Failed > 0 && job.Status.CompletionTime == nil {
+		severity := SeverityLow
+		if job.Status.Failed >= backoffLimit-1 {
+			severity = SeverityHigh
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity:    severity,
+			Type:        IssueJobFailed,
+			Resource:    resource,
+			Description: fmt.Sprintf("%d pod(s) failed so far; the job fails after backoffLimit %d", job.Status.Failed, backoffLimit),
+			Details:     map[string]interface{}{"failed": job.Status.Failed, "backoffLimit": backoffLimit},
+		})
+	}
+
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityLow,
+			Type:        IssueSuspended,
+			Resource:    resource,
+			Description: "Job is suspended; no pods will be created until it is resumed",
+		})
+	}
+}
+
+// rollUpJobPods analyzes the pods of a job and returns the issues found
+func (e *Engine) rollUpJobPods(ctx context.Context, snapshot *k8s.Snapshot, report *Report, job *batchv1.Job) int {
+	podIssues := 0
+	for _, pod := range snapshot.PodsOwnedBy(job) {
+		if pod.Status.Phase == corev1.PodSucceeded {
+			continue
+		}
+		before := len(report.Issues)
+		e.analyzePod(ctx, snapshot, report, pod)
+		podIssues += len(report.Issues) - before
+	}
+	return podIssues
+}
+
+// jobRemediations suggests commands for the issues found
+func jobRemediations(job *batchv1.Job, issues []Issue) []Remediation {
+	target := fmt.Sprintf("job/%s -n %s", job.Name, job.Namespace)
+	remediations := []Remediation{
+		{
+			Title:       "Check job logs",
+			Description: "Inspect the output of the job's pods",
+			Command:     "kubectl logs " + target + " --all-containers",
+			Confidence:  "High",
+			Safe:        true,
+		},
+		{
+			Title:       "List the job's pods",
+			Description: "See the exit status of every attempt",
+			Command:     fmt.Sprintf("kubectl get pods -n %s -l job-name=%s", job.Namespace, job.Name),
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	if hasIssueType(issues, IssueJobDeadlineExceeded, "job/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Review the active deadline",
+			Description: "Raise activeDeadlineSeconds in the job template if the work legitimately takes longer",
+			Command:     "kubectl get " + target + " -o jsonpath='{.spec.activeDeadlineSeconds}'",
+			Confidence:  "Medium",
+			Safe:        true,
+		})
+	}
+	if owner := cronJobOwner(job); owner != "" && (hasIssueType(issues, IssueJobFailed, "job/") || hasIssueType(issues, IssueJobDeadlineExceeded, "job/")) {
+		remediations = append(remediations, Remediation{
+			Title:       "Re-run the job",
+			Description: "Start a new run from the cronjob once the cause is fixed",
+			Command:     fmt.Sprintf("kubectl create job %s-rerun --from=cronjob/%s -n %s", owner, owner, job.Namespace),
+			Confidence:  "Medium",
+			Safe:        false,
+		})
+	}
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		remediations = append(remediations, Remediation{
+			Title:       "Resume the job",
+			Description: "Let the job create pods",
+			Command:     fmt.Sprintf(`kubectl patch %s -p '{"spec":{"suspend":false}}'`, target),
+			Confidence:  "High",
+			Safe:        false,
+		})
+	}
+
+	return remediations
+}
+
+// cronJobOwner returns the name of the cronjob controlling a job, if any
+func cronJobOwner(job *batchv1.Job) string {
+	for _, ref := range job.OwnerReferences {
+		if ref.Kind == "CronJob" && ref.Controller != nil && *ref.Controller {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+// diagnoseCronJob diagnoses a cronjob's schedule, concurrency and most
+// recent run
+func (e *Engine) diagnoseCronJob(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceCronJobs); err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+
+	cronJob := snapshot.CronJob(e.namespace, name)
+	if cronJob == nil {
+		return nil, fmt.Errorf("cronjob %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for cronjob: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	resource := "cronjob/" + name
+
+	jobs := snapshot.JobsOwnedBy(cronJob)
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreationTimestamp.After(jobs[j].CreationTimestamp.Time)
+	})
+
+	checkSchedule(report, cronJob, resource)
+	checkConcurrency(report, cronJob, resource)
+
+	// The most recent run explains why the cronjob isn't succeeding
+	podIssues := 0
+	if len(jobs) > 0 {
+		latest := jobs[0]
+		checkJob(report, latest, "job/"+latest.Name)
+		podIssues = e.rollUpJobPods(ctx, snapshot, report, latest)
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = cronJobRemediations(cronJob, report.Issues, jobs)
+	}
+
+	lastSchedule := "never"
+	if cronJob.Status.LastScheduleTime != nil {
+		lastSchedule = cronJob.Status.LastScheduleTime.UTC().Format(time.RFC3339)
+	}
+	report.Summary = fmt.Sprintf("Diagnostics for cronjob: %s (schedule %q, last scheduled %s, %d active, %d pod issue(s))",
+		name, cronJob.Spec.Schedule, lastSchedule, len(cronJob.Status.Active), podIssues)
+	return report, nil
+}
+
+// checkSchedule flags suspended cronjobs and scheduled runs that were never
+// started, counted from the last scheduled time
+func checkSchedule(report *Report, cronJob *batchv1.CronJob, resource string) {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueSuspended,
+			Resource:    resource,
+			Description: "CronJob is suspended; no jobs will be scheduled until it is resumed",
+		})
+		return
+	}
+
+	spec := cronJob.Spec.Schedule
+	if cronJob.Spec.TimeZone != nil {
+		spec = "CRON_TZ=" + *cronJob.Spec.TimeZone + " " + spec
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssueMissedSchedule,
+			Resource:    resource,
+			Description: fmt.Sprintf("Schedule %q cannot be parsed: %v", cronJob.Spec.Schedule, err),
+		})
+		return
+	}
+
+	since := cronJob.CreationTimestamp.Time
+	if cronJob.Status.LastScheduleTime != nil {
+		since = cronJob.Status.LastScheduleTime.Time
+	}
+	missed := missedSchedules(schedule, since, now().Add(-scheduleGrace))
+	if len(missed) == 0 {
+		return
+	}
+
+	description := fmt.Sprintf("%d scheduled run(s) did not start since %s", len(missed), since.UTC().Format(time.RFC3339))
+	if len(missed) >= maxMissedSchedules {
+		description = fmt.Sprintf("At least %d scheduled runs did not start since %s", maxMissedSchedules, since.UTC().Format(time.RFC3339))
+	}
+	switch {
+	case cronJob.Spec.ConcurrencyPolicy == batchv1.ForbidConcurrent && len(cronJob.Status.Active) > 0:
+		description += fmt.Sprintf("; concurrencyPolicy Forbid skips runs while job %s is active", cronJob.Status.Active[0].Name)
+	case cronJob.Spec.StartingDeadlineSeconds != nil:
+		description += fmt.Sprintf("; runs more than %ds late are skipped (startingDeadlineSeconds)", *cronJob.Spec.StartingDeadlineSeconds)
+	}
+
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityHigh,
+		Type:        IssueMissedSchedule,
+		Resource:    resource,
+		Description: description,
+		Details: map[string]interface{}{
+			"missed":      len(missed),
+			"firstMissed": missed[0].UTC().Format(time.RFC3339),
+			"lastMissed":  missed[len(missed)-1].UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// missedSchedules returns the scheduled times after since and up to until,
+// capped at maxMissedSchedules
+func missedSchedules(schedule cron.Schedule, since, until time.Time) []time.Time {
+	var missed []time.Time
+	for t := schedule.Next(since); !t.IsZero() && !t.After(until); t = schedule.Next(t) {
+		missed = append(missed, t)
+		if len(missed) >= maxMissedSchedules {
+			break
+		}
+	}
+	return missed
+}
+
+// checkConcurrency flags runs piling up under concurrencyPolicy Allow
+func checkConcurrency(report *Report, cronJob *batchv1.CronJob, resource string) {
+	active := len(cronJob.Status.Active)
+	policy := cronJob.Spec.ConcurrencyPolicy
+	if policy == "" {
+		policy = batchv1.AllowConcurrent
+	}
+	if policy != batchv1.AllowConcurrent || active < 2 {
+		return
+	}
+
+	var names []string
+	for _, ref := range cronJob.Status.Active {
+		names = append(names, ref.Name)
+	}
+	severity := SeverityMedium
+	if active > 3 {
+		severity = SeverityHigh
+	}
+	report.Issues = append(report.Issues, Issue{
+		Severity:    severity,
+		Type:        IssueConcurrencyPileup,
+		Resource:    resource,
+		Description: fmt.Sprintf("%d runs are active at once; runs take longer than the schedule interval", active),
+		Details:     map[string]interface{}{"active": names},
+	})
+}
+
+// cronJobRemediations suggests commands for the issues found
+func cronJobRemediations(cronJob *batchv1.CronJob, issues []Issue, jobs []*batchv1.Job) []Remediation {
+	target := fmt.Sprintf("cronjob/%s -n %s", cronJob.Name, cronJob.Namespace)
+	remediations := []Remediation{
+		{
+			Title:       "List recent runs",
+			Description: "Compare the cronjob's jobs and their completion status",
+			Command:     fmt.Sprintf("kubectl get jobs -n %s --sort-by=.metadata.creationTimestamp", cronJob.Namespace),
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		remediations = append(remediations, Remediation{
+			Title:       "Resume the cronjob",
+			Description: "Let the controller schedule jobs again",
+			Command:     fmt.Sprintf(`kubectl patch %s -p '{"spec":{"suspend":false}}'`, target),
+			Confidence:  "High",
+			Safe:        false,
+		})
+	}
+	if hasIssueType(issues, IssueMissedSchedule, "cronjob/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Trigger a run now",
+			Description: "Start a job from the cronjob's template to catch up",
+			Command:     fmt.Sprintf("kubectl create job %s-manual --from=cronjob/%s -n %s", cronJob.Name, cronJob.Name, cronJob.Namespace),
+			Confidence:  "Medium",
+			Safe:        false,
+		})
+	}
+	if hasIssueType(issues, IssueConcurrencyPileup, "cronjob/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Stop overlapping runs",
+			Description: "Forbid skips a run while the previous one is active; Replace cancels the old run",
+			Command:     fmt.Sprintf(`kubectl patch %s -p '{"spec":{"concurrencyPolicy":"Forbid"}}'`, target),
+			Confidence:  "Medium",
+			Safe:        false,
+		})
+	}
+	if len(jobs) > 0 && (hasIssueType(issues, IssueJobFailed, "job/") || hasIssueType(issues, IssueJobDeadlineExceeded, "job/")) {
+		remediations = append(remediations, Remediation{
+			Title:       "Check the latest run's logs",
+			Description: "Find out why the most recent job failed",
+			Command:     fmt.Sprintf("kubectl logs job/%s -n %s --all-containers", jobs[0].Name, cronJob.Namespace),
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+
+	return remediations
+}
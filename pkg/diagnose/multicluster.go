@@ -0,0 +1,78 @@
+package diagnose
+
+import (
+	"fmt"
+
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+)
+
+// ClusterReport is the diagnostic result for a single kubeconfig context
+type ClusterReport struct {
+	Context string
+	Report  *Report
+	Err     error
+}
+
+// MultiClusterReport merges diagnostic reports from several clusters
+type MultiClusterReport struct {
+	Clusters []ClusterReport
+}
+
+// DiagnoseContexts runs diagnose against each context concurrently, with at
+// most parallelism clusters in flight. A failing cluster is reported on its
+// own and never aborts the others.
+func DiagnoseContexts(contexts []string, parallelism int, diagnose func(context string) (*Report, error)) *MultiClusterReport {
+	results := k8s.FanOut(contexts, parallelism, diagnose)
+
+	merged := &MultiClusterReport{}
+	for _, result := range results {
+		merged.Clusters = append(merged.Clusters, ClusterReport{
+			Context: result.Context,
+			Report:  result.Value,
+			Err:     result.Err,
+		})
+	}
+	return merged
+}
+
+// Failed returns the number of clusters that could not be diagnosed
+func (m *MultiClusterReport) Failed() int {
+	failed := 0
+	for _, cluster := range m.Clusters {
+		if cluster.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// TotalIssues returns the number of issues across all clusters
+func (m *MultiClusterReport) TotalIssues() int {
+	total := 0
+	for _, cluster := range m.Clusters {
+		if cluster.Report != nil {
+			total += len(cluster.Report.Issues)
+		}
+	}
+	return total
+}
+
+// Display displays the merged report grouped by cluster
+func (m *MultiClusterReport) Display() {
+	fmt.Fprintf(output.Stdout, "\nDiagnosed %d cluster(s): %d issue(s) found, %d cluster(s) failed\n",
+		len(m.Clusters), m.TotalIssues(), m.Failed())
+
+	for _, cluster := range m.Clusters {
+		fmt.Fprintf(output.Stdout, "\n☸️  Cluster: %s\n", cluster.Context)
+		fmt.Fprintln(output.Stdout, "────────────────────────────")
+
+		if cluster.Err != nil {
+			fmt.Fprintf(output.Stdout, "❌ Diagnostics failed: %v\n", cluster.Err)
+			continue
+		}
+
+		cluster.Report.Display()
+		cluster.Report.DisplayRemediations()
+	}
+}
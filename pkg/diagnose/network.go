@@ -0,0 +1,778 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// diagnoseService diagnoses whether a service routes to ready backends: its
+// selector, endpoints, ports, load balancer and network policies
+func (e *Engine) diagnoseService(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceServices); err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	service := snapshot.Service(e.namespace, name)
+	if service == nil {
+		return nil, fmt.Errorf("service %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for service: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	resource := "service/" + name
+
+	var backends []*corev1.Pod
+	ready, total := 0, 0
+	if service.Spec.Type != corev1.ServiceTypeExternalName {
+		backends = checkServiceSelector(snapshot, report, service, resource)
+		ready, total = checkEndpoints(snapshot, report, service, resource)
+		checkTargetPorts(report, service, backends, resource)
+		checkNetworkPolicies(snapshot, report, service, backends, resource)
+	}
+	checkLoadBalancer(report, service, resource)
+
+	if len(report.Issues) > 0 {
+		if events := e.warningEvents(snapshot, report, "Service", name, service.Namespace); len(events) > 0 {
+			for i := range report.Issues {
+				if report.Issues[i].Details == nil {
+					report.Issues[i].Details = map[string]interface{}{}
+				}
+				report.Issues[i].Details["events"] = events
+			}
+		}
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = e.serviceRemediations(ctx, service, report.Issues)
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for service: %s (%s, %d/%d endpoint(s) ready, %d backend pod(s))",
+		name, service.Spec.Type, ready, total, len(backends))
+	return report, nil
+}
+
+// checkServiceSelector returns the pods a service selects. When none match,
+// pods whose label value contains the selected one or vice versa (app=api
+// vs app=api-v2) are reported as likely typos.
+func checkServiceSelector(snapshot *k8s.Snapshot, report *Report, service *corev1.Service, resource string) []*corev1.Pod {
+	if len(service.Spec.Selector) == 0 {
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	var backends []*corev1.Pod
+	var nearMisses []string
+	for i := range snapshot.Pods {
+		pod := &snapshot.Pods[i]
+		if pod.Namespace != service.Namespace {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			backends = append(backends, pod)
+			continue
+		}
+		for key, want := range service.Spec.Selector {
+			if got, ok := pod.Labels[key]; ok && got != want && (strings.Contains(got, want) || strings.Contains(want, got)) {
+				nearMisses = append(nearMisses, fmt.Sprintf("%s has %s=%s", pod.Name, key, got))
+			}
+		}
+	}
+	if len(backends) > 0 {
+		return backends
+	}
+
+	sort.Strings(nearMisses)
+	issue := Issue{
+		Severity:    SeverityCritical,
+		Type:        IssueSelectorMismatch,
+		Resource:    resource,
+		Description: fmt.Sprintf("No pods match selector %s", selector),
+	}
+	if len(nearMisses) > 0 {
+		issue.Description += fmt.Sprintf("; %d pod(s) have a similar label", len(nearMisses))
+		issue.Details = map[string]interface{}{"nearMisses": nearMisses}
+	}
+	report.Issues = append(report.Issues, issue)
+	return nil
+}
+
+// checkEndpoints counts a service's ready endpoints from its EndpointSlices,
+// falling back to the Endpoints object when slices can't be listed
+func checkEndpoints(snapshot *k8s.Snapshot, report *Report, service *corev1.Service, resource string) (ready, total int) {
+	var notReady []string
+	if snapshot.Err(k8s.ResourceEndpointSlices) == nil {
+		for _, slice := range snapshot.EndpointSlicesFor(service.Namespace, service.Name) {
+			for _, endpoint := range slice.Endpoints {
+				total++
+				if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+					ready++
+					continue
+				}
+				notReady = append(notReady, endpointName(endpoint.TargetRef, endpoint.Addresses))
+			}
+		}
+	} else if snapshot.Err(k8s.ResourceEndpoints) == nil {
+		for _, endpoints := range snapshot.Endpoints {
+			if endpoints.Namespace != service.Namespace || endpoints.Name != service.Name {
+				continue
+			}
+			for _, subset := range endpoints.Subsets {
+				ready += len(subset.Addresses)
+				total += len(subset.Addresses) + len(subset.NotReadyAddresses)
+				for _, address := range subset.NotReadyAddresses {
+					notReady = append(notReady, endpointName(address.TargetRef, []string{address.IP}))
+				}
+			}
+		}
+	} else {
+		report.AddWarning(fmt.Sprintf("Endpoints not inspected: %v", snapshot.Err(k8s.ResourceEndpointSlices)))
+		return 0, 0
+	}
+
+	switch {
+	case total == 0:
+		// A selector mismatch already explains the missing endpoints
+		if len(service.Spec.Selector) > 0 && hasIssueType(report.Issues, IssueSelectorMismatch, "service/") {
+			return ready, total
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityCritical,
+			Type:        IssueNoEndpoints,
+			Resource:    resource,
+			Description: "Service has no endpoints; traffic to it is dropped",
+		})
+	case ready == 0:
+		sort.Strings(notReady)
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityCritical,
+			Type:        IssueBackendNotReady,
+			Resource:    resource,
+			Description: fmt.Sprintf("None of the service's %d endpoint(s) are ready", total),
+			Details:     map[string]interface{}{"notReady": notReady},
+		})
+	case ready < total:
+		sort.Strings(notReady)
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueBackendNotReady,
+			Resource:    resource,
+			Description: fmt.Sprintf("%d of %d endpoint(s) are not ready", total-ready, total),
+			Details:     map[string]interface{}{"notReady": notReady},
+		})
+	}
+	return ready, total
+}
+
+// endpointName names an endpoint by its pod, or by its addresses
+func endpointName(ref *corev1.ObjectReference, addresses []string) string {
+	if ref != nil && ref.Name != "" {
+		return ref.Name
+	}
+	return strings.Join(addresses, ",")
+}
+
+// checkTargetPorts checks that every service port's targetPort is served by
+// the backends. Numeric ports are only checked against pods that declare
+// container ports, since declaring them is optional.
+func checkTargetPorts(report *Report, service *corev1.Service, backends []*corev1.Pod, resource string) {
+	if len(backends) == 0 {
+		return
+	}
+
+	for _, port := range service.Spec.Ports {
+		target := port.TargetPort
+		if target.Type == intstr.Int && target.IntVal == 0 {
+			target = intstr.FromInt32(port.Port)
+		}
+
+		var missing []string
+		declared := map[string]bool{}
+		for _, pod := range backends {
+			ports := containerPorts(pod, port.Protocol)
+			if len(ports) == 0 && target.Type == intstr.Int {
+				continue
+			}
+			found := false
+			for _, containerPort := range ports {
+				declared[describeContainerPort(containerPort)] = true
+				if (target.Type == intstr.String && containerPort.Name == target.StrVal) ||
+					(target.Type == intstr.Int && containerPort.ContainerPort == target.IntVal) {
+					found = true
+				}
+			}
+			if !found {
+				missing = append(missing, pod.Name)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		var available []string
+		for name := range declared {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityHigh,
+			Type:     IssuePortMismatch,
+			Resource: resource,
+			Description: fmt.Sprintf("Port %s targets %s, which %d of %d backend pod(s) don't expose (container ports: %s)",
+				servicePortName(port), target.String(), len(missing), len(backends), strings.Join(available, ", ")),
+			Details: map[string]interface{}{
+				"port":           port.Port,
+				"targetPort":     target.String(),
+				"pods":           missing,
+				"containerPorts": available,
+			},
+		})
+	}
+}
+
+// containerPorts returns the ports a pod's containers declare for a protocol
+func containerPorts(pod *corev1.Pod, protocol corev1.Protocol) []corev1.ContainerPort {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	var ports []corev1.ContainerPort
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol == protocol || (port.Protocol == "" && protocol == corev1.ProtocolTCP) {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// describeContainerPort formats a container port as "8080" or "http/8080"
+func describeContainerPort(port corev1.ContainerPort) string {
+	if port.Name == "" {
+		return fmt.Sprint(port.ContainerPort)
+	}
+	return fmt.Sprintf("%s/%d", port.Name, port.ContainerPort)
+}
+
+// servicePortName formats a service port as "80" or "http (80)"
+func servicePortName(port corev1.ServicePort) string {
+	if port.Name == "" {
+		return fmt.Sprint(port.Port)
+	}
+	return fmt.Sprintf("%s (%d)", port.Name, port.Port)
+}
+
+// checkLoadBalancer flags LoadBalancer services that have no ingress IP or
+// hostname yet
+func checkLoadBalancer(report *Report, service *corev1.Service, resource string) {
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer || len(service.Status.LoadBalancer.Ingress) > 0 {
+		return
+	}
+
+	age := "recently created"
+	if !service.CreationTimestamp.IsZero() {
+		age = fmt.Sprintf("created %s ago", now().Sub(service.CreationTimestamp.Time).Round(1e9))
+	}
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityHigh,
+		Type:        IssueLoadBalancerPending,
+		Resource:    resource,
+		Description: fmt.Sprintf("LoadBalancer has no external IP or hostname (%s)", age),
+	})
+}
+
+// checkNetworkPolicies flags backends whose ingress is isolated by network
+// policies that allow none of the service's target ports. Policies are
+// additive, so a port is reachable if any rule of any selecting policy
+// allows it.
+func checkNetworkPolicies(snapshot *k8s.Snapshot, report *Report, service *corev1.Service, backends []*corev1.Pod, resource string) {
+	if len(backends) == 0 {
+		return
+	}
+	if err := snapshot.Err(k8s.ResourceNetworkPolicies); err != nil {
+		report.AddWarning(fmt.Sprintf("NetworkPolicies not inspected: %v", err))
+		return
+	}
+
+	for _, port := range service.Spec.Ports {
+		target := port.TargetPort
+		if target.Type == intstr.Int && target.IntVal == 0 {
+			target = intstr.FromInt32(port.Port)
+		}
+
+		isolating := map[string]bool{}
+		var blocked []string
+		for _, pod := range backends {
+			policies := selectingPolicies(snapshot, pod)
+			if len(policies) == 0 {
+				continue
+			}
+			allowed := false
+			for _, policy := range policies {
+				if policyAllowsPort(policy, pod, target, port.Protocol) {
+					allowed = true
+					break
+				}
+			}
+			if allowed {
+				continue
+			}
+			blocked = append(blocked, pod.Name)
+			for _, policy := range policies {
+				isolating[policy.Name] = true
+			}
+		}
+		if len(blocked) == 0 {
+			continue
+		}
+
+		var names []string
+		for name := range isolating {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		severity := SeverityHigh
+		if len(blocked) == len(backends) {
+			severity = SeverityCritical
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity: severity,
+			Type:     IssueNetworkPolicyIsolation,
+			Resource: resource,
+			Description: fmt.Sprintf("NetworkPolicy %s allows no ingress on port %s to %d of %d backend pod(s)",
+				strings.Join(names, ", "), target.String(), len(blocked), len(backends)),
+			Details: map[string]interface{}{"policies": names, "pods": blocked, "targetPort": target.String()},
+		})
+	}
+}
+
+// selectingPolicies returns the network policies that select a pod for
+// ingress
+func selectingPolicies(snapshot *k8s.Snapshot, pod *corev1.Pod) []*networkingv1.NetworkPolicy {
+	var policies []*networkingv1.NetworkPolicy
+	for i := range snapshot.NetworkPolicies {
+		policy := &snapshot.NetworkPolicies[i]
+		if policy.Namespace != pod.Namespace || !policyAppliesToIngress(policy) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// policyAppliesToIngress reports whether a policy restricts ingress, which
+// it does by default when policyTypes is unset
+func policyAppliesToIngress(policy *networkingv1.NetworkPolicy) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress {
+			return true
+		}
+	}
+	return false
+}
+
+// policyAllowsPort reports whether any ingress rule of a policy allows a
+// port, regardless of the traffic's source
+func policyAllowsPort(policy *networkingv1.NetworkPolicy, pod *corev1.Pod, target intstr.IntOrString, protocol corev1.Protocol) bool {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	for _, rule := range policy.Spec.Ingress {
+		if len(rule.Ports) == 0 {
+			return true
+		}
+		for _, port := range rule.Ports {
+			if port.Protocol != nil && *port.Protocol != protocol {
+				continue
+			}
+			if port.Port == nil || portMatches(*port.Port, port.EndPort, pod, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// portMatches compares a policy port with a target port, resolving names
+// through the pod's container ports
+func portMatches(policyPort intstr.IntOrString, endPort *int32, pod *corev1.Pod, target intstr.IntOrString) bool {
+	resolve := func(port intstr.IntOrString) int32 {
+		if port.Type == intstr.Int {
+			return port.IntVal
+		}
+		for _, containerPort := range containerPorts(pod, "") {
+			if containerPort.Name == port.StrVal {
+				return containerPort.ContainerPort
+			}
+		}
+		return 0
+	}
+
+	if policyPort.Type == intstr.String && target.Type == intstr.String {
+		return policyPort.StrVal == target.StrVal
+	}
+	want, got := resolve(target), resolve(policyPort)
+	if want == 0 || got == 0 {
+		return false
+	}
+	if endPort != nil {
+		return want >= got && want <= *endPort
+	}
+	return want == got
+}
+
+// serviceRemediations suggests commands for the issues found
+func (e *Engine) serviceRemediations(ctx context.Context, service *corev1.Service, issues []Issue) []Remediation {
+	ns := service.Namespace
+	remediations := []Remediation{
+		{
+			Title:       "Check service endpoints",
+			Description: "List the backend addresses the service routes to",
+			Command:     fmt.Sprintf("kubectl get endpointslices -n %s -l kubernetes.io/service-name=%s", ns, service.Name),
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	if hasIssueType(issues, IssueSelectorMismatch, "service/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Compare selector and pod labels",
+			Description: fmt.Sprintf("The service selects %s", labels.FormatLabels(service.Spec.Selector)),
+			Command:     fmt.Sprintf("kubectl get pods -n %s --show-labels", ns),
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+	for _, issue := range issues {
+		if issue.Type != IssuePortMismatch {
+			continue
+		}
+		available, _ := issue.Details["containerPorts"].([]string)
+		port, _ := issue.Details["port"].(int32)
+		if len(available) == 1 {
+			target := available[0]
+			if name, _, ok := strings.Cut(target, "/"); ok {
+				target = fmt.Sprintf("%q", name)
+			}
+			remediations = append(remediations, Remediation{
+				Title:       "Point targetPort at the container port",
+				Description: fmt.Sprintf("The backends only expose %s", available[0]),
+				Command: fmt.Sprintf(`kubectl patch service %s -n %s --type=json -p '[{"op":"replace","path":"/spec/ports/%d/targetPort","value":%s}]'`,
+					service.Name, ns, servicePortIndex(service, port), target),
+				Confidence: "Medium",
+				Safe:       false,
+			})
+		}
+	}
+	if hasIssueType(issues, IssueBackendNotReady, "service/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Inspect not-ready backends",
+			Description: "Readiness probe failures keep pods out of the endpoints",
+			Command:     fmt.Sprintf("kubectl get pods -n %s -l %s -o wide", ns, labels.FormatLabels(service.Spec.Selector)),
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+	if hasIssueType(issues, IssueNetworkPolicyIsolation, "service/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Review network policies",
+			Description: "Add an ingress rule that allows the service's target port",
+			Command:     fmt.Sprintf("kubectl describe networkpolicies -n %s", ns),
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+	if hasIssueType(issues, IssueLoadBalancerPending, "service/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Check the load balancer controller",
+			Description: "Events on the service explain quota, subnet or annotation errors",
+			Command:     fmt.Sprintf("kubectl describe service %s -n %s", service.Name, ns),
+			Confidence:  "High",
+			Safe:        true,
+		})
+		if clusterType := e.detectClusterType(ctx); clusterType == k8s.ClusterTypeKind {
+			remediations = append(remediations, Remediation{
+				Title:       "Use NodePort on kind",
+				Description: "kind has no load balancer unless cloud-provider-kind or MetalLB is installed",
+				Command:     fmt.Sprintf(`kubectl patch service %s -n %s -p '{"spec":{"type":"NodePort"}}'`, service.Name, ns),
+				Confidence:  "Medium",
+				Safe:        false,
+			})
+		}
+	}
+
+	return remediations
+}
+
+// servicePortIndex returns the index of a service port number
+func servicePortIndex(service *corev1.Service, port int32) int {
+	for i, p := range service.Spec.Ports {
+		if p.Port == port {
+			return i
+		}
+	}
+	return 0
+}
+
+// diagnoseIngress diagnoses an ingress's backends, TLS secrets and address
+func (e *Engine) diagnoseIngress(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceIngresses); err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+
+	ingress := snapshot.Ingress(e.namespace, name)
+	if ingress == nil {
+		return nil, fmt.Errorf("ingress %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for ingress: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	resource := "ingress/" + name
+
+	backends := checkIngressBackends(snapshot, report, ingress, resource)
+	checkIngressTLS(snapshot, report, ingress, resource)
+
+	if len(ingress.Status.LoadBalancer.Ingress) == 0 {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueLoadBalancerPending,
+			Resource:    resource,
+			Description: "Ingress has no address; no ingress controller has admitted it",
+			Details:     map[string]interface{}{"ingressClass": ingressClass(ingress)},
+		})
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = ingressRemediations(ingress, report.Issues)
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for ingress: %s (class %s, %d backend service(s))",
+		name, ingressClass(ingress), len(backends))
+	return report, nil
+}
+
+// checkIngressBackends checks that every backend service exists, exposes
+// the referenced port and has ready endpoints. Returns the backend services.
+func checkIngressBackends(snapshot *k8s.Snapshot, report *Report, ingress *networkingv1.Ingress, resource string) []string {
+	type backendRef struct {
+		path    string
+		backend *networkingv1.IngressServiceBackend
+	}
+	var refs []backendRef
+	if backend := ingress.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+		refs = append(refs, backendRef{path: "default backend", backend: backend.Service})
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil {
+				refs = append(refs, backendRef{path: rule.Host + path.Path, backend: path.Backend.Service})
+			}
+		}
+	}
+
+	checked := map[string]bool{}
+	var services []string
+	for _, ref := range refs {
+		backend := ref.backend
+		key := fmt.Sprintf("%s:%s", backend.Name, ingressPort(backend.Port))
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		services = append(services, backend.Name)
+
+		service := snapshot.Service(ingress.Namespace, backend.Name)
+		if service == nil {
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityCritical,
+				Type:        IssueMissingBackend,
+				Resource:    resource,
+				Description: fmt.Sprintf("%s routes to service %s, which does not exist", ref.path, backend.Name),
+				Details:     map[string]interface{}{"service": backend.Name, "path": ref.path},
+			})
+			continue
+		}
+
+		if !serviceHasPort(service, backend.Port) {
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssuePortMismatch,
+				Resource:    resource,
+				Description: fmt.Sprintf("%s routes to port %s of service %s, which it does not expose", ref.path, ingressPort(backend.Port), backend.Name),
+				Details:     map[string]interface{}{"service": backend.Name, "path": ref.path},
+			})
+			continue
+		}
+
+		if service.Spec.Type == corev1.ServiceTypeExternalName {
+			continue
+		}
+		// Count endpoints on a scratch report so only the verdict is kept
+		scratch := &Report{}
+		if ready, _ := checkEndpoints(snapshot, scratch, service, "service/"+service.Name); ready == 0 && len(scratch.Warnings) == 0 {
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityCritical,
+				Type:        IssueNoEndpoints,
+				Resource:    resource,
+				Description: fmt.Sprintf("%s routes to service %s, which has no ready endpoints", ref.path, backend.Name),
+				Details:     map[string]interface{}{"service": backend.Name, "path": ref.path},
+			})
+		}
+	}
+	return services
+}
+
+// serviceHasPort reports whether a service exposes an ingress backend port
+func serviceHasPort(service *corev1.Service, port networkingv1.ServiceBackendPort) bool {
+	for _, p := range service.Spec.Ports {
+		if (port.Name != "" && p.Name == port.Name) || (port.Name == "" && p.Port == port.Number) {
+			return true
+		}
+	}
+	return false
+}
+
+// ingressPort formats an ingress backend port by name or number
+func ingressPort(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprint(port.Number)
+}
+
+// checkIngressTLS checks that the TLS secrets exist and hold certificates
+func checkIngressTLS(snapshot *k8s.Snapshot, report *Report, ingress *networkingv1.Ingress, resource string) {
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+		if err := snapshot.SecretErr(ingress.Namespace, tls.SecretName); err != nil {
+			report.AddWarning(fmt.Sprintf("TLS secret %s not inspected: %v", tls.SecretName, err))
+			continue
+		}
+		hosts := strings.Join(tls.Hosts, ", ")
+		secret := snapshot.Secret(ingress.Namespace, tls.SecretName)
+		switch {
+		case secret == nil:
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssueMissingTLSSecret,
+				Resource:    resource,
+				Description: fmt.Sprintf("TLS secret %s for %s does not exist; the controller serves its default certificate", tls.SecretName, hosts),
+				Details:     map[string]interface{}{"secret": tls.SecretName, "hosts": tls.Hosts},
+			})
+		case secret.Type != corev1.SecretTypeTLS:
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityMedium,
+				Type:        IssueMissingTLSSecret,
+				Resource:    resource,
+				Description: fmt.Sprintf("TLS secret %s has type %s, not %s", tls.SecretName, secret.Type, corev1.SecretTypeTLS),
+				Details:     map[string]interface{}{"secret": tls.SecretName, "hosts": tls.Hosts},
+			})
+		}
+	}
+}
+
+// ingressClass returns an ingress's class from the spec or the legacy
+// annotation
+func ingressClass(ingress *networkingv1.Ingress) string {
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName
+	}
+	if class := ingress.Annotations["kubernetes.io/ingress.class"]; class != "" {
+		return class
+	}
+	return "<default>"
+}
+
+// ingressRemediations suggests commands for the issues found
+func ingressRemediations(ingress *networkingv1.Ingress, issues []Issue) []Remediation {
+	ns := ingress.Namespace
+	remediations := []Remediation{
+		{
+			Title:       "Describe the ingress",
+			Description: "Show the resolved backends and the controller's events",
+			Command:     fmt.Sprintf("kubectl describe ingress %s -n %s", ingress.Name, ns),
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	seen := map[string]bool{}
+	for _, issue := range issues {
+		switch issue.Type {
+		case IssueMissingBackend, IssueNoEndpoints:
+			service, _ := issue.Details["service"].(string)
+			if seen[service] {
+				continue
+			}
+			seen[service] = true
+			command := fmt.Sprintf("kubectl get services -n %s", ns)
+			if issue.Type == IssueNoEndpoints {
+				command = fmt.Sprintf("kubectl-pilot diagnose service %s -n %s", service, ns)
+			}
+			remediations = append(remediations, Remediation{
+				Title:       "Check backend service " + service,
+				Description: "The ingress can only route to a service with ready endpoints",
+				Command:     command,
+				Confidence:  "High",
+				Safe:        true,
+			})
+		case IssueMissingTLSSecret:
+			secret, _ := issue.Details["secret"].(string)
+			remediations = append(remediations, Remediation{
+				Title:       "Create TLS secret " + secret,
+				Description: "Create the secret from a certificate and key, or let cert-manager issue it",
+				Command:     fmt.Sprintf("kubectl create secret tls %s -n %s --cert=tls.crt --key=tls.key", secret, ns),
+				Confidence:  "Medium",
+				Safe:        false,
+			})
+		}
+	}
+	if hasIssueType(issues, IssueLoadBalancerPending, "ingress/") {
+		remediations = append(remediations, Remediation{
+			Title:       "Check ingress classes",
+			Description: "The ingress class must match an installed, running controller",
+			Command:     "kubectl get ingressclasses",
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+
+	return remediations
+}
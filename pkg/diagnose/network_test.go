@@ -0,0 +1,86 @@
+package diagnose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiagnoseService(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/services.yaml")
+
+	web, err := engine.DiagnoseResource("svc", "web")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(web, IssuePortMismatch, "service/web", SeverityHigh, "Port http (80) targets 8081, which 2 of 2 backend pod(s) don't expose (container ports: http/8080)") {
+		t.Errorf("expected a targetPort mismatch, got %+v", web.Issues)
+	}
+	if !hasIssue(web, IssueBackendNotReady, "service/web", SeverityMedium, "1 of 2 endpoint(s) are not ready") {
+		t.Errorf("expected a not-ready backend, got %+v", web.Issues)
+	}
+	if !hasIssue(web, IssueLoadBalancerPending, "service/web", SeverityHigh, "no external IP") {
+		t.Errorf("expected a pending load balancer, got %+v", web.Issues)
+	}
+	if hasIssueType(web.Issues, IssueNetworkPolicyIsolation, "service/") {
+		t.Errorf("allow-web admits all ports to the web pods: %+v", web.Issues)
+	}
+	if !hasCommand(web, `kubectl patch service web -n shop --type=json -p '[{"op":"replace","path":"/spec/ports/0/targetPort","value":"http"}]'`) {
+		t.Errorf("expected a targetPort patch, got %+v", web.Remediations)
+	}
+
+	api, err := engine.DiagnoseResource("service", "api")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(api, IssueSelectorMismatch, "service/api", SeverityCritical, "No pods match selector app=api") {
+		t.Errorf("expected a selector mismatch, got %+v", api.Issues)
+	}
+	if misses, _ := api.Issues[0].Details["nearMisses"].([]string); !reflect.DeepEqual(misses, []string{"api-1 has app=api-v2"}) {
+		t.Errorf("nearMisses = %v", misses)
+	}
+	if hasIssueType(api.Issues, IssueNoEndpoints, "service/") {
+		t.Errorf("missing endpoints are explained by the selector: %+v", api.Issues)
+	}
+
+	db, err := engine.DiagnoseResource("services", "db")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(db, IssueNetworkPolicyIsolation, "service/db", SeverityCritical, "NetworkPolicy default-deny allows no ingress on port 5432 to 1 of 1") {
+		t.Errorf("expected default-deny to isolate the database, got %+v", db.Issues)
+	}
+}
+
+func TestDiagnoseIngress(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/services.yaml")
+
+	report, err := engine.DiagnoseResource("ing", "storefront")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueMissingBackend, "ingress/storefront", SeverityCritical, "shop.example.com/cart routes to service cart, which does not exist") {
+		t.Errorf("expected a missing backend, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueNoEndpoints, "ingress/storefront", SeverityCritical, "service api, which has no ready endpoints") {
+		t.Errorf("expected the api backend to have no endpoints, got %+v", report.Issues)
+	}
+	if hasIssue(report, IssueNoEndpoints, "ingress/storefront", SeverityCritical, "service web") {
+		t.Errorf("web has a ready endpoint: %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueMissingTLSSecret, "ingress/storefront", SeverityHigh, "TLS secret shop-tls for shop.example.com does not exist") {
+		t.Errorf("expected a missing TLS secret, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueMissingTLSSecret, "ingress/storefront", SeverityMedium, "legacy-cert has type Opaque") {
+		t.Errorf("expected a non-TLS secret, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueLoadBalancerPending, "ingress/storefront", SeverityMedium, "no address") {
+		t.Errorf("expected an unadmitted ingress, got %+v", report.Issues)
+	}
+	if !hasCommand(report, "kubectl create secret tls shop-tls -n shop --cert=tls.crt --key=tls.key") {
+		t.Errorf("expected a create secret remediation, got %+v", report.Remediations)
+	}
+	if !hasCommand(report, "kubectl-pilot diagnose service api -n shop") {
+		t.Errorf("expected a service diagnosis remediation, got %+v", report.Remediations)
+	}
+}
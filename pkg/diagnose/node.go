@@ -0,0 +1,564 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+const (
+	// evictionStormThreshold is the number of evicted pods on a node that
+	// indicates repeated resource pressure rather than a one-off eviction
+	evictionStormThreshold = 5
+	// maxKubeletSkew is the number of minor versions a kubelet may be older
+	// than the API server
+	maxKubeletSkew = 3
+	// fullNodePercent is the share of allocatable CPU or memory requested
+	// beyond which new pods are unlikely to fit
+	fullNodePercent = 95
+)
+
+// expectedTaints are taints that nodes carry by design, or that mirror a
+// condition or cordon that is reported on its own
+var expectedTaints = map[string]bool{
+	"node-role.kubernetes.io/control-plane": true,
+	"node-role.kubernetes.io/master":        true,
+	corev1.TaintNodeNotReady:                true,
+	corev1.TaintNodeUnreachable:             true,
+	corev1.TaintNodeUnschedulable:           true,
+	corev1.TaintNodeMemoryPressure:          true,
+	corev1.TaintNodeDiskPressure:            true,
+	corev1.TaintNodePIDPressure:             true,
+	corev1.TaintNodeNetworkUnavailable:      true,
+}
+
+// pressureConditions are the node conditions that are unhealthy when true
+var pressureConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+}
+
+// diagnoseNode diagnoses a node's conditions, scheduling, version and
+// capacity, and links its issues to the pods on it
+func (e *Engine) diagnoseNode(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceNodes); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	node := snapshot.Node(name)
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for node: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	warnPartialPods(snapshot, report)
+	analyzeNode(snapshot, report, node)
+
+	if len(report.Issues) > 0 {
+		report.Remediations = nodeRemediations(report.Issues)
+		if needsCapacity(report.Issues) {
+			if remediation, ok := capacityRemediation(e.detectClusterType(ctx)); ok {
+				report.Remediations = append(report.Remediations, remediation)
+			}
+		}
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for node: %s (%s, kubelet %s, %d pod(s))",
+		name, nodeStatus(node), node.Status.NodeInfo.KubeletVersion, len(podsOnNode(snapshot, name)))
+	return report, nil
+}
+
+// diagnoseNodes diagnoses every node
+func (e *Engine) diagnoseNodes(ctx context.Context) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceNodes); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	report := &Report{
+		Summary:      "Node diagnostics",
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	warnPartialPods(snapshot, report)
+	unhealthy := analyzeNodes(snapshot, report)
+
+	if len(report.Issues) > 0 {
+		report.Remediations = nodeRemediations(report.Issues)
+	}
+
+	report.Summary = fmt.Sprintf("Found %d issue(s) on %d of %d nodes", len(report.Issues), unhealthy, len(snapshot.Nodes))
+	return report, nil
+}
+
+// analyzeNodes adds the issues of every node in the snapshot and returns
+// the number of nodes with issues
+func analyzeNodes(snapshot *k8s.Snapshot, report *Report) int {
+	if len(snapshot.Nodes) == 0 {
+		return 0
+	}
+
+	unhealthy := 0
+	for i := range snapshot.Nodes {
+		nodeReport := &Report{}
+		analyzeNode(snapshot, nodeReport, &snapshot.Nodes[i])
+		if len(nodeReport.Issues) == 0 {
+			continue
+		}
+		unhealthy++
+		report.Issues = append(report.Issues, nodeReport.Issues...)
+		for _, warning := range nodeReport.Warnings {
+			report.AddWarning(warning)
+		}
+	}
+	return unhealthy
+}
+
+// analyzeNode adds the issues found on a node to the report
+func analyzeNode(snapshot *k8s.Snapshot, report *Report, node *corev1.Node) {
+	resource := "node/" + node.Name
+	pods := podsOnNode(snapshot, node.Name)
+
+	checkNodeConditions(report, node, pods, resource)
+	checkNodeScheduling(report, node, resource)
+	checkKubeletVersion(snapshot, report, node, resource)
+	checkNodeCapacity(snapshot, report, node, pods, resource)
+	checkEvictions(report, node, pods, resource)
+}
+
+// checkNodeConditions flags NotReady nodes and resource pressure, linked to
+// the pods running on the node
+func checkNodeConditions(report *Report, node *corev1.Node, pods []*corev1.Pod, resource string) {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != corev1.NodeReady || condition.Status == corev1.ConditionTrue {
+			continue
+		}
+		description := fmt.Sprintf("Node is NotReady (%s): %s", condition.Reason, condition.Message)
+		if condition.Status == corev1.ConditionUnknown {
+			description = fmt.Sprintf("Node is NotReady: the kubelet stopped posting status (%s)", condition.Reason)
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityCritical,
+			Type:        IssueNodeNotReady,
+			Resource:    resource,
+			Description: description,
+			Details:     map[string]interface{}{"since": condition.LastTransitionTime.UTC().Format("2006-01-02T15:04:05Z"), "pods": podNames(pods)},
+		})
+	}
+
+	for _, conditionType := range pressureConditions {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type != conditionType || condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			var affected []string
+			for _, pod := range pods {
+				if !podReady(pod) || pod.Status.Reason == "Evicted" {
+					affected = append(affected, pod.Name)
+				}
+			}
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssueNodePressure,
+				Resource:    resource,
+				Description: fmt.Sprintf("Node has %s: %s", condition.Type, condition.Message),
+				Details:     map[string]interface{}{"condition": string(condition.Type), "pods": affected},
+			})
+		}
+	}
+}
+
+// checkNodeScheduling flags cordoned nodes and taints that are neither
+// expected nor explained by a condition
+func checkNodeScheduling(report *Report, node *corev1.Node, resource string) {
+	if node.Spec.Unschedulable {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityMedium,
+			Type:        IssueNodeCordoned,
+			Resource:    resource,
+			Description: "Node is cordoned; no new pods will be scheduled on it",
+		})
+	}
+
+	var unexpected []string
+	var taints []corev1.Taint
+	for _, taint := range node.Spec.Taints {
+		if expectedTaints[taint.Key] || taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if taint.Key == "node.cloudprovider.kubernetes.io/uninitialized" {
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityHigh,
+				Type:        IssueNodeTainted,
+				Resource:    resource,
+				Description: "The cloud controller manager has not initialized the node; it accepts no regular pods",
+				Details:     map[string]interface{}{"taints": []string{taint.ToString()}},
+			})
+			continue
+		}
+		unexpected = append(unexpected, taint.ToString())
+		taints = append(taints, taint)
+	}
+	if len(unexpected) == 0 {
+		return
+	}
+
+	severity := SeverityLow
+	for _, taint := range taints {
+		if taint.Effect == corev1.TaintEffectNoExecute {
+			severity = SeverityMedium
+		}
+	}
+	report.Issues = append(report.Issues, Issue{
+		Severity:    severity,
+		Type:        IssueNodeTainted,
+		Resource:    resource,
+		Description: fmt.Sprintf("Node has taints that pods must tolerate: %s", strings.Join(unexpected, ", ")),
+		Details:     map[string]interface{}{"taints": unexpected},
+	})
+}
+
+// checkKubeletVersion compares the kubelet with the API server, which it
+// may trail by up to three minor versions but never be newer than
+func checkKubeletVersion(snapshot *k8s.Snapshot, report *Report, node *corev1.Node, resource string) {
+	kubelet, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion)
+	if err != nil {
+		return
+	}
+	server, err := version.ParseGeneric(snapshot.ServerVersion)
+	if err != nil || server.Major() == 0 {
+		return
+	}
+
+	skew := int(server.Minor()) - int(kubelet.Minor())
+	switch {
+	case kubelet.Major() != server.Major() || skew < 0:
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssueVersionSkew,
+			Resource:    resource,
+			Description: fmt.Sprintf("Kubelet %s is newer than the API server %s, which is unsupported", kubelet, server),
+			Details:     map[string]interface{}{"kubelet": kubelet.String(), "server": server.String()},
+		})
+	case skew > maxKubeletSkew:
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityHigh,
+			Type:     IssueVersionSkew,
+			Resource: resource,
+			Description: fmt.Sprintf("Kubelet %s is %d minor versions behind the API server %s; at most %d are supported",
+				kubelet, skew, server, maxKubeletSkew),
+			Details: map[string]interface{}{"kubelet": kubelet.String(), "server": server.String(), "skew": skew},
+		})
+	case skew >= 2:
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityLow,
+			Type:        IssueVersionSkew,
+			Resource:    resource,
+			Description: fmt.Sprintf("Kubelet %s is %d minor versions behind the API server %s; upgrade before the next control plane upgrade", kubelet, skew, server),
+			Details:     map[string]interface{}{"kubelet": kubelet.String(), "server": server.String(), "skew": skew},
+		})
+	}
+}
+
+// checkNodeCapacity compares the requests of the pods on a node with its
+// allocatable resources. It needs every namespace's pods to be accurate.
+func checkNodeCapacity(snapshot *k8s.Snapshot, report *Report, node *corev1.Node, pods []*corev1.Pod, nodeResource string) {
+	if snapshot.Namespace != "" {
+		return
+	}
+
+	var active []*corev1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			active = append(active, pod)
+		}
+	}
+
+	var full []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		allocatable, ok := node.Status.Allocatable[name]
+		if !ok || allocatable.IsZero() {
+			continue
+		}
+		requested := resource.Quantity{}
+		for _, pod := range active {
+			request := podRequest(pod, name)
+			requested.Add(request)
+		}
+		percent := requested.MilliValue() * 100 / allocatable.MilliValue()
+		if percent >= fullNodePercent {
+			full = append(full, fmt.Sprintf("%s %s/%s (%d%%)", name, requested.String(), allocatable.String(), percent))
+		}
+	}
+	if allocatable, ok := node.Status.Allocatable[corev1.ResourcePods]; ok && int64(len(active)) >= allocatable.Value() {
+		full = append(full, fmt.Sprintf("pods %d/%d", len(active), allocatable.Value()))
+	}
+	if len(full) == 0 {
+		return
+	}
+
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityMedium,
+		Type:        IssueNodeCapacity,
+		Resource:    nodeResource,
+		Description: fmt.Sprintf("Node is full; requests reach its allocatable %s", strings.Join(full, ", ")),
+		Details:     map[string]interface{}{"requested": full},
+	})
+}
+
+// podRequest returns a pod's effective request for a resource: the larger
+// of its containers' sum and its largest init container, plus overhead
+func podRequest(pod *corev1.Pod, name corev1.ResourceName) resource.Quantity {
+	total := resource.Quantity{}
+	for _, container := range pod.Spec.Containers {
+		if request, ok := container.Resources.Requests[name]; ok {
+			total.Add(request)
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if request, ok := container.Resources.Requests[name]; ok && request.Cmp(total) > 0 {
+			total = request.DeepCopy()
+		}
+	}
+	if overhead, ok := pod.Spec.Overhead[name]; ok {
+		total.Add(overhead)
+	}
+	return total
+}
+
+// checkEvictions flags nodes that have evicted many pods, which points at
+// sustained resource pressure
+func checkEvictions(report *Report, node *corev1.Node, pods []*corev1.Pod, resource string) {
+	var evicted []string
+	reasons := map[string]int{}
+	for _, pod := range pods {
+		if pod.Status.Reason != "Evicted" {
+			continue
+		}
+		evicted = append(evicted, pod.Name)
+		reasons[evictionCause(pod.Status.Message)]++
+	}
+	if len(evicted) < evictionStormThreshold {
+		return
+	}
+
+	var causes []string
+	for cause, count := range reasons {
+		causes = append(causes, fmt.Sprintf("%s (%d)", cause, count))
+	}
+	sort.Strings(causes)
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityHigh,
+		Type:        IssueEvictionStorm,
+		Resource:    resource,
+		Description: fmt.Sprintf("Node evicted %d pods: %s", len(evicted), strings.Join(causes, ", ")),
+		Details:     map[string]interface{}{"pods": evicted},
+	})
+}
+
+// evictionCause extracts the starved resource from a kubelet eviction
+// message such as "The node was low on resource: memory. ..."
+func evictionCause(message string) string {
+	const marker = "low on resource: "
+	i := strings.Index(message, marker)
+	if i < 0 {
+		return "unknown"
+	}
+	cause := message[i+len(marker):]
+	if end := strings.IndexAny(cause, ". "); end >= 0 {
+		cause = cause[:end]
+	}
+	return cause
+}
+
+// analyzePodNode links a pod to the problems of the node it runs on
+func analyzePodNode(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	if pod.Status.Reason == "Evicted" {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssuePodEvicted,
+			Resource:    pod.Name,
+			Description: fmt.Sprintf("Pod was evicted from node %s: %s", pod.Spec.NodeName, pod.Status.Message),
+			Details:     map[string]interface{}{"node": pod.Spec.NodeName},
+		})
+	}
+
+	if pod.Spec.NodeName == "" || snapshot.Err(k8s.ResourceNodes) != nil {
+		return
+	}
+	node := snapshot.Node(pod.Spec.NodeName)
+	if node == nil {
+		return
+	}
+
+	nodeReport := &Report{}
+	checkNodeConditions(nodeReport, node, nil, "node/"+node.Name)
+	for _, issue := range nodeReport.Issues {
+		report.Issues = append(report.Issues, Issue{
+			Severity:    issue.Severity,
+			Type:        issue.Type,
+			Resource:    pod.Name,
+			Description: fmt.Sprintf("Pod runs on node %s: %s", node.Name, issue.Description),
+			Details:     map[string]interface{}{"node": node.Name},
+		})
+	}
+}
+
+// podsOnNode returns the pods in the snapshot that are bound to a node
+func podsOnNode(snapshot *k8s.Snapshot, node string) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for i := range snapshot.Pods {
+		if snapshot.Pods[i].Spec.NodeName == node {
+			pods = append(pods, &snapshot.Pods[i])
+		}
+	}
+	return pods
+}
+
+// podNames returns the namespaced names of pods
+func podNames(pods []*corev1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
+
+// nodeStatus summarizes a node as Ready, NotReady and/or cordoned
+func nodeStatus(node *corev1.Node) string {
+	status := "NotReady"
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			status = "Ready"
+		}
+	}
+	if node.Spec.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+// warnPartialPods notes that node checks only see the snapshot's namespace
+func warnPartialPods(snapshot *k8s.Snapshot, report *Report) {
+	if snapshot.Namespace != "" {
+		report.AddWarning(fmt.Sprintf("Only pods in namespace %s were inspected; use --all-namespaces to check node requests and evictions", snapshot.Namespace))
+	}
+}
+
+// nodeRemediations suggests commands for the node issues found
+func nodeRemediations(issues []Issue) []Remediation {
+	var remediations []Remediation
+	seen := map[string]bool{}
+	add := func(remediation Remediation) {
+		if seen[remediation.Command] {
+			return
+		}
+		seen[remediation.Command] = true
+		remediations = append(remediations, remediation)
+	}
+
+	for _, issue := range issues {
+		node, ok := strings.CutPrefix(issue.Resource, "node/")
+		if !ok {
+			continue
+		}
+		add(Remediation{
+			Title:       "Describe node " + node,
+			Description: "Show the node's conditions, taints and allocated resources",
+			Command:     "kubectl describe node " + node,
+			Confidence:  "High",
+			Safe:        true,
+		})
+
+		switch issue.Type {
+		case IssueNodeNotReady:
+			add(Remediation{
+				Title:       "Check the node's events",
+				Description: "Find out when and why the kubelet stopped reporting",
+				Command:     fmt.Sprintf("kubectl get events -A --field-selector involvedObject.kind=Node,involvedObject.name=%s", node),
+				Confidence:  "High",
+				Safe:        true,
+			})
+			add(Remediation{
+				Title:       "Drain node " + node,
+				Description: "Move the node's pods elsewhere while the kubelet is repaired",
+				Command:     fmt.Sprintf("kubectl drain %s --ignore-daemonsets --delete-emptydir-data", node),
+				Confidence:  "Medium",
+				Safe:        false,
+			})
+		case IssueNodePressure, IssueNodeCapacity:
+			add(Remediation{
+				Title:       "Check node resource usage",
+				Description: "Compare actual usage with requests to find the pods consuming the node",
+				Command:     "kubectl top pods -A --sort-by=memory --field-selector spec.nodeName=" + node,
+				Confidence:  "Medium",
+				Safe:        true,
+			})
+		case IssueNodeCordoned:
+			add(Remediation{
+				Title:       "Uncordon node " + node,
+				Description: "Allow scheduling again once maintenance is done",
+				Command:     "kubectl uncordon " + node,
+				Confidence:  "Medium",
+				Safe:        false,
+			})
+		case IssueNodeTainted:
+			taints, _ := issue.Details["taints"].([]string)
+			for _, taint := range taints {
+				key, effect := taint, ""
+				if i := strings.LastIndex(taint, ":"); i >= 0 {
+					key, effect = taint[:i], taint[i:]
+				}
+				if i := strings.Index(key, "="); i >= 0 {
+					key = key[:i]
+				}
+				add(Remediation{
+					Title:       "Remove taint " + taint,
+					Description: "Remove the taint if it is not intended",
+					Command:     fmt.Sprintf("kubectl taint nodes %s %s%s-", node, key, effect),
+					Confidence:  "Low",
+					Safe:        false,
+				})
+			}
+		case IssueVersionSkew:
+			add(Remediation{
+				Title:       "Compare kubelet versions",
+				Description: "Upgrade nodes that trail the control plane",
+				Command:     "kubectl get nodes -o wide",
+				Confidence:  "High",
+				Safe:        true,
+			})
+		case IssueEvictionStorm:
+			add(Remediation{
+				Title:       "Clean up evicted pods",
+				Description: "Evicted pods are kept until garbage collected; delete them once the cause is fixed",
+				Command:     "kubectl delete pods -A --field-selector status.phase=Failed,spec.nodeName=" + node,
+				Confidence:  "Medium",
+				Safe:        false,
+			})
+		}
+	}
+	return remediations
+}
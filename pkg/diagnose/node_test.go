@@ -0,0 +1,164 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+// newNodeTestEngine returns an all-namespaces engine for the node fixtures,
+// served by a v1.29 API server
+func newNodeTestEngine(t *testing.T) *Engine {
+	t.Helper()
+
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	client := k8stest.NewClient(t, "shop", "testdata/nodes.yaml")
+	discovery := client.Clientset().Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.FakedServerVersion = &version.Info{GitVersion: "v1.29.4"}
+	return NewEngineWithClient(client, provider, "shop", true)
+}
+
+func TestDiagnoseNode(t *testing.T) {
+	engine := newNodeTestEngine(t)
+
+	tests := []struct {
+		node     string
+		issue    IssueType
+		severity Severity
+		text     string
+		command  string
+	}{
+		{
+			node:     "node-a",
+			issue:    IssueNodeCapacity,
+			severity: SeverityMedium,
+			text:     "Node is full; requests reach its allocatable cpu 2/2 (100%)",
+			command:  "kubectl top pods -A --sort-by=memory --field-selector spec.nodeName=node-a",
+		},
+		{
+			node:     "node-b",
+			issue:    IssueNodeNotReady,
+			severity: SeverityCritical,
+			text:     "the kubelet stopped posting status (NodeStatusUnknown)",
+			command:  "kubectl drain node-b --ignore-daemonsets --delete-emptydir-data",
+		},
+		{
+			node:     "node-b",
+			issue:    IssueNodeCordoned,
+			severity: SeverityMedium,
+			text:     "Node is cordoned",
+			command:  "kubectl uncordon node-b",
+		},
+		{
+			node:     "node-b",
+			issue:    IssueNodeTainted,
+			severity: SeverityMedium,
+			text:     "dedicated=batch:NoExecute",
+			command:  "kubectl taint nodes node-b dedicated:NoExecute-",
+		},
+		{
+			node:     "node-b",
+			issue:    IssueVersionSkew,
+			severity: SeverityHigh,
+			text:     "Kubelet 1.24.17 is 5 minor versions behind the API server 1.29.4",
+			command:  "kubectl get nodes -o wide",
+		},
+		{
+			node:     "node-c",
+			issue:    IssueNodePressure,
+			severity: SeverityHigh,
+			text:     "Node has MemoryPressure",
+			command:  "kubectl describe node node-c",
+		},
+		{
+			node:     "node-c",
+			issue:    IssueEvictionStorm,
+			severity: SeverityHigh,
+			text:     "Node evicted 5 pods: ephemeral-storage (1), memory (4)",
+			command:  "kubectl delete pods -A --field-selector status.phase=Failed,spec.nodeName=node-c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.node+"/"+string(tt.issue), func(t *testing.T) {
+			report, err := engine.DiagnoseResource("node", tt.node)
+			if err != nil {
+				t.Fatalf("DiagnoseResource: %v", err)
+			}
+			if !hasIssue(report, tt.issue, "node/"+tt.node, tt.severity, tt.text) {
+				t.Errorf("expected %q, got %+v", tt.text, report.Issues)
+			}
+			if !hasCommand(report, tt.command) {
+				t.Errorf("expected remediation %q, got %+v", tt.command, report.Remediations)
+			}
+		})
+	}
+
+	report, err := engine.DiagnoseResource("node", "node-a")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if issue.Type == IssueNodeTainted {
+			t.Errorf("control-plane taint reported: %+v", issue)
+		}
+	}
+
+	if _, err := engine.DiagnoseResource("node", "node-z"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestDiagnosePodOnUnhealthyNode(t *testing.T) {
+	engine := newNodeTestEngine(t)
+
+	report, err := engine.DiagnoseResource("pod", "api-1")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(report, IssueNodeNotReady, "api-1", SeverityCritical, "Pod runs on node node-b: Node is NotReady") {
+		t.Errorf("expected the pod to be linked to its NotReady node, got %+v", report.Issues)
+	}
+
+	report, err = engine.DiagnoseResource("pod", "report-1")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(report, IssuePodEvicted, "report-1", SeverityHigh, "Pod was evicted from node node-c") {
+		t.Errorf("expected an eviction, got %+v", report.Issues)
+	}
+}
+
+func TestDiagnoseClusterIncludesNodes(t *testing.T) {
+	engine := newNodeTestEngine(t)
+
+	report, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+	for _, node := range []string{"node-b", "node-c"} {
+		if !reported(report, "node/"+node) {
+			t.Errorf("expected node %s in the cluster report, got %+v", node, report.Issues)
+		}
+	}
+	if !strings.Contains(report.Summary, "3 nodes") {
+		t.Errorf("summary = %q", report.Summary)
+	}
+
+	// Namespaced engines can't see every pod on a node, so requests aren't checked
+	report, err = newTestEngine(t, "shop", "testdata/nodes.yaml").DiagnoseResource("node", "node-a")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if len(report.Issues) != 0 || len(report.Warnings) != 1 {
+		t.Errorf("expected only a partial-pods warning, got issues %+v, warnings %v", report.Issues, report.Warnings)
+	}
+}
@@ -0,0 +1,443 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// Probe defaults applied by the API server when fields are unset
+const (
+	defaultProbePeriod    = 10
+	defaultProbeThreshold = 3
+	// startupProbePeriod and startupProbeThreshold size a suggested startup
+	// probe when the startup time is unknown: up to five minutes
+	startupProbePeriod    = 10
+	startupProbeThreshold = 30
+)
+
+var (
+	// statusCodePattern matches the kubelet's HTTP probe failure message
+	statusCodePattern = regexp.MustCompile(`statuscode: (\d+)`)
+	// fieldPathPattern extracts the container from "spec.containers{api}"
+	fieldPathPattern = regexp.MustCompile(`^spec\.(?:initContainers|containers)\{(.+)\}$`)
+)
+
+// probeFailures are the Unhealthy events of one probe of one container
+type probeFailures struct {
+	Count   int32
+	Message string
+}
+
+// analyzeProbes correlates Unhealthy probe events with the probes in the pod
+// spec and flags probe misconfigurations
+func analyzeProbes(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	failures := probeEvents(snapshot, pod)
+	workload := podWorkload(snapshot, pod)
+
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		status := containerStatus(pod, container.Name)
+		probes := map[string]*corev1.Probe{
+			"Startup":   container.StartupProbe,
+			"Liveness":  container.LivenessProbe,
+			"Readiness": container.ReadinessProbe,
+		}
+		add := func(issue Issue) {
+			issue.Resource = fmt.Sprintf("%s/%s", pod.Name, container.Name)
+			if issue.Details == nil {
+				issue.Details = map[string]interface{}{}
+			}
+			issue.Details["container"] = container.Name
+			if workload != "" {
+				issue.Details["workload"] = workload
+				if patch, ok := issue.Details["patch"].(string); ok {
+					issue.Details["patch"] = retargetPatch(patch, workload)
+				}
+			} else {
+				delete(issue.Details, "patch")
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+
+		for _, kind := range []string{"Startup", "Liveness", "Readiness"} {
+			probe := probes[kind]
+			failed, ok := failures[container.Name][kind]
+			if probe == nil || !ok {
+				continue
+			}
+			severity := SeverityHigh
+			effect := "the kubelet restarts the container"
+			if kind == "Readiness" {
+				severity = SeverityMedium
+				effect = "the pod is removed from Service endpoints"
+			}
+			add(Issue{
+				Severity: severity,
+				Type:     IssueProbeFailure,
+				Description: fmt.Sprintf("%s probe (%s) failed %d time(s), so %s: %s",
+					kind, describeProbe(probe, container), failed.Count, effect, failed.Message),
+				Details: map[string]interface{}{"probe": strings.ToLower(kind), "failures": failed.Count},
+			})
+
+			if match := statusCodePattern.FindStringSubmatch(failed.Message); match != nil && probe.HTTPGet != nil {
+				add(statusCodeIssue(container, i, kind, probe, probes, failures[container.Name], match[1]))
+			}
+		}
+
+		if issue, ok := startupBudgetIssue(pod, container, i, status, failures[container.Name]); ok {
+			add(issue)
+		}
+		for _, kind := range []string{"Startup", "Liveness", "Readiness"} {
+			if issue, ok := probePortIssue(container, i, kind, probes[kind]); ok {
+				add(issue)
+			}
+		}
+		if liveness, readiness := container.LivenessProbe, container.ReadinessProbe; liveness != nil && readiness != nil &&
+			reflect.DeepEqual(liveness.ProbeHandler, readiness.ProbeHandler) &&
+			probeThreshold(liveness) <= probeThreshold(readiness) {
+			threshold := probeThreshold(readiness) * 2
+			add(Issue{
+				Severity: SeverityLow,
+				Type:     IssueProbeFailure,
+				Description: fmt.Sprintf("Liveness and readiness probes of container %s are identical (%s); a slow dependency restarts the container instead of only taking it out of service",
+					container.Name, describeProbe(liveness, container)),
+				Details: map[string]interface{}{
+					"probe": "liveness",
+					"patch": fmt.Sprintf(`[{"op":"add","path":"/spec/template/spec/containers/%d/livenessProbe/failureThreshold","value":%d}]`, i, threshold),
+				},
+			})
+		}
+	}
+}
+
+// retargetPatch moves a patch built against /spec/template/spec to the
+// workload's pod template, which CronJobs nest in their job template
+func retargetPatch(patch, workload string) string {
+	return strings.ReplaceAll(patch, `"path":"/spec/template/spec/`, `"path":"`+podSpecPath(workload)+`/`)
+}
+
+// probeEvents groups Unhealthy events by container and probe kind
+func probeEvents(snapshot *k8s.Snapshot, pod *corev1.Pod) map[string]map[string]probeFailures {
+	failures := map[string]map[string]probeFailures{}
+	if snapshot.Err(k8s.ResourceEvents) != nil {
+		return failures
+	}
+
+	for _, event := range snapshot.EventsFor("Pod", pod.Namespace, pod.Name) {
+		if event.Reason != "Unhealthy" {
+			continue
+		}
+		kind, message, ok := strings.Cut(event.Message, " probe failed: ")
+		if !ok {
+			// Probe errors, as opposed to failures, read "Liveness probe errored: ..."
+			kind, message, ok = strings.Cut(event.Message, " probe errored: ")
+		}
+		if !ok {
+			continue
+		}
+
+		container := ""
+		if match := fieldPathPattern.FindStringSubmatch(event.InvolvedObject.FieldPath); match != nil {
+			container = match[1]
+		} else if len(pod.Spec.Containers) == 1 {
+			container = pod.Spec.Containers[0].Name
+		}
+		if failures[container] == nil {
+			failures[container] = map[string]probeFailures{}
+		}
+
+		count := event.Count
+		if count == 0 {
+			count = 1
+		}
+		failed := failures[container][kind]
+		failed.Count += count
+		// Events are sorted oldest first, so this keeps the latest message
+		failed.Message = strings.TrimSpace(message)
+		failures[container][kind] = failed
+	}
+	return failures
+}
+
+// statusCodeIssue explains an HTTP probe answered with a non-2xx status.
+// A 404 usually means the path is wrong; when another probe of the
+// container uses a path that isn't failing, it is proposed instead.
+func statusCodeIssue(container *corev1.Container, index int, kind string, probe *corev1.Probe,
+	probes map[string]*corev1.Probe, failures map[string]probeFailures, code string) Issue {
+	endpoint := describeProbe(probe, container)
+	issue := Issue{
+		Severity: SeverityMedium,
+		Type:     IssueProbeFailure,
+		Description: fmt.Sprintf("%s probe %s answers HTTP %s; the application reports itself unhealthy",
+			kind, endpoint, code),
+		Details: map[string]interface{}{
+			"probe":      strings.ToLower(kind),
+			"statusCode": code,
+			"port":       resolvePort(probe.HTTPGet.Port, container),
+		},
+	}
+	if code != "404" && code != "405" {
+		return issue
+	}
+
+	issue.Severity = SeverityHigh
+	issue.Description = fmt.Sprintf("%s probe %s answers HTTP %s; the probe path or method is probably wrong", kind, endpoint, code)
+	for _, other := range []string{"Startup", "Liveness", "Readiness"} {
+		candidate := probes[other]
+		if _, failing := failures[other]; failing || other == kind || candidate == nil || candidate.HTTPGet == nil ||
+			candidate.HTTPGet.Path == probe.HTTPGet.Path {
+			continue
+		}
+		issue.Details["patch"] = fmt.Sprintf(`[{"op":"replace","path":"/spec/template/spec/containers/%d/%sProbe/httpGet/path","value":%q}]`,
+			index, strings.ToLower(kind), candidate.HTTPGet.Path)
+		issue.Description += fmt.Sprintf(" (the %s probe's %s is not failing)", strings.ToLower(other), candidate.HTTPGet.Path)
+		break
+	}
+	return issue
+}
+
+// startupBudgetIssue flags containers that take longer to start than their
+// liveness (or startup) probe allows, so they are killed while starting
+func startupBudgetIssue(pod *corev1.Pod, container *corev1.Container, index int,
+	status *corev1.ContainerStatus, failures map[string]probeFailures) (Issue, bool) {
+	kind, probe := "Liveness", container.LivenessProbe
+	if container.StartupProbe != nil {
+		kind, probe = "Startup", container.StartupProbe
+	}
+	if probe == nil || status == nil {
+		return Issue{}, false
+	}
+	budget := time.Duration(probe.InitialDelaySeconds+probePeriod(probe)*probeThreshold(probe)) * time.Second
+
+	// Slow readiness alone is harmless; the probe must have killed it
+	killed := status.RestartCount > 0 || failures["Liveness"].Count > 0 || failures["Startup"].Count > 0
+
+	var description string
+	startup, observed := observedStartup(pod, status)
+	switch {
+	case observed && startup > budget && killed:
+		description = fmt.Sprintf("Container %s took %s to become ready, but its %s probe allows only %s",
+			container.Name, startup.Round(time.Second), strings.ToLower(kind), budget)
+	case failures[kind].Count > 0 && status.LastTerminationState.Terminated != nil:
+		last := status.LastTerminationState.Terminated
+		ran := last.FinishedAt.Sub(last.StartedAt.Time)
+		if ran <= 0 || ran > budget+time.Duration(probePeriod(probe))*time.Second {
+			return Issue{}, false
+		}
+		description = fmt.Sprintf("Container %s was killed after %s, within its %s probe's %s startup allowance; it is probably restarted before it finishes starting",
+			container.Name, ran.Round(time.Second), strings.ToLower(kind), budget)
+	default:
+		return Issue{}, false
+	}
+
+	// Give startup twice the observed time, or five minutes if unknown
+	threshold := int32(startupProbeThreshold)
+	if observed {
+		threshold = int32(2*startup/time.Second)/startupProbePeriod + 1
+	}
+	var patch string
+	if kind == "Startup" {
+		patch = fmt.Sprintf(`[{"op":"replace","path":"/spec/template/spec/containers/%d/startupProbe/failureThreshold","value":%d}]`, index, threshold)
+	} else {
+		startupProbe := corev1.Probe{ProbeHandler: probe.ProbeHandler, PeriodSeconds: startupProbePeriod, FailureThreshold: threshold}
+		value, err := json.Marshal(startupProbe)
+		if err != nil {
+			return Issue{}, false
+		}
+		patch = fmt.Sprintf(`[{"op":"add","path":"/spec/template/spec/containers/%d/startupProbe","value":%s}]`, index, value)
+	}
+
+	return Issue{
+		Severity:    SeverityHigh,
+		Type:        IssueProbeFailure,
+		Description: description,
+		Details: map[string]interface{}{
+			"probe":  strings.ToLower(kind),
+			"budget": budget.String(),
+			"patch":  patch,
+		},
+	}, true
+}
+
+// probePortIssue flags probes on ports the container doesn't declare. A
+// named port that doesn't exist makes the probe fail outright.
+func probePortIssue(container *corev1.Container, index int, kind string, probe *corev1.Probe) (Issue, bool) {
+	if probe == nil {
+		return Issue{}, false
+	}
+	var port intstr.IntOrString
+	var field string
+	switch {
+	case probe.HTTPGet != nil:
+		port, field = probe.HTTPGet.Port, "httpGet/port"
+	case probe.TCPSocket != nil:
+		port, field = probe.TCPSocket.Port, "tcpSocket/port"
+	default:
+		return Issue{}, false
+	}
+
+	severity := SeverityMedium
+	if port.Type == intstr.String {
+		for _, declared := range container.Ports {
+			if declared.Name == port.StrVal {
+				return Issue{}, false
+			}
+		}
+		severity = SeverityHigh
+	} else {
+		if len(container.Ports) == 0 {
+			return Issue{}, false
+		}
+		for _, declared := range container.Ports {
+			if declared.ContainerPort == port.IntVal {
+				return Issue{}, false
+			}
+		}
+	}
+
+	var ports []string
+	for _, declared := range container.Ports {
+		ports = append(ports, strconv.Itoa(int(declared.ContainerPort)))
+	}
+	issue := Issue{
+		Severity: severity,
+		Type:     IssueProbeFailure,
+		Description: fmt.Sprintf("%s probe of container %s uses port %s, which is not one of its containerPorts (%s)",
+			kind, container.Name, port.String(), strings.Join(ports, ", ")),
+		Details: map[string]interface{}{"probe": strings.ToLower(kind), "port": port.String()},
+	}
+	if len(container.Ports) == 1 {
+		issue.Details["patch"] = fmt.Sprintf(`[{"op":"replace","path":"/spec/template/spec/containers/%d/%sProbe/%s","value":%d}]`,
+			index, strings.ToLower(kind), field, container.Ports[0].ContainerPort)
+	}
+	return issue, true
+}
+
+// observedStartup returns how long a ready container took to become ready
+func observedStartup(pod *corev1.Pod, status *corev1.ContainerStatus) (time.Duration, bool) {
+	if !status.Ready || status.State.Running == nil {
+		return 0, false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			startup := condition.LastTransitionTime.Sub(status.State.Running.StartedAt.Time)
+			return startup, startup > 0
+		}
+	}
+	return 0, false
+}
+
+// describeProbe formats a probe's action, e.g. "HTTP GET :8080/healthz"
+func describeProbe(probe *corev1.Probe, container *corev1.Container) string {
+	switch {
+	case probe.HTTPGet != nil:
+		return fmt.Sprintf("%s GET :%s%s", httpScheme(probe), resolvePort(probe.HTTPGet.Port, container), probe.HTTPGet.Path)
+	case probe.TCPSocket != nil:
+		return "TCP :" + resolvePort(probe.TCPSocket.Port, container)
+	case probe.GRPC != nil:
+		return fmt.Sprintf("gRPC :%d", probe.GRPC.Port)
+	case probe.Exec != nil:
+		return "exec " + strings.Join(probe.Exec.Command, " ")
+	}
+	return "no action"
+}
+
+// httpScheme returns the scheme of an HTTP probe
+func httpScheme(probe *corev1.Probe) corev1.URIScheme {
+	if probe.HTTPGet.Scheme == "" {
+		return corev1.URISchemeHTTP
+	}
+	return probe.HTTPGet.Scheme
+}
+
+// resolvePort formats a probe port, resolving named ports to numbers
+func resolvePort(port intstr.IntOrString, container *corev1.Container) string {
+	if port.Type == intstr.String {
+		for _, declared := range container.Ports {
+			if declared.Name == port.StrVal {
+				return fmt.Sprintf("%d", declared.ContainerPort)
+			}
+		}
+	}
+	return port.String()
+}
+
+// probePeriod returns a probe's period in seconds, defaulted
+func probePeriod(probe *corev1.Probe) int32 {
+	if probe.PeriodSeconds == 0 {
+		return defaultProbePeriod
+	}
+	return probe.PeriodSeconds
+}
+
+// probeThreshold returns a probe's failure threshold, defaulted
+func probeThreshold(probe *corev1.Probe) int32 {
+	if probe.FailureThreshold == 0 {
+		return defaultProbeThreshold
+	}
+	return probe.FailureThreshold
+}
+
+// containerStatus returns the status of a container by name
+func containerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// probeRemediations proposes the probe patches recorded on issues
+func probeRemediations(issues []Issue, namespace string) []Remediation {
+	var remediations []Remediation
+	seen := map[string]bool{}
+	for _, issue := range issues {
+		if issue.Type != IssueProbeFailure {
+			continue
+		}
+		workload, _ := issue.Details["workload"].(string)
+		container, _ := issue.Details["container"].(string)
+		probe, _ := issue.Details["probe"].(string)
+
+		if patch, ok := issue.Details["patch"].(string); ok && workload != "" {
+			command := fmt.Sprintf("kubectl patch %s --type=json -p '%s'", workload, patch)
+			if !seen[command] {
+				seen[command] = true
+				remediations = append(remediations, Remediation{
+					Title:       fmt.Sprintf("Fix the %s probe of container %s", probe, container),
+					Description: issue.Description,
+					Command:     command,
+					Confidence:  "Medium",
+					Safe:        false,
+				})
+			}
+		}
+		if _, ok := issue.Details["statusCode"]; ok {
+			pod, _, _ := strings.Cut(issue.Resource, "/")
+			port, _ := issue.Details["port"].(string)
+			command := fmt.Sprintf("kubectl port-forward pod/%s -n %s %s:%s", pod, namespace, port, port)
+			if !seen[command] {
+				seen[command] = true
+				remediations = append(remediations, Remediation{
+					Title:       "Call the probe endpoint",
+					Description: "Forward the probe port and request the probe path to see the response body",
+					Command:     command,
+					Confidence:  "High",
+					Safe:        true,
+				})
+			}
+		}
+	}
+	return remediations
+}
@@ -0,0 +1,63 @@
+package diagnose
+
+import "testing"
+
+func TestDiagnosePodProbes(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/probes.yaml")
+
+	report, err := engine.DiagnoseResource("pod", "checkout-api-5f7d9-wk2lp")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	tests := []struct {
+		resource string
+		severity Severity
+		text     string
+	}{
+		{"api", SeverityHigh, "Liveness probe (HTTP GET :8080/healthz) failed 8 time(s), so the kubelet restarts the container: Get"},
+		{"api", SeverityHigh, "Container api was killed after 22s, within its liveness probe's 20s startup allowance"},
+		{"api", SeverityLow, "Liveness and readiness probes of container api are identical (HTTP GET :8080/healthz)"},
+		{"worker", SeverityMedium, "Readiness probe (HTTP GET :9000/readyz) failed 30 time(s), so the pod is removed from Service endpoints"},
+		{"worker", SeverityHigh, "Readiness probe HTTP GET :9000/readyz answers HTTP 404; the probe path or method is probably wrong"},
+		{"worker", SeverityHigh, "Liveness probe of container worker uses port metrics, which is not one of its containerPorts (9000)"},
+	}
+	for _, tt := range tests {
+		if !hasIssue(report, IssueProbeFailure, "checkout-api-5f7d9-wk2lp/"+tt.resource, tt.severity, tt.text) {
+			t.Errorf("expected %q, got %+v", tt.text, report.Issues)
+		}
+	}
+
+	for _, command := range []string{
+		`kubectl patch deployment/checkout-api -n shop --type=json -p '[{"op":"add","path":"/spec/template/spec/containers/0/startupProbe","value":{"httpGet":{"path":"/healthz","port":8080},"periodSeconds":10,"failureThreshold":30}}]'`,
+		`kubectl patch deployment/checkout-api -n shop --type=json -p '[{"op":"add","path":"/spec/template/spec/containers/0/livenessProbe/failureThreshold","value":6}]'`,
+		`kubectl patch deployment/checkout-api -n shop --type=json -p '[{"op":"replace","path":"/spec/template/spec/containers/1/livenessProbe/tcpSocket/port","value":9000}]'`,
+		"kubectl port-forward pod/checkout-api-5f7d9-wk2lp -n shop 9000:9000",
+	} {
+		if !hasCommand(report, command) {
+			t.Errorf("expected remediation %q, got %+v", command, report.Remediations)
+		}
+	}
+}
+
+func TestDiagnosePodSlowReadiness(t *testing.T) {
+	engine := newTestEngine(t, "shop", "testdata/probes.yaml")
+
+	// A long readiness delay alone doesn't mean liveness kills the container
+	report, err := engine.DiagnoseResource("pod", "checkout-api-5f7d9-m8r4t")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if hasIssue(report, IssueProbeFailure, "checkout-api-5f7d9-m8r4t/api", SeverityHigh, "to become ready") {
+		t.Errorf("unexpected startup issue for a container that never restarted: %+v", report.Issues)
+	}
+
+	report, err = engine.DiagnoseResource("pod", "checkout-api-5f7d9-t6j9c")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	text := "Container api took 1m35s to become ready, but its liveness probe allows only 20s"
+	if !hasIssue(report, IssueProbeFailure, "checkout-api-5f7d9-t6j9c/api", SeverityHigh, text) {
+		t.Errorf("expected %q, got %+v", text, report.Issues)
+	}
+}
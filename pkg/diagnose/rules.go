@@ -0,0 +1,292 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/rules"
+)
+
+// ruleObject is an object converted for rule evaluation
+type ruleObject struct {
+	kind      string
+	namespace string
+	name      string
+	object    map[string]interface{}
+}
+
+// SetRules makes the engine evaluate custom rules alongside its built-in
+// checks
+func (e *Engine) SetRules(set *rules.Set) {
+	e.ruleSet = set
+}
+
+// applyRules evaluates the rules for each kind against its objects,
+// narrowed to one object when name is set, and adds their findings to the
+// report. It returns the number of issues added.
+func (e *Engine) applyRules(ctx context.Context, snapshot *k8s.Snapshot, report *Report, name string, kinds ...string) int {
+	added := 0
+	for _, kind := range kinds {
+		matching := e.ruleSet.ForKind(kind)
+		if len(matching) == 0 {
+			continue
+		}
+
+		objects, err := e.ruleObjects(ctx, snapshot, kind, name)
+		if err != nil {
+			report.AddWarning(fmt.Sprintf("Custom rules for %s not evaluated: %v", kind, err))
+			continue
+		}
+
+		for _, obj := range objects {
+			events := ruleEvents(snapshot, obj)
+			for _, rule := range matching {
+				findings, err := rule.Evaluate(obj.object, events)
+				if err != nil {
+					report.AddWarning(err.Error())
+					continue
+				}
+				for _, finding := range findings {
+					report.Issues = append(report.Issues, ruleIssue(finding))
+					added++
+				}
+			}
+		}
+	}
+
+	if added > 0 {
+		report.Remediations = append(report.Remediations, ruleRemediations(report.Issues)...)
+	}
+	return added
+}
+
+// applyResourceRules evaluates the custom rules for one named object
+func (e *Engine) applyResourceRules(ctx context.Context, report *Report, resourceType, name string) error {
+	if len(e.ruleSet.List()) == 0 {
+		return nil
+	}
+	kind, err := e.ruleKind(resourceType)
+	if err != nil {
+		return err
+	}
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if added := e.applyRules(ctx, snapshot, report, name, kind); added > 0 {
+		refreshSummary(report, added)
+	}
+	return nil
+}
+
+// ruleKind returns the kind a resource type argument refers to, as rules
+// name it
+func (e *Engine) ruleKind(resourceType string) (string, error) {
+	if kind, ok := snapshotKinds[strings.ToLower(resourceType)]; ok {
+		return kind, nil
+	}
+	mapping, err := e.resolveKind(resourceType)
+	if err != nil {
+		return "", err
+	}
+	return mapping.GroupVersionKind.Kind, nil
+}
+
+// snapshotKinds maps the names of the resources captured in a snapshot to
+// their kind
+var snapshotKinds = map[string]string{
+	"pod": "Pod", "pods": "Pod", "po": "Pod",
+	"node": "Node", "nodes": "Node", "no": "Node",
+	"deployment": "Deployment", "deployments": "Deployment", "deploy": "Deployment",
+	"replicaset": "ReplicaSet", "replicasets": "ReplicaSet", "rs": "ReplicaSet",
+	"statefulset": "StatefulSet", "statefulsets": "StatefulSet", "sts": "StatefulSet",
+	"daemonset": "DaemonSet", "daemonsets": "DaemonSet", "ds": "DaemonSet",
+	"job": "Job", "jobs": "Job",
+	"cronjob": "CronJob", "cronjobs": "CronJob", "cj": "CronJob",
+	"service": "Service", "services": "Service", "svc": "Service",
+	"ingress": "Ingress", "ingresses": "Ingress", "ing": "Ingress",
+	"pvc": "PersistentVolumeClaim", "pvcs": "PersistentVolumeClaim",
+	"persistentvolumeclaim": "PersistentVolumeClaim", "persistentvolumeclaims": "PersistentVolumeClaim",
+	"pv": "PersistentVolume", "pvs": "PersistentVolume",
+	"persistentvolume": "PersistentVolume", "persistentvolumes": "PersistentVolume",
+	"networkpolicy": "NetworkPolicy", "networkpolicies": "NetworkPolicy", "netpol": "NetworkPolicy",
+}
+
+// ruleObjects returns the objects of a kind, narrowed to one name when set.
+// Kinds captured in the snapshot are read from it; any other kind is read
+// through the dynamic client.
+func (e *Engine) ruleObjects(ctx context.Context, snapshot *k8s.Snapshot, kind, name string) ([]ruleObject, error) {
+	objects, captured := snapshotObjects(snapshot, kind)
+	if captured {
+		kind = snapshotKinds[strings.ToLower(kind)]
+	} else {
+		mapping, err := e.resolveKind(kind)
+		if err != nil {
+			return nil, err
+		}
+		namespace := snapshot.Namespace
+		kind = mapping.GroupVersionKind.Kind
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			namespace = metav1.NamespaceAll
+		}
+		list, err := e.k8sClient.ListObjects(ctx, mapping, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			objects = append(objects, &list[i])
+		}
+	}
+
+	var converted []ruleObject
+	for _, obj := range objects {
+		accessor, ok := obj.(metav1.Object)
+		if !ok || (name != "" && accessor.GetName() != name) {
+			continue
+		}
+		if name != "" && accessor.GetNamespace() != "" && snapshot.Namespace == "" && accessor.GetNamespace() != e.namespace {
+			continue
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s/%s: %w", kind, accessor.GetName(), err)
+		}
+		converted = append(converted, ruleObject{kind: kind, namespace: accessor.GetNamespace(), name: accessor.GetName(), object: content})
+	}
+	return converted, nil
+}
+
+// snapshotObjects returns the snapshot's objects of a kind, and whether the
+// snapshot captures that kind at all
+func snapshotObjects(snapshot *k8s.Snapshot, kind string) ([]runtime.Object, bool) {
+	var objects []runtime.Object
+	switch strings.ToLower(kind) {
+	case "pod":
+		for i := range snapshot.Pods {
+			objects = append(objects, &snapshot.Pods[i])
+		}
+	case "node":
+		for i := range snapshot.Nodes {
+			objects = append(objects, &snapshot.Nodes[i])
+		}
+	case "deployment":
+		for i := range snapshot.Deployments {
+			objects = append(objects, &snapshot.Deployments[i])
+		}
+	case "replicaset":
+		for i := range snapshot.ReplicaSets {
+			objects = append(objects, &snapshot.ReplicaSets[i])
+		}
+	case "statefulset":
+		for i := range snapshot.StatefulSets {
+			objects = append(objects, &snapshot.StatefulSets[i])
+		}
+	case "daemonset":
+		for i := range snapshot.DaemonSets {
+			objects = append(objects, &snapshot.DaemonSets[i])
+		}
+	case "job":
+		for i := range snapshot.Jobs {
+			objects = append(objects, &snapshot.Jobs[i])
+		}
+	case "cronjob":
+		for i := range snapshot.CronJobs {
+			objects = append(objects, &snapshot.CronJobs[i])
+		}
+	case "service":
+		for i := range snapshot.Services {
+			objects = append(objects, &snapshot.Services[i])
+		}
+	case "ingress":
+		for i := range snapshot.Ingresses {
+			objects = append(objects, &snapshot.Ingresses[i])
+		}
+	case "persistentvolumeclaim":
+		for i := range snapshot.PVCs {
+			objects = append(objects, &snapshot.PVCs[i])
+		}
+	case "persistentvolume":
+		for i := range snapshot.PVs {
+			objects = append(objects, &snapshot.PVs[i])
+		}
+	case "networkpolicy":
+		for i := range snapshot.NetworkPolicies {
+			objects = append(objects, &snapshot.NetworkPolicies[i])
+		}
+	default:
+		return nil, false
+	}
+	return objects, true
+}
+
+// ruleEvents converts the events involving an object for rule evaluation
+func ruleEvents(snapshot *k8s.Snapshot, obj ruleObject) []map[string]interface{} {
+	var events []map[string]interface{}
+	for _, event := range snapshot.EventsFor(obj.kind, obj.namespace, obj.name) {
+		event := event
+		if content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&event); err == nil {
+			events = append(events, content)
+		}
+	}
+	return events
+}
+
+// ruleIssue converts a rule finding into an issue
+func ruleIssue(finding rules.Finding) Issue {
+	remediations := make([]Remediation, 0, len(finding.Remediations))
+	for _, r := range finding.Remediations {
+		confidence := r.Confidence
+		if confidence == "" {
+			confidence = "Medium"
+		}
+		remediations = append(remediations, Remediation{
+			Title:       r.Title,
+			Description: r.Description,
+			Command:     r.Command,
+			Confidence:  confidence,
+			Safe:        r.Safe,
+		})
+	}
+
+	return Issue{
+		Severity:    Severity(finding.Rule.Severity),
+		Type:        IssueType(finding.Rule.Type),
+		Resource:    fmt.Sprintf("%s/%s", strings.ToLower(finding.Kind), finding.Name),
+		Description: finding.Message,
+		Details: map[string]interface{}{
+			"rule":         finding.Rule.Name,
+			"value":        finding.Value,
+			"namespace":    finding.Namespace,
+			"remediations": remediations,
+		},
+	}
+}
+
+// ruleRemediations collects the remediations rendered by custom rules,
+// once per command
+func ruleRemediations(issues []Issue) []Remediation {
+	var remediations []Remediation
+	seen := map[string]bool{}
+	for _, issue := range issues {
+		rendered, _ := issue.Details["remediations"].([]Remediation)
+		for _, remediation := range rendered {
+			if seen[remediation.Command] {
+				continue
+			}
+			seen[remediation.Command] = true
+			remediations = append(remediations, remediation)
+		}
+	}
+	return remediations
+}
+
+// refreshSummary notes the issues custom rules added to the report
+func refreshSummary(report *Report, added int) {
+	report.Summary += fmt.Sprintf(" (%d issue(s) from custom rules)", added)
+}
@@ -0,0 +1,84 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/rules"
+)
+
+func testRules(t *testing.T) *rules.Set {
+	t.Helper()
+
+	backoff := &rules.Rule{
+		Name:     "backoff-events",
+		Match:    rules.Match{Kind: "Pod", JSONPath: `{.events[?(@.reason=="BackOff")].count}`},
+		Severity: "high",
+		Type:     "RepeatedBackOff",
+		Message:  "Pod {{ .Name }} backed off {{ .Value }} times",
+		Remediations: []rules.Remediation{{
+			Title:   "Describe {{ .Name }}",
+			Command: "kubectl describe pod {{ .Name }} -n {{ .Namespace }}",
+			Safe:    true,
+		}},
+	}
+	restarts := &rules.Rule{
+		Name:     "frequent-restarts",
+		Match:    rules.Match{Kind: "pod", JSONPath: ".status.containerStatuses[?(@.restartCount > 10)].name"},
+		Severity: "low",
+		Type:     "FrequentRestarts",
+		Message:  "Container {{ .Value }} restarted more than ten times",
+	}
+	for _, rule := range []*rules.Rule{backoff, restarts} {
+		if err := rule.Validate(); err != nil {
+			t.Fatalf("Validate: %v", err)
+		}
+	}
+	return rules.NewSet(backoff, restarts)
+}
+
+func TestDiagnoseResourceAppliesRules(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/crashloop.yaml")
+	engine.SetRules(testRules(t))
+
+	report, err := engine.DiagnoseResource("pod", "api-7d9f8b-x2k4p")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, "RepeatedBackOff", "pod/api-7d9f8b-x2k4p", SeverityHigh, "backed off 42 times") {
+		t.Errorf("expected the custom rule's issue, got %+v", report.Issues)
+	}
+	if !hasIssue(report, "FrequentRestarts", "pod/api-7d9f8b-x2k4p", SeverityLow, "Container api restarted") {
+		t.Errorf("expected the restart rule's issue, got %+v", report.Issues)
+	}
+	if !hasCommand(report, "kubectl describe pod api-7d9f8b-x2k4p -n payments") {
+		t.Errorf("expected the rule's remediation, got %+v", report.Remediations)
+	}
+
+	healthy, err := engine.DiagnoseResource("pod", "worker-5c6b7-q8r2t")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	for _, issue := range healthy.Issues {
+		if issue.Details["rule"] != nil {
+			t.Errorf("expected no custom rule issue on a healthy pod, got %+v", issue)
+		}
+	}
+}
+
+func TestDiagnoseClusterAppliesRules(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/crashloop.yaml")
+	engine.SetRules(testRules(t))
+
+	report, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+	if !hasIssue(report, "RepeatedBackOff", "pod/api-7d9f8b-x2k4p", SeverityHigh, "") {
+		t.Errorf("expected the custom rule's issue, got %+v", report.Issues)
+	}
+	if !strings.Contains(report.Summary, "issue(s) from custom rules") {
+		t.Errorf("expected the summary to count custom rule issues, got %q", report.Summary)
+	}
+}
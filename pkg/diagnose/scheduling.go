@@ -0,0 +1,598 @@
+package diagnose
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// Scheduler predicates recognized in FailedScheduling messages
+const (
+	predicateResources     = "resources"
+	predicateTaint         = "taint"
+	predicateNodeAffinity  = "nodeAffinity"
+	predicateTopology      = "topologySpread"
+	predicateVolumeZone    = "volumeZone"
+	predicateHostPort      = "hostPort"
+	predicateUnschedulable = "unschedulable"
+	predicatePodAffinity   = "podAffinity"
+	predicateUnboundClaim  = "unboundClaim"
+	predicateOther         = "other"
+)
+
+var (
+	// availabilityPattern matches "0/5 nodes are available: <reasons>"
+	availabilityPattern = regexp.MustCompile(`^(\d+)/(\d+) nodes are available: (.*)$`)
+	// countPattern splits "2 Insufficient cpu" into a node count and reason
+	countPattern = regexp.MustCompile(`^(\d+) (.*)$`)
+	// taintPattern matches the "{key: value}" of an untolerated taint
+	taintPattern = regexp.MustCompile(`\{([^:}]+):\s*([^}]*)\}`)
+)
+
+// schedulingFailure is a parsed FailedScheduling message
+type schedulingFailure struct {
+	Available  int
+	Total      int
+	Predicates []failedPredicate
+}
+
+// failedPredicate is one reason the scheduler ruled out nodes
+type failedPredicate struct {
+	Nodes  int
+	Reason string
+	Kind   string
+}
+
+// parseSchedulingFailure parses the scheduler's message, e.g.
+// "0/5 nodes are available: 2 Insufficient cpu, 3 node(s) had untolerated
+// taint {dedicated: batch}. preemption: ..."
+func parseSchedulingFailure(message string) (*schedulingFailure, bool) {
+	message = strings.TrimSpace(message)
+	if i := strings.Index(message, " preemption:"); i >= 0 {
+		message = message[:i]
+	}
+	message = strings.TrimSuffix(message, ".")
+
+	match := availabilityPattern.FindStringSubmatch(message)
+	if match == nil {
+		return nil, false
+	}
+	failure := &schedulingFailure{}
+	failure.Available, _ = strconv.Atoi(match[1])
+	failure.Total, _ = strconv.Atoi(match[2])
+
+	for _, part := range strings.Split(match[3], ", ") {
+		part = strings.TrimSpace(strings.TrimSuffix(part, "."))
+		if part == "" {
+			continue
+		}
+		predicate := failedPredicate{Reason: part}
+		if count := countPattern.FindStringSubmatch(part); count != nil {
+			predicate.Nodes, _ = strconv.Atoi(count[1])
+			predicate.Reason = count[2]
+		} else if n := len(failure.Predicates); n > 0 {
+			// Older schedulers wrote "had taint {...}, that the pod didn't tolerate"
+			failure.Predicates[n-1].Reason += ", " + part
+			continue
+		}
+		predicate.Kind = predicateKind(predicate.Reason)
+		failure.Predicates = append(failure.Predicates, predicate)
+	}
+	return failure, len(failure.Predicates) > 0
+}
+
+// predicateKind classifies a scheduler reason
+func predicateKind(reason string) string {
+	switch {
+	case strings.HasPrefix(reason, "Insufficient "), reason == "Too many pods":
+		return predicateResources
+	case strings.Contains(reason, "taint"):
+		return predicateTaint
+	case strings.Contains(reason, "node affinity/selector"):
+		return predicateNodeAffinity
+	case strings.Contains(reason, "topology spread"):
+		return predicateTopology
+	case strings.Contains(reason, "volume node affinity"):
+		return predicateVolumeZone
+	case strings.Contains(reason, "free ports"):
+		return predicateHostPort
+	case strings.Contains(reason, "were unschedulable"):
+		return predicateUnschedulable
+	case strings.Contains(reason, "pod affinity"), strings.Contains(reason, "anti-affinity"):
+		return predicatePodAffinity
+	case strings.Contains(reason, "unbound immediate PersistentVolumeClaims"):
+		return predicateUnboundClaim
+	default:
+		return predicateOther
+	}
+}
+
+// analyzeScheduling explains why an unscheduled pod is Pending from its
+// PodScheduled condition, or else its latest FailedScheduling event
+func analyzeScheduling(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	if pod.Spec.NodeName != "" || pod.Status.Phase != corev1.PodPending {
+		return
+	}
+
+	var message string
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != corev1.PodScheduled || condition.Status == corev1.ConditionTrue {
+			continue
+		}
+		if condition.Reason == corev1.PodReasonSchedulingGated {
+			var gates []string
+			for _, gate := range pod.Spec.SchedulingGates {
+				gates = append(gates, gate.Name)
+			}
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityMedium,
+				Type:        IssueFailedScheduling,
+				Resource:    pod.Name,
+				Description: fmt.Sprintf("Pod is held by scheduling gate(s) %s until a controller removes them", strings.Join(gates, ", ")),
+				Details:     map[string]interface{}{"predicate": "schedulingGates"},
+			})
+			return
+		}
+		message = condition.Message
+	}
+	if _, ok := parseSchedulingFailure(message); !ok && snapshot.Err(k8s.ResourceEvents) == nil {
+		events := snapshot.EventsFor("Pod", pod.Namespace, pod.Name)
+		for i := len(events) - 1; i >= 0; i-- {
+			if events[i].Reason == "FailedScheduling" {
+				message = events[i].Message
+				break
+			}
+		}
+	}
+
+	failure, ok := parseSchedulingFailure(message)
+	if !ok {
+		return
+	}
+
+	workload := podWorkload(snapshot, pod)
+	for _, predicate := range failure.Predicates {
+		issue := Issue{
+			Severity: SeverityHigh,
+			Type:     IssueFailedScheduling,
+			Resource: pod.Name,
+			Details: map[string]interface{}{
+				"predicate": predicate.Kind,
+				"nodes":     predicate.Nodes,
+				"available": fmt.Sprintf("%d/%d", failure.Available, failure.Total),
+			},
+		}
+		if workload != "" {
+			issue.Details["workload"] = workload
+		}
+		issue.Description = explainPredicate(snapshot, report, pod, predicate, issue.Details)
+		report.Issues = append(report.Issues, issue)
+	}
+}
+
+// explainPredicate describes a failed predicate with the pod's own spec and
+// records what a remediation needs in details
+func explainPredicate(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod, predicate failedPredicate, details map[string]interface{}) string {
+	ruledOut := fmt.Sprintf("%d node(s)", predicate.Nodes)
+
+	switch predicate.Kind {
+	case predicateResources:
+		name := corev1.ResourcePods
+		if reason, ok := strings.CutPrefix(predicate.Reason, "Insufficient "); ok {
+			name = corev1.ResourceName(reason)
+		}
+		if name == corev1.ResourcePods {
+			return fmt.Sprintf("%s are at their pod limit", ruledOut)
+		}
+		requested := podRequest(pod, name)
+		description := fmt.Sprintf("%s lack %s: the pod requests %s", ruledOut, name, requested.String())
+		if snapshot.Namespace != "" {
+			report.AddWarning("Free node capacity was not computed; use --all-namespaces to size requests")
+			return description
+		}
+		free, ok := maxFree(snapshot, pod, name)
+		if !ok {
+			return description
+		}
+		description += fmt.Sprintf(", the most free on an eligible node is %s", free.String())
+		if container, lowered, ok := lowerRequest(pod, name, requested, free); ok {
+			details["resource"] = string(name)
+			details["container"] = container
+			details["request"] = lowered
+		}
+		return description
+
+	case predicateTaint:
+		var taints []string
+		var tolerations []string
+		for _, match := range taintPattern.FindAllStringSubmatch(predicate.Reason, -1) {
+			taint := corev1.Taint{Key: strings.TrimSpace(match[1]), Value: strings.TrimSpace(match[2]), Effect: taintEffect(snapshot, match[1], match[2])}
+			taints = append(taints, taint.ToString())
+			if taint.Value == "" {
+				tolerations = append(tolerations, fmt.Sprintf(`{"key":%q,"operator":"Exists","effect":%q}`, taint.Key, taint.Effect))
+			} else {
+				tolerations = append(tolerations, fmt.Sprintf(`{"key":%q,"operator":"Equal","value":%q,"effect":%q}`, taint.Key, taint.Value, taint.Effect))
+			}
+		}
+		if len(taints) == 0 {
+			return fmt.Sprintf("%s have taints the pod doesn't tolerate", ruledOut)
+		}
+		details["taints"] = taints
+		details["tolerations"] = tolerations
+		details["hasTolerations"] = templateHasTolerations(snapshot, pod)
+		return fmt.Sprintf("%s have untolerated taint %s", ruledOut, strings.Join(taints, ", "))
+
+	case predicateNodeAffinity:
+		var requirements []string
+		if len(pod.Spec.NodeSelector) > 0 {
+			requirements = append(requirements, "nodeSelector "+labels.FormatLabels(pod.Spec.NodeSelector))
+		}
+		if affinity := pod.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil &&
+			affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+			requirements = append(requirements, "affinity "+describeNodeSelector(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution))
+		}
+		matching := 0
+		for i := range snapshot.Nodes {
+			if podFitsNodeSelection(pod, &snapshot.Nodes[i]) {
+				matching++
+			}
+		}
+		return fmt.Sprintf("%s don't match the pod's %s; %d of %d nodes have matching labels",
+			ruledOut, strings.Join(requirements, " and "), matching, len(snapshot.Nodes))
+
+	case predicateTopology:
+		for i, constraint := range pod.Spec.TopologySpreadConstraints {
+			if constraint.WhenUnsatisfiable != corev1.DoNotSchedule {
+				continue
+			}
+			details["constraint"] = i
+			return fmt.Sprintf("%s would exceed maxSkew %d of the topology spread over %s",
+				ruledOut, constraint.MaxSkew, constraint.TopologyKey)
+		}
+		return fmt.Sprintf("%s would violate the pod's topology spread constraints", ruledOut)
+
+	case predicateVolumeZone:
+		var claims []string
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+		details["claims"] = claims
+		return fmt.Sprintf("%s are outside the topology of the pod's volumes (claims: %s)", ruledOut, strings.Join(claims, ", "))
+
+	case predicateHostPort:
+		var ports []string
+		var paths []string
+		workload, _ := details["workload"].(string)
+		for i, container := range pod.Spec.Containers {
+			for j, port := range container.Ports {
+				if port.HostPort != 0 {
+					ports = append(ports, strconv.Itoa(int(port.HostPort)))
+					paths = append(paths, fmt.Sprintf("%s/containers/%d/ports/%d/hostPort", podSpecPath(workload), i, j))
+				}
+			}
+		}
+		details["hostPorts"] = paths
+		return fmt.Sprintf("%s already use host port(s) %s", ruledOut, strings.Join(ports, ", "))
+
+	case predicateUnschedulable:
+		return fmt.Sprintf("%s are cordoned", ruledOut)
+
+	case predicateUnboundClaim:
+		return "The pod's PersistentVolumeClaims are not bound yet"
+	}
+	return fmt.Sprintf("%s: %s", ruledOut, predicate.Reason)
+}
+
+// maxFree returns the most unrequested capacity of a resource on a node the
+// pod could otherwise be scheduled to
+func maxFree(snapshot *k8s.Snapshot, pod *corev1.Pod, name corev1.ResourceName) (resource.Quantity, bool) {
+	var best resource.Quantity
+	found := false
+	for i := range snapshot.Nodes {
+		node := &snapshot.Nodes[i]
+		if node.Spec.Unschedulable || !podFitsNodeSelection(pod, node) || !toleratesNode(pod, node) {
+			continue
+		}
+		allocatable, ok := node.Status.Allocatable[name]
+		if !ok {
+			continue
+		}
+		free := allocatable.DeepCopy()
+		for _, other := range podsOnNode(snapshot, node.Name) {
+			if other.Status.Phase != corev1.PodSucceeded && other.Status.Phase != corev1.PodFailed {
+				free.Sub(podRequest(other, name))
+			}
+		}
+		if free.Sign() < 0 {
+			free = resource.Quantity{Format: free.Format}
+		}
+		if !found || free.Cmp(best) > 0 {
+			best, found = free, true
+		}
+	}
+	return best, found
+}
+
+// toleratesNode reports whether a pod tolerates a node's scheduling taints
+func toleratesNode(pod *corev1.Pod, node *corev1.Node) bool {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect != corev1.TaintEffectPreferNoSchedule && !tolerated(pod.Spec.Tolerations, taint) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerRequest finds the container whose request can absorb the shortfall
+// and returns its reduced request, rounded down to m or Mi
+func lowerRequest(pod *corev1.Pod, name corev1.ResourceName, requested, free resource.Quantity) (string, string, bool) {
+	if free.IsZero() {
+		return "", "", false
+	}
+	shortfall := requested.DeepCopy()
+	shortfall.Sub(free)
+
+	var container string
+	var largest resource.Quantity
+	for _, c := range pod.Spec.Containers {
+		if request, ok := c.Resources.Requests[name]; ok && request.Cmp(largest) > 0 {
+			container, largest = c.Name, request
+		}
+	}
+	if container == "" || largest.Cmp(shortfall) <= 0 {
+		return "", "", false
+	}
+	lowered := largest.DeepCopy()
+	lowered.Sub(shortfall)
+
+	switch name {
+	case corev1.ResourceCPU:
+		return container, fmt.Sprintf("%dm", lowered.MilliValue()), true
+	case corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+		return container, fmt.Sprintf("%dMi", lowered.Value()/(1024*1024)), true
+	default:
+		return container, strconv.FormatInt(lowered.Value(), 10), true
+	}
+}
+
+// taintEffect returns the effect of a taint as set on the nodes, which the
+// scheduler message leaves out
+func taintEffect(snapshot *k8s.Snapshot, key, value string) corev1.TaintEffect {
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	for i := range snapshot.Nodes {
+		for _, taint := range snapshot.Nodes[i].Spec.Taints {
+			if taint.Key == key && taint.Value == value && taint.Effect != corev1.TaintEffectPreferNoSchedule {
+				return taint.Effect
+			}
+		}
+	}
+	return corev1.TaintEffectNoSchedule
+}
+
+// podWorkload returns the controller to patch for a pod, e.g.
+// "deployment/api -n shop", or "" for bare pods
+func podWorkload(snapshot *k8s.Snapshot, pod *corev1.Pod) string {
+	workload, _ := podController(snapshot, pod)
+	return workload
+}
+
+// podController returns the controller to patch for a pod and its pod
+// template spec, which is nil when the controller is not in the snapshot.
+// A Job's template is immutable, so pods of a CronJob's Job resolve to the
+// CronJob and pods of a bare Job to none.
+func podController(snapshot *k8s.Snapshot, pod *corev1.Pod) (string, *corev1.PodSpec) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return "", nil
+	}
+	target := func(kind, name string) string {
+		return fmt.Sprintf("%s/%s -n %s", kind, name, pod.Namespace)
+	}
+
+	switch ref.Kind {
+	case "ReplicaSet":
+		for i := range snapshot.ReplicaSets {
+			rs := &snapshot.ReplicaSets[i]
+			if rs.Namespace != pod.Namespace || rs.Name != ref.Name {
+				continue
+			}
+			owner := metav1.GetControllerOf(rs)
+			if owner == nil || owner.Kind != "Deployment" {
+				return target("replicaset", ref.Name), &rs.Spec.Template.Spec
+			}
+			if deployment := snapshot.Deployment(pod.Namespace, owner.Name); deployment != nil {
+				return target("deployment", owner.Name), &deployment.Spec.Template.Spec
+			}
+			return target("deployment", owner.Name), nil
+		}
+		return target("replicaset", ref.Name), nil
+	case "StatefulSet":
+		if sts := snapshot.StatefulSet(pod.Namespace, ref.Name); sts != nil {
+			return target("statefulset", ref.Name), &sts.Spec.Template.Spec
+		}
+		return target("statefulset", ref.Name), nil
+	case "DaemonSet":
+		if ds := snapshot.DaemonSet(pod.Namespace, ref.Name); ds != nil {
+			return target("daemonset", ref.Name), &ds.Spec.Template.Spec
+		}
+		return target("daemonset", ref.Name), nil
+	case "Job":
+		job := snapshot.Job(pod.Namespace, ref.Name)
+		if job == nil {
+			return "", nil
+		}
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.Kind != "CronJob" {
+			return "", nil
+		}
+		if cronJob := snapshot.CronJob(pod.Namespace, owner.Name); cronJob != nil {
+			return target("cronjob", owner.Name), &cronJob.Spec.JobTemplate.Spec.Template.Spec
+		}
+		return target("cronjob", owner.Name), nil
+	}
+	return "", nil
+}
+
+// templateHasTolerations reports whether the pod template of a pod's
+// controller has tolerations. Without the template, the pod's own
+// tolerations are used, less the ones admission adds to every pod.
+func templateHasTolerations(snapshot *k8s.Snapshot, pod *corev1.Pod) bool {
+	if _, template := podController(snapshot, pod); template != nil {
+		return len(template.Tolerations) > 0
+	}
+	for _, toleration := range pod.Spec.Tolerations {
+		if toleration.Key != corev1.TaintNodeNotReady && toleration.Key != corev1.TaintNodeUnreachable {
+			return true
+		}
+	}
+	return false
+}
+
+// podSpecPath returns the JSON pointer of a controller's pod template spec
+func podSpecPath(workload string) string {
+	if strings.HasPrefix(workload, "cronjob/") {
+		return "/spec/jobTemplate/spec/template/spec"
+	}
+	return "/spec/template/spec"
+}
+
+// tolerationPatch returns a JSON patch adding a toleration to the pod
+// template spec at specPath. Appending to a list that doesn't exist fails,
+// so a template without tolerations gets a new list.
+func tolerationPatch(specPath string, hasTolerations bool, toleration string) string {
+	if hasTolerations {
+		return fmt.Sprintf(`[{"op":"add","path":"%s/tolerations/-","value":%s}]`, specPath, toleration)
+	}
+	return fmt.Sprintf(`[{"op":"add","path":"%s/tolerations","value":[%s]}]`, specPath, toleration)
+}
+
+// schedulingRemediations suggests concrete changes for failed predicates
+func schedulingRemediations(issues []Issue, namespace string) []Remediation {
+	var remediations []Remediation
+	seen := map[string]bool{}
+	add := func(remediation Remediation) {
+		if seen[remediation.Command] {
+			return
+		}
+		seen[remediation.Command] = true
+		remediations = append(remediations, remediation)
+	}
+
+	for _, issue := range issues {
+		if issue.Type != IssueFailedScheduling {
+			continue
+		}
+		workload, _ := issue.Details["workload"].(string)
+
+		switch issue.Details["predicate"] {
+		case predicateResources:
+			container, _ := issue.Details["container"].(string)
+			request, _ := issue.Details["request"].(string)
+			name, _ := issue.Details["resource"].(string)
+			if workload != "" && container != "" {
+				add(Remediation{
+					Title:       fmt.Sprintf("Lower the %s request of container %s to %s", name, container, request),
+					Description: "Fit the pod on the node with the most free capacity; make sure the workload still gets enough",
+					Command:     fmt.Sprintf("kubectl set resources %s -c %s --requests=%s=%s", workload, container, name, request),
+					Confidence:  "Medium",
+					Safe:        false,
+				})
+			}
+			add(Remediation{
+				Title:       "Compare node allocations",
+				Description: "See how much of each node's allocatable capacity is already requested",
+				Command:     `kubectl describe nodes | grep -A 8 "Allocated resources"`,
+				Confidence:  "High",
+				Safe:        true,
+			})
+		case predicateTaint:
+			tolerations, _ := issue.Details["tolerations"].([]string)
+			taints, _ := issue.Details["taints"].([]string)
+			hasTolerations, _ := issue.Details["hasTolerations"].(bool)
+			for i, toleration := range tolerations {
+				if workload == "" {
+					break
+				}
+				add(Remediation{
+					Title:       "Tolerate taint " + taints[i],
+					Description: "Add a toleration if the pod is meant to run on the tainted nodes",
+					Command: fmt.Sprintf(`kubectl patch %s --type=json -p '%s'`,
+						workload, tolerationPatch(podSpecPath(workload), hasTolerations, toleration)),
+					Confidence: "Medium",
+					Safe:       false,
+				})
+			}
+		case predicateNodeAffinity:
+			add(Remediation{
+				Title:       "Compare node labels with the pod's selector",
+				Description: "Label a node, or relax the pod's nodeSelector or required node affinity",
+				Command:     "kubectl get nodes --show-labels",
+				Confidence:  "High",
+				Safe:        true,
+			})
+		case predicateTopology:
+			if index, ok := issue.Details["constraint"].(int); ok && workload != "" {
+				add(Remediation{
+					Title:       "Relax the topology spread constraint",
+					Description: "Let the scheduler place the pod even if the spread is uneven",
+					Command: fmt.Sprintf(`kubectl patch %s --type=json -p '[{"op":"replace","path":"%s/topologySpreadConstraints/%d/whenUnsatisfiable","value":"ScheduleAnyway"}]'`,
+						workload, podSpecPath(workload), index),
+					Confidence: "Medium",
+					Safe:       false,
+				})
+			}
+			add(Remediation{
+				Title:       "Compare node topology",
+				Description: "A domain without schedulable nodes makes the spread unsatisfiable",
+				Command:     "kubectl get nodes -L topology.kubernetes.io/zone",
+				Confidence:  "High",
+				Safe:        true,
+			})
+		case predicateVolumeZone:
+			claims, _ := issue.Details["claims"].([]string)
+			for _, claim := range claims {
+				add(Remediation{
+					Title:       "Diagnose claim " + claim,
+					Description: "Check which zone the claim's volume is pinned to",
+					Command:     fmt.Sprintf("kubectl-pilot diagnose pvc %s -n %s", claim, namespace),
+					Confidence:  "High",
+					Safe:        true,
+				})
+			}
+		case predicateHostPort:
+			paths, _ := issue.Details["hostPorts"].([]string)
+			for _, path := range paths {
+				if workload == "" {
+					break
+				}
+				add(Remediation{
+					Title:       "Remove the hostPort",
+					Description: "Expose the port through a Service instead, so several replicas can share a node",
+					Command:     fmt.Sprintf(`kubectl patch %s --type=json -p '[{"op":"remove","path":"%s"}]'`, workload, path),
+					Confidence:  "Medium",
+					Safe:        false,
+				})
+			}
+		case predicateUnschedulable:
+			add(Remediation{
+				Title:       "List cordoned nodes",
+				Description: "Uncordon nodes whose maintenance is done",
+				Command:     "kubectl get nodes --field-selector spec.unschedulable=true",
+				Confidence:  "High",
+				Safe:        true,
+			})
+		}
+	}
+	return remediations
+}
@@ -0,0 +1,131 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func TestParseSchedulingFailure(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []failedPredicate
+	}{
+		{
+			message: "0/5 nodes are available: 2 Insufficient memory, 3 node(s) had untolerated taint {gpu: true}. preemption: 0/5 nodes are available: 5 Preemption is not helpful for scheduling..",
+			want: []failedPredicate{
+				{Nodes: 2, Reason: "Insufficient memory", Kind: predicateResources},
+				{Nodes: 3, Reason: "node(s) had untolerated taint {gpu: true}", Kind: predicateTaint},
+			},
+		},
+		{
+			message: "0/3 nodes are available: 3 node(s) had taint {node-role.kubernetes.io/master: }, that the pod didn't tolerate.",
+			want: []failedPredicate{
+				{Nodes: 3, Reason: "node(s) had taint {node-role.kubernetes.io/master: }, that the pod didn't tolerate", Kind: predicateTaint},
+			},
+		},
+		{
+			message: "0/1 nodes are available: pod has unbound immediate PersistentVolumeClaims. preemption: 0/1 nodes are available: 1 Preemption is not helpful for scheduling..",
+			want: []failedPredicate{
+				{Reason: "pod has unbound immediate PersistentVolumeClaims", Kind: predicateUnboundClaim},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		failure, ok := parseSchedulingFailure(tt.message)
+		if !ok {
+			t.Fatalf("parseSchedulingFailure(%q) failed", tt.message)
+		}
+		if len(failure.Predicates) != len(tt.want) {
+			t.Fatalf("predicates = %+v, want %+v", failure.Predicates, tt.want)
+		}
+		for i, want := range tt.want {
+			if failure.Predicates[i] != want {
+				t.Errorf("predicate %d = %+v, want %+v", i, failure.Predicates[i], want)
+			}
+		}
+	}
+
+	if _, ok := parseSchedulingFailure("pod is being deleted"); ok {
+		t.Error("expected an unrelated message not to parse")
+	}
+}
+
+func TestDiagnosePendingPod(t *testing.T) {
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	// All namespaces, so the requests of pods in other namespaces count
+	client := k8stest.NewClient(t, "checkout", "testdata/pending.yaml")
+	engine := NewEngineWithClient(client, provider, "checkout", true)
+
+	tests := []struct {
+		pod     string
+		text    string
+		command string
+	}{
+		{
+			pod:     "web-6d8f7c-q9z2x",
+			text:    "1 node(s) lack cpu: the pod requests 1100m, the most free on an eligible node is 500m",
+			command: "kubectl set resources deployment/web -n checkout -c web --requests=cpu=400m",
+		},
+		{
+			pod:     "web-6d8f7c-q9z2x",
+			text:    "1 node(s) have untolerated taint dedicated=batch:NoSchedule",
+			command: `kubectl patch deployment/web -n checkout --type=json -p '[{"op":"add","path":"/spec/template/spec/tolerations","value":[{"key":"dedicated","operator":"Equal","value":"batch","effect":"NoSchedule"}]}]'`,
+		},
+		{
+			pod:     "reports-29321160-h7d2k",
+			text:    "1 node(s) have untolerated taint dedicated=batch:NoSchedule",
+			command: `kubectl patch cronjob/reports -n checkout --type=json -p '[{"op":"add","path":"/spec/jobTemplate/spec/template/spec/tolerations/-","value":{"key":"dedicated","operator":"Equal","value":"batch","effect":"NoSchedule"}}]'`,
+		},
+		{
+			pod:     "web-6d8f7c-q9z2x",
+			text:    "1 node(s) don't match the pod's nodeSelector disktype=ssd; 2 of 3 nodes have matching labels",
+			command: "kubectl get nodes --show-labels",
+		},
+		{
+			pod:     "cache-1",
+			text:    "2 node(s) would exceed maxSkew 1 of the topology spread over topology.kubernetes.io/zone",
+			command: `kubectl patch statefulset/cache -n checkout --type=json -p '[{"op":"replace","path":"/spec/template/spec/topologySpreadConstraints/1/whenUnsatisfiable","value":"ScheduleAnyway"}]'`,
+		},
+		{
+			pod:     "cache-1",
+			text:    "1 node(s) already use host port(s) 6379",
+			command: `kubectl patch statefulset/cache -n checkout --type=json -p '[{"op":"remove","path":"/spec/template/spec/containers/0/ports/0/hostPort"}]'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			report, err := engine.DiagnoseResource("pod", tt.pod)
+			if err != nil {
+				t.Fatalf("DiagnoseResource: %v", err)
+			}
+			if !hasIssue(report, IssueFailedScheduling, tt.pod, SeverityHigh, tt.text) {
+				t.Errorf("expected %q, got %+v", tt.text, report.Issues)
+			}
+			if !hasCommand(report, tt.command) {
+				t.Errorf("expected remediation %q, got %+v", tt.command, report.Remediations)
+			}
+		})
+	}
+
+	// A Job's pod template is immutable, so a bare Job gets no patch
+	report, err := engine.DiagnoseResource("pod", "migrate-x4q8n")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(report, IssueFailedScheduling, "migrate-x4q8n", SeverityHigh, "untolerated taint dedicated=batch:NoSchedule") {
+		t.Errorf("expected the taint to be explained, got %+v", report.Issues)
+	}
+	for _, remediation := range report.Remediations {
+		if strings.HasPrefix(remediation.Command, "kubectl patch") {
+			t.Errorf("unexpected patch for a bare Job: %q", remediation.Command)
+		}
+	}
+}
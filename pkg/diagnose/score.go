@@ -0,0 +1,437 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+)
+
+// ScoreWeights configures the health score. Severity weights are the share
+// of a resource's health an issue of that severity takes away (0-1).
+// Category weights set how much each category counts towards the overall
+// score. Namespace and label ("key=value") weights mark critical resources,
+// whose problems count more within their category; a resource takes the
+// highest weight that matches it.
+type ScoreWeights struct {
+	Severity   map[Severity]float64
+	Categories map[string]float64
+	Namespaces map[string]float64
+	Labels     map[string]float64
+}
+
+// Score categories
+const (
+	CategoryPods      = "pods"
+	CategoryWorkloads = "workloads"
+	CategoryNodes     = "nodes"
+	CategoryNetwork   = "network"
+	CategoryStorage   = "storage"
+	CategoryConfig    = "config"
+	CategoryOther     = "other"
+)
+
+// clusterScope names the group of cluster-scoped resources in the
+// namespace breakdown
+const clusterScope = "(cluster)"
+
+// DefaultScoreWeights returns the weights used unless configured otherwise
+func DefaultScoreWeights() ScoreWeights {
+	return ScoreWeights{
+		Severity: map[Severity]float64{
+			SeverityCritical: 0.8,
+			SeverityHigh:     0.5,
+			SeverityMedium:   0.25,
+			SeverityLow:      0.05,
+		},
+		Categories: map[string]float64{
+			CategoryPods:      1,
+			CategoryWorkloads: 1,
+			CategoryNodes:     1,
+			CategoryNetwork:   1,
+			CategoryStorage:   1,
+			CategoryConfig:    1,
+			CategoryOther:     1,
+		},
+		Namespaces: map[string]float64{
+			"kube-system": 2,
+		},
+		Labels: map[string]float64{
+			"tier=critical": 2,
+		},
+	}
+}
+
+// Merge returns the weights with the set entries of other overriding them
+func (w ScoreWeights) Merge(other ScoreWeights) ScoreWeights {
+	merged := ScoreWeights{
+		Severity:   map[Severity]float64{},
+		Categories: map[string]float64{},
+		Namespaces: map[string]float64{},
+		Labels:     map[string]float64{},
+	}
+	for _, weights := range []ScoreWeights{w, other} {
+		for k, v := range weights.Severity {
+			merged.Severity[k] = v
+		}
+		for k, v := range weights.Categories {
+			merged.Categories[k] = v
+		}
+		for k, v := range weights.Namespaces {
+			merged.Namespaces[k] = v
+		}
+		for k, v := range weights.Labels {
+			merged.Labels[k] = v
+		}
+	}
+	return merged
+}
+
+// Validate checks that the weights are usable
+func (w ScoreWeights) Validate() error {
+	for severity, weight := range w.Severity {
+		if weight < 0 || weight > 1 {
+			return fmt.Errorf("severity weight for %s must be between 0 and 1, got %g", severity, weight)
+		}
+	}
+	for name, weights := range map[string]map[string]float64{"category": w.Categories, "namespace": w.Namespaces, "label": w.Labels} {
+		for key, weight := range weights {
+			if weight < 0 {
+				return fmt.Errorf("%s weight for %s must not be negative, got %g", name, key, weight)
+			}
+		}
+	}
+	for label := range w.Labels {
+		if !strings.Contains(label, "=") {
+			return fmt.Errorf("label weight key %q must be key=value", label)
+		}
+	}
+	return nil
+}
+
+// SetScoreWeights replaces the default health score weights
+func (e *Engine) SetScoreWeights(weights ScoreWeights) error {
+	if err := weights.Validate(); err != nil {
+		return err
+	}
+	e.weights = &weights
+	return nil
+}
+
+// scoreWeights returns the configured weights or the defaults
+func (e *Engine) scoreWeights() ScoreWeights {
+	if e.weights != nil {
+		return *e.weights
+	}
+	return DefaultScoreWeights()
+}
+
+// ScoreBreakdown explains a health score per category and per namespace
+type ScoreBreakdown struct {
+	Categories []ScoreGroup
+	Namespaces []ScoreGroup
+}
+
+// ScoreGroup is the health of a group of resources
+type ScoreGroup struct {
+	Name      string
+	Score     int
+	Resources int
+	Affected  int
+	// Weight is the group's weight in the overall score; namespaces don't
+	// carry one
+	Weight float64
+}
+
+// scoredResource is a resource counted in the health score
+type scoredResource struct {
+	ref      ref
+	weight   float64
+	penalty  float64
+	affected bool
+}
+
+// score computes the report's health score over the examined population
+// and the resources its issues are about
+func (e *Engine) score(ctx context.Context, report *Report, population []ref) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		snapshot = &k8s.Snapshot{Namespace: e.namespace}
+	}
+	report.HealthScore, report.Score = scoreReport(snapshot, buildGraph(snapshot), report, append(population, report.examined...), e.namespace, e.scoreWeights())
+}
+
+// scoreReport computes a health score from 0 to 100. Each resource loses
+// health for its issues, combined so that no resource drops below zero; a
+// category's score is the criticality-weighted average of its resources,
+// and the overall score the category-weighted average of the categories
+// present. A few bad resources in a large cluster therefore score well.
+func scoreReport(snapshot *k8s.Snapshot, g *dependencyGraph, report *Report, population []ref, namespace string, weights ScoreWeights) (int, *ScoreBreakdown) {
+	resources := map[ref]*scoredResource{}
+	var order []ref
+	add := func(r ref) *scoredResource {
+		if r.kind == "node" || r.kind == "pv" {
+			r.namespace = ""
+		}
+		if resource, ok := resources[r]; ok {
+			return resource
+		}
+		resource := &scoredResource{ref: r}
+		resources[r] = resource
+		order = append(order, r)
+		return resource
+	}
+
+	for _, r := range population {
+		add(r)
+	}
+	health := map[ref]float64{}
+	for _, issue := range report.Issues {
+		r, ok := g.resolve(issue, namespace)
+		if !ok {
+			r = refFromString(issue.Resource, namespace)
+		}
+		resource := add(r)
+		if _, seen := health[resource.ref]; !seen {
+			health[resource.ref] = 1
+		}
+		health[resource.ref] *= 1 - weights.Severity[issue.Severity]
+		resource.affected = true
+	}
+
+	labels := resourceLabels(snapshot)
+	for _, r := range order {
+		resource := resources[r]
+		if h, ok := health[r]; ok {
+			resource.penalty = 1 - h
+		}
+		resource.weight = criticality(r, labels[r], weights)
+	}
+
+	categories := map[string][]*scoredResource{}
+	namespaces := map[string][]*scoredResource{}
+	for _, r := range order {
+		resource := resources[r]
+		category := scoreCategory(r.kind)
+		categories[category] = append(categories[category], resource)
+		ns := r.namespace
+		if ns == "" {
+			ns = clusterScope
+		}
+		namespaces[ns] = append(namespaces[ns], resource)
+	}
+
+	breakdown := &ScoreBreakdown{}
+	var total, totalWeight float64
+	for _, name := range sortedGroupNames(categories) {
+		group := scoreGroup(name, categories[name])
+		weight, ok := weights.Categories[name]
+		if !ok {
+			weight = 1
+		}
+		group.Weight = weight
+		breakdown.Categories = append(breakdown.Categories, group)
+		total += weight * groupHealth(categories[name])
+		totalWeight += weight
+	}
+	for _, name := range sortedGroupNames(namespaces) {
+		breakdown.Namespaces = append(breakdown.Namespaces, scoreGroup(name, namespaces[name]))
+	}
+
+	if totalWeight == 0 {
+		return 100, breakdown
+	}
+	return toScore(total / totalWeight), breakdown
+}
+
+// scoreGroup summarizes the health of a group of resources
+func scoreGroup(name string, resources []*scoredResource) ScoreGroup {
+	group := ScoreGroup{Name: name, Score: toScore(groupHealth(resources)), Resources: len(resources)}
+	for _, resource := range resources {
+		if resource.affected {
+			group.Affected++
+		}
+	}
+	return group
+}
+
+// groupHealth returns the criticality-weighted health (0-1) of resources
+func groupHealth(resources []*scoredResource) float64 {
+	var lost, weight float64
+	for _, resource := range resources {
+		lost += resource.weight * resource.penalty
+		weight += resource.weight
+	}
+	if weight == 0 {
+		return 1
+	}
+	return 1 - lost/weight
+}
+
+// toScore converts a health between 0 and 1 to a score out of 100. Only a
+// resource set without any penalty scores 100.
+func toScore(health float64) int {
+	score := int(math.Round(health * 100))
+	if score == 100 && health < 1 {
+		return 99
+	}
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// criticality returns the highest namespace or label weight matching a
+// resource, 1 when none does
+func criticality(r ref, labels map[string]string, weights ScoreWeights) float64 {
+	weight, matched := 0.0, false
+	if w, ok := weights.Namespaces[r.namespace]; ok && r.namespace != "" {
+		weight, matched = w, true
+	}
+	for key, value := range labels {
+		if w, ok := weights.Labels[key+"="+value]; ok && (!matched || w > weight) {
+			weight, matched = w, true
+		}
+	}
+	if !matched {
+		return 1
+	}
+	return weight
+}
+
+// scoreCategory returns the score category of a graph kind
+func scoreCategory(kind string) string {
+	switch kind {
+	case "pod":
+		return CategoryPods
+	case "deployment", "replicaset", "statefulset", "daemonset", "job", "cronjob":
+		return CategoryWorkloads
+	case "node":
+		return CategoryNodes
+	case "service", "endpoints", "ingress", "networkpolicy":
+		return CategoryNetwork
+	case "pvc", "pv":
+		return CategoryStorage
+	case "configmap", "secret":
+		return CategoryConfig
+	default:
+		return CategoryOther
+	}
+}
+
+// resourceLabels indexes the labels of the snapshot's objects
+func resourceLabels(snapshot *k8s.Snapshot) map[ref]map[string]string {
+	labels := map[ref]map[string]string{}
+	for _, kind := range []string{"pod", "node", "deployment", "replicaset", "statefulset", "daemonset",
+		"job", "cronjob", "service", "ingress", "persistentvolumeclaim", "persistentvolume", "networkpolicy"} {
+		objects, _ := snapshotObjects(snapshot, kind)
+		for _, obj := range objects {
+			accessor, ok := obj.(metav1.Object)
+			if !ok || len(accessor.GetLabels()) == 0 {
+				continue
+			}
+			labels[ref{kind: refKinds[kind], namespace: accessor.GetNamespace(), name: accessor.GetName()}] = accessor.GetLabels()
+		}
+	}
+	return labels
+}
+
+// resourcePopulation returns the resource a single-resource diagnosis
+// examined
+func (e *Engine) resourcePopulation(resourceType, name string) []ref {
+	kind, err := e.ruleKind(resourceType)
+	if err != nil {
+		kind = resourceType
+	}
+	return []ref{{kind: graphKind(kind), namespace: e.namespace, name: name}}
+}
+
+// typePopulation returns the resources of a type a diagnosis examined, when
+// the snapshot captures them
+func (e *Engine) typePopulation(ctx context.Context, resourceType string) []ref {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil
+	}
+	kind, err := e.ruleKind(resourceType)
+	if err != nil {
+		return nil
+	}
+	return populationOf(snapshot, kind)
+}
+
+// clusterPopulation returns the resources a cluster diagnosis examined: its
+// pods, claims and nodes, and the objects custom rules were evaluated on
+func (e *Engine) clusterPopulation(ctx context.Context) []ref {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil
+	}
+	kinds := []string{"pod", "persistentvolumeclaim"}
+	if snapshot.Err(k8s.ResourceNodes) == nil {
+		kinds = append(kinds, "node")
+	}
+	var population []ref
+	for _, kind := range append(kinds, e.ruleSet.Kinds()...) {
+		population = append(population, populationOf(snapshot, kind)...)
+	}
+	return population
+}
+
+// populationOf returns the snapshot's resources of a kind
+func populationOf(snapshot *k8s.Snapshot, kind string) []ref {
+	objects, _ := snapshotObjects(snapshot, kind)
+	population := make([]ref, 0, len(objects))
+	for _, obj := range objects {
+		if accessor, ok := obj.(metav1.Object); ok {
+			population = append(population, ref{kind: graphKind(kind), namespace: accessor.GetNamespace(), name: accessor.GetName()})
+		}
+	}
+	return population
+}
+
+// graphKind returns the graph kind of a kind or resource type
+func graphKind(kind string) string {
+	kind = strings.ToLower(kind)
+	if k, ok := snapshotKinds[kind]; ok {
+		kind = strings.ToLower(k)
+	}
+	if k, ok := refKinds[kind]; ok {
+		return k
+	}
+	return kind
+}
+
+// sortedGroupNames returns the names of score groups in order
+func sortedGroupNames(groups map[string][]*scoredResource) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// displayScore prints how the health score was computed
+func (r *Report) displayScore() {
+	if r.Score == nil || len(r.Issues) == 0 {
+		return
+	}
+	fmt.Fprintln(output.Stdout, "Score breakdown:")
+	for _, group := range r.Score.Categories {
+		fmt.Fprintf(output.Stdout, "  %-12s %3d/100  %d of %d resource(s) affected, weight %g\n", group.Name, group.Score, group.Affected, group.Resources, group.Weight)
+	}
+	if len(r.Score.Namespaces) > 0 {
+		fmt.Fprintln(output.Stdout, "  By namespace:")
+		for _, group := range r.Score.Namespaces {
+			fmt.Fprintf(output.Stdout, "    %-20s %3d/100  %d of %d resource(s) affected\n", group.Name, group.Score, group.Affected, group.Resources)
+		}
+	}
+	fmt.Fprintln(output.Stdout)
+}
@@ -0,0 +1,126 @@
+package diagnose
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+func scoreSnapshot(pods int, namespace string, labels map[string]string) *k8s.Snapshot {
+	snapshot := &k8s.Snapshot{}
+	for i := 0; i < pods; i++ {
+		snapshot.Pods = append(snapshot.Pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("pod-%d", i), Namespace: namespace, Labels: labels,
+		}})
+	}
+	return snapshot
+}
+
+func scoreSnapshotReport(snapshot *k8s.Snapshot, report *Report, weights ScoreWeights) (int, *ScoreBreakdown) {
+	return scoreReport(snapshot, buildGraph(snapshot), report, populationOf(snapshot, "pod"), "default", weights)
+}
+
+func TestScoreNormalizesByPopulation(t *testing.T) {
+	snapshot := scoreSnapshot(1000, "default", nil)
+	report := &Report{}
+	for i := 0; i < 10; i++ {
+		report.Issues = append(report.Issues, Issue{Severity: SeverityCritical, Resource: fmt.Sprintf("pod/pod-%d", i)})
+	}
+
+	score, breakdown := scoreSnapshotReport(snapshot, report, DefaultScoreWeights())
+	if score != 99 {
+		t.Errorf("score = %d, want 99 for 10 bad pods out of 1000", score)
+	}
+	if len(breakdown.Namespaces) != 1 || breakdown.Namespaces[0].Affected != 10 || breakdown.Namespaces[0].Resources != 1000 {
+		t.Errorf("namespaces = %+v, want 10 of 1000 affected in default", breakdown.Namespaces)
+	}
+}
+
+func TestScoreCombinesIssuesPerResource(t *testing.T) {
+	snapshot := scoreSnapshot(1, "default", nil)
+	report := &Report{}
+	for i := 0; i < 5; i++ {
+		report.Issues = append(report.Issues, Issue{Severity: SeverityCritical, Resource: "pod/pod-0"})
+	}
+
+	score, _ := scoreSnapshotReport(snapshot, report, DefaultScoreWeights())
+	if score < 0 || score > 1 {
+		t.Errorf("score = %d, want close to but not below 0", score)
+	}
+
+	healthy, _ := scoreSnapshotReport(snapshot, &Report{}, DefaultScoreWeights())
+	if healthy != 100 {
+		t.Errorf("score = %d, want 100 without issues", healthy)
+	}
+}
+
+func TestScoreWeightsCriticalResources(t *testing.T) {
+	report := &Report{Issues: []Issue{{Severity: SeverityHigh, Resource: "pod/pod-0"}}}
+
+	plain, _ := scoreSnapshotReport(scoreSnapshot(4, "default", nil), report, DefaultScoreWeights())
+	critical, _ := scoreSnapshotReport(scoreSnapshot(4, "default", map[string]string{"tier": "critical"}), report, DefaultScoreWeights())
+	if plain != 88 {
+		t.Errorf("score = %d, want 88", plain)
+	}
+	// Every pod is critical, so the share lost is the same
+	if critical != plain {
+		t.Errorf("score = %d, want %d when every pod carries the same weight", critical, plain)
+	}
+
+	snapshot := scoreSnapshot(3, "default", nil)
+	snapshot.Pods = append(snapshot.Pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "kube-system"}})
+	system := &Report{Issues: []Issue{{Severity: SeverityHigh, Resource: "pod/dns", Details: map[string]interface{}{"namespace": "kube-system"}}}}
+	score, breakdown := scoreSnapshotReport(snapshot, system, DefaultScoreWeights())
+	if score != 80 {
+		t.Errorf("score = %d, want 80 with the kube-system pod weighted twice", score)
+	}
+	if len(breakdown.Namespaces) != 2 || breakdown.Namespaces[1].Name != "kube-system" || breakdown.Namespaces[1].Score != 50 {
+		t.Errorf("namespaces = %+v, want kube-system at 50", breakdown.Namespaces)
+	}
+}
+
+func TestScoreCategories(t *testing.T) {
+	snapshot := scoreSnapshot(2, "default", nil)
+	snapshot.Nodes = []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}}
+	report := &Report{Issues: []Issue{{Severity: SeverityCritical, Resource: "node/worker-1"}}}
+	population := append(populationOf(snapshot, "pod"), populationOf(snapshot, "node")...)
+
+	score, breakdown := scoreReport(snapshot, buildGraph(snapshot), report, population, "default", DefaultScoreWeights())
+	if score != 60 {
+		t.Errorf("score = %d, want 60", score)
+	}
+	want := []ScoreGroup{
+		{Name: CategoryNodes, Score: 20, Resources: 1, Affected: 1, Weight: 1},
+		{Name: CategoryPods, Score: 100, Resources: 2, Weight: 1},
+	}
+	if fmt.Sprint(breakdown.Categories) != fmt.Sprint(want) {
+		t.Errorf("categories = %+v, want %+v", breakdown.Categories, want)
+	}
+	if breakdown.Namespaces[0].Name != clusterScope {
+		t.Errorf("namespaces = %+v, want the node under %s", breakdown.Namespaces, clusterScope)
+	}
+
+	weights := DefaultScoreWeights().Merge(ScoreWeights{Categories: map[string]float64{CategoryNodes: 3}})
+	if weighted, _ := scoreReport(snapshot, buildGraph(snapshot), report, population, "default", weights); weighted != 40 {
+		t.Errorf("score = %d, want 40 with nodes weighted 3", weighted)
+	}
+}
+
+func TestScoreWeightsValidate(t *testing.T) {
+	for _, weights := range []ScoreWeights{
+		{Severity: map[Severity]float64{SeverityHigh: 1.5}},
+		{Namespaces: map[string]float64{"prod": -1}},
+		{Labels: map[string]float64{"critical": 2}},
+	} {
+		if err := weights.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", weights)
+		}
+	}
+	if err := DefaultScoreWeights().Validate(); err != nil {
+		t.Errorf("Validate(defaults) = %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// diagnoseStatefulSet diagnoses a statefulset's ordinals, volume claims and
+// update, and rolls up the diagnostics of its pods
+func (e *Engine) diagnoseStatefulSet(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourceStatefulSets); err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+
+	sts := snapshot.StatefulSet(e.namespace, name)
+	if sts == nil {
+		return nil, fmt.Errorf("statefulset %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for statefulset: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+	resource := "statefulset/" + name
+
+	stalled := checkOrdinals(snapshot, report, sts, resource)
+	checkVolumeClaims(snapshot, report, sts, resource)
+	checkStatefulSetUpdate(report, sts, resource)
+
+	podIssues := 0
+	for _, pod := range snapshot.PodsOwnedBy(sts) {
+		before := len(report.Issues)
+		e.analyzePod(ctx, snapshot, report, pod)
+		podIssues += len(report.Issues) - before
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = statefulSetRemediations(sts, report.Issues, stalled)
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for statefulset: %s (%d/%d replicas ready, %d pod issue(s))",
+		name, sts.Status.ReadyReplicas, statefulSetReplicas(sts), podIssues)
+	return report, nil
+}
+
+// checkOrdinals finds the lowest ordinal that is missing or not ready. With
+// OrderedReady pod management the controller won't create higher ordinals
+// until it is ready, so it stalls the whole set. Returns the stalled pod name.
+func checkOrdinals(snapshot *k8s.Snapshot, report *Report, sts *appsv1.StatefulSet, resource string) string {
+	replicas := statefulSetReplicas(sts)
+	ordered := sts.Spec.PodManagementPolicy != appsv1.ParallelPodManagement
+
+	for i := int32(0); i < replicas; i++ {
+		podName := fmt.Sprintf("%s-%d", sts.Name, i)
+		pod := snapshot.Pod(sts.Namespace, podName)
+
+		var state string
+		switch {
+		case pod == nil:
+			state = "has not been created"
+		case !podReady(pod):
+			state = fmt.Sprintf("is not ready (phase %s)", pod.Status.Phase)
+		default:
+			continue
+		}
+
+		if !ordered {
+			report.Issues = append(report.Issues, Issue{
+				Severity:    SeverityMedium,
+				Type:        IssueReplicasUnavailable,
+				Resource:    resource,
+				Description: fmt.Sprintf("Ordinal %d (%s) %s", i, podName, state),
+				Details:     map[string]interface{}{"ordinal": i, "pod": podName},
+			})
+			continue
+		}
+
+		blocked := replicas - i - 1
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityHigh,
+			Type:     IssueOrdinalStalled,
+			Resource: resource,
+			Description: fmt.Sprintf("Ordinal %d (%s) %s; OrderedReady blocks the %d ordinal(s) after it",
+				i, podName, state, blocked),
+			Details: map[string]interface{}{"ordinal": i, "pod": podName, "blocked": blocked},
+		})
+		return podName
+	}
+	return ""
+}
+
+// checkVolumeClaims checks the claim of every volumeClaimTemplate for each
+// ordinal, which the controller names <template>-<statefulset>-<ordinal>
+func checkVolumeClaims(snapshot *k8s.Snapshot, report *Report, sts *appsv1.StatefulSet, resource string) {
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return
+	}
+	if err := snapshot.Err(k8s.ResourcePVCs); err != nil {
+		report.AddWarning(fmt.Sprintf("PersistentVolumeClaims not inspected: %v", err))
+		return
+	}
+
+	for i := int32(0); i < statefulSetReplicas(sts); i++ {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			claimName := fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, i)
+			claim := snapshot.PVC(sts.Namespace, claimName)
+
+			switch {
+			case claim == nil:
+				// Claims are created with their pod, so a missing claim for
+				// an existing pod means it was deleted out from under it
+				if snapshot.Pod(sts.Namespace, fmt.Sprintf("%s-%d", sts.Name, i)) == nil {
+					continue
+				}
+				report.Issues = append(report.Issues, Issue{
+					Severity:    SeverityHigh,
+					Type:        IssuePVCPending,
+					Resource:    resource,
+					Description: fmt.Sprintf("Ordinal %d has no PersistentVolumeClaim %s", i, claimName),
+					Details:     map[string]interface{}{"ordinal": i, "claim": claimName},
+				})
+			case claim.Status.Phase != corev1.ClaimBound:
+				report.Issues = append(report.Issues, Issue{
+					Severity:    SeverityHigh,
+					Type:        IssuePVCPending,
+					Resource:    resource,
+					Description: fmt.Sprintf("Ordinal %d claim %s is %s", i, claimName, claim.Status.Phase),
+					Details: map[string]interface{}{
+						"ordinal":      i,
+						"claim":        claimName,
+						"storageClass": storageClassName(claim),
+					},
+				})
+			}
+		}
+	}
+}
+
+// checkStatefulSetUpdate flags rolling updates that have stopped short of
+// the update revision
+func checkStatefulSetUpdate(report *Report, sts *appsv1.StatefulSet, resource string) {
+	if sts.Status.ObservedGeneration < sts.Generation {
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityLow,
+			Type:     IssueRolloutStuck,
+			Resource: resource,
+			Description: fmt.Sprintf("Controller has not observed the latest spec (generation %d, observed %d)",
+				sts.Generation, sts.Status.ObservedGeneration),
+		})
+	}
+
+	status := sts.Status
+	if status.UpdateRevision == "" || status.CurrentRevision == status.UpdateRevision {
+		return
+	}
+
+	var partition int32
+	if rolling := sts.Spec.UpdateStrategy.RollingUpdate; rolling != nil && rolling.Partition != nil {
+		partition = *rolling.Partition
+	}
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		report.Issues = append(report.Issues, Issue{
+			Severity: SeverityLow,
+			Type:     IssueRolloutStuck,
+			Resource: resource,
+			Description: fmt.Sprintf("Update revision %s is waiting for pods to be deleted (OnDelete strategy, %d/%d updated)",
+				status.UpdateRevision, status.UpdatedReplicas, statefulSetReplicas(sts)),
+		})
+		return
+	}
+
+	expected := statefulSetReplicas(sts) - partition
+	if status.UpdatedReplicas >= expected {
+		return
+	}
+	report.Issues = append(report.Issues, Issue{
+		Severity: SeverityMedium,
+		Type:     IssueRolloutStuck,
+		Resource: resource,
+		Description: fmt.Sprintf("Rolling update to %s has updated %d of %d replica(s) (partition %d)",
+			status.UpdateRevision, status.UpdatedReplicas, expected, partition),
+		Details: map[string]interface{}{
+			"currentRevision": status.CurrentRevision,
+			"updateRevision":  status.UpdateRevision,
+			"partition":       partition,
+		},
+	})
+}
+
+// statefulSetRemediations suggests commands for the issues found
+func statefulSetRemediations(sts *appsv1.StatefulSet, issues []Issue, stalled string) []Remediation {
+	target := fmt.Sprintf("statefulset/%s -n %s", sts.Name, sts.Namespace)
+	remediations := []Remediation{
+		{
+			Title:       "Check rollout status",
+			Description: "See which ordinal the statefulset is waiting for",
+			Command:     "kubectl rollout status " + target,
+			Confidence:  "High",
+			Safe:        true,
+		},
+	}
+
+	if stalled != "" {
+		remediations = append(remediations, Remediation{
+			Title:       "Inspect the stalled ordinal",
+			Description: fmt.Sprintf("Find out why %s is not ready", stalled),
+			Command:     fmt.Sprintf("kubectl describe pod %s -n %s", stalled, sts.Namespace),
+			Confidence:  "High",
+			Safe:        true,
+		})
+	}
+	for _, issue := range issues {
+		if issue.Type != IssuePVCPending {
+			continue
+		}
+		claim, _ := issue.Details["claim"].(string)
+		remediations = append(remediations, Remediation{
+			Title:       "Inspect the volume claim",
+			Description: "Check the claim's events for provisioning or binding errors",
+			Command:     fmt.Sprintf("kubectl describe pvc %s -n %s", claim, sts.Namespace),
+			Confidence:  "High",
+			Safe:        true,
+		})
+		break
+	}
+	if hasIssueType(issues, IssueRolloutStuck, "statefulset/") && sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		remediations = append(remediations, Remediation{
+			Title:       "Restart pods onto the update revision",
+			Description: "OnDelete statefulsets only update pods that are deleted",
+			Command:     "kubectl rollout restart " + target,
+			Confidence:  "Medium",
+			Safe:        false,
+		})
+	}
+
+	return remediations
+}
+
+// statefulSetReplicas returns the desired replica count, defaulting to 1
+func statefulSetReplicas(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Replicas == nil {
+		return 1
+	}
+	return *sts.Spec.Replicas
+}
+
+// podReady reports whether a pod's Ready condition is true
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// storageClassName returns a claim's storage class, or "" for the default
+func storageClassName(claim *corev1.PersistentVolumeClaim) string {
+	if claim.Spec.StorageClassName != nil {
+		return *claim.Spec.StorageClassName
+	}
+	return ""
+}
+
+// hasIssueType reports whether an issue of a type was found on a resource
+// with the given prefix, such as "statefulset/"
+func hasIssueType(issues []Issue, issueType IssueType, prefix string) bool {
+	for _, issue := range issues {
+		if issue.Type == issueType && strings.HasPrefix(issue.Resource, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,693 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// noProvisioner is the provisioner of storage classes for static volumes
+const noProvisioner = "kubernetes.io/no-provisioner"
+
+// blockProvisioners provision block devices that attach to a single node,
+// so they can't serve ReadWriteMany or ReadOnlyMany claims
+var blockProvisioners = map[string]bool{
+	"ebs.csi.aws.com":          true,
+	"kubernetes.io/aws-ebs":    true,
+	"pd.csi.storage.gke.io":    true,
+	"kubernetes.io/gce-pd":     true,
+	"disk.csi.azure.com":       true,
+	"kubernetes.io/azure-disk": true,
+	"rancher.io/local-path":    true,
+	"cinder.csi.openstack.org": true,
+}
+
+// mountFailureReasons are the pod event reasons of volume attach and mount
+// failures
+var mountFailureReasons = map[string]bool{
+	"FailedAttachVolume": true,
+	"FailedMount":        true,
+	"FailedMapVolume":    true,
+}
+
+// diagnosePVC diagnoses a claim's binding and the volume problems of the
+// pods using it
+func (e *Engine) diagnosePVC(ctx context.Context, name string) (*Report, error) {
+	snapshot, err := e.loadSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Err(k8s.ResourcePVCs); err != nil {
+		return nil, fmt.Errorf("failed to list persistentvolumeclaims: %w", err)
+	}
+
+	claim := snapshot.PVC(e.namespace, name)
+	if claim == nil {
+		return nil, fmt.Errorf("persistentvolumeclaim %s not found in namespace %s", name, e.namespace)
+	}
+
+	report := &Report{
+		Summary:      fmt.Sprintf("Diagnostics for persistentvolumeclaim: %s", name),
+		Issues:       []Issue{},
+		Remediations: []Remediation{},
+		HealthScore:  100,
+	}
+
+	e.checkClaim(snapshot, report, claim)
+	consumers := podsUsingClaim(snapshot, claim)
+	for _, pod := range consumers {
+		e.analyzePodStorage(snapshot, report, pod)
+	}
+
+	if len(report.Issues) > 0 {
+		report.Remediations = storageRemediations(report.Issues, claim.Namespace)
+	}
+
+	report.Summary = fmt.Sprintf("Diagnostics for persistentvolumeclaim: %s (%s, class %s, %d pod(s) using it)",
+		name, claimPhase(claim), describeClass(claim), len(consumers))
+	return report, nil
+}
+
+// analyzePodStorage adds the storage issues of a pod's volumes: unbound
+// claims, attach and mount failures, PV node affinity conflicts and CSI
+// drivers missing from the pod's node
+func (e *Engine) analyzePodStorage(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	for _, volume := range pod.Spec.Volumes {
+		claimName := ""
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		case volume.Ephemeral != nil:
+			claimName = pod.Name + "-" + volume.Name
+		default:
+			continue
+		}
+		if snapshot.Err(k8s.ResourcePVCs) != nil {
+			report.AddWarning(fmt.Sprintf("PersistentVolumeClaims not inspected: %v", snapshot.Err(k8s.ResourcePVCs)))
+			return
+		}
+
+		claim := snapshot.PVC(pod.Namespace, claimName)
+		if claim == nil {
+			if !reported(report, "pvc/"+claimName) {
+				report.Issues = append(report.Issues, Issue{
+					Severity:    SeverityHigh,
+					Type:        IssuePVCPending,
+					Resource:    "pvc/" + claimName,
+					Description: fmt.Sprintf("Pod %s uses PersistentVolumeClaim %s, which does not exist", pod.Name, claimName),
+					Details:     map[string]interface{}{"claim": claimName, "pod": pod.Name},
+				})
+			}
+			continue
+		}
+
+		if claim.Status.Phase != corev1.ClaimBound {
+			e.checkClaim(snapshot, report, claim)
+			continue
+		}
+		if pv := snapshot.PV(claim.Spec.VolumeName); pv != nil {
+			checkVolumeNode(snapshot, report, pod, pv)
+			checkCSIDriver(snapshot, report, pod, pv)
+		}
+	}
+
+	checkMountEvents(snapshot, report, pod)
+}
+
+// reported reports whether the report already has issues for a resource,
+// so claims shared by several pods are only analyzed once
+func reported(report *Report, resource string) bool {
+	for _, issue := range report.Issues {
+		if issue.Resource == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClaim explains why a claim is not bound: its storage class, volume
+// binding mode, provisioner and the available volumes it could bind to
+func (e *Engine) checkClaim(snapshot *k8s.Snapshot, report *Report, claim *corev1.PersistentVolumeClaim) {
+	resource := "pvc/" + claim.Name
+	if reported(report, resource) {
+		return
+	}
+	first := len(report.Issues)
+	add := func(severity Severity, description string, details map[string]interface{}) {
+		if details == nil {
+			details = map[string]interface{}{}
+		}
+		details["claim"] = claim.Name
+		report.Issues = append(report.Issues, Issue{
+			Severity:    severity,
+			Type:        IssuePVCPending,
+			Resource:    resource,
+			Description: description,
+			Details:     details,
+		})
+	}
+
+	switch claim.Status.Phase {
+	case corev1.ClaimBound:
+		return
+	case corev1.ClaimLost:
+		add(SeverityCritical, fmt.Sprintf("PersistentVolume %s backing the claim was lost", claim.Spec.VolumeName), nil)
+		return
+	}
+
+	classesKnown := snapshot.Err(k8s.ResourceStorageClasses) == nil
+	if !classesKnown {
+		report.AddWarning(fmt.Sprintf("StorageClasses not inspected: %v", snapshot.Err(k8s.ResourceStorageClasses)))
+	}
+
+	// A claim pre-bound to a volume only binds to that volume
+	if claim.Spec.VolumeName != "" {
+		pv := snapshot.PV(claim.Spec.VolumeName)
+		switch {
+		case snapshot.Err(k8s.ResourcePVs) != nil:
+			report.AddWarning(fmt.Sprintf("PersistentVolumes not inspected: %v", snapshot.Err(k8s.ResourcePVs)))
+		case pv == nil:
+			add(SeverityHigh, fmt.Sprintf("Claim is pre-bound to PersistentVolume %s, which does not exist", claim.Spec.VolumeName), nil)
+		case pv.Spec.ClaimRef != nil && (pv.Spec.ClaimRef.Namespace != claim.Namespace || pv.Spec.ClaimRef.Name != claim.Name):
+			add(SeverityHigh, fmt.Sprintf("Claim is pre-bound to PersistentVolume %s, which is bound to %s/%s",
+				pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name), nil)
+		default:
+			if mismatches := volumeMismatches(claim, pv); len(mismatches) > 0 {
+				add(SeverityHigh, fmt.Sprintf("Claim is pre-bound to PersistentVolume %s, which doesn't fit: %s",
+					pv.Name, strings.Join(mismatches, "; ")), nil)
+			}
+		}
+		e.attachClaimEvents(snapshot, report, claim, first)
+		return
+	}
+
+	var class *storagev1.StorageClass
+	switch {
+	case claim.Spec.StorageClassName != nil && *claim.Spec.StorageClassName == "":
+		// An explicitly empty class only binds to static volumes without one
+		matchStaticVolumes(snapshot, claim, "", add)
+		e.attachClaimEvents(snapshot, report, claim, first)
+		return
+	case claim.Spec.StorageClassName == nil:
+		defaults := snapshot.DefaultStorageClasses()
+		if classesKnown && len(defaults) == 0 {
+			add(SeverityHigh, "Claim has no storageClassName and the cluster has no default StorageClass",
+				map[string]interface{}{"storageClasses": storageClassNames(snapshot)})
+			e.attachClaimEvents(snapshot, report, claim, first)
+			return
+		}
+		if len(defaults) > 0 {
+			class = defaults[0]
+		}
+	default:
+		class = snapshot.StorageClass(*claim.Spec.StorageClassName)
+		if class == nil && classesKnown {
+			description := fmt.Sprintf("StorageClass %s does not exist", *claim.Spec.StorageClassName)
+			if defaults := snapshot.DefaultStorageClasses(); len(defaults) > 0 {
+				description += fmt.Sprintf(" (the default is %s)", defaults[0].Name)
+			}
+			add(SeverityHigh, description, map[string]interface{}{"storageClasses": storageClassNames(snapshot)})
+			e.attachClaimEvents(snapshot, report, claim, first)
+			return
+		}
+	}
+	if class == nil {
+		e.attachClaimEvents(snapshot, report, claim, first)
+		return
+	}
+
+	if class.VolumeBindingMode != nil && *class.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		consumers := podsUsingClaim(snapshot, claim)
+		var unscheduled []string
+		for _, pod := range consumers {
+			if pod.Spec.NodeName == "" {
+				unscheduled = append(unscheduled, pod.Name)
+			}
+		}
+		switch {
+		case len(consumers) == 0:
+			add(SeverityLow, fmt.Sprintf("StorageClass %s uses WaitForFirstConsumer; the claim binds once a pod uses it", class.Name), nil)
+			return
+		case len(unscheduled) == len(consumers):
+			add(SeverityMedium, fmt.Sprintf("StorageClass %s uses WaitForFirstConsumer; binding waits for pod %s to be scheduled, so its scheduling failure is the cause",
+				class.Name, strings.Join(unscheduled, ", ")), map[string]interface{}{"pods": unscheduled})
+			e.attachClaimEvents(snapshot, report, claim, first)
+			return
+		}
+	}
+
+	if class.Provisioner == noProvisioner {
+		matchStaticVolumes(snapshot, claim, class.Name, add)
+		e.attachClaimEvents(snapshot, report, claim, first)
+		return
+	}
+
+	if blockProvisioners[class.Provisioner] {
+		for _, mode := range claim.Spec.AccessModes {
+			if mode == corev1.ReadWriteMany || mode == corev1.ReadOnlyMany {
+				add(SeverityHigh, fmt.Sprintf("StorageClass %s provisions single-node volumes (%s), which can't be %s",
+					class.Name, class.Provisioner, mode), map[string]interface{}{"accessMode": string(mode), "provisioner": class.Provisioner})
+				e.attachClaimEvents(snapshot, report, claim, first)
+				return
+			}
+		}
+	}
+
+	var failures []string
+	for _, event := range snapshot.EventsFor("PersistentVolumeClaim", claim.Namespace, claim.Name) {
+		if event.Type == corev1.EventTypeWarning && event.Reason == "ProvisioningFailed" {
+			failures = append(failures, event.Message)
+		}
+	}
+	if len(failures) > 0 {
+		add(SeverityHigh, fmt.Sprintf("Provisioner %s failed to provision a volume: %s", class.Provisioner, failures[len(failures)-1]),
+			map[string]interface{}{"provisioner": class.Provisioner})
+	} else {
+		add(SeverityMedium, fmt.Sprintf("Waiting for provisioner %s to provision a volume; check that its controller is running", class.Provisioner),
+			map[string]interface{}{"provisioner": class.Provisioner})
+	}
+	e.attachClaimEvents(snapshot, report, claim, first)
+}
+
+// attachClaimEvents adds a claim's warning events to the issues found on it
+func (e *Engine) attachClaimEvents(snapshot *k8s.Snapshot, report *Report, claim *corev1.PersistentVolumeClaim, first int) {
+	if len(report.Issues) == first {
+		return
+	}
+	events := e.warningEvents(snapshot, report, "PersistentVolumeClaim", claim.Name, claim.Namespace)
+	if len(events) == 0 {
+		return
+	}
+	for i := first; i < len(report.Issues); i++ {
+		report.Issues[i].Details["events"] = events
+	}
+}
+
+// matchStaticVolumes explains why none of the available volumes of a
+// storage class can bind to a claim
+func matchStaticVolumes(snapshot *k8s.Snapshot, claim *corev1.PersistentVolumeClaim, className string,
+	add func(Severity, string, map[string]interface{})) {
+	if snapshot.Err(k8s.ResourcePVs) != nil {
+		return
+	}
+
+	var candidates []string
+	var misfits []string
+	for i := range snapshot.PVs {
+		pv := &snapshot.PVs[i]
+		if pv.Spec.StorageClassName != className || pv.Status.Phase != corev1.VolumeAvailable || pv.Spec.ClaimRef != nil {
+			continue
+		}
+		candidates = append(candidates, pv.Name)
+		if mismatches := volumeMismatches(claim, pv); len(mismatches) > 0 {
+			misfits = append(misfits, fmt.Sprintf("%s: %s", pv.Name, strings.Join(mismatches, ", ")))
+		}
+	}
+
+	describe := "without a storage class"
+	if className != "" {
+		describe = "of StorageClass " + className
+	}
+	switch {
+	case len(candidates) == 0:
+		add(SeverityHigh, fmt.Sprintf("No Available PersistentVolume %s; static volumes must be created before the claim can bind", describe), nil)
+	case len(misfits) == len(candidates):
+		add(SeverityHigh, fmt.Sprintf("None of the %d Available PersistentVolume(s) %s fit the claim", len(candidates), describe),
+			map[string]interface{}{"mismatches": misfits})
+	}
+}
+
+// volumeMismatches lists why a volume can't satisfy a claim: access modes,
+// capacity, volume mode and label selector
+func volumeMismatches(claim *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) []string {
+	var mismatches []string
+
+	for _, mode := range claim.Spec.AccessModes {
+		if !hasAccessMode(pv.Spec.AccessModes, mode) {
+			mismatches = append(mismatches, fmt.Sprintf("access modes %s lack %s", accessModes(pv.Spec.AccessModes), mode))
+			break
+		}
+	}
+
+	requested := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+	if capacity.Cmp(requested) < 0 {
+		mismatches = append(mismatches, fmt.Sprintf("capacity %s < requested %s", capacity.String(), requested.String()))
+	}
+
+	claimMode, volumeMode := corev1.PersistentVolumeFilesystem, corev1.PersistentVolumeFilesystem
+	if claim.Spec.VolumeMode != nil {
+		claimMode = *claim.Spec.VolumeMode
+	}
+	if pv.Spec.VolumeMode != nil {
+		volumeMode = *pv.Spec.VolumeMode
+	}
+	if claimMode != volumeMode {
+		mismatches = append(mismatches, fmt.Sprintf("volume mode %s, claim wants %s", volumeMode, claimMode))
+	}
+
+	if claim.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(claim.Spec.Selector)
+		if err == nil && !selector.Matches(labels.Set(pv.Labels)) {
+			mismatches = append(mismatches, fmt.Sprintf("labels don't match selector %s", selector))
+		}
+	}
+	return mismatches
+}
+
+// hasAccessMode reports whether modes includes mode
+func hasAccessMode(modes []corev1.PersistentVolumeAccessMode, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// accessModes formats access modes as "ReadWriteOnce,ReadOnlyMany"
+func accessModes(modes []corev1.PersistentVolumeAccessMode) string {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		names = append(names, string(mode))
+	}
+	return strings.Join(names, ",")
+}
+
+// checkVolumeNode flags pods that can't run where their volume is, because
+// the PV's node affinity (usually its zone) excludes the pod's node, or
+// every node the pod may schedule on
+func checkVolumeNode(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod, pv *corev1.PersistentVolume) {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil || snapshot.Err(k8s.ResourceNodes) != nil {
+		return
+	}
+	required := pv.Spec.NodeAffinity.Required
+	pinned := describeNodeSelector(required)
+
+	if pod.Spec.NodeName != "" {
+		node := snapshot.Node(pod.Spec.NodeName)
+		if node == nil || k8s.NodeSelectorMatches(required, node) {
+			return
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityCritical,
+			Type:        IssueVolumeNodeConflict,
+			Resource:    pod.Name,
+			Description: fmt.Sprintf("PersistentVolume %s is pinned to %s, but the pod is on node %s", pv.Name, pinned, node.Name),
+			Details:     map[string]interface{}{"volume": pv.Name, "node": node.Name, "zone": node.Labels[corev1.LabelTopologyZone]},
+		})
+		return
+	}
+
+	var volumeNodes, eligible []string
+	for i := range snapshot.Nodes {
+		node := &snapshot.Nodes[i]
+		if !k8s.NodeSelectorMatches(required, node) {
+			continue
+		}
+		volumeNodes = append(volumeNodes, node.Name)
+		if podFitsNodeSelection(pod, node) {
+			eligible = append(eligible, node.Name)
+		}
+	}
+	if len(snapshot.Nodes) == 0 || len(eligible) > 0 {
+		return
+	}
+
+	description := fmt.Sprintf("PersistentVolume %s is pinned to %s, where no node exists", pv.Name, pinned)
+	if len(volumeNodes) > 0 {
+		description = fmt.Sprintf("PersistentVolume %s is pinned to %s, but the pod's node selector or affinity excludes all %d node(s) there",
+			pv.Name, pinned, len(volumeNodes))
+	}
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityHigh,
+		Type:        IssueVolumeNodeConflict,
+		Resource:    pod.Name,
+		Description: description,
+		Details:     map[string]interface{}{"volume": pv.Name, "volumeNodes": volumeNodes},
+	})
+}
+
+// podFitsNodeSelection reports whether a node satisfies a pod's node
+// selector and required node affinity
+func podFitsNodeSelection(pod *corev1.Pod, node *corev1.Node) bool {
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if affinity := pod.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil {
+		return k8s.NodeSelectorMatches(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution, node)
+	}
+	return true
+}
+
+// describeNodeSelector formats a node selector as
+// "topology.kubernetes.io/zone in (us-east-1a)"
+func describeNodeSelector(selector *corev1.NodeSelector) string {
+	var terms []string
+	for _, term := range selector.NodeSelectorTerms {
+		var requirements []string
+		for _, requirement := range term.MatchExpressions {
+			switch requirement.Operator {
+			case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+				requirements = append(requirements, fmt.Sprintf("%s %s", requirement.Key, strings.ToLower(string(requirement.Operator))))
+			default:
+				requirements = append(requirements, fmt.Sprintf("%s %s (%s)", requirement.Key,
+					strings.ToLower(string(requirement.Operator)), strings.Join(requirement.Values, ", ")))
+			}
+		}
+		terms = append(terms, strings.Join(requirements, " and "))
+	}
+	return strings.Join(terms, " or ")
+}
+
+// checkCSIDriver flags CSI volumes whose driver is not registered on the
+// pod's node, which happens when the driver's node plugin pod is down
+func checkCSIDriver(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod, pv *corev1.PersistentVolume) {
+	if pv.Spec.CSI == nil || pod.Spec.NodeName == "" || snapshot.Err(k8s.ResourceCSINodes) != nil {
+		return
+	}
+	driver := pv.Spec.CSI.Driver
+	node := pod.Spec.NodeName
+
+	if csiNode := snapshot.CSINode(node); csiNode != nil {
+		for _, registered := range csiNode.Spec.Drivers {
+			if registered.Name == driver {
+				return
+			}
+		}
+	}
+
+	for _, issue := range report.Issues {
+		if issue.Type == IssueCSIDriverUnavailable && issue.Details["driver"] == driver && issue.Details["node"] == node {
+			return
+		}
+	}
+
+	description := fmt.Sprintf("CSI driver %s is not registered on node %s, so volume %s can't be attached or mounted", driver, node, pv.Name)
+	details := map[string]interface{}{"driver": driver, "node": node, "volume": pv.Name}
+	if plugins := csiPluginPods(snapshot, node); len(plugins) > 0 {
+		description += fmt.Sprintf("; node plugin pod(s) not ready: %s", strings.Join(plugins, ", "))
+		details["pluginPods"] = plugins
+	}
+	report.Issues = append(report.Issues, Issue{
+		Severity:    SeverityHigh,
+		Type:        IssueCSIDriverUnavailable,
+		Resource:    pod.Name,
+		Description: description,
+		Details:     details,
+	})
+}
+
+// csiPluginPods returns the CSI node plugin pods on a node that are not
+// ready. Node plugins are recognized by their node-driver-registrar sidecar.
+func csiPluginPods(snapshot *k8s.Snapshot, node string) []string {
+	var pods []string
+	for i := range snapshot.Pods {
+		pod := &snapshot.Pods[i]
+		if pod.Spec.NodeName != node || podReady(pod) {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if strings.Contains(container.Name, "driver-registrar") {
+				pods = append(pods, pod.Namespace+"/"+pod.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(pods)
+	return pods
+}
+
+// checkMountEvents turns a pod's attach and mount failure events into
+// issues, one per reason with its latest message
+func checkMountEvents(snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	latest := map[string]*corev1.Event{}
+	var reasons []string
+	events := snapshot.EventsFor("Pod", pod.Namespace, pod.Name)
+	for i := range events {
+		event := &events[i]
+		if event.Type != corev1.EventTypeWarning || !mountFailureReasons[event.Reason] {
+			continue
+		}
+		if latest[event.Reason] == nil {
+			reasons = append(reasons, event.Reason)
+		}
+		latest[event.Reason] = event
+	}
+
+	for _, reason := range reasons {
+		event := latest[reason]
+		description := fmt.Sprintf("%s: %s (x%d)", reason, event.Message, event.Count)
+		if strings.Contains(event.Message, "Multi-Attach") {
+			description += "; a ReadWriteOnce volume is still attached to another node"
+		}
+		report.Issues = append(report.Issues, Issue{
+			Severity:    SeverityHigh,
+			Type:        IssueVolumeMountFailure,
+			Resource:    pod.Name,
+			Description: description,
+			Details:     map[string]interface{}{"reason": reason},
+		})
+	}
+}
+
+// podsUsingClaim returns the pods that mount a claim
+func podsUsingClaim(snapshot *k8s.Snapshot, claim *corev1.PersistentVolumeClaim) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for i := range snapshot.Pods {
+		pod := &snapshot.Pods[i]
+		if pod.Namespace != claim.Namespace {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if (volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claim.Name) ||
+				(volume.Ephemeral != nil && pod.Name+"-"+volume.Name == claim.Name) {
+				pods = append(pods, pod)
+				break
+			}
+		}
+	}
+	return pods
+}
+
+// storageClassNames lists the storage classes in the snapshot
+func storageClassNames(snapshot *k8s.Snapshot) []string {
+	names := make([]string, 0, len(snapshot.StorageClasses))
+	for _, class := range snapshot.StorageClasses {
+		names = append(names, class.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// claimPhase returns a claim's phase, treating an empty phase as Pending
+func claimPhase(claim *corev1.PersistentVolumeClaim) corev1.PersistentVolumeClaimPhase {
+	if claim.Status.Phase == "" {
+		return corev1.ClaimPending
+	}
+	return claim.Status.Phase
+}
+
+// describeClass names a claim's storage class for display
+func describeClass(claim *corev1.PersistentVolumeClaim) string {
+	switch {
+	case claim.Spec.StorageClassName == nil:
+		return "<default>"
+	case *claim.Spec.StorageClassName == "":
+		return `""`
+	default:
+		return *claim.Spec.StorageClassName
+	}
+}
+
+// storageRemediations suggests commands for the storage issues found
+func storageRemediations(issues []Issue, namespace string) []Remediation {
+	var remediations []Remediation
+	seen := map[string]bool{}
+	add := func(remediation Remediation) {
+		if seen[remediation.Command] {
+			return
+		}
+		seen[remediation.Command] = true
+		remediations = append(remediations, remediation)
+	}
+
+	for _, issue := range issues {
+		switch issue.Type {
+		case IssuePVCPending:
+			claim, _ := issue.Details["claim"].(string)
+			add(Remediation{
+				Title:       "Describe claim " + claim,
+				Description: "The claim's events show binding and provisioning errors",
+				Command:     fmt.Sprintf("kubectl describe pvc %s -n %s", claim, namespace),
+				Confidence:  "High",
+				Safe:        true,
+			})
+			if _, ok := issue.Details["storageClasses"]; ok {
+				add(Remediation{
+					Title:       "List storage classes",
+					Description: "Pick an existing class, or mark one as the default",
+					Command:     "kubectl get storageclass",
+					Confidence:  "High",
+					Safe:        true,
+				})
+			}
+			if provisioner, ok := issue.Details["provisioner"].(string); ok {
+				add(Remediation{
+					Title:       "Check the provisioner",
+					Description: fmt.Sprintf("Make sure the %s controller is running", provisioner),
+					Command:     fmt.Sprintf("kubectl get pods -A | grep -i %s", provisionerHint(provisioner)),
+					Confidence:  "Medium",
+					Safe:        true,
+				})
+			}
+		case IssueVolumeMountFailure:
+			add(Remediation{
+				Title:       "Check volume attachments",
+				Description: "A volume still attached to another node blocks ReadWriteOnce mounts",
+				Command:     "kubectl get volumeattachments",
+				Confidence:  "Medium",
+				Safe:        true,
+			})
+		case IssueVolumeNodeConflict:
+			add(Remediation{
+				Title:       "Compare node zones",
+				Description: "Volumes can only be used from nodes in their zone",
+				Command:     "kubectl get nodes -L topology.kubernetes.io/zone",
+				Confidence:  "High",
+				Safe:        true,
+			})
+		case IssueCSIDriverUnavailable:
+			node, _ := issue.Details["node"].(string)
+			add(Remediation{
+				Title:       "Check the CSI node plugin on " + node,
+				Description: "The driver registers on a node once its node plugin pod is ready",
+				Command:     fmt.Sprintf("kubectl get pods -A -o wide --field-selector spec.nodeName=%s | grep -i csi", node),
+				Confidence:  "High",
+				Safe:        true,
+			})
+		}
+	}
+	return remediations
+}
+
+// provisionerHint returns a word to find a provisioner's pods by, such as
+// "ebs" for ebs.csi.aws.com
+func provisionerHint(provisioner string) string {
+	name := provisioner
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
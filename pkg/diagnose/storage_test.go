@@ -0,0 +1,123 @@
+package diagnose
+
+import (
+	"testing"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func TestDiagnosePVC(t *testing.T) {
+	engine := newTestEngine(t, "media", "testdata/storage.yaml")
+
+	tests := []struct {
+		claim    string
+		severity Severity
+		text     string
+		command  string
+	}{
+		{
+			claim:    "uploads",
+			severity: SeverityHigh,
+			text:     "StorageClass fast provisions single-node volumes (ebs.csi.aws.com), which can't be ReadWriteMany",
+			command:  "kubectl get pods -A | grep -i ebs",
+		},
+		{
+			claim:    "cache",
+			severity: SeverityHigh,
+			text:     "StorageClass premium does not exist (the default is standard)",
+			command:  "kubectl get storageclass",
+		},
+		{
+			claim:    "archive",
+			severity: SeverityHigh,
+			text:     "None of the 1 Available PersistentVolume(s) of StorageClass local fit the claim",
+			command:  "kubectl describe pvc archive -n media",
+		},
+		{
+			claim:    "scratch",
+			severity: SeverityLow,
+			text:     "StorageClass standard uses WaitForFirstConsumer; the claim binds once a pod uses it",
+			command:  "kubectl describe pvc scratch -n media",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.claim, func(t *testing.T) {
+			report, err := engine.DiagnoseResource("pvc", tt.claim)
+			if err != nil {
+				t.Fatalf("DiagnoseResource: %v", err)
+			}
+			if !hasIssue(report, IssuePVCPending, "pvc/"+tt.claim, tt.severity, tt.text) {
+				t.Errorf("expected %q, got %+v", tt.text, report.Issues)
+			}
+			if !hasCommand(report, tt.command) {
+				t.Errorf("expected remediation %q, got %+v", tt.command, report.Remediations)
+			}
+		})
+	}
+
+	report, err := engine.DiagnoseResource("persistentvolumeclaims", "archive")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	mismatches, _ := report.Issues[0].Details["mismatches"].([]string)
+	if len(mismatches) != 1 || mismatches[0] != "local-pv-1: capacity 50Gi < requested 100Gi" {
+		t.Errorf("mismatches = %v", mismatches)
+	}
+
+	report, err = engine.DiagnoseResource("pvc", "cache")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if events, _ := report.Issues[0].Details["events"].([]string); len(events) != 1 {
+		t.Errorf("expected the ProvisioningFailed event as evidence, got %v", report.Issues[0].Details)
+	}
+}
+
+func TestDiagnosePodStorage(t *testing.T) {
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	// All namespaces, so the CSI node plugin in kube-system is visible
+	client := k8stest.NewClient(t, "media", "testdata/storage.yaml")
+	engine := NewEngineWithClient(client, provider, "media", true)
+
+	report, err := engine.DiagnoseResource("pod", "worker-0")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueVolumeNodeConflict, "worker-0", SeverityCritical,
+		"PersistentVolume pv-data is pinned to topology.kubernetes.io/zone in (us-east-1a), but the pod is on node node-b") {
+		t.Errorf("expected a zone conflict, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueCSIDriverUnavailable, "worker-0", SeverityHigh,
+		"CSI driver ebs.csi.aws.com is not registered on node node-b, so volume pv-data can't be attached or mounted; node plugin pod(s) not ready: kube-system/ebs-csi-node-7xk2p") {
+		t.Errorf("expected an unregistered CSI driver, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueVolumeMountFailure, "worker-0", SeverityHigh, "a ReadWriteOnce volume is still attached to another node") {
+		t.Errorf("expected a Multi-Attach failure, got %+v", report.Issues)
+	}
+	if !hasCommand(report, "kubectl get pods -A -o wide --field-selector spec.nodeName=node-b | grep -i csi") {
+		t.Errorf("expected a CSI plugin remediation, got %+v", report.Remediations)
+	}
+}
+
+func TestDiagnoseClusterReportsPendingClaims(t *testing.T) {
+	engine := newTestEngine(t, "media", "testdata/storage.yaml")
+
+	report, err := engine.DiagnoseCluster()
+	if err != nil {
+		t.Fatalf("DiagnoseCluster: %v", err)
+	}
+	for _, claim := range []string{"uploads", "cache", "archive", "scratch"} {
+		if !reported(report, "pvc/"+claim) {
+			t.Errorf("expected pending claim %s in the cluster report, got %+v", claim, report.Issues)
+		}
+	}
+	if reported(report, "pvc/data") {
+		t.Errorf("bound claim reported: %+v", report.Issues)
+	}
+}
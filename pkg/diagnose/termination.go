@@ -0,0 +1,202 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+const (
+	// limitHeadroom is the factor by which an OOMKilled container's memory
+	// limit is raised
+	limitHeadroom = 1.5
+	// memoryStep is the granularity suggested memory limits are rounded up to
+	memoryStep = 64 * 1024 * 1024
+)
+
+// analyzeTerminations interprets how each container last terminated: its
+// reason and exit code, memory limit vs usage for OOM kills, and the output
+// of the terminated instance
+func (e *Engine) analyzeTerminations(ctx context.Context, snapshot *k8s.Snapshot, report *Report, pod *corev1.Pod) {
+	var usage map[string]corev1.ResourceList
+	usageRead := false
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		terminated := cs.LastTerminationState.Terminated
+		current := false
+		if cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0 {
+			terminated, current = cs.State.Terminated, true
+		}
+		if terminated == nil || (terminated.ExitCode == 0 && terminated.Reason != "OOMKilled") {
+			continue
+		}
+
+		issue := Issue{
+			Severity: SeverityMedium,
+			Type:     IssueContainerTerminated,
+			Resource: fmt.Sprintf("%s/%s", pod.Name, cs.Name),
+			Description: fmt.Sprintf("Container %s last terminated with exit code %d (%s): %s",
+				cs.Name, terminated.ExitCode, terminated.Reason, exitCodeMeaning(terminated)),
+			Details: map[string]interface{}{
+				"pod":        pod.Name,
+				"container":  cs.Name,
+				"exitCode":   terminated.ExitCode,
+				"reason":     terminated.Reason,
+				"finishedAt": terminated.FinishedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			},
+		}
+		if terminated.Message != "" {
+			issue.Details["message"] = terminated.Message
+		}
+		if workload := podWorkload(snapshot, pod); workload != "" {
+			issue.Details["workload"] = workload
+		}
+
+		switch {
+		case terminated.Reason == "OOMKilled":
+			if !usageRead {
+				usage = e.containerUsage(ctx, report, pod)
+				usageRead = true
+			}
+			issue.Type = IssueOOMKilled
+			issue.Severity = SeverityHigh
+			issue.Description = describeOOMKill(pod, cs.Name, usage[cs.Name], issue.Details)
+		case terminated.ExitCode == 139 || terminated.ExitCode == 134:
+			issue.Severity = SeverityHigh
+		}
+
+		// The CrashLoopBackOff issue already carries the previous instance's logs
+		if cs.State.Waiting == nil || cs.State.Waiting.Reason != "CrashLoopBackOff" {
+			if logs := e.containerLogs(ctx, report, pod.Namespace, pod.Name, cs.Name, !current); logs != "" {
+				issue.Details["logs"] = logs
+			}
+		}
+
+		report.Issues = append(report.Issues, issue)
+	}
+}
+
+// exitCodeMeaning explains a container exit code; codes above 128 mean the
+// process was killed by signal code-128
+func exitCodeMeaning(terminated *corev1.ContainerStateTerminated) string {
+	switch code := terminated.ExitCode; {
+	case code == 137 && terminated.Reason == "OOMKilled":
+		return "killed by the kernel for exceeding its memory limit (SIGKILL)"
+	case code == 137:
+		return "killed with SIGKILL; it did not stop within the grace period after SIGTERM (e.g. a failed liveness probe), or was OOM killed outside its cgroup"
+	case code == 139:
+		return "segmentation fault (SIGSEGV); the process accessed invalid memory, often a native library or architecture mismatch"
+	case code == 143:
+		return "stopped with SIGTERM by the kubelet, e.g. after a failed liveness probe, an eviction or a rollout"
+	case code == 134:
+		return "aborted (SIGABRT), e.g. a failed assertion or runtime panic"
+	case code == 1:
+		return "application error; the process exited on its own, see its logs"
+	case code == 126:
+		return "the command is not executable (permissions or binary format)"
+	case code == 127:
+		return "the command was not found; check the image's entrypoint and the container command"
+	case code > 128:
+		return fmt.Sprintf("killed by signal %d", code-128)
+	case code == 0:
+		return "completed"
+	default:
+		return "application error"
+	}
+}
+
+// describeOOMKill compares an OOMKilled container's memory limit with its
+// current usage and records a raised limit in details
+func describeOOMKill(pod *corev1.Pod, container string, usage corev1.ResourceList, details map[string]interface{}) string {
+	var limit resource.Quantity
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			limit = c.Resources.Limits[corev1.ResourceMemory]
+		}
+	}
+
+	used, measured := usage[corev1.ResourceMemory]
+	if limit.IsZero() {
+		description := fmt.Sprintf("Container %s was OOMKilled without a memory limit; the node ran out of memory", container)
+		if measured {
+			description += fmt.Sprintf(" (current usage %s)", used.String())
+		}
+		return description
+	}
+
+	raised := int64(float64(limit.Value()) * limitHeadroom)
+	if measured && used.Value() > raised {
+		raised = int64(float64(used.Value()) * limitHeadroom)
+	}
+	raised = (raised + memoryStep - 1) / memoryStep * memoryStep
+	details["memoryLimit"] = limit.String()
+	details["suggestedLimit"] = fmt.Sprintf("%dMi", raised/(1024*1024))
+
+	description := fmt.Sprintf("Container %s was OOMKilled (exit 137): it exceeded its memory limit of %s", container, limit.String())
+	if measured {
+		percent := used.Value() * 100 / limit.Value()
+		details["memoryUsage"] = used.String()
+		description += fmt.Sprintf("; current usage is %s (%d%% of the limit)", used.String(), percent)
+	}
+	return description
+}
+
+// containerUsage reads the pod's usage from metrics-server. It is optional
+// evidence, so failures are recorded as a report warning.
+func (e *Engine) containerUsage(ctx context.Context, report *Report, pod *corev1.Pod) map[string]corev1.ResourceList {
+	usage, err := e.k8sClient.GetContainerUsage(ctx, pod.Name, pod.Namespace)
+	if err != nil {
+		if k8s.IsForbidden(err) {
+			report.AddWarning(fmt.Sprintf("Memory usage not compared: you are not permitted to read pod metrics in namespace %s", pod.Namespace))
+		} else {
+			report.AddWarning("Memory usage not compared: pod metrics are unavailable (is metrics-server installed?)")
+		}
+		return nil
+	}
+	return usage
+}
+
+// terminationRemediations suggests commands for terminated containers
+func terminationRemediations(issues []Issue, namespace string) []Remediation {
+	var remediations []Remediation
+	for _, issue := range issues {
+		if issue.Type != IssueOOMKilled && issue.Type != IssueContainerTerminated {
+			continue
+		}
+		pod, _ := issue.Details["pod"].(string)
+		container, _ := issue.Details["container"].(string)
+		workload, _ := issue.Details["workload"].(string)
+
+		remediations = append(remediations, Remediation{
+			Title:       "Read the crashed container's logs",
+			Description: "The previous instance's output shows why it exited",
+			Command:     fmt.Sprintf("kubectl logs %s -n %s -c %s --previous", pod, namespace, container),
+			Confidence:  "High",
+			Safe:        true,
+		})
+
+		if limit, ok := issue.Details["suggestedLimit"].(string); ok && workload != "" {
+			remediations = append(remediations, Remediation{
+				Title:       fmt.Sprintf("Raise the memory limit of container %s to %s", container, limit),
+				Description: "Give the container headroom above its peak usage, or fix the memory growth",
+				Command:     fmt.Sprintf("kubectl set resources %s -c %s --limits=memory=%s", workload, container, limit),
+				Confidence:  "Medium",
+				Safe:        false,
+			})
+		}
+		if code, _ := issue.Details["exitCode"].(int32); issue.Type == IssueContainerTerminated && (code == 137 || code == 143) {
+			remediations = append(remediations, Remediation{
+				Title:       "Check the liveness probe",
+				Description: "A failing liveness probe makes the kubelet restart the container",
+				Command:     fmt.Sprintf(`kubectl get pod %s -n %s -o jsonpath='{.spec.containers[?(@.name=="%s")].livenessProbe}'`, pod, namespace, container),
+				Confidence:  "Medium",
+				Safe:        true,
+			})
+		}
+	}
+	return remediations
+}
@@ -0,0 +1,83 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func TestDiagnosePodTerminations(t *testing.T) {
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	client := k8stest.NewClient(t, "payments", "testdata/oomkilled.yaml")
+
+	// metrics-server reports the ledger container close to its limit
+	metrics := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{k8s.PodMetricsResource: "PodMetricsList"})
+	usage := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetrics",
+		"metadata":   map[string]interface{}{"name": "ledger-84c9d-m2xkq", "namespace": "payments"},
+		"containers": []interface{}{
+			map[string]interface{}{"name": "ledger", "usage": map[string]interface{}{"cpu": "120m", "memory": "240Mi"}},
+		},
+	}}
+	if _, err := metrics.Resource(k8s.PodMetricsResource).Namespace("payments").Create(context.Background(), usage, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	client.SetDynamicClient(metrics)
+
+	engine := NewEngineWithClient(client, provider, "payments", false)
+	report, err := engine.DiagnoseResource("pod", "ledger-84c9d-m2xkq")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueOOMKilled, "ledger-84c9d-m2xkq/ledger", SeverityHigh,
+		"it exceeded its memory limit of 256Mi; current usage is 240Mi (93% of the limit)") {
+		t.Errorf("expected an OOM kill compared with usage, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueContainerTerminated, "ledger-84c9d-m2xkq/indexer", SeverityHigh, "exit code 139 (Error): segmentation fault") {
+		t.Errorf("expected a segfault, got %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if issue.Type == IssueOOMKilled && issue.Details["logs"] != "fake logs" {
+			t.Errorf("expected the previous container's logs, got %v", issue.Details["logs"])
+		}
+	}
+
+	for _, command := range []string{
+		"kubectl set resources deployment/ledger -n payments -c ledger --limits=memory=384Mi",
+		"kubectl logs ledger-84c9d-m2xkq -n payments -c indexer --previous",
+	} {
+		if !hasCommand(report, command) {
+			t.Errorf("expected remediation %q, got %+v", command, report.Remediations)
+		}
+	}
+}
+
+func TestDiagnosePodOOMKilledWithoutMetrics(t *testing.T) {
+	engine := newTestEngine(t, "payments", "testdata/oomkilled.yaml")
+
+	report, err := engine.DiagnoseResource("pod", "ledger-84c9d-m2xkq")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	if !hasIssue(report, IssueOOMKilled, "ledger-84c9d-m2xkq/ledger", SeverityHigh, "it exceeded its memory limit of 256Mi") {
+		t.Errorf("expected an OOM kill, got %+v", report.Issues)
+	}
+	if len(report.Warnings) != 1 {
+		t.Errorf("expected a warning that usage was not compared, got %v", report.Warnings)
+	}
+}
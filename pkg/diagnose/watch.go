@@ -0,0 +1,309 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// watchSettle is how long the watch waits for a burst of changes to end
+// before diagnosing again
+const watchSettle = 500 * time.Millisecond
+
+// KindIssueChange is the document kind of an issue change
+const KindIssueChange = "IssueChange"
+
+// ChangeKind is how an issue changed between two diagnoses
+type ChangeKind string
+
+// Issue changes
+const (
+	ChangeNew       ChangeKind = "new"
+	ChangeEscalated ChangeKind = "escalated"
+	ChangeResolved  ChangeKind = "resolved"
+)
+
+// IssueChange is an issue that appeared, escalated or was resolved
+type IssueChange struct {
+	Kind  ChangeKind
+	Issue Issue
+	// Previous is the severity the issue had before it escalated
+	Previous Severity
+	Time     time.Time
+}
+
+// IssueTracker compares successive reports. A change is only reported once
+// it has persisted for the debounce period, so a flapping resource does not
+// flood the output; lowered severities are tracked without being reported.
+type IssueTracker struct {
+	debounce time.Duration
+	started  bool
+	issues   map[string]*trackedIssue
+}
+
+// trackedIssue is the last reported and the last observed state of an issue
+type trackedIssue struct {
+	reported *Issue
+	observed *Issue
+	// since is when the observed state started to differ from the reported one
+	since time.Time
+}
+
+// NewIssueTracker creates a tracker that reports changes after debounce
+func NewIssueTracker(debounce time.Duration) *IssueTracker {
+	return &IssueTracker{debounce: debounce, issues: map[string]*trackedIssue{}}
+}
+
+// Observe records the issues of a report and returns the changes that are
+// due. The issues of the first report are all new and reported at once.
+func (t *IssueTracker) Observe(report *Report, now time.Time) []IssueChange {
+	current := map[string]*Issue{}
+	for i := range report.Issues {
+		issue := &report.Issues[i]
+		key := issueKey(*issue)
+		if existing, ok := current[key]; !ok || severityWeight(issue.Severity) > severityWeight(existing.Severity) {
+			current[key] = issue
+		}
+	}
+
+	if !t.started {
+		t.started = true
+		var changes []IssueChange
+		for _, key := range sortedKeys(current) {
+			issue := current[key]
+			t.issues[key] = &trackedIssue{reported: issue, observed: issue}
+			changes = append(changes, IssueChange{Kind: ChangeNew, Issue: *issue, Time: now})
+		}
+		return changes
+	}
+
+	for key, tracked := range t.issues {
+		if _, ok := current[key]; !ok {
+			tracked.observe(nil, now)
+		}
+	}
+	for key, issue := range current {
+		tracked, ok := t.issues[key]
+		if !ok {
+			tracked = &trackedIssue{}
+			t.issues[key] = tracked
+		}
+		tracked.observe(issue, now)
+	}
+	return t.Flush(now)
+}
+
+// Flush returns the pending changes that have persisted for the debounce
+// period, without a new report
+func (t *IssueTracker) Flush(now time.Time) []IssueChange {
+	var changes []IssueChange
+	for _, key := range sortedKeys(t.issues) {
+		tracked := t.issues[key]
+		if !tracked.pending() || now.Sub(tracked.since) < t.debounce {
+			continue
+		}
+		if change, ok := tracked.change(now); ok {
+			changes = append(changes, change)
+		}
+		tracked.reported = tracked.observed
+		if tracked.reported == nil {
+			delete(t.issues, key)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return severityWeight(changes[i].Issue.Severity) > severityWeight(changes[j].Issue.Severity)
+	})
+	return changes
+}
+
+// Pending reports whether changes are waiting out the debounce period
+func (t *IssueTracker) Pending() bool {
+	for _, tracked := range t.issues {
+		if tracked.pending() {
+			return true
+		}
+	}
+	return false
+}
+
+// observe records the issue's current state, nil when it is gone
+func (t *trackedIssue) observe(issue *Issue, now time.Time) {
+	wasPending := t.pending()
+	changed := !sameState(t.observed, issue)
+	t.observed = issue
+	if t.pending() && (!wasPending || changed) {
+		t.since = now
+	}
+}
+
+// pending reports whether the observed state differs from the reported one
+func (t *trackedIssue) pending() bool {
+	return !sameState(t.reported, t.observed)
+}
+
+// change describes the transition from the reported to the observed state;
+// a lowered severity is not a change worth reporting
+func (t *trackedIssue) change(now time.Time) (IssueChange, bool) {
+	switch {
+	case t.reported == nil:
+		return IssueChange{Kind: ChangeNew, Issue: *t.observed, Time: now}, true
+	case t.observed == nil:
+		return IssueChange{Kind: ChangeResolved, Issue: *t.reported, Time: now}, true
+	case severityWeight(t.observed.Severity) > severityWeight(t.reported.Severity):
+		return IssueChange{Kind: ChangeEscalated, Issue: *t.observed, Previous: t.reported.Severity, Time: now}, true
+	}
+	return IssueChange{}, false
+}
+
+// sameState reports whether two observations are the same: both absent, or
+// both present with the same severity
+func sameState(a, b *Issue) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Severity == b.Severity
+}
+
+// issueKey identifies an issue across reports
+func issueKey(issue Issue) string {
+	namespace, _ := issue.Details["namespace"].(string)
+	return fmt.Sprintf("%s|%s|%s", namespace, issue.Type, issue.Resource)
+}
+
+// WatchOptions configures Engine.Watch
+type WatchOptions struct {
+	// Diagnose produces a report from the engine's current snapshot
+	Diagnose func() (*Report, error)
+	// Debounce is how long a change must persist before it is reported
+	Debounce time.Duration
+	// OnChanges receives the changes each diagnosis found
+	OnChanges func([]IssueChange)
+	// OnError receives the errors of diagnoses that failed; the watch
+	// carries on
+	OnError func(error)
+}
+
+// Watch keeps informers open and diagnoses again whenever pods, events or
+// nodes change, until ctx is cancelled. Only new, escalated and resolved
+// issues are passed on.
+func (e *Engine) Watch(ctx context.Context, opts WatchOptions) error {
+	namespace := e.namespace
+	if e.allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	watcher := e.k8sClient.NewSnapshotWatcher(namespace, 0)
+
+	changed := make(chan struct{}, 1)
+	err := watcher.OnChange(func(resource string) {
+		switch resource {
+		case k8s.ResourcePods, k8s.ResourceEvents, k8s.ResourceNodes:
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := watcher.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start watching: %w", err)
+	}
+
+	tracker := NewIssueTracker(opts.Debounce)
+	diagnose := func() {
+		snapshot, err := watcher.Snapshot(ctx)
+		if err != nil {
+			opts.OnError(err)
+			return
+		}
+		e.SetSnapshot(snapshot)
+		report, err := opts.Diagnose()
+		if err != nil {
+			opts.OnError(err)
+			return
+		}
+		if changes := tracker.Observe(report, time.Now()); len(changes) > 0 {
+			opts.OnChanges(changes)
+		}
+	}
+	diagnose()
+
+	// Pending changes are checked again even when nothing else happens, so
+	// they are reported as soon as they have persisted long enough
+	interval := opts.Debounce / 2
+	if interval < watchSettle {
+		interval = watchSettle
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+			if settle == nil {
+				settle = time.After(watchSettle)
+			}
+		case <-settle:
+			settle = nil
+			diagnose()
+		case <-ticker.C:
+			if settle == nil && tracker.Pending() {
+				diagnose()
+			}
+		}
+	}
+}
+
+// Display prints the change as a timestamped line
+func (c IssueChange) Display() {
+	c.display(output.Stdout)
+}
+
+// display writes the change as a timestamped line
+func (c IssueChange) display(w io.Writer) {
+	stamp := c.Time.Format("15:04:05")
+	switch c.Kind {
+	case ChangeNew:
+		fmt.Fprintf(w, "[%s] 🆕 NEW       %s [%s] %s %s: %s\n", stamp, severityEmoji(c.Issue.Severity),
+			c.Issue.Severity, c.Issue.Type, c.Issue.Resource, c.Issue.Description)
+	case ChangeEscalated:
+		fmt.Fprintf(w, "[%s] 🔺 ESCALATED %s [%s → %s] %s %s: %s\n", stamp, severityEmoji(c.Issue.Severity),
+			c.Previous, c.Issue.Severity, c.Issue.Type, c.Issue.Resource, c.Issue.Description)
+	case ChangeResolved:
+		fmt.Fprintf(w, "[%s] ✅ RESOLVED  [%s] %s %s\n", stamp, c.Issue.Severity, c.Issue.Type, c.Issue.Resource)
+	}
+}
+
+// IssueChangeDocument is the versioned machine-readable form of an
+// IssueChange
+type IssueChangeDocument struct {
+	APIVersion       string        `json:"api_version"`
+	Kind             string        `json:"kind"`
+	Change           ChangeKind    `json:"change"`
+	Time             time.Time     `json:"time"`
+	PreviousSeverity Severity      `json:"previous_severity,omitempty"`
+	Issue            IssueDocument `json:"issue"`
+}
+
+// Document returns the change's machine-readable form
+func (c IssueChange) Document() *IssueChangeDocument {
+	return &IssueChangeDocument{
+		APIVersion:       output.APIVersion,
+		Kind:             KindIssueChange,
+		Change:           c.Kind,
+		Time:             c.Time,
+		PreviousSeverity: c.Previous,
+		Issue:            issueDocuments([]Issue{c.Issue})[0],
+	}
+}
@@ -0,0 +1,147 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/k8s/k8stest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func trackedReport(issues ...Issue) *Report {
+	return &Report{Issues: issues}
+}
+
+func TestIssueTrackerReportsChangesAfterDebounce(t *testing.T) {
+	tracker := NewIssueTracker(10 * time.Second)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	crash := Issue{Type: IssueCrashLoopBackOff, Severity: SeverityHigh, Resource: "api-0"}
+	pending := Issue{Type: IssuePVCPending, Severity: SeverityMedium, Resource: "data-worker-0"}
+
+	changes := tracker.Observe(trackedReport(crash), start)
+	if len(changes) != 1 || changes[0].Kind != ChangeNew {
+		t.Fatalf("baseline changes = %+v, want the existing issue as new", changes)
+	}
+
+	if changes := tracker.Observe(trackedReport(crash, pending), start.Add(time.Second)); len(changes) != 0 {
+		t.Errorf("changes before debounce = %+v, want none", changes)
+	}
+	if !tracker.Pending() {
+		t.Error("Pending() = false, want the new issue waiting out the debounce")
+	}
+	changes = tracker.Flush(start.Add(11 * time.Second))
+	if len(changes) != 1 || changes[0].Kind != ChangeNew || changes[0].Issue.Resource != "data-worker-0" {
+		t.Fatalf("changes after debounce = %+v, want data-worker-0 new", changes)
+	}
+
+	critical := crash
+	critical.Severity = SeverityCritical
+	tracker.Observe(trackedReport(critical, pending), start.Add(20*time.Second))
+	changes = tracker.Flush(start.Add(30 * time.Second))
+	if len(changes) != 1 || changes[0].Kind != ChangeEscalated || changes[0].Previous != SeverityHigh {
+		t.Fatalf("changes = %+v, want api-0 escalated from high", changes)
+	}
+
+	tracker.Observe(trackedReport(crash), start.Add(40*time.Second))
+	changes = tracker.Flush(start.Add(50 * time.Second))
+	if len(changes) != 1 || changes[0].Kind != ChangeResolved || changes[0].Issue.Resource != "data-worker-0" {
+		t.Fatalf("changes = %+v, want only data-worker-0 resolved; lowered severity is silent", changes)
+	}
+	if tracker.Pending() {
+		t.Error("Pending() = true after every change was flushed")
+	}
+}
+
+func TestIssueTrackerIgnoresFlapping(t *testing.T) {
+	tracker := NewIssueTracker(10 * time.Second)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	probe := Issue{Type: IssueProbeFailure, Severity: SeverityMedium, Resource: "api-0"}
+
+	tracker.Observe(trackedReport(), start)
+	for i := 1; i <= 6; i++ {
+		report := trackedReport()
+		if i%2 == 1 {
+			report = trackedReport(probe)
+		}
+		if changes := tracker.Observe(report, start.Add(time.Duration(i)*4*time.Second)); len(changes) != 0 {
+			t.Fatalf("flapping issue reported at step %d: %+v", i, changes)
+		}
+	}
+	if changes := tracker.Flush(start.Add(60 * time.Second)); len(changes) != 0 {
+		t.Errorf("issue that flapped back to healthy reported: %+v", changes)
+	}
+}
+
+func TestIssueChangeDisplay(t *testing.T) {
+	change := IssueChange{
+		Kind:     ChangeEscalated,
+		Issue:    Issue{Type: IssueCrashLoopBackOff, Severity: SeverityCritical, Resource: "api-0", Description: "restarting"},
+		Previous: SeverityHigh,
+		Time:     time.Date(2024, 5, 1, 15, 4, 5, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	change.display(&buf)
+	if line := buf.String(); !strings.HasPrefix(line, "[15:04:05]") || !strings.Contains(line, "ESCALATED") || !strings.Contains(line, "high → critical") {
+		t.Errorf("display = %q", line)
+	}
+	if doc := change.Document(); doc.Kind != KindIssueChange || doc.Change != ChangeEscalated || doc.PreviousSeverity != SeverityHigh {
+		t.Errorf("Document() = %+v", doc)
+	}
+}
+
+func TestEngineWatch(t *testing.T) {
+	clientset := k8stest.NewClientset(t, "testdata/crashloop.yaml")
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	engine := NewEngineWithClient(k8s.NewClientFromInterface(clientset, "payments"), provider, "payments", false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan IssueChange, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.Watch(ctx, WatchOptions{
+			Diagnose: engine.DiagnoseCluster,
+			OnChanges: func(changes []IssueChange) {
+				for _, change := range changes {
+					received <- change
+				}
+			},
+			OnError: func(err error) { t.Errorf("diagnosis failed: %v", err) },
+		})
+	}()
+
+	waitFor := func(kind ChangeKind) {
+		t.Helper()
+		for {
+			select {
+			case change := <-received:
+				if change.Kind == kind && change.Issue.Type == IssueCrashLoopBackOff {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatalf("no %s CrashLoopBackOff change", kind)
+			}
+		}
+	}
+	waitFor(ChangeNew)
+
+	if err := clientset.CoreV1().Pods("payments").Delete(ctx, "api-7d9f8b-x2k4p", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	waitFor(ChangeResolved)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch: %v", err)
+	}
+}
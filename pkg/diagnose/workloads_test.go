@@ -0,0 +1,209 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// hasIssue reports whether a report contains a matching issue
+func hasIssue(report *Report, issueType IssueType, resource string, severity Severity, text string) bool {
+	for _, issue := range report.Issues {
+		if issue.Type == issueType && issue.Resource == resource && issue.Severity == severity &&
+			strings.Contains(issue.Description, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCommand reports whether a report suggests a remediation command
+func hasCommand(report *Report, command string) bool {
+	for _, remediation := range report.Remediations {
+		if remediation.Command == command {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDiagnoseStatefulSetStalledOrdinal(t *testing.T) {
+	engine := newTestEngine(t, "data", "testdata/statefulset-stalled.yaml")
+
+	report, err := engine.DiagnoseResource("sts", "db")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueOrdinalStalled, "statefulset/db", SeverityHigh, "Ordinal 1 (db-1) is not ready (phase Pending); OrderedReady blocks the 1 ordinal(s)") {
+		t.Errorf("expected ordinal 1 to stall the set, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssuePVCPending, "statefulset/db", SeverityHigh, "Ordinal 1 claim data-db-1 is Pending") {
+		t.Errorf("expected the pending claim of ordinal 1, got %+v", report.Issues)
+	}
+	if hasIssue(report, IssuePVCPending, "statefulset/db", SeverityHigh, "data-db-2") {
+		t.Errorf("ordinal 2 has no pod yet, so its missing claim should not be reported: %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueRolloutStuck, "statefulset/db", SeverityMedium, "updated 1 of 3") {
+		t.Errorf("expected the partial rolling update, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueType("Pending"), "db-1", SeverityHigh, "") {
+		t.Errorf("expected the pending pod to be rolled up, got %+v", report.Issues)
+	}
+	if !hasCommand(report, "kubectl describe pvc data-db-1 -n data") {
+		t.Errorf("expected a describe pvc remediation, got %+v", report.Remediations)
+	}
+}
+
+func TestDiagnoseDaemonSetTaints(t *testing.T) {
+	engine := newTestEngine(t, "monitoring", "testdata/daemonset-tainted.yaml")
+
+	report, err := engine.DiagnoseResource("ds", "log-agent")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueDaemonSetMisscheduled, "daemonset/log-agent", SeverityMedium, "1 pod(s)") {
+		t.Errorf("expected a misscheduled pod, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueDaemonSetUnavailable, "daemonset/log-agent", SeverityMedium, "1 of 3") {
+		t.Errorf("expected an unavailable daemon pod, got %+v", report.Issues)
+	}
+
+	var taints Issue
+	for _, issue := range report.Issues {
+		if issue.Type == IssueTaintNotTolerated {
+			taints = issue
+		}
+	}
+	// The control plane taint is tolerated, PreferNoSchedule doesn't block
+	// and the windows node is excluded by the node selector
+	nodes, _ := taints.Details["nodes"].([]string)
+	if len(nodes) != 1 || nodes[0] != "gpu-1 (nvidia.com/gpu=present:NoSchedule)" {
+		t.Errorf("untolerated nodes = %v", nodes)
+	}
+
+	patch := `kubectl patch daemonset/log-agent -n monitoring --type=json -p '[{"op":"add","path":"/spec/template/spec/tolerations/-","value":{"key":"nvidia.com/gpu","operator":"Exists","effect":"NoSchedule"}}]'`
+	if !hasCommand(report, patch) {
+		t.Errorf("expected a toleration patch, got %+v", report.Remediations)
+	}
+}
+
+func TestDiagnoseDaemonSetSkippingTaintedNodes(t *testing.T) {
+	engine := newTestEngine(t, "monitoring", "testdata/daemonset-healthy.yaml")
+
+	report, err := engine.DiagnoseResource("ds", "node-exporter")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	// The daemon deliberately skips the control plane and, with OnDelete,
+	// keeps old pods until they are deleted; neither is a problem
+	if len(report.Issues) != 0 {
+		t.Errorf("expected no issues for a healthy daemonset, got %+v", report.Issues)
+	}
+}
+
+func TestDiagnoseJobBackoffLimit(t *testing.T) {
+	engine := newTestEngine(t, "batch", "testdata/cronjob-failing.yaml")
+
+	report, err := engine.DiagnoseResource("job", "report-29300940")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+
+	if !hasIssue(report, IssueJobFailed, "job/report-29300940", SeverityCritical, "BackoffLimitExceeded) after 3 failed pod(s) with backoffLimit 2") {
+		t.Errorf("expected a critical backoff limit issue, got %+v", report.Issues)
+	}
+	if !hasIssue(report, IssueType("Failed"), "report-29300940-x7k2p", SeverityHigh, "") {
+		t.Errorf("expected the failed pod to be rolled up, got %+v", report.Issues)
+	}
+	if !hasCommand(report, "kubectl create job report-rerun --from=cronjob/report -n batch") {
+		t.Errorf("expected a re-run from the cronjob, got %+v", report.Remediations)
+	}
+}
+
+func TestDiagnoseCronJob(t *testing.T) {
+	defer func(saved func() time.Time) { now = saved }(now)
+	now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+
+	engine := newTestEngine(t, "batch", "testdata/cronjob-failing.yaml")
+
+	tests := []struct {
+		name      string
+		issueType IssueType
+		resource  string
+		severity  Severity
+		text      string
+		command   string
+	}{
+		{
+			name:      "report",
+			issueType: IssueMissedSchedule,
+			resource:  "cronjob/report",
+			severity:  SeverityHigh,
+			text:      "2 scheduled run(s) did not start since 2026-10-18T09:00:00Z; runs more than 300s late are skipped",
+			command:   "kubectl create job report-manual --from=cronjob/report -n batch",
+		},
+		{
+			name:      "report",
+			issueType: IssueJobFailed,
+			resource:  "job/report-29300940",
+			severity:  SeverityCritical,
+			text:      "BackoffLimitExceeded",
+			command:   "kubectl logs job/report-29300940 -n batch --all-containers",
+		},
+		{
+			name:      "sync",
+			issueType: IssueConcurrencyPileup,
+			resource:  "cronjob/sync",
+			severity:  SeverityMedium,
+			text:      "3 runs are active",
+			command:   `kubectl patch cronjob/sync -n batch -p '{"spec":{"concurrencyPolicy":"Forbid"}}'`,
+		},
+		{
+			name:      "cleanup",
+			issueType: IssueSuspended,
+			resource:  "cronjob/cleanup",
+			severity:  SeverityMedium,
+			text:      "suspended",
+			command:   `kubectl patch cronjob/cleanup -n batch -p '{"spec":{"suspend":false}}'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+string(tt.issueType), func(t *testing.T) {
+			report, err := engine.DiagnoseResource("cj", tt.name)
+			if err != nil {
+				t.Fatalf("DiagnoseResource: %v", err)
+			}
+			if !hasIssue(report, tt.issueType, tt.resource, tt.severity, tt.text) {
+				t.Errorf("expected %s issue %q, got %+v", tt.issueType, tt.text, report.Issues)
+			}
+			if !hasCommand(report, tt.command) {
+				t.Errorf("expected remediation %q, got %+v", tt.command, report.Remediations)
+			}
+		})
+	}
+
+	// Runs on schedule aren't reported as missed
+	report, err := engine.DiagnoseResource("cronjob", "sync")
+	if err != nil {
+		t.Fatalf("DiagnoseResource: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if issue.Type == IssueMissedSchedule {
+			t.Errorf("unexpected missed schedule: %s", issue.Description)
+		}
+	}
+}
+
+func TestDiagnoseWorkloadNotFound(t *testing.T) {
+	engine := newTestEngine(t, "batch", "testdata/cronjob-failing.yaml")
+
+	for _, kind := range []string{"statefulset", "daemonset", "job", "cronjob"} {
+		if _, err := engine.DiagnoseResource(kind, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("%s: expected a not found error, got %v", kind, err)
+		}
+	}
+}
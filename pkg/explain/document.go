@@ -0,0 +1,48 @@
+package explain
+
+import (
+	"fmt"
+	"io"
+
+	"k8s-pilot/pkg/output"
+)
+
+// KindExplanation is the document kind of an explanation
+const KindExplanation = "Explanation"
+
+// ExplanationDocument is the versioned machine-readable form of an
+// Explanation
+type ExplanationDocument struct {
+	APIVersion      string   `json:"api_version"`
+	Kind            string   `json:"kind"`
+	Query           string   `json:"query"`
+	Answer          string   `json:"answer"`
+	RelatedCommands []string `json:"related_commands,omitempty"`
+	Tip             string   `json:"tip,omitempty"`
+}
+
+// Document returns the explanation's machine-readable form
+func (ex *Explanation) Document() *ExplanationDocument {
+	return &ExplanationDocument{
+		APIVersion:      output.APIVersion,
+		Kind:            KindExplanation,
+		Query:           ex.Query,
+		Answer:          ex.Answer,
+		RelatedCommands: ex.RelatedCommands,
+		Tip:             ex.Tip,
+	}
+}
+
+// Markdown writes the explanation as markdown
+func (ex *Explanation) Markdown(w io.Writer) {
+	fmt.Fprintf(w, "# Explanation\n\n**Query:** %s\n\n%s\n", ex.Query, ex.Answer)
+	if len(ex.RelatedCommands) > 0 {
+		fmt.Fprint(w, "\n## Related kubectl commands\n\n")
+		for _, cmd := range ex.RelatedCommands {
+			fmt.Fprintf(w, "- `%s`\n", cmd)
+		}
+	}
+	if ex.Tip != "" {
+		fmt.Fprintf(w, "\n**Tip:** %s\n", ex.Tip)
+	}
+}
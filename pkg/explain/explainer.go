@@ -5,26 +5,41 @@ import (
 	"fmt"
 	"strings"
 
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
 	"k8s-pilot/pkg/ai"
 	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
 )
 
 // Explainer provides AI-powered explanations
 type Explainer struct {
 	aiProvider ai.Provider
 	k8sClient  *k8s.Client
+	clientErr  error
 	namespace  string
 }
 
-// NewExplainer creates a new explainer
+// NewExplainer creates a new explainer. Concept explanations work without a
+// cluster, so a client error is only reported when cluster data is needed.
 func NewExplainer(namespace string) *Explainer {
-	k8sClient, _ := k8s.NewClient(namespace)
-	
+	k8sClient, clientErr := k8s.NewClient(namespace)
+
 	aiConfig := &ai.Config{
 		Provider: ai.ProviderMock,
 	}
 	aiProvider, _ := ai.NewProvider(aiConfig)
-	
+
+	explainer := NewExplainerWithClient(k8sClient, aiProvider, namespace)
+	explainer.clientErr = clientErr
+	return explainer
+}
+
+// NewExplainerWithClient creates an explainer on an existing client and AI
+// provider
+func NewExplainerWithClient(k8sClient *k8s.Client, aiProvider ai.Provider, namespace string) *Explainer {
 	return &Explainer{
 		aiProvider: aiProvider,
 		k8sClient:  k8sClient,
@@ -32,6 +47,17 @@ func NewExplainer(namespace string) *Explainer {
 	}
 }
 
+// client returns the Kubernetes client or the error that prevented creating it
+func (e *Explainer) client() (*k8s.Client, error) {
+	if e.clientErr != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", e.clientErr)
+	}
+	if e.k8sClient == nil {
+		return nil, fmt.Errorf("no Kubernetes client configured")
+	}
+	return e.k8sClient, nil
+}
+
 // Explanation represents an AI-generated explanation
 type Explanation struct {
 	Query           string
@@ -54,6 +80,8 @@ func (e *Explainer) Explain(query string) (*Explanation, error) {
 		content, err = e.explainLogs(ctx, query)
 	} else if strings.Contains(queryLower, "events") {
 		content, err = e.explainEvents(ctx, query)
+	} else if mapping, obj := e.findObject(ctx, query); obj != nil {
+		content, err = e.explainObject(ctx, query, mapping, obj)
 	} else if strings.Contains(queryLower, "pod") || strings.Contains(queryLower, "deployment") {
 		content, err = e.explainResource(ctx, query)
 	} else {
@@ -90,8 +118,13 @@ func (e *Explainer) explainLogs(ctx context.Context, query string) (string, erro
 		return "Please specify a pod name. Example: kubectl-pilot explain logs mypod", nil
 	}
 	
+	client, err := e.client()
+	if err != nil {
+		return "", err
+	}
+
 	// Get the actual logs
-	logs, err := e.k8sClient.GetPodLogs(ctx, podName, "", e.namespace, 50)
+	logs, err := client.GetPodLogs(ctx, podName, e.namespace, k8s.LogOptions{TailLines: 50})
 	if err != nil {
 		return fmt.Sprintf("Could not retrieve logs: %v", err), nil
 	}
@@ -118,7 +151,12 @@ Provide:
 
 // explainEvents explains Kubernetes events
 func (e *Explainer) explainEvents(ctx context.Context, query string) (string, error) {
-	events, err := e.k8sClient.GetEvents(ctx, e.namespace)
+	client, err := e.client()
+	if err != nil {
+		return "", err
+	}
+
+	events, err := client.GetEvents(ctx, e.namespace)
 	if err != nil {
 		return "", fmt.Errorf("failed to get events: %w", err)
 	}
@@ -147,6 +185,55 @@ Provide a summary of cluster activity and any issues that need attention.`, even
 	return response.Content, nil
 }
 
+// findObject looks for a "<resource> <name>" pair in the query naming an
+// object that exists in the cluster, for any kind including custom resources
+func (e *Explainer) findObject(ctx context.Context, query string) (*meta.RESTMapping, *unstructured.Unstructured) {
+	client, err := e.client()
+	if err != nil {
+		return nil, nil
+	}
+	
+	words := strings.Fields(query)
+	for i := 0; i+1 < len(words); i++ {
+		mapping, err := client.ResolveResource(words[i])
+		if err != nil {
+			continue
+		}
+		obj, err := client.GetObject(ctx, mapping, strings.Trim(words[i+1], "?.,"), e.namespace)
+		if err == nil {
+			return mapping, obj
+		}
+	}
+	return nil, nil
+}
+
+// explainObject explains the state of a specific object in the cluster
+func (e *Explainer) explainObject(ctx context.Context, query string, mapping *meta.RESTMapping, obj *unstructured.Unstructured) (string, error) {
+	status, err := yaml.Marshal(obj.Object["status"])
+	if err != nil {
+		return "", fmt.Errorf("failed to encode status: %w", err)
+	}
+	
+	prompt := fmt.Sprintf(`User asked: "%s"
+
+Explain the current state of this Kubernetes %s (%s):
+
+Name: %s
+Namespace: %s
+Status:
+%s
+Describe what the resource does, whether it is healthy, what any failing
+conditions mean and which kubectl commands would help investigate.`,
+		query, mapping.GroupVersionKind.Kind, mapping.Resource.GroupResource(), obj.GetName(), obj.GetNamespace(), status)
+	
+	response, err := e.aiProvider.Generate(ctx, prompt, ai.DefaultOptions())
+	if err != nil {
+		return "", err
+	}
+	
+	return response.Content, nil
+}
+
 // explainResource explains a specific resource
 func (e *Explainer) explainResource(ctx context.Context, query string) (string, error) {
 	prompt := fmt.Sprintf(`User asked: "%s"
@@ -219,6 +306,6 @@ func (e *Explainer) generateTip(query string) string {
 
 // Display displays the explanation
 func (ex *Explanation) Display() {
-	fmt.Printf("\nQuery: %s\n\n", ex.Query)
-	fmt.Println(ex.Answer)
+	fmt.Fprintf(output.Stdout, "\nQuery: %s\n\n", ex.Query)
+	fmt.Fprintln(output.Stdout, ex.Answer)
 }
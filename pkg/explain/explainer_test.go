@@ -0,0 +1,83 @@
+package explain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func newTestExplainer(t *testing.T, objects ...runtime.Object) *Explainer {
+	t.Helper()
+
+	provider, err := ai.NewMockProvider(&ai.Config{Provider: ai.ProviderMock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	return NewExplainerWithClient(k8stest.NewClientFromObjects("payments", objects...), provider, "payments")
+}
+
+func TestExplainEvents(t *testing.T) {
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "api.1", Namespace: "payments"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api"},
+		Type:           "Warning",
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+	}
+	explainer := newTestExplainer(t, event)
+
+	explanation, err := explainer.Explain("events in payments")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if explanation.Answer == "" {
+		t.Error("expected an answer")
+	}
+	if explanation.Tip == "" {
+		t.Error("expected a tip")
+	}
+}
+
+func TestExplainWithoutClient(t *testing.T) {
+	explainer := newTestExplainer(t)
+	explainer.k8sClient = nil
+	explainer.clientErr = errors.New("no kubeconfig")
+
+	if _, err := explainer.Explain("events in payments"); err == nil {
+		t.Error("expected an error explaining events without a cluster")
+	}
+	if _, err := explainer.Explain("what is a statefulset"); err != nil {
+		t.Errorf("concept explanations should not need a cluster: %v", err)
+	}
+}
+
+func TestExplainCustomResource(t *testing.T) {
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": "api-tls", "namespace": "payments"},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "False", "message": "Issuer letsencrypt-prod not found"},
+			},
+		},
+	}}
+	explainer := newTestExplainer(t, certificate)
+
+	explanation, err := explainer.Explain("why is certificate api-tls not ready?")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	// The mock provider echoes the prompt, which must carry the object status
+	if !strings.Contains(explanation.Answer, "Issuer letsencrypt-prod not found") {
+		t.Errorf("prompt did not include the certificate status:\n%s", explanation.Answer)
+	}
+}
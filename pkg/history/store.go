@@ -0,0 +1,187 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"k8s-pilot/pkg/plan"
+)
+
+// Status represents the lifecycle state of a recorded plan
+type Status string
+
+const (
+	StatusPlanned Status = "planned"
+	StatusBlocked Status = "blocked"
+	StatusApplied Status = "applied"
+	StatusFailed  Status = "failed"
+)
+
+// Record is a persisted plan together with its execution outcome
+type Record struct {
+	ID        uint64       `json:"id"`
+	Query     string       `json:"query"`
+	Source    string       `json:"source"`
+	User      string       `json:"user"`
+	Context   string       `json:"context"`
+	Cluster   string       `json:"cluster"`
+	Namespace string       `json:"namespace"`
+	Status    Status       `json:"status"`
+	Plan      *plan.Plan   `json:"plan"`
+	Result    *plan.Result `json:"result,omitempty"`
+	Undo      []string     `json:"undo,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Filter narrows the records returned by List
+type Filter struct {
+	Namespace string
+	Status    Status
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// matches reports whether a record satisfies the filter
+func (f Filter) matches(r *Record) bool {
+	if f.Namespace != "" && r.Namespace != f.Namespace {
+		return false
+	}
+	if f.Status != "" && r.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && r.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.CreatedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+var recordsBucket = []byte("records")
+
+// Store persists plan history in an embedded bbolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// DefaultPath returns the default history database path ($HOME/.k8s-pilot/history.db)
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".k8s-pilot", "history.db")
+	}
+	return filepath.Join(home, ".k8s-pilot", "history.db")
+}
+
+// Open opens (creating if needed) the history database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(recordsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save inserts a new record, or updates it when the record already has an ID
+func (s *Store) Save(record *Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+
+		now := time.Now().UTC()
+		if record.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return fmt.Errorf("failed to allocate record id: %w", err)
+			}
+			record.ID = id
+			record.CreatedAt = now
+		}
+		record.UpdatedAt = now
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+
+		return bucket.Put(itob(record.ID), data)
+	})
+}
+
+// Get returns the record with the given ID
+func (s *Store) Get(id uint64) (*Record, error) {
+	var record Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(recordsBucket).Get(itob(id))
+		if data == nil {
+			return fmt.Errorf("history record %d not found", id)
+		}
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// List returns records matching the filter, newest first
+func (s *Store) List(filter Filter) ([]*Record, error) {
+	var records []*Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(recordsBucket).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode record %d: %w", btoi(k), err)
+			}
+			if !filter.matches(&record) {
+				continue
+			}
+			records = append(records, &record)
+			if filter.Limit > 0 && len(records) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// itob encodes an ID as a big-endian key so records sort by insertion order
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+// btoi decodes a big-endian key
+func btoi(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
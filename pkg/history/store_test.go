@@ -0,0 +1,99 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s-pilot/pkg/plan"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSaveAndGet(t *testing.T) {
+	store := openTestStore(t)
+
+	record := &Record{
+		Query:     "restart api",
+		Namespace: "payments",
+		Status:    StatusPlanned,
+		Plan: &plan.Plan{
+			Summary:  "Restart api",
+			Commands: []plan.Command{{Command: "kubectl rollout restart deployment api -n payments"}},
+		},
+	}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Failed to save record: %v", err)
+	}
+	if record.ID == 0 {
+		t.Fatal("Expected record ID to be assigned")
+	}
+
+	record.Status = StatusApplied
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+
+	got, err := store.Get(record.ID)
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if got.Status != StatusApplied {
+		t.Errorf("Expected status %s, got %s", StatusApplied, got.Status)
+	}
+	if got.Plan == nil || len(got.Plan.Commands) != 1 {
+		t.Fatal("Expected plan to round-trip")
+	}
+	if got.CreatedAt.IsZero() || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Error("Expected timestamps to be set")
+	}
+
+	if _, err := store.Get(999); err == nil {
+		t.Error("Expected error for missing record")
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	store := openTestStore(t)
+
+	for _, r := range []*Record{
+		{Query: "one", Namespace: "a", Status: StatusApplied},
+		{Query: "two", Namespace: "b", Status: StatusPlanned},
+		{Query: "three", Namespace: "a", Status: StatusPlanned},
+	} {
+		if err := store.Save(r); err != nil {
+			t.Fatalf("Failed to save record: %v", err)
+		}
+	}
+
+	all, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(all) != 3 || all[0].Query != "three" {
+		t.Fatalf("Expected 3 records newest first, got %d", len(all))
+	}
+
+	byNamespace, _ := store.List(Filter{Namespace: "a", Status: StatusPlanned})
+	if len(byNamespace) != 1 || byNamespace[0].Query != "three" {
+		t.Errorf("Unexpected namespace/status filter result: %+v", byNamespace)
+	}
+
+	limited, _ := store.List(Filter{Limit: 2})
+	if len(limited) != 2 {
+		t.Errorf("Expected 2 records, got %d", len(limited))
+	}
+
+	future, _ := store.List(Filter{Since: time.Now().Add(time.Hour)})
+	if len(future) != 0 {
+		t.Errorf("Expected no records after since, got %d", len(future))
+	}
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessCheck describes an action to verify with a SelfSubjectAccessReview
+type AccessCheck struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Name        string
+	Namespace   string
+	// Unverifiable explains why a command's permissions could not be
+	// derived, such as a remote manifest; such checks are always denied
+	Unverifiable string
+}
+
+// String returns a kubectl auth can-i style description of the check
+func (a AccessCheck) String() string {
+	if a.Unverifiable != "" {
+		return "verify permissions: " + a.Unverifiable
+	}
+	resource := a.Resource
+	if a.Group != "" {
+		resource += "." + a.Group
+	}
+	if a.Subresource != "" {
+		resource += "/" + a.Subresource
+	}
+	if a.Name != "" {
+		resource += "/" + a.Name
+	}
+	if a.Namespace != "" {
+		return fmt.Sprintf("%s %s in namespace %s", a.Verb, resource, a.Namespace)
+	}
+	return fmt.Sprintf("%s %s", a.Verb, resource)
+}
+
+// AccessDecision is the outcome of an access check
+type AccessDecision struct {
+	Allowed bool
+	Reason  string
+}
+
+// CanI checks whether the current user may perform an action
+func (c *Client) CanI(ctx context.Context, check AccessCheck) (*AccessDecision, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   check.Namespace,
+				Verb:        check.Verb,
+				Group:       check.Group,
+				Resource:    check.Resource,
+				Subresource: check.Subresource,
+				Name:        check.Name,
+			},
+		},
+	}
+
+	result, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to review access for %s: %w", check, err)
+	}
+
+	return &AccessDecision{
+		Allowed: result.Status.Allowed && !result.Status.Denied,
+		Reason:  result.Status.Reason,
+	}, nil
+}
+
+// IsForbidden reports whether err is an RBAC "forbidden" API error
+func IsForbidden(err error) bool {
+	return apierrors.IsForbidden(err)
+}
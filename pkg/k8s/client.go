@@ -1,75 +1,201 @@
 package k8s
 
 import (
-	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
+	"sort"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
 // Client wraps the Kubernetes client
 type Client struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	mapper    restMapper
 	config    *rest.Config
 	namespace string
+	target    Target
+	metrics   *APIMetrics
+	// dump is set for clients reading a dump instead of an API server
+	dump      *Dump
 }
 
-// NewClient creates a new Kubernetes client
+// Options selects the kubeconfig, context and identity used to reach the
+// cluster, mirroring kubectl's --kubeconfig, --context, --as and --as-group
+type Options struct {
+	Kubeconfig        string
+	Context           string
+	Impersonate       string
+	ImpersonateGroups []string
+}
+
+// Target describes the effective cluster, context and user of a client
+type Target struct {
+	Context   string
+	Cluster   string
+	Server    string
+	User      string
+	Namespace string
+}
+
+// String returns a one-line description of the target
+func (t Target) String() string {
+	return fmt.Sprintf("context=%s cluster=%s user=%s namespace=%s",
+		orUnknown(t.Context), orUnknown(t.Cluster), orUnknown(t.User), orUnknown(t.Namespace))
+}
+
+// orUnknown returns value, or "<unknown>" when it is empty
+func orUnknown(value string) string {
+	if value == "" {
+		return "<unknown>"
+	}
+	return value
+}
+
+var defaultOptions Options
+
+// SetDefaultOptions sets the options used by NewClient, typically from
+// global command-line flags
+func SetDefaultOptions(opts Options) {
+	defaultOptions = opts
+}
+
+// DefaultOptions returns the options used by NewClient
+func DefaultOptions() Options {
+	return defaultOptions
+}
+
+// NewClient creates a new Kubernetes client using the default options
 func NewClient(namespace string) (*Client, error) {
-	config, err := getConfig()
+	return NewClientWithOptions(namespace, defaultOptions)
+}
+
+// NewClientWithOptions creates a new Kubernetes client for the given options
+func NewClientWithOptions(namespace string, opts Options) (*Client, error) {
+	clientConfig := newClientConfig(opts)
+	
+	config, err := clientConfig.ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubernetes config: %w", err)
 	}
 	
+	// Count every API call for --verbose output
+	metrics := NewAPIMetrics()
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &metricsTransport{next: rt, metrics: metrics}
+	})
+	
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 	
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	
 	if namespace == "" {
-		namespace = "default"
+		// Fall back to the context's namespace, like kubectl
+		if ns, _, err := clientConfig.Namespace(); err == nil && ns != "" {
+			namespace = ns
+		} else {
+			namespace = "default"
+		}
 	}
 	
+	target := resolveTarget(clientConfig, opts, config)
+	target.Namespace = namespace
+	
 	return &Client{
 		clientset: clientset,
+		dynamic:   dynamicClient,
 		config:    config,
 		namespace: namespace,
+		target:    target,
+		metrics:   metrics,
 	}, nil
 }
 
-// getConfig returns the Kubernetes config from kubeconfig or in-cluster
-func getConfig() (*rest.Config, error) {
-	// Try in-cluster config first
-	config, err := rest.InClusterConfig()
-	if err == nil {
-		return config, nil
+// NewClientFromInterface creates a client around an existing clientset, such
+// as a fake clientset in tests
+func NewClientFromInterface(clientset kubernetes.Interface, namespace string) *Client {
+	if namespace == "" {
+		namespace = "default"
+	}
+	
+	return &Client{
+		clientset: clientset,
+		config:    &rest.Config{},
+		namespace: namespace,
+		target:    Target{Namespace: namespace},
+		metrics:   NewAPIMetrics(),
+	}
+}
+
+// newClientConfig builds a kubeconfig loader using the standard loading
+// rules: --kubeconfig, then the merged KUBECONFIG paths, then
+// ~/.kube/config, falling back to in-cluster config when none exist
+func newClientConfig(opts Options) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.Kubeconfig
+	
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: opts.Context,
 	}
+	overrides.AuthInfo.Impersonate = opts.Impersonate
+	overrides.AuthInfo.ImpersonateGroups = opts.ImpersonateGroups
+	
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+// resolveTarget determines the effective context, cluster and user
+func resolveTarget(clientConfig clientcmd.ClientConfig, opts Options, config *rest.Config) Target {
+	target := Target{Server: config.Host}
 	
-	// Fall back to kubeconfig
-	var kubeconfig string
-	if os.Getenv("KUBECONFIG") != "" {
-		kubeconfig = os.Getenv("KUBECONFIG")
-	} else if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
+	raw, err := clientConfig.RawConfig()
+	if err != nil || len(raw.Contexts) == 0 {
+		target.Context = "in-cluster"
+		target.Cluster = "in-cluster"
 	} else {
-		return nil, fmt.Errorf("unable to find kubeconfig")
+		target.Context = raw.CurrentContext
+		if opts.Context != "" {
+			target.Context = opts.Context
+		}
+		if kubeContext, ok := raw.Contexts[target.Context]; ok {
+			target.Cluster = kubeContext.Cluster
+			target.User = kubeContext.AuthInfo
+		}
+	}
+	
+	if opts.Impersonate != "" {
+		target.User = fmt.Sprintf("%s (as %s)", orUnknown(target.User), opts.Impersonate)
 	}
 	
-	config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	return target
+}
+
+// Contexts returns the context names defined in the merged kubeconfig
+func Contexts(opts Options) ([]string, error) {
+	raw, err := newClientConfig(opts).RawConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to build config from kubeconfig: %w", err)
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
 	}
 	
-	return config, nil
+	names := make([]string, 0, len(raw.Contexts))
+	for name := range raw.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
 }
 
 // Clientset returns the underlying Kubernetes clientset
-func (c *Client) Clientset() *kubernetes.Clientset {
+func (c *Client) Clientset() kubernetes.Interface {
 	return c.clientset
 }
 
@@ -78,45 +204,22 @@ func (c *Client) Config() *rest.Config {
 	return c.config
 }
 
+// Target returns the effective cluster, context and user of the client
+func (c *Client) Target() Target {
+	return c.target
+}
+
 // Namespace returns the current namespace
 func (c *Client) Namespace() string {
 	return c.namespace
 }
 
+// Metrics returns the API call metrics recorded by the client
+func (c *Client) Metrics() *APIMetrics {
+	return c.metrics
+}
+
 // SetNamespace sets the namespace for operations
 func (c *Client) SetNamespace(namespace string) {
 	c.namespace = namespace
 }
-
-// DetectClusterType detects the type of Kubernetes cluster
-func (c *Client) DetectClusterType(ctx context.Context) (ClusterType, error) {
-	// Try to detect cluster type from API server
-	version, err := c.clientset.Discovery().ServerVersion()
-	if err != nil {
-		return ClusterTypeUnknown, err
-	}
-	
-	// Check for GKE
-	if version.GitVersion != "" && len(version.GitVersion) > 0 {
-		// GKE has specific version patterns
-		// EKS has specific version patterns
-		// AKS has specific version patterns
-		// This is a simplified detection
-	}
-	
-	// For now, return generic
-	return ClusterTypeGeneric, nil
-}
-
-// ClusterType represents the type of Kubernetes cluster
-type ClusterType string
-
-const (
-	ClusterTypeGKE     ClusterType = "gke"
-	ClusterTypeEKS     ClusterType = "eks"
-	ClusterTypeAKS     ClusterType = "aks"
-	ClusterTypeK3s     ClusterType = "k3s"
-	ClusterTypeKind    ClusterType = "kind"
-	ClusterTypeGeneric ClusterType = "generic"
-	ClusterTypeUnknown ClusterType = "unknown"
-)
@@ -0,0 +1,263 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterType represents the type of Kubernetes cluster
+type ClusterType string
+
+const (
+	ClusterTypeGKE     ClusterType = "gke"
+	ClusterTypeEKS     ClusterType = "eks"
+	ClusterTypeAKS     ClusterType = "aks"
+	ClusterTypeK3s     ClusterType = "k3s"
+	ClusterTypeKind    ClusterType = "kind"
+	ClusterTypeGeneric ClusterType = "generic"
+	ClusterTypeUnknown ClusterType = "unknown"
+)
+
+// String returns a human-readable name for the distribution
+func (t ClusterType) String() string {
+	switch t {
+	case ClusterTypeGKE:
+		return "GKE"
+	case ClusterTypeEKS:
+		return "EKS"
+	case ClusterTypeAKS:
+		return "AKS"
+	case ClusterTypeK3s:
+		return "k3s"
+	case ClusterTypeKind:
+		return "kind"
+	default:
+		return string(t)
+	}
+}
+
+// Weights of each kind of evidence; a single strong signal is enough
+const (
+	strongSignal = 3
+	weakSignal   = 1
+)
+
+// versionMarkers are substrings of the server GitVersion set by distributions
+var versionMarkers = map[string]ClusterType{
+	"-gke.": ClusterTypeGKE,
+	"-eks-": ClusterTypeEKS,
+	"+k3s":  ClusterTypeK3s,
+}
+
+// providerIDPrefixes map node spec.providerID schemes to distributions
+var providerIDPrefixes = map[string]ClusterType{
+	"gce://":   ClusterTypeGKE,
+	"aws://":   ClusterTypeEKS,
+	"azure://": ClusterTypeAKS,
+	"k3s://":   ClusterTypeK3s,
+	"kind://":  ClusterTypeKind,
+}
+
+// nodeLabelMarkers map well-known node label keys to distributions
+var nodeLabelMarkers = map[string]ClusterType{
+	"cloud.google.com/gke-nodepool":        ClusterTypeGKE,
+	"cloud.google.com/gke-os-distribution": ClusterTypeGKE,
+	"eks.amazonaws.com/nodegroup":          ClusterTypeEKS,
+	"eks.amazonaws.com/compute-type":       ClusterTypeEKS,
+	"alpha.eksctl.io/nodegroup-name":       ClusterTypeEKS,
+	"kubernetes.azure.com/cluster":         ClusterTypeAKS,
+	"kubernetes.azure.com/agentpool":       ClusterTypeAKS,
+	"node.k3s.io/instance-type":            ClusterTypeK3s,
+	"k3s.io/hostname":                      ClusterTypeK3s,
+}
+
+// systemNamespaces map namespaces created by distributions
+var systemNamespaces = map[string]ClusterType{
+	"gke-managed-system": ClusterTypeGKE,
+	"gke-gmp-system":     ClusterTypeGKE,
+	"gmp-system":         ClusterTypeGKE,
+	"amazon-cloudwatch":  ClusterTypeEKS,
+	"aks-command":        ClusterTypeAKS,
+	"local-path-storage": ClusterTypeKind,
+}
+
+// apiGroupMarkers map API groups installed by distributions
+var apiGroupMarkers = map[string]ClusterType{
+	"networking.gke.io":     ClusterTypeGKE,
+	"nodemanagement.gke.io": ClusterTypeGKE,
+	"cloud.google.com":      ClusterTypeGKE,
+	"crd.k8s.amazonaws.com": ClusterTypeEKS,
+	"vpcresources.k8s.aws":  ClusterTypeEKS,
+	"elbv2.k8s.aws":         ClusterTypeEKS,
+	"k3s.cattle.io":         ClusterTypeK3s,
+	"helm.cattle.io":        ClusterTypeK3s,
+}
+
+// ClusterSignals is the evidence used to identify a distribution
+type ClusterSignals struct {
+	GitVersion  string
+	ProviderIDs []string
+	NodeLabels  []map[string]string
+	Namespaces  []string
+	APIGroups   []string
+}
+
+// nodeSampleSize is the number of nodes inspected for labels and providerIDs
+const nodeSampleSize int64 = 10
+
+// DetectClusterType detects the distribution from the server version, node
+// labels and providerIDs, system namespaces and API groups. Signals the user
+// is not permitted to read are skipped.
+func (c *Client) DetectClusterType(ctx context.Context) (ClusterType, error) {
+	signals, err := c.clusterSignals(ctx)
+	if err != nil {
+		return ClusterTypeUnknown, err
+	}
+	return ClassifyCluster(signals), nil
+}
+
+// clusterSignals collects detection evidence from the API server
+func (c *Client) clusterSignals(ctx context.Context) (ClusterSignals, error) {
+	var signals ClusterSignals
+
+	version, err := c.clientset.Discovery().ServerVersion()
+	if err != nil {
+		return signals, err
+	}
+	signals.GitVersion = version.GitVersion
+
+	if nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: nodeSampleSize}); err == nil {
+		for _, node := range nodes.Items {
+			signals.ProviderIDs = append(signals.ProviderIDs, node.Spec.ProviderID)
+			signals.NodeLabels = append(signals.NodeLabels, node.Labels)
+		}
+	}
+
+	if namespaces, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, ns := range namespaces.Items {
+			signals.Namespaces = append(signals.Namespaces, ns.Name)
+		}
+	}
+
+	if groups, err := c.clientset.Discovery().ServerGroups(); err == nil {
+		for _, group := range groups.Groups {
+			signals.APIGroups = append(signals.APIGroups, group.Name)
+		}
+	}
+
+	return signals, nil
+}
+
+// ClassifyCluster scores the signals and returns the most likely
+// distribution, or ClusterTypeGeneric when nothing matches
+func ClassifyCluster(signals ClusterSignals) ClusterType {
+	scores := map[ClusterType]int{}
+
+	for marker, clusterType := range versionMarkers {
+		if strings.Contains(signals.GitVersion, marker) {
+			scores[clusterType] += strongSignal
+		}
+	}
+	for _, providerID := range signals.ProviderIDs {
+		for prefix, clusterType := range providerIDPrefixes {
+			if strings.HasPrefix(providerID, prefix) {
+				scores[clusterType] += strongSignal
+			}
+		}
+	}
+	for _, labels := range signals.NodeLabels {
+		for key := range labels {
+			if clusterType, ok := nodeLabelMarkers[key]; ok {
+				scores[clusterType] += strongSignal
+			}
+		}
+	}
+	for _, ns := range signals.Namespaces {
+		if clusterType, ok := systemNamespaces[ns]; ok {
+			scores[clusterType] += weakSignal
+		}
+	}
+	for _, group := range signals.APIGroups {
+		if clusterType, ok := apiGroupMarkers[group]; ok {
+			scores[clusterType] += strongSignal
+		}
+	}
+
+	// Self-managed clusters on AWS also use aws:// providerIDs; without
+	// EKS-specific evidence they are treated as generic
+	if scores[ClusterTypeEKS] > 0 && !hasEKSMarker(signals) {
+		delete(scores, ClusterTypeEKS)
+	}
+
+	best := ClusterTypeGeneric
+	bestScore := 0
+	for _, clusterType := range []ClusterType{ClusterTypeGKE, ClusterTypeEKS, ClusterTypeAKS, ClusterTypeK3s, ClusterTypeKind} {
+		if scores[clusterType] > bestScore {
+			best = clusterType
+			bestScore = scores[clusterType]
+		}
+	}
+	return best
+}
+
+// hasEKSMarker reports whether there is evidence of EKS beyond an AWS
+// providerID
+func hasEKSMarker(signals ClusterSignals) bool {
+	if strings.Contains(signals.GitVersion, "-eks-") {
+		return true
+	}
+	for _, labels := range signals.NodeLabels {
+		for key := range labels {
+			if nodeLabelMarkers[key] == ClusterTypeEKS {
+				return true
+			}
+		}
+	}
+	for _, group := range signals.APIGroups {
+		if apiGroupMarkers[group] == ClusterTypeEKS {
+			return true
+		}
+	}
+	for _, ns := range signals.Namespaces {
+		if systemNamespaces[ns] == ClusterTypeEKS {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeScaleCommand returns the distribution's command for adding node
+// capacity to the current cluster, with placeholders for cluster-specific
+// names, or "" when there is no such command
+func (t ClusterType) NodeScaleCommand() string {
+	switch t {
+	case ClusterTypeGKE:
+		return "gcloud container clusters resize <cluster> --node-pool <pool> --num-nodes <count>"
+	case ClusterTypeEKS:
+		return "eksctl scale nodegroup --cluster <cluster> --name <nodegroup> --nodes <count>"
+	case ClusterTypeAKS:
+		return "az aks nodepool scale --resource-group <group> --cluster-name <cluster> --name <pool> --node-count <count>"
+	default:
+		return ""
+	}
+}
+
+// NodeScaleHint explains how to add node capacity on distributions whose
+// tooling has no command that is safe to suggest, or returns ""
+func (t ClusterType) NodeScaleHint() string {
+	switch t {
+	case ClusterTypeK3s:
+		return "Join another agent node to the cluster with the K3s server URL and token (see https://docs.k3s.io/quick-start)"
+	case ClusterTypeKind:
+		return "Kind cannot add nodes to a running cluster; recreate it with more worker nodes in its config (see https://kind.sigs.k8s.io/docs/user/configuration/#nodes)"
+	default:
+		return ""
+	}
+}
+
+// IsKnown reports whether a specific distribution was identified
+func (t ClusterType) IsKnown() bool {
+	return t != "" && t != ClusterTypeGeneric && t != ClusterTypeUnknown
+}
@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectClusterType(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		gitVersion string
+		apiGroups  []string
+		want       ClusterType
+	}{
+		{"gke nodes", "testdata/nodes-gke.yaml", "v1.29.1-gke.1589017", nil, ClusterTypeGKE},
+		{"gke version only", "", "v1.29.1-gke.1589017", nil, ClusterTypeGKE},
+		{"eks nodes", "testdata/nodes-eks.yaml", "v1.29.0-eks-c417bb3", nil, ClusterTypeEKS},
+		{"eks api groups", "", "v1.29.0", []string{"vpcresources.k8s.aws"}, ClusterTypeEKS},
+		{"self-managed aws", "testdata/nodes-aws-selfmanaged.yaml", "v1.29.0", nil, ClusterTypeGeneric},
+		{"aks nodes", "testdata/nodes-aks.yaml", "v1.29.0", nil, ClusterTypeAKS},
+		{"kind nodes", "testdata/nodes-kind.yaml", "v1.29.0", nil, ClusterTypeKind},
+		{"k3s version", "testdata/nodes-k3s.yaml", "v1.29.1+k3s2", []string{"helm.cattle.io"}, ClusterTypeK3s},
+		{"vanilla", "", "v1.29.0", []string{"apps", "batch"}, ClusterTypeGeneric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fixtures []string
+			if tt.fixture != "" {
+				fixtures = append(fixtures, tt.fixture)
+			}
+			objects, err := LoadObjects(fixtures...)
+			if err != nil {
+				t.Fatalf("LoadObjects: %v", err)
+			}
+
+			clientset := fake.NewSimpleClientset(objects...)
+			discovery := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+			discovery.FakedServerVersion = &version.Info{GitVersion: tt.gitVersion}
+			for _, group := range tt.apiGroups {
+				discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{GroupVersion: group + "/v1"})
+			}
+
+			got, err := NewClientFromInterface(clientset, "default").DetectClusterType(context.Background())
+			if err != nil {
+				t.Fatalf("DetectClusterType: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectClusterType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeScaleCommand(t *testing.T) {
+	for _, clusterType := range []ClusterType{ClusterTypeGKE, ClusterTypeEKS, ClusterTypeAKS} {
+		if clusterType.NodeScaleCommand() == "" {
+			t.Errorf("%s: no node scale command", clusterType)
+		}
+	}
+	// Kind can't grow a running cluster and K3s joins nodes by piping an
+	// installer into a shell, so neither gets a command, only a hint
+	for _, clusterType := range []ClusterType{ClusterTypeK3s, ClusterTypeKind} {
+		if command := clusterType.NodeScaleCommand(); command != "" {
+			t.Errorf("%s: NodeScaleCommand() = %q, want none", clusterType, command)
+		}
+		if clusterType.NodeScaleHint() == "" {
+			t.Errorf("%s: no node scale hint", clusterType)
+		}
+	}
+}
@@ -0,0 +1,592 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-pilot/pkg/output"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Dump layout: metadata.yaml, resources/<resource>.yaml and
+// logs/<namespace>/<pod>/<container>[.previous].log
+const (
+	dumpMetadataFile = "metadata.yaml"
+	dumpResourcesDir = "resources"
+	dumpLogsDir      = "logs"
+	previousLogs     = ".previous"
+)
+
+// KindDump is the kind of a dump's metadata document
+const KindDump = "Dump"
+
+// errNotInDump marks resources and logs the dump does not contain
+var errNotInDump = errors.New("not included in the dump")
+
+// DumpMetadata describes how and where a dump was captured
+type DumpMetadata struct {
+	APIVersion    string    `json:"api_version"`
+	Kind          string    `json:"kind"`
+	Context       string    `json:"context,omitempty"`
+	Namespace     string    `json:"namespace,omitempty"`
+	ServerVersion string    `json:"server_version,omitempty"`
+	CapturedAt    time.Time `json:"captured_at"`
+	// Resources are the snapshot resources captured, even when empty
+	Resources []string `json:"resources"`
+	// Errors holds the resources that could not be captured
+	Errors map[string]string `json:"errors,omitempty"`
+	// SecretErrors holds the secrets that could not be fetched, by
+	// namespace/name
+	SecretErrors map[string]string `json:"secret_errors,omitempty"`
+	Logs         int               `json:"logs"`
+}
+
+// Dump is cluster state saved to files, as written by "kubectl-pilot dump"
+// or collected with "kubectl get -o yaml", that can be diagnosed without an
+// API server
+type Dump struct {
+	// Path is the directory or archive the dump was loaded from
+	Path string
+	// Metadata is only set for dumps written by WriteDump
+	Metadata *DumpMetadata
+	Objects  []runtime.Object
+
+	logs map[string]string
+	seen map[string]int
+}
+
+// DumpOptions selects what WriteDump captures
+type DumpOptions struct {
+	// LogLines is the number of log lines kept per container; 0 skips logs
+	LogLines int64
+}
+
+// snapshotResources lists every resource captured in a snapshot
+var snapshotResources = []string{
+	ResourcePods, ResourceEvents, ResourceNodes, ResourceDeployments, ResourceReplicaSets,
+	ResourcePVCs, ResourceServices, ResourceEndpoints, ResourceStatefulSets, ResourceDaemonSets,
+	ResourceJobs, ResourceCronJobs, ResourceEndpointSlices, ResourceIngresses,
+	ResourceNetworkPolicies, ResourceSecrets, ResourcePVs, ResourceStorageClasses, ResourceCSINodes,
+}
+
+// LoadDump reads a dump from a directory or a .tar.gz archive. YAML and
+// JSON files anywhere in it are decoded as objects and files under logs/ are
+// container logs.
+func LoadDump(dumpPath string) (*Dump, error) {
+	info, err := os.Stat(dumpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+
+	dump := &Dump{Path: dumpPath, logs: map[string]string{}, seen: map[string]int{}}
+	if info.IsDir() {
+		err = filepath.WalkDir(dumpPath, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(dumpPath, file)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			return dump.addFile(filepath.ToSlash(rel), data)
+		})
+	} else {
+		err = dump.readArchive(dumpPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// readArchive reads the files of a gzipped tarball
+func (d *Dump) readArchive(archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		if err := d.addFile(path.Clean(header.Name), data); err != nil {
+			return err
+		}
+	}
+}
+
+// addFile adds the metadata, objects or logs a file holds
+func (d *Dump) addFile(name string, data []byte) error {
+	parts := strings.Split(name, "/")
+	switch ext := path.Ext(name); {
+	case ext == ".log" && len(parts) >= 4 && parts[len(parts)-4] == dumpLogsDir:
+		namespace, pod, container := parts[len(parts)-3], parts[len(parts)-2], strings.TrimSuffix(parts[len(parts)-1], ext)
+		d.logs[namespace+"/"+pod+"/"+container] = string(data)
+		return nil
+	case ext != ".yaml" && ext != ".yml" && ext != ".json":
+		return nil
+	case path.Base(name) == dumpMetadataFile:
+		var metadata DumpMetadata
+		if err := yaml.Unmarshal(data, &metadata); err == nil && metadata.Kind == KindDump {
+			d.Metadata = &metadata
+			return nil
+		}
+	}
+
+	objects, err := DecodeObjects(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, obj := range objects {
+		d.addObject(obj)
+	}
+	return nil
+}
+
+// addObject adds an object, replacing an earlier copy of it. Secrets are
+// redacted as soon as they are read.
+func (d *Dump) addObject(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	if secret, ok := obj.(*corev1.Secret); ok {
+		redactSecret(secret)
+	}
+	key := obj.GetObjectKind().GroupVersionKind().GroupKind().String() + "/" + accessor.GetNamespace() + "/" + accessor.GetName()
+	if i, ok := d.seen[key]; ok {
+		d.Objects[i] = obj
+		return
+	}
+	d.seen[key] = len(d.Objects)
+	d.Objects = append(d.Objects, obj)
+}
+
+// Snapshot returns the dump's objects in a namespace, or in every namespace
+// when namespace is metav1.NamespaceAll (""). Resources the dump does not
+// contain are recorded in Snapshot.Errors, like those a live snapshot could
+// not list.
+func (d *Dump) Snapshot(namespace string) *Snapshot {
+	snapshot := &Snapshot{Namespace: namespace, Errors: map[string]error{}}
+	if d.Metadata != nil {
+		snapshot.ServerVersion = d.Metadata.ServerVersion
+		snapshot.CapturedAt = d.Metadata.CapturedAt
+	}
+
+	found := map[string]bool{}
+	for _, obj := range d.Objects {
+		if accessor, err := meta.Accessor(obj); err != nil || (namespace != "" && accessor.GetNamespace() != "" && accessor.GetNamespace() != namespace) {
+			continue
+		}
+		if resource := snapshot.add(obj); resource != "" {
+			found[resource] = true
+		}
+	}
+
+	for _, resource := range snapshotResources {
+		switch {
+		case d.Metadata != nil && d.Metadata.Errors[resource] != "":
+			snapshot.Errors[resource] = errors.New(d.Metadata.Errors[resource])
+		case d.Metadata != nil && !contains(d.Metadata.Resources, resource):
+			snapshot.Errors[resource] = errNotInDump
+		case d.Metadata == nil && !found[resource] && resource != ResourcePods:
+			snapshot.Errors[resource] = errNotInDump
+		}
+	}
+	if d.Metadata != nil && d.Metadata.Errors[ResourceServerVersion] != "" {
+		snapshot.Errors[ResourceServerVersion] = errors.New(d.Metadata.Errors[ResourceServerVersion])
+	}
+	if d.Metadata != nil && len(d.Metadata.SecretErrors) > 0 {
+		snapshot.secretErrors = map[string]error{}
+		for key, message := range d.Metadata.SecretErrors {
+			snapshot.secretErrors[key] = errors.New(message)
+		}
+	}
+
+	snapshot.index()
+	return snapshot
+}
+
+// add appends a typed object to its snapshot list and returns its resource,
+// or "" for kinds a snapshot does not hold
+func (s *Snapshot) add(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		s.Pods = append(s.Pods, *o)
+		return ResourcePods
+	case *corev1.Event:
+		s.Events = append(s.Events, *o)
+		return ResourceEvents
+	case *corev1.Node:
+		s.Nodes = append(s.Nodes, *o)
+		return ResourceNodes
+	case *appsv1.Deployment:
+		s.Deployments = append(s.Deployments, *o)
+		return ResourceDeployments
+	case *appsv1.ReplicaSet:
+		s.ReplicaSets = append(s.ReplicaSets, *o)
+		return ResourceReplicaSets
+	case *corev1.PersistentVolumeClaim:
+		s.PVCs = append(s.PVCs, *o)
+		return ResourcePVCs
+	case *corev1.Service:
+		s.Services = append(s.Services, *o)
+		return ResourceServices
+	case *corev1.Endpoints:
+		s.Endpoints = append(s.Endpoints, *o)
+		return ResourceEndpoints
+	case *appsv1.StatefulSet:
+		s.StatefulSets = append(s.StatefulSets, *o)
+		return ResourceStatefulSets
+	case *appsv1.DaemonSet:
+		s.DaemonSets = append(s.DaemonSets, *o)
+		return ResourceDaemonSets
+	case *batchv1.Job:
+		s.Jobs = append(s.Jobs, *o)
+		return ResourceJobs
+	case *batchv1.CronJob:
+		s.CronJobs = append(s.CronJobs, *o)
+		return ResourceCronJobs
+	case *discoveryv1.EndpointSlice:
+		s.EndpointSlices = append(s.EndpointSlices, *o)
+		return ResourceEndpointSlices
+	case *networkingv1.Ingress:
+		s.Ingresses = append(s.Ingresses, *o)
+		return ResourceIngresses
+	case *networkingv1.NetworkPolicy:
+		s.NetworkPolicies = append(s.NetworkPolicies, *o)
+		return ResourceNetworkPolicies
+	case *corev1.Secret:
+		s.Secrets = append(s.Secrets, *o)
+		return ResourceSecrets
+	case *corev1.PersistentVolume:
+		s.PVs = append(s.PVs, *o)
+		return ResourcePVs
+	case *storagev1.StorageClass:
+		s.StorageClasses = append(s.StorageClasses, *o)
+		return ResourceStorageClasses
+	case *storagev1.CSINode:
+		s.CSINodes = append(s.CSINodes, *o)
+		return ResourceCSINodes
+	}
+	return ""
+}
+
+// objects returns the snapshot's objects of a resource
+func (s *Snapshot) objects(resource string) []runtime.Object {
+	switch resource {
+	case ResourcePods:
+		return objectsOf(s.Pods)
+	case ResourceEvents:
+		return objectsOf(s.Events)
+	case ResourceNodes:
+		return objectsOf(s.Nodes)
+	case ResourceDeployments:
+		return objectsOf(s.Deployments)
+	case ResourceReplicaSets:
+		return objectsOf(s.ReplicaSets)
+	case ResourcePVCs:
+		return objectsOf(s.PVCs)
+	case ResourceServices:
+		return objectsOf(s.Services)
+	case ResourceEndpoints:
+		return objectsOf(s.Endpoints)
+	case ResourceStatefulSets:
+		return objectsOf(s.StatefulSets)
+	case ResourceDaemonSets:
+		return objectsOf(s.DaemonSets)
+	case ResourceJobs:
+		return objectsOf(s.Jobs)
+	case ResourceCronJobs:
+		return objectsOf(s.CronJobs)
+	case ResourceEndpointSlices:
+		return objectsOf(s.EndpointSlices)
+	case ResourceIngresses:
+		return objectsOf(s.Ingresses)
+	case ResourceNetworkPolicies:
+		return objectsOf(s.NetworkPolicies)
+	case ResourceSecrets:
+		return objectsOf(s.Secrets)
+	case ResourcePVs:
+		return objectsOf(s.PVs)
+	case ResourceStorageClasses:
+		return objectsOf(s.StorageClasses)
+	case ResourceCSINodes:
+		return objectsOf(s.CSINodes)
+	}
+	return nil
+}
+
+// objectsOf returns pointers to the items of a snapshot list as objects
+func objectsOf[T any, P interface {
+	*T
+	runtime.Object
+}](items []T) []runtime.Object {
+	objects := make([]runtime.Object, 0, len(items))
+	for i := range items {
+		objects = append(objects, P(&items[i]))
+	}
+	return objects
+}
+
+// PodLogs returns a container's logs from the dump, keeping the last
+// options.TailLines lines
+func (d *Dump) PodLogs(namespace, podName string, options LogOptions) (string, error) {
+	key := namespace + "/" + podName + "/" + options.Container
+	if options.Previous {
+		key += previousLogs
+	}
+	logs, ok := d.logs[key]
+	if !ok {
+		return "", fmt.Errorf("failed to get logs: %w", errNotInDump)
+	}
+	if options.TailLines > 0 {
+		lines := strings.SplitAfter(logs, "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if n := int(options.TailLines); len(lines) > n {
+			logs = strings.Join(lines[len(lines)-n:], "")
+		}
+	}
+	return logs, nil
+}
+
+// ClusterSignals returns the distribution evidence the dump holds
+func (d *Dump) ClusterSignals() ClusterSignals {
+	var signals ClusterSignals
+	if d.Metadata != nil {
+		signals.GitVersion = d.Metadata.ServerVersion
+	}
+	namespaces := map[string]bool{}
+	groups := map[string]bool{}
+	for _, obj := range d.Objects {
+		if node, ok := obj.(*corev1.Node); ok {
+			signals.ProviderIDs = append(signals.ProviderIDs, node.Spec.ProviderID)
+			signals.NodeLabels = append(signals.NodeLabels, node.Labels)
+		}
+		if accessor, err := meta.Accessor(obj); err == nil && accessor.GetNamespace() != "" && !namespaces[accessor.GetNamespace()] {
+			namespaces[accessor.GetNamespace()] = true
+			signals.Namespaces = append(signals.Namespaces, accessor.GetNamespace())
+		}
+		if group := obj.GetObjectKind().GroupVersionKind().Group; group != "" && !groups[group] {
+			groups[group] = true
+			signals.APIGroups = append(signals.APIGroups, group)
+		}
+	}
+	return signals
+}
+
+// NewClientFromDump creates a client that reads a dump instead of an API
+// server: objects are served from memory and pod logs from the dump's files
+func NewClientFromDump(dump *Dump, namespace string) *Client {
+	clientset := fake.NewSimpleClientset()
+	for _, obj := range dump.Objects {
+		if _, custom := obj.(*unstructured.Unstructured); !custom {
+			_ = clientset.Tracker().Add(obj)
+		}
+	}
+
+	client := NewClientFromInterface(clientset, namespace)
+	client.dump = dump
+	client.target = Target{Cluster: dump.Path, Namespace: client.namespace}
+	if dump.Metadata != nil {
+		client.target.Context = dump.Metadata.Context
+	}
+	return client
+}
+
+// WriteDump captures a snapshot of a namespace, or of every namespace when
+// namespace is metav1.NamespaceAll (""), and the logs of containers that
+// restarted or are not ready, and writes them to w as a gzipped tarball.
+// Referenced secrets keep their metadata only.
+func (c *Client) WriteDump(ctx context.Context, namespace string, w io.Writer, opts DumpOptions) (*DumpMetadata, error) {
+	snapshot, err := c.Snapshot(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.FetchSecrets()
+
+	metadata := &DumpMetadata{
+		APIVersion:    output.APIVersion,
+		Kind:          KindDump,
+		Context:       c.target.Context,
+		Namespace:     namespace,
+		ServerVersion: snapshot.ServerVersion,
+		CapturedAt:    snapshot.CapturedAt.UTC().Truncate(time.Second),
+		Resources:     []string{},
+		Errors:        map[string]string{},
+	}
+	if err := snapshot.Errors[ResourceServerVersion]; err != nil {
+		metadata.Errors[ResourceServerVersion] = err.Error()
+	}
+	for key, err := range snapshot.secretErrors {
+		if metadata.SecretErrors == nil {
+			metadata.SecretErrors = map[string]string{}
+		}
+		metadata.SecretErrors[key] = err.Error()
+	}
+
+	files := map[string][]byte{}
+	for _, resource := range snapshotResources {
+		if err := snapshot.Errors[resource]; err != nil {
+			metadata.Errors[resource] = err.Error()
+			continue
+		}
+		data, err := encodeList(snapshot.objects(resource))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", resource, err)
+		}
+		files[path.Join(dumpResourcesDir, resource+".yaml")] = data
+		metadata.Resources = append(metadata.Resources, resource)
+	}
+
+	if opts.LogLines > 0 {
+		for _, pod := range snapshot.Pods {
+			for _, cs := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+				if cs.Ready && cs.RestartCount == 0 {
+					continue
+				}
+				for _, previous := range []bool{false, true} {
+					if previous && cs.RestartCount == 0 {
+						continue
+					}
+					logs, err := c.GetPodLogs(ctx, pod.Name, pod.Namespace, LogOptions{Container: cs.Name, TailLines: opts.LogLines, Previous: previous})
+					if err != nil {
+						continue
+					}
+					name := cs.Name
+					if previous {
+						name += previousLogs
+					}
+					files[path.Join(dumpLogsDir, pod.Namespace, pod.Name, name+".log")] = []byte(logs)
+					metadata.Logs++
+				}
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dump metadata: %w", err)
+	}
+	if err := writeArchive(w, metadata.CapturedAt, data, files); err != nil {
+		return nil, fmt.Errorf("failed to write dump: %w", err)
+	}
+	return metadata, nil
+}
+
+// encodeList encodes objects as a v1 List, like "kubectl get -o yaml".
+// Managed fields are dropped to keep the dump small.
+func encodeList(objects []runtime.Object) ([]byte, error) {
+	list := &corev1.List{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"}, Items: []runtime.RawExtension{}}
+	for _, obj := range objects {
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(kinds[0])
+		if accessor, err := meta.Accessor(obj); err == nil {
+			accessor.SetManagedFields(nil)
+		}
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+	}
+	return yaml.Marshal(list)
+}
+
+// writeArchive writes the metadata file followed by the other files, in
+// order, as a gzipped tarball
+func writeArchive(w io.Writer, modTime time.Time, metadata []byte, files map[string][]byte) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := io.Copy(archive, bytes.NewReader(data))
+		return err
+	}
+	if err := write(dumpMetadataFile, metadata); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := write(name, files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
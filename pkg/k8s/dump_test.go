@@ -0,0 +1,218 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWriteDumpRoundTrip(t *testing.T) {
+	objects := append(snapshotObjects(),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "payments"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+				Name:         "db",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db-password"}},
+			}}},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "api", RestartCount: 3},
+				{Name: "proxy", Ready: true},
+			}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "db-password",
+				Namespace:   "payments",
+				Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: `{"data":{"password":"aHVudGVyMg=="}}`},
+			},
+			Data: map[string][]byte{"password": []byte("hunter2")},
+		},
+	)
+	client := NewClientFromInterface(fake.NewSimpleClientset(objects...), "payments")
+
+	var archive bytes.Buffer
+	metadata, err := client.WriteDump(context.Background(), "payments", &archive, DumpOptions{LogLines: 50})
+	if err != nil {
+		t.Fatalf("WriteDump: %v", err)
+	}
+	if len(metadata.Errors) != 0 || !contains(metadata.Resources, ResourcePods) || metadata.Logs != 2 {
+		t.Errorf("metadata = %+v, want every resource and the current and previous logs of api-1/api", metadata)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.tar.gz")
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dump, err := LoadDump(path)
+	if err != nil {
+		t.Fatalf("LoadDump: %v", err)
+	}
+	if dump.Metadata == nil || dump.Metadata.Namespace != "payments" {
+		t.Fatalf("Metadata = %+v", dump.Metadata)
+	}
+
+	snapshot := dump.Snapshot("payments")
+	if len(snapshot.Pods) != 2 || len(snapshot.Deployments) != 1 || len(snapshot.Nodes) != 1 || len(snapshot.Errors) != 0 {
+		t.Errorf("unexpected dump snapshot: %s, errors %v", snapshot.Summary(), snapshot.Errors)
+	}
+	if events := snapshot.EventsFor("Pod", "payments", "api-0"); len(events) != 2 {
+		t.Errorf("EventsFor = %v, want 2 events", events)
+	}
+	secret := snapshot.Secret("payments", "db-password")
+	if secret == nil || secret.Data != nil || secret.Annotations[corev1.LastAppliedConfigAnnotation] != "" {
+		t.Errorf("Secret = %+v, want metadata without data", secret)
+	}
+	for _, leaked := range []string{"hunter2", "aHVudGVyMg==", "private", "unreferenced"} {
+		if bytes.Contains(decompress(t, archive.Bytes()), []byte(leaked)) {
+			t.Errorf("dump contains secret data %q", leaked)
+		}
+	}
+
+	offline := NewClientFromDump(dump, "payments")
+	for _, previous := range []bool{false, true} {
+		if logs, err := offline.GetPodLogs(context.Background(), "api-1", "payments", LogOptions{Container: "api", Previous: previous}); err != nil || logs == "" {
+			t.Errorf("GetPodLogs(previous=%v) = %q, %v", previous, logs, err)
+		}
+	}
+	if _, err := offline.GetPodLogs(context.Background(), "api-1", "payments", LogOptions{Container: "proxy"}); !errors.Is(err, errNotInDump) {
+		t.Errorf("logs of a healthy container: err = %v, want not in dump", err)
+	}
+	if pod, err := offline.GetPod(context.Background(), "api-0", "payments"); err != nil || pod.Name != "api-0" {
+		t.Errorf("GetPod = %v, %v", pod, err)
+	}
+}
+
+func TestWriteDumpSecretErrors(t *testing.T) {
+	clientset := fake.NewSimpleClientset(snapshotObjects()...)
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "api-tls" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "api-tls", fmt.Errorf("denied"))
+	})
+	client := NewClientFromInterface(clientset, "payments")
+
+	var archive bytes.Buffer
+	metadata, err := client.WriteDump(context.Background(), "payments", &archive, DumpOptions{})
+	if err != nil {
+		t.Fatalf("WriteDump: %v", err)
+	}
+	if metadata.SecretErrors["payments/api-tls"] == "" || metadata.Errors[ResourceSecrets] != "" {
+		t.Fatalf("metadata = %+v, want only api-tls recorded as failed", metadata)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.tar.gz")
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dump, err := LoadDump(path)
+	if err != nil {
+		t.Fatalf("LoadDump: %v", err)
+	}
+	snapshot := dump.Snapshot("payments")
+	if snapshot.SecretErr("payments", "api-tls") == nil || snapshot.SecretErr("payments", "www-tls") != nil {
+		t.Errorf("SecretErr(api-tls) = %v, want the recorded failure only for api-tls", snapshot.SecretErr("payments", "api-tls"))
+	}
+}
+
+func TestLoadDumpDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"support/pods.yaml": `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: api-0
+    namespace: payments
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: web-0
+    namespace: frontend
+`,
+		"support/secrets.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: api-tls
+  namespace: payments
+data:
+  tls.key: cHJpdmF0ZQ==
+`,
+		"support/logs/payments/api-0/api.log": "starting\nconnecting\npanic: no database\n",
+		"support/README.txt":                  "collected by support",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dump, err := LoadDump(dir)
+	if err != nil {
+		t.Fatalf("LoadDump: %v", err)
+	}
+	if dump.Metadata != nil {
+		t.Errorf("Metadata = %+v, want none for plain kubectl output", dump.Metadata)
+	}
+
+	snapshot := dump.Snapshot("payments")
+	if len(snapshot.Pods) != 1 || snapshot.Pod("payments", "api-0") == nil {
+		t.Errorf("Pods = %v, want only payments/api-0", snapshot.Pods)
+	}
+	if !errors.Is(snapshot.Err(ResourceNodes), errNotInDump) || snapshot.Err(ResourcePods) != nil || snapshot.Err(ResourceSecrets) != nil {
+		t.Errorf("Errors = %v, want only the missing resources", snapshot.Errors)
+	}
+	if secret := snapshot.Secret("payments", "api-tls"); secret == nil || secret.Data != nil {
+		t.Errorf("Secret = %+v, want metadata without data", secret)
+	}
+	if all := dump.Snapshot(metav1.NamespaceAll); len(all.Pods) != 2 {
+		t.Errorf("captured %d pods across namespaces, want 2", len(all.Pods))
+	}
+
+	logs, err := dump.PodLogs("payments", "api-0", LogOptions{Container: "api", TailLines: 2})
+	if err != nil || logs != "connecting\npanic: no database\n" {
+		t.Errorf("PodLogs = %q, %v, want the last 2 lines", logs, err)
+	}
+}
+
+// decompress returns the concatenated contents of a dump archive's files
+func decompress(t *testing.T, archive []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all bytes.Buffer
+	files := tar.NewReader(gz)
+	for {
+		if _, err := files.Next(); errors.Is(err, io.EOF) {
+			return all.Bytes()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(&all, files); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ContextResult holds the outcome of running an operation against one context
+type ContextResult[T any] struct {
+	Context string
+	Value   T
+	Err     error
+}
+
+// FanOut runs fn once per kubeconfig context with at most parallelism calls
+// in flight. A failure in one context never aborts the others; results are
+// returned in the same order as contexts.
+func FanOut[T any](contexts []string, parallelism int, fn func(context string) (T, error)) []ContextResult[T] {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	results := make([]ContextResult[T], len(contexts))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	for i, name := range contexts {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i].Context = name
+			defer func() {
+				// Keep one misbehaving cluster from taking down the whole run
+				if r := recover(); r != nil {
+					results[i].Err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			results[i].Value, results[i].Err = fn(name)
+		}(i, name)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// WithContext returns a copy of opts targeting the named context
+func (o Options) WithContext(name string) Options {
+	o.Context = name
+	return o
+}
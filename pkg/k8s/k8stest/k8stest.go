@@ -0,0 +1,191 @@
+// Package k8stest provides helpers for testing code built on k8s.Client
+// against fake clientsets loaded from YAML fixtures.
+package k8stest
+
+import (
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// builtinResources is the discovery information served by fake clients for
+// the built-in resources
+var builtinResources = []*metav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}},
+			{Name: "services", SingularName: "service", Kind: "Service", Namespaced: true, ShortNames: []string{"svc"}},
+			{Name: "endpoints", SingularName: "endpoints", Kind: "Endpoints", Namespaced: true, ShortNames: []string{"ep"}},
+			{Name: "events", SingularName: "event", Kind: "Event", Namespaced: true, ShortNames: []string{"ev"}},
+			{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap", Namespaced: true, ShortNames: []string{"cm"}},
+			{Name: "secrets", SingularName: "secret", Kind: "Secret", Namespaced: true},
+			{Name: "persistentvolumeclaims", SingularName: "persistentvolumeclaim", Kind: "PersistentVolumeClaim", Namespaced: true, ShortNames: []string{"pvc"}},
+			{Name: "persistentvolumes", SingularName: "persistentvolume", Kind: "PersistentVolume", ShortNames: []string{"pv"}},
+			{Name: "nodes", SingularName: "node", Kind: "Node", ShortNames: []string{"no"}},
+			{Name: "namespaces", SingularName: "namespace", Kind: "Namespace", ShortNames: []string{"ns"}},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}},
+			{Name: "replicasets", SingularName: "replicaset", Kind: "ReplicaSet", Namespaced: true, ShortNames: []string{"rs"}},
+			{Name: "statefulsets", SingularName: "statefulset", Kind: "StatefulSet", Namespaced: true, ShortNames: []string{"sts"}},
+			{Name: "daemonsets", SingularName: "daemonset", Kind: "DaemonSet", Namespaced: true, ShortNames: []string{"ds"}},
+		},
+	},
+	{
+		GroupVersion: "batch/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "jobs", SingularName: "job", Kind: "Job", Namespaced: true},
+			{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true, ShortNames: []string{"cj"}},
+		},
+	},
+	{
+		GroupVersion: "discovery.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "endpointslices", SingularName: "endpointslice", Kind: "EndpointSlice", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "networking.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "ingresses", SingularName: "ingress", Kind: "Ingress", Namespaced: true, ShortNames: []string{"ing"}},
+			{Name: "networkpolicies", SingularName: "networkpolicy", Kind: "NetworkPolicy", Namespaced: true, ShortNames: []string{"netpol"}},
+		},
+	},
+	{
+		GroupVersion: "storage.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "storageclasses", SingularName: "storageclass", Kind: "StorageClass", ShortNames: []string{"sc"}},
+		},
+	},
+}
+
+// NewClientset returns a fake clientset seeded with the built-in objects in
+// the given fixture files. Its discovery serves the built-in resources plus
+// any CustomResourceDefinitions and custom resources in the fixtures.
+func NewClientset(t testing.TB, fixtures ...string) *fake.Clientset {
+	t.Helper()
+	return newClientset(loadFixtures(t, fixtures...))
+}
+
+// NewClient returns a k8s.Client backed by fake typed and dynamic clients
+// seeded with the objects in the given fixture files
+func NewClient(t testing.TB, namespace string, fixtures ...string) *k8s.Client {
+	t.Helper()
+	return NewClientFromObjects(namespace, loadFixtures(t, fixtures...)...)
+}
+
+// NewClientFromObjects returns a k8s.Client backed by fake typed and dynamic
+// clients seeded with objects
+func NewClientFromObjects(namespace string, objects ...runtime.Object) *k8s.Client {
+	client := k8s.NewClientFromInterface(newClientset(objects), namespace)
+	client.SetDynamicClient(dynamicfake.NewSimpleDynamicClient(scheme.Scheme, objects...))
+	return client
+}
+
+// loadFixtures decodes fixture files, failing the test on error
+func loadFixtures(t testing.TB, fixtures ...string) []runtime.Object {
+	t.Helper()
+
+	objects, err := k8s.LoadObjects(fixtures...)
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	return objects
+}
+
+// newClientset seeds a fake clientset with the typed objects and serves
+// discovery for every object
+func newClientset(objects []runtime.Object) *fake.Clientset {
+	var typed []runtime.Object
+	var custom []*unstructured.Unstructured
+	for _, obj := range objects {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			custom = append(custom, u)
+			continue
+		}
+		typed = append(typed, obj)
+	}
+
+	clientset := fake.NewSimpleClientset(typed...)
+	clientset.Resources = append(append([]*metav1.APIResourceList{}, builtinResources...), customResources(custom)...)
+	return clientset
+}
+
+// customResources builds discovery for custom resources, from their
+// CustomResourceDefinitions when present and by guessing otherwise
+func customResources(objects []*unstructured.Unstructured) []*metav1.APIResourceList {
+	lists := map[string]*metav1.APIResourceList{}
+	seen := map[string]bool{}
+	add := func(groupVersion string, resource metav1.APIResource) {
+		key := groupVersion + "/" + resource.Name
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		list, ok := lists[groupVersion]
+		if !ok {
+			list = &metav1.APIResourceList{GroupVersion: groupVersion}
+			lists[groupVersion] = list
+		}
+		list.APIResources = append(list.APIResources, resource)
+	}
+
+	for _, obj := range objects {
+		if obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		plural, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "plural")
+		singular, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "singular")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		shortNames, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "names", "shortNames")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+		versions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "versions")
+
+		for _, v := range versions {
+			version, _ := v.(map[string]interface{})["name"].(string)
+			add(group+"/"+version, metav1.APIResource{
+				Name:         plural,
+				SingularName: singular,
+				Kind:         kind,
+				Namespaced:   scope != "Cluster",
+				ShortNames:   shortNames,
+			})
+		}
+	}
+
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Kind == "CustomResourceDefinition" {
+			continue
+		}
+		plural, singular := meta.UnsafeGuessKindToResource(gvk)
+		add(gvk.GroupVersion().String(), metav1.APIResource{
+			Name:         plural.Resource,
+			SingularName: singular.Resource,
+			Kind:         gvk.Kind,
+			Namespaced:   obj.GetNamespace() != "",
+		})
+	}
+
+	var result []*metav1.APIResourceList
+	for _, list := range lists {
+		result = append(result, list)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GroupVersion < result[j].GroupVersion })
+	return result
+}
@@ -0,0 +1,155 @@
+package k8s
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-pilot/pkg/output"
+)
+
+// APIMetrics counts Kubernetes API calls and their latency per verb and
+// resource
+type APIMetrics struct {
+	mu    sync.Mutex
+	calls map[string]*CallStats
+}
+
+// CallStats aggregates the calls made for one verb and resource
+type CallStats struct {
+	Key    string
+	Calls  int
+	Errors int
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Average returns the mean latency of the calls
+func (s CallStats) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// NewAPIMetrics creates an empty metrics collector
+func NewAPIMetrics() *APIMetrics {
+	return &APIMetrics{calls: map[string]*CallStats{}}
+}
+
+// Record records one API call
+func (m *APIMetrics) Record(key string, latency time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.calls[key]
+	if !ok {
+		stats = &CallStats{Key: key}
+		m.calls[key] = stats
+	}
+	stats.Calls++
+	stats.Total += latency
+	if latency > stats.Max {
+		stats.Max = latency
+	}
+	if failed {
+		stats.Errors++
+	}
+}
+
+// Stats returns the recorded calls sorted by key
+func (m *APIMetrics) Stats() []CallStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]CallStats, 0, len(m.calls))
+	for _, s := range m.calls {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats
+}
+
+// TotalCalls returns the number of API calls recorded
+func (m *APIMetrics) TotalCalls() int {
+	total := 0
+	for _, s := range m.Stats() {
+		total += s.Calls
+	}
+	return total
+}
+
+// Display displays the call counts and latencies
+func (m *APIMetrics) Display() {
+	stats := m.Stats()
+	if len(stats) == 0 {
+		fmt.Fprintln(output.Stdout, "  No API calls recorded")
+		return
+	}
+
+	for _, s := range stats {
+		fmt.Fprintf(output.Stdout, "  %-32s %4d call(s)  avg %-8s max %-8s", s.Key, s.Calls,
+			s.Average().Round(time.Millisecond), s.Max.Round(time.Millisecond))
+		if s.Errors > 0 {
+			fmt.Fprintf(output.Stdout, "  %d failed", s.Errors)
+		}
+		fmt.Fprintln(output.Stdout)
+	}
+	fmt.Fprintf(output.Stdout, "  Total: %d API call(s)\n", m.TotalCalls())
+}
+
+// metricsTransport records every request made through a rest.Config
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *APIMetrics
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	failed := err != nil || resp.StatusCode >= http.StatusBadRequest
+	t.metrics.Record(requestKey(req), time.Since(start), failed)
+	return resp, err
+}
+
+// requestKey summarizes a request as "VERB resource[/subresource]"
+func requestKey(req *http.Request) string {
+	verb := req.Method
+	if req.URL.Query().Get("watch") == "true" {
+		verb = "WATCH"
+	}
+	return verb + " " + resourceFromPath(req.URL.Path)
+}
+
+// resourceFromPath extracts the resource (and subresource) from an API path
+// such as /api/v1/namespaces/default/pods/web/log
+func resourceFromPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	case parts[0] == "api" || parts[0] == "apis":
+		return "discovery"
+	default:
+		return "/" + strings.Join(parts, "/")
+	}
+
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	switch len(parts) {
+	case 0:
+		return "discovery"
+	case 1, 2:
+		return parts[0]
+	default:
+		return parts[0] + "/" + parts[2]
+	}
+}
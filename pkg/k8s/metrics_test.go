@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResourceFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/namespaces/default/pods", "pods"},
+		{"/api/v1/namespaces/default/pods/web-0/log", "pods/log"},
+		{"/api/v1/nodes", "nodes"},
+		{"/api/v1/namespaces", "namespaces"},
+		{"/api/v1/namespaces/payments", "namespaces"},
+		{"/apis/apps/v1/namespaces/payments/deployments/api", "deployments"},
+		{"/apis/apps/v1/namespaces/payments/deployments/api/scale", "deployments/scale"},
+		{"/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", "selfsubjectaccessreviews"},
+		{"/api", "discovery"},
+		{"/version", "/version"},
+	}
+
+	for _, tt := range tests {
+		if got := resourceFromPath(tt.path); got != tt.want {
+			t.Errorf("resourceFromPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAPIMetrics(t *testing.T) {
+	metrics := NewAPIMetrics()
+	metrics.Record("GET pods", 10*time.Millisecond, false)
+	metrics.Record("GET pods", 30*time.Millisecond, true)
+	metrics.Record("GET events", 5*time.Millisecond, false)
+
+	stats := metrics.Stats()
+	if len(stats) != 2 || stats[1].Key != "GET pods" {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+	pods := stats[1]
+	if pods.Calls != 2 || pods.Errors != 1 || pods.Max != 30*time.Millisecond || pods.Average() != 20*time.Millisecond {
+		t.Errorf("unexpected pod stats: %+v", pods)
+	}
+	if metrics.TotalCalls() != 3 {
+		t.Errorf("TotalCalls() = %d, want 3", metrics.TotalCalls())
+	}
+}
@@ -0,0 +1,76 @@
+package k8s
+
+import (
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodeSelectorMatches reports whether a node satisfies a required node
+// selector, as used by PV node affinity and pod required node affinity.
+// Terms are ORed and the expressions and fields of a term are ANDed. A nil
+// selector matches every node.
+func NodeSelectorMatches(selector *corev1.NodeSelector, node *corev1.Node) bool {
+	if selector == nil {
+		return true
+	}
+	for _, term := range selector.NodeSelectorTerms {
+		if nodeSelectorTermMatches(term, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSelectorTermMatches reports whether a node satisfies every
+// requirement of a term. Empty terms match no nodes.
+func nodeSelectorTermMatches(term corev1.NodeSelectorTerm, node *corev1.Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, requirement := range term.MatchExpressions {
+		value, ok := node.Labels[requirement.Key]
+		if !requirementMatches(requirement, value, ok) {
+			return false
+		}
+	}
+	for _, requirement := range term.MatchFields {
+		// metadata.name is the only supported field
+		if requirement.Key != "metadata.name" || !requirementMatches(requirement, node.Name, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// requirementMatches evaluates one requirement against a label value
+func requirementMatches(requirement corev1.NodeSelectorRequirement, value string, exists bool) bool {
+	switch requirement.Operator {
+	case corev1.NodeSelectorOpIn:
+		return exists && slices.Contains(requirement.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !exists || !slices.Contains(requirement.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return exists
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !exists
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !exists || len(requirement.Values) != 1 {
+			return false
+		}
+		got, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		want, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == corev1.NodeSelectorOpGt {
+			return got > want
+		}
+		return got < want
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeSelectorMatches(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-a",
+		Labels: map[string]string{"topology.kubernetes.io/zone": "us-east-1a", "gpus": "4"},
+	}}
+	term := func(requirements ...corev1.NodeSelectorRequirement) *corev1.NodeSelector {
+		return &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: requirements}}}
+	}
+	zone := func(op corev1.NodeSelectorOperator, values ...string) corev1.NodeSelectorRequirement {
+		return corev1.NodeSelectorRequirement{Key: "topology.kubernetes.io/zone", Operator: op, Values: values}
+	}
+
+	tests := []struct {
+		name     string
+		selector *corev1.NodeSelector
+		want     bool
+	}{
+		{"nil selector", nil, true},
+		{"no terms", &corev1.NodeSelector{}, false},
+		{"empty term", &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}}, false},
+		{"in", term(zone(corev1.NodeSelectorOpIn, "us-east-1b", "us-east-1a")), true},
+		{"not in", term(zone(corev1.NodeSelectorOpNotIn, "us-east-1a")), false},
+		{"exists", term(zone(corev1.NodeSelectorOpExists)), true},
+		{"does not exist", term(corev1.NodeSelectorRequirement{Key: "spot", Operator: corev1.NodeSelectorOpDoesNotExist}), true},
+		{"gt", term(corev1.NodeSelectorRequirement{Key: "gpus", Operator: corev1.NodeSelectorOpGt, Values: []string{"2"}}), true},
+		{"lt", term(corev1.NodeSelectorRequirement{Key: "gpus", Operator: corev1.NodeSelectorOpLt, Values: []string{"2"}}), false},
+		{"anded expressions", term(zone(corev1.NodeSelectorOpIn, "us-east-1a"), zone(corev1.NodeSelectorOpDoesNotExist)), false},
+		{"ored terms", &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+			{MatchExpressions: []corev1.NodeSelectorRequirement{zone(corev1.NodeSelectorOpIn, "us-east-1b")}},
+			{MatchFields: []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-a"}}}},
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NodeSelectorMatches(tt.selector, node); got != tt.want {
+				t.Errorf("NodeSelectorMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// DecodeObjects decodes Kubernetes objects from multi-document YAML or JSON,
+// as produced by "kubectl get -o yaml". Built-in kinds are decoded into
+// typed objects and others into unstructured objects. List objects are
+// flattened into their items.
+func DecodeObjects(data []byte) ([]runtime.Object, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	deserializer := scheme.Codecs.UniversalDeserializer()
+
+	var objects []runtime.Object
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 || string(bytes.TrimSpace(raw.Raw)) == "null" {
+			continue
+		}
+
+		obj, err := decodeObject(deserializer, raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if meta.IsListType(obj) {
+			items, err := meta.ExtractList(obj)
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract list items: %w", err)
+			}
+			for _, item := range items {
+				decoded, err := decodeListItem(item)
+				if err != nil {
+					return nil, err
+				}
+				objects = append(objects, decoded)
+			}
+			continue
+		}
+
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// decodeListItem converts an item of a generic v1 List into a typed object
+func decodeListItem(item runtime.Object) (runtime.Object, error) {
+	unknown, ok := item.(*runtime.Unknown)
+	if !ok {
+		return item, nil
+	}
+
+	return decodeObject(scheme.Codecs.UniversalDeserializer(), unknown.Raw)
+}
+
+// decodeObject decodes a JSON document into a typed object, or into an
+// unstructured object for kinds the client-go scheme doesn't know, such as
+// custom resources
+func decodeObject(deserializer runtime.Decoder, data []byte) (runtime.Object, error) {
+	obj, _, err := deserializer.Decode(data, nil, nil)
+	if err == nil {
+		return obj, nil
+	}
+	if !runtime.IsNotRegisteredError(err) {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+
+	custom := &unstructured.Unstructured{}
+	if err := custom.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	return custom, nil
+}
+
+// LoadObjects reads and decodes Kubernetes objects from YAML or JSON files
+func LoadObjects(paths ...string) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		decoded, err := DecodeObjects(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
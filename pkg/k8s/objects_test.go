@@ -0,0 +1,68 @@
+package k8s
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDecodeObjects(t *testing.T) {
+	data := []byte(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: api
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: api
+---
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+`)
+
+	objects, err := DecodeObjects(data)
+	if err != nil {
+		t.Fatalf("DecodeObjects: %v", err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("decoded %d objects, want 3", len(objects))
+	}
+	if _, ok := objects[0].(*corev1.Pod); !ok {
+		t.Errorf("objects[0] = %T, want *v1.Pod", objects[0])
+	}
+	if _, ok := objects[1].(*appsv1.Deployment); !ok {
+		t.Errorf("objects[1] = %T, want *v1.Deployment", objects[1])
+	}
+	if _, ok := objects[2].(*corev1.Service); !ok {
+		t.Errorf("objects[2] = %T, want *v1.Service", objects[2])
+	}
+}
+
+func TestDecodeObjectsCustomResource(t *testing.T) {
+	objects, err := DecodeObjects([]byte("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n"))
+	if err != nil {
+		t.Fatalf("DecodeObjects: %v", err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("decoded %d objects, want 1", len(objects))
+	}
+	custom, ok := objects[0].(*unstructured.Unstructured)
+	if !ok || custom.GetKind() != "Widget" || custom.GetName() != "w" {
+		t.Errorf("objects[0] = %#v, want unstructured Widget w", objects[0])
+	}
+}
+
+func TestDecodeObjectsInvalid(t *testing.T) {
+	if _, err := DecodeObjects([]byte("kind: [")); err == nil {
+		t.Error("expected an error for malformed YAML")
+	}
+}
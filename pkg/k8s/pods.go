@@ -6,9 +6,23 @@ import (
 	"io"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// PodMetricsResource is the metrics-server resource reporting pod usage
+var PodMetricsResource = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+
+// LogOptions selects which logs GetPodLogs reads
+type LogOptions struct {
+	Container string
+	TailLines int64
+	// Previous reads the logs of the last terminated container instance
+	Previous bool
+}
+
 // PodInfo contains information about a pod
 type PodInfo struct {
 	Name          string
@@ -40,45 +54,50 @@ func (c *Client) GetPods(ctx context.Context, namespace string) ([]PodInfo, erro
 	}
 	
 	var podInfos []PodInfo
-	for _, pod := range pods.Items {
-		podInfo := PodInfo{
-			Name:      pod.Name,
-			Namespace: pod.Namespace,
-			Phase:     pod.Status.Phase,
+	for i := range pods.Items {
+		podInfos = append(podInfos, NewPodInfo(&pods.Items[i]))
+	}
+	
+	return podInfos, nil
+}
+
+// NewPodInfo summarizes a pod's phase, readiness and restarts
+func NewPodInfo(pod *corev1.Pod) PodInfo {
+	podInfo := PodInfo{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Phase:     pod.Status.Phase,
+	}
+	
+	// Calculate readiness and restarts
+	ready := true
+	totalRestarts := int32(0)
+	for _, cs := range pod.Status.ContainerStatuses {
+		if !cs.Ready {
+			ready = false
 		}
+		totalRestarts += cs.RestartCount
 		
-		// Calculate readiness and restarts
-		ready := true
-		totalRestarts := int32(0)
-		for _, cs := range pod.Status.ContainerStatuses {
-			if !cs.Ready {
-				ready = false
-			}
-			totalRestarts += cs.RestartCount
-			
-			state := "running"
-			if cs.State.Waiting != nil {
-				state = cs.State.Waiting.Reason
-			} else if cs.State.Terminated != nil {
-				state = cs.State.Terminated.Reason
-			}
-			
-			podInfo.ContainerInfo = append(podInfo.ContainerInfo, ContainerInfo{
-				Name:         cs.Name,
-				Image:        cs.Image,
-				Ready:        cs.Ready,
-				RestartCount: cs.RestartCount,
-				State:        state,
-			})
+		state := "running"
+		if cs.State.Waiting != nil {
+			state = cs.State.Waiting.Reason
+		} else if cs.State.Terminated != nil {
+			state = cs.State.Terminated.Reason
 		}
 		
-		podInfo.Ready = ready
-		podInfo.Restarts = totalRestarts
-		
-		podInfos = append(podInfos, podInfo)
+		podInfo.ContainerInfo = append(podInfo.ContainerInfo, ContainerInfo{
+			Name:         cs.Name,
+			Image:        cs.Image,
+			Ready:        cs.Ready,
+			RestartCount: cs.RestartCount,
+			State:        state,
+		})
 	}
 	
-	return podInfos, nil
+	podInfo.Ready = ready
+	podInfo.Restarts = totalRestarts
+	
+	return podInfo
 }
 
 // GetPod retrieves a specific pod
@@ -96,17 +115,21 @@ func (c *Client) GetPod(ctx context.Context, name, namespace string) (*corev1.Po
 }
 
 // GetPodLogs retrieves logs from a pod
-func (c *Client) GetPodLogs(ctx context.Context, podName, containerName, namespace string, tailLines int64) (string, error) {
+func (c *Client) GetPodLogs(ctx context.Context, podName, namespace string, options LogOptions) (string, error) {
 	if namespace == "" {
 		namespace = c.namespace
 	}
+	if c.dump != nil {
+		return c.dump.PodLogs(namespace, podName, options)
+	}
 	
 	opts := &corev1.PodLogOptions{
-		Container: containerName,
+		Container: options.Container,
+		Previous:  options.Previous,
 	}
 	
-	if tailLines > 0 {
-		opts.TailLines = &tailLines
+	if options.TailLines > 0 {
+		opts.TailLines = &options.TailLines
 	}
 	
 	req := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, opts)
@@ -116,9 +139,6 @@ func (c *Client) GetPodLogs(ctx context.Context, podName, containerName, namespa
 	}
 	defer podLogs.Close()
 	
-	buf := new(io.Reader)
-	*buf = podLogs
-	
 	bytes, err := io.ReadAll(podLogs)
 	if err != nil {
 		return "", fmt.Errorf("failed to read logs: %w", err)
@@ -127,6 +147,45 @@ func (c *Client) GetPodLogs(ctx context.Context, podName, containerName, namespa
 	return string(bytes), nil
 }
 
+// GetContainerUsage returns each container's current resource usage as
+// reported by metrics-server
+func (c *Client) GetContainerUsage(ctx context.Context, podName, namespace string) (map[string]corev1.ResourceList, error) {
+	if c.dynamic == nil {
+		return nil, fmt.Errorf("no dynamic client configured")
+	}
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	
+	metrics, err := c.dynamic.Resource(PodMetricsResource).Namespace(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics for pod %s: %w", podName, err)
+	}
+	
+	containers, _, err := unstructured.NestedSlice(metrics.Object, "containers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics for pod %s: %w", podName, err)
+	}
+	
+	usage := map[string]corev1.ResourceList{}
+	for _, item := range containers {
+		container, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(container, "name")
+		values, _, _ := unstructured.NestedStringMap(container, "usage")
+		list := corev1.ResourceList{}
+		for key, value := range values {
+			if quantity, err := resource.ParseQuantity(value); err == nil {
+				list[corev1.ResourceName(key)] = quantity
+			}
+		}
+		usage[name] = list
+	}
+	return usage, nil
+}
+
 // GetEvents retrieves events for a namespace
 func (c *Client) GetEvents(ctx context.Context, namespace string) (*corev1.EventList, error) {
 	if namespace == "" {
@@ -154,3 +213,27 @@ func (c *Client) DeletePod(ctx context.Context, name, namespace string) error {
 	
 	return nil
 }
+
+// GetEventsForObject retrieves events whose involved object matches kind and name
+func (c *Client) GetEventsForObject(ctx context.Context, kind, name, namespace string) (*corev1.EventList, error) {
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	
+	selector := fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind, name)
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events for %s/%s: %w", kind, name, err)
+	}
+	
+	// Filter again client-side; not every backend honours field selectors
+	matched := events.Items[:0]
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind == kind && event.InvolvedObject.Name == name {
+			matched = append(matched, event)
+		}
+	}
+	events.Items = matched
+	
+	return events, nil
+}
@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// restMapper lazily builds a discovery-backed RESTMapper that understands
+// plural, singular and short resource names, including CRDs
+type restMapper struct {
+	once   sync.Once
+	mapper meta.RESTMapper
+}
+
+// SetDynamicClient sets the client used to read arbitrary resources, such
+// as a fake dynamic client in tests
+func (c *Client) SetDynamicClient(dynamicClient dynamic.Interface) {
+	c.dynamic = dynamicClient
+}
+
+// RESTMapper returns the discovery-backed mapper for the cluster's resources
+func (c *Client) RESTMapper() meta.RESTMapper {
+	c.mapper.once.Do(func() {
+		cached := memory.NewMemCacheClient(c.clientset.Discovery())
+		c.mapper.mapper = restmapper.NewShortcutExpander(
+			restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil)
+	})
+	return c.mapper.mapper
+}
+
+// ResolveResource maps a kubectl-style resource name ("deploy",
+// "certificates", "Certificate", "rollouts.argoproj.io") to its API resource
+func (c *Client) ResolveResource(name string) (*meta.RESTMapping, error) {
+	mapper := c.RESTMapper()
+	fullySpecified, groupResource := schema.ParseResourceArg(strings.ToLower(name))
+
+	var gvk schema.GroupVersionKind
+	var err error
+	if fullySpecified != nil {
+		gvk, err = mapper.KindFor(*fullySpecified)
+	}
+	if gvk.Empty() {
+		gvk, err = mapper.KindFor(groupResource.WithVersion(""))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unknown resource type %q: %w", name, err)
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unknown resource type %q: %w", name, err)
+	}
+	return mapping, nil
+}
+
+// resourceInterface returns the dynamic client for a mapped resource
+func (c *Client) resourceInterface(mapping *meta.RESTMapping, namespace string) (dynamic.ResourceInterface, error) {
+	if c.dynamic == nil {
+		return nil, fmt.Errorf("no dynamic client configured")
+	}
+
+	resource := c.dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return resource.Namespace(namespace), nil
+	}
+	return resource, nil
+}
+
+// GetObject retrieves any object by its mapped resource. Namespaced objects
+// default to the client's namespace.
+func (c *Client) GetObject(ctx context.Context, mapping *meta.RESTMapping, name, namespace string) (*unstructured.Unstructured, error) {
+	if namespace == "" {
+		namespace = c.namespace
+	}
+
+	resource, err := c.resourceInterface(mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := resource.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", mapping.Resource.Resource, name, err)
+	}
+	return obj, nil
+}
+
+// ListObjects lists every object of a mapped resource, paginated. Pass
+// metav1.NamespaceAll ("") to list across namespaces.
+func (c *Client) ListObjects(ctx context.Context, mapping *meta.RESTMapping, namespace string) ([]unstructured.Unstructured, error) {
+	resource, err := c.resourceInterface(mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := listAll(ctx, func(opts metav1.ListOptions) ([]unstructured.Unstructured, string, error) {
+		list, err := resource.List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.GetContinue(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+	}
+	return objects, nil
+}
@@ -0,0 +1,71 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func TestResolveResource(t *testing.T) {
+	client := k8stest.NewClient(t, "payments", "testdata/certificates.yaml")
+
+	tests := []struct {
+		name       string
+		resource   string
+		group      string
+		namespaced bool
+	}{
+		{"deploy", "deployments", "apps", true},
+		{"Deployment", "deployments", "apps", true},
+		{"po", "pods", "", true},
+		{"nodes", "nodes", "", false},
+		{"cert", "certificates", "cert-manager.io", true},
+		{"Certificate", "certificates", "cert-manager.io", true},
+		{"certificates.cert-manager.io", "certificates", "cert-manager.io", true},
+	}
+
+	for _, tt := range tests {
+		mapping, err := client.ResolveResource(tt.name)
+		if err != nil {
+			t.Errorf("ResolveResource(%q): %v", tt.name, err)
+			continue
+		}
+		if mapping.Resource.Resource != tt.resource || mapping.Resource.Group != tt.group {
+			t.Errorf("ResolveResource(%q) = %v, want %s.%s", tt.name, mapping.Resource, tt.resource, tt.group)
+		}
+		if namespaced := mapping.Scope.Name() == "namespace"; namespaced != tt.namespaced {
+			t.Errorf("ResolveResource(%q) namespaced = %v, want %v", tt.name, namespaced, tt.namespaced)
+		}
+	}
+
+	if _, err := client.ResolveResource("widgets"); err == nil {
+		t.Error("expected an error for an unknown resource")
+	}
+}
+
+func TestGetAndListObjects(t *testing.T) {
+	client := k8stest.NewClient(t, "payments", "testdata/certificates.yaml")
+	ctx := context.Background()
+
+	mapping, err := client.ResolveResource("cert")
+	if err != nil {
+		t.Fatalf("ResolveResource: %v", err)
+	}
+
+	obj, err := client.GetObject(ctx, mapping, "api-tls", "")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	if obj.GetKind() != "Certificate" || obj.GetNamespace() != "payments" {
+		t.Errorf("GetObject = %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	}
+
+	objects, err := client.ListObjects(ctx, mapping, "payments")
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Errorf("listed %d certificates, want 2", len(objects))
+	}
+}
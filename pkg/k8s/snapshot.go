@@ -0,0 +1,799 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Resources captured in a snapshot, used as keys for Snapshot.Errors
+const (
+	ResourcePods            = "pods"
+	ResourceEvents          = "events"
+	ResourceNodes           = "nodes"
+	ResourceDeployments     = "deployments"
+	ResourceReplicaSets     = "replicasets"
+	ResourcePVCs            = "persistentvolumeclaims"
+	ResourceServices        = "services"
+	ResourceEndpoints       = "endpoints"
+	ResourceStatefulSets    = "statefulsets"
+	ResourceDaemonSets      = "daemonsets"
+	ResourceJobs            = "jobs"
+	ResourceCronJobs        = "cronjobs"
+	ResourceEndpointSlices  = "endpointslices"
+	ResourceIngresses       = "ingresses"
+	ResourceNetworkPolicies = "networkpolicies"
+	ResourceSecrets         = "secrets"
+	ResourcePVs             = "persistentvolumes"
+	ResourceStorageClasses  = "storageclasses"
+	ResourceCSINodes        = "csinodes"
+	ResourceServerVersion   = "version"
+)
+
+// snapshotPageSize is the number of objects requested per List call
+const snapshotPageSize int64 = 500
+
+// Snapshot is a consistent in-memory view of the cluster state that
+// analyzers read from instead of querying the API server themselves
+type Snapshot struct {
+	// Namespace is the namespace captured, or "" for all namespaces
+	Namespace string
+	// ServerVersion is the API server's git version, such as "v1.29.3"
+	ServerVersion   string
+	CapturedAt      time.Time
+	Duration        time.Duration
+	Pods            []corev1.Pod
+	Events          []corev1.Event
+	Nodes           []corev1.Node
+	Deployments     []appsv1.Deployment
+	ReplicaSets     []appsv1.ReplicaSet
+	PVCs            []corev1.PersistentVolumeClaim
+	Services        []corev1.Service
+	Endpoints       []corev1.Endpoints
+	StatefulSets    []appsv1.StatefulSet
+	DaemonSets      []appsv1.DaemonSet
+	Jobs            []batchv1.Job
+	CronJobs        []batchv1.CronJob
+	EndpointSlices  []discoveryv1.EndpointSlice
+	Ingresses       []networkingv1.Ingress
+	NetworkPolicies []networkingv1.NetworkPolicy
+	// Secrets holds the metadata and type of the secrets analyzers looked
+	// up, fetched by name on first use; their data is dropped
+	Secrets []corev1.Secret
+	// PVs, StorageClasses and CSINodes are cluster-scoped and always
+	// captured in full
+	PVs            []corev1.PersistentVolume
+	StorageClasses []storagev1.StorageClass
+	CSINodes       []storagev1.CSINode
+	// Errors holds the resources that could not be read, such as those the
+	// user is not permitted to list
+	Errors map[string]error
+
+	pods   map[string]*corev1.Pod
+	events map[string][]*corev1.Event
+
+	// getSecret fetches a secret the first time it is looked up; it is nil
+	// for snapshots loaded from a dump, which hold their secrets up front
+	getSecret     func(namespace, name string) (*corev1.Secret, error)
+	secretMu      sync.Mutex
+	secretFetches map[string]*sync.Once
+	// secretErrors holds the secrets that could not be fetched, by
+	// namespace/name; missing secrets are not errors
+	secretErrors map[string]error
+}
+
+// Snapshot lists pods, events, nodes, workloads, storage, services,
+// endpoints, ingresses and network policies once, paginated, and returns
+// them as a snapshot. Secrets are fetched by name when analyzers look them up.
+// Pass metav1.NamespaceAll ("") to capture every namespace. Pods are
+// required; other resources that fail are recorded in Snapshot.Errors.
+func (c *Client) Snapshot(ctx context.Context, namespace string) (*Snapshot, error) {
+	start := time.Now()
+	snapshot := &Snapshot{Namespace: namespace, CapturedAt: start, Errors: map[string]error{}}
+
+	core := c.clientset.CoreV1()
+	apps := c.clientset.AppsV1()
+	batch := c.clientset.BatchV1()
+	networking := c.clientset.NetworkingV1()
+
+	fetchers := map[string]func() error{
+		ResourcePods: func() (err error) {
+			snapshot.Pods, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.Pod, string, error) {
+				list, err := core.Pods(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceEvents: func() (err error) {
+			snapshot.Events, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.Event, string, error) {
+				list, err := core.Events(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceNodes: func() (err error) {
+			snapshot.Nodes, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.Node, string, error) {
+				list, err := core.Nodes().List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceDeployments: func() (err error) {
+			snapshot.Deployments, err = listAll(ctx, func(opts metav1.ListOptions) ([]appsv1.Deployment, string, error) {
+				list, err := apps.Deployments(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceReplicaSets: func() (err error) {
+			snapshot.ReplicaSets, err = listAll(ctx, func(opts metav1.ListOptions) ([]appsv1.ReplicaSet, string, error) {
+				list, err := apps.ReplicaSets(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourcePVCs: func() (err error) {
+			snapshot.PVCs, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.PersistentVolumeClaim, string, error) {
+				list, err := core.PersistentVolumeClaims(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceServices: func() (err error) {
+			snapshot.Services, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.Service, string, error) {
+				list, err := core.Services(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceEndpoints: func() (err error) {
+			snapshot.Endpoints, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.Endpoints, string, error) {
+				list, err := core.Endpoints(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceStatefulSets: func() (err error) {
+			snapshot.StatefulSets, err = listAll(ctx, func(opts metav1.ListOptions) ([]appsv1.StatefulSet, string, error) {
+				list, err := apps.StatefulSets(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceDaemonSets: func() (err error) {
+			snapshot.DaemonSets, err = listAll(ctx, func(opts metav1.ListOptions) ([]appsv1.DaemonSet, string, error) {
+				list, err := apps.DaemonSets(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceJobs: func() (err error) {
+			snapshot.Jobs, err = listAll(ctx, func(opts metav1.ListOptions) ([]batchv1.Job, string, error) {
+				list, err := batch.Jobs(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceCronJobs: func() (err error) {
+			snapshot.CronJobs, err = listAll(ctx, func(opts metav1.ListOptions) ([]batchv1.CronJob, string, error) {
+				list, err := batch.CronJobs(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceEndpointSlices: func() (err error) {
+			snapshot.EndpointSlices, err = listAll(ctx, func(opts metav1.ListOptions) ([]discoveryv1.EndpointSlice, string, error) {
+				list, err := c.clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceIngresses: func() (err error) {
+			snapshot.Ingresses, err = listAll(ctx, func(opts metav1.ListOptions) ([]networkingv1.Ingress, string, error) {
+				list, err := networking.Ingresses(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceNetworkPolicies: func() (err error) {
+			snapshot.NetworkPolicies, err = listAll(ctx, func(opts metav1.ListOptions) ([]networkingv1.NetworkPolicy, string, error) {
+				list, err := networking.NetworkPolicies(namespace).List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceServerVersion: func() error {
+			info, err := c.clientset.Discovery().ServerVersion()
+			if err != nil {
+				return err
+			}
+			snapshot.ServerVersion = info.GitVersion
+			return nil
+		},
+		ResourcePVs: func() (err error) {
+			snapshot.PVs, err = listAll(ctx, func(opts metav1.ListOptions) ([]corev1.PersistentVolume, string, error) {
+				list, err := core.PersistentVolumes().List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceStorageClasses: func() (err error) {
+			snapshot.StorageClasses, err = listAll(ctx, func(opts metav1.ListOptions) ([]storagev1.StorageClass, string, error) {
+				list, err := c.clientset.StorageV1().StorageClasses().List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+		ResourceCSINodes: func() (err error) {
+			snapshot.CSINodes, err = listAll(ctx, func(opts metav1.ListOptions) ([]storagev1.CSINode, string, error) {
+				list, err := c.clientset.StorageV1().CSINodes().List(ctx, opts)
+				if err != nil {
+					return nil, "", err
+				}
+				return list.Items, list.Continue, nil
+			})
+			return err
+		},
+	}
+
+	// Each fetcher writes its own field, so only the error map is shared
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for resource, fetch := range fetchers {
+		wg.Add(1)
+		go func(resource string, fetch func() error) {
+			defer wg.Done()
+			if err := fetch(); err != nil {
+				mu.Lock()
+				snapshot.Errors[resource] = err
+				mu.Unlock()
+			}
+		}(resource, fetch)
+	}
+	wg.Wait()
+
+	if err := snapshot.Errors[ResourcePods]; err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	snapshot.getSecret = secretGetter(ctx, core)
+
+	snapshot.Duration = time.Since(start)
+	snapshot.index()
+	return snapshot, nil
+}
+
+// secretFetchConcurrency bounds the secrets fetched by name at once
+const secretFetchConcurrency = 8
+
+// secretGetter returns a function that gets a secret by name and drops its
+// data, so that no secret is listed or watched and only get access to the
+// secrets analyzers look at is needed
+func secretGetter(ctx context.Context, secrets corev1client.SecretsGetter) func(namespace, name string) (*corev1.Secret, error) {
+	return func(namespace, name string) (*corev1.Secret, error) {
+		secret, err := secrets.Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		redactSecret(secret)
+		return secret, nil
+	}
+}
+
+// FetchSecrets fetches every secret the snapshot's ingresses and pods
+// reference, for callers that need them all up front, such as dumps
+func (s *Snapshot) FetchSecrets() {
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, secretFetchConcurrency)
+	for _, ref := range s.secretReferences() {
+		wg.Add(1)
+		go func(namespace, name string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			s.lookupSecret(namespace, name)
+		}(ref[0], ref[1])
+	}
+	wg.Wait()
+
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	sort.Slice(s.Secrets, func(i, j int) bool {
+		a, b := s.Secrets[i], s.Secrets[j]
+		return a.Namespace < b.Namespace || (a.Namespace == b.Namespace && a.Name < b.Name)
+	})
+}
+
+// lookupSecret fetches a secret the first time it is looked up. Missing
+// secrets are left out; other failures are recorded for that secret only.
+func (s *Snapshot) lookupSecret(namespace, name string) {
+	key := namespace + "/" + name
+	s.secretMu.Lock()
+	if s.getSecret == nil {
+		s.secretMu.Unlock()
+		return
+	}
+	if s.secretFetches == nil {
+		s.secretFetches = map[string]*sync.Once{}
+	}
+	once, ok := s.secretFetches[key]
+	if !ok {
+		once = &sync.Once{}
+		s.secretFetches[key] = once
+	}
+	s.secretMu.Unlock()
+
+	once.Do(func() {
+		secret, err := s.getSecret(namespace, name)
+		s.secretMu.Lock()
+		defer s.secretMu.Unlock()
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			if s.secretErrors == nil {
+				s.secretErrors = map[string]error{}
+			}
+			s.secretErrors[key] = err
+		default:
+			s.Secrets = append(s.Secrets, *secret)
+		}
+	})
+}
+
+// secretReferences returns the namespace and name of every secret the
+// snapshot's ingress TLS sections and pods refer to
+func (s *Snapshot) secretReferences() [][2]string {
+	seen := map[[2]string]bool{}
+	var refs [][2]string
+	add := func(namespace, name string) {
+		ref := [2]string{namespace, name}
+		if name != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, ingress := range s.Ingresses {
+		for _, tls := range ingress.Spec.TLS {
+			add(ingress.Namespace, tls.SecretName)
+		}
+	}
+	for _, pod := range s.Pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				add(pod.Namespace, volume.Secret.SecretName)
+			}
+			if volume.Projected != nil {
+				for _, source := range volume.Projected.Sources {
+					if source.Secret != nil {
+						add(pod.Namespace, source.Secret.Name)
+					}
+				}
+			}
+		}
+		containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			for _, source := range container.EnvFrom {
+				if source.SecretRef != nil {
+					add(pod.Namespace, source.SecretRef.Name)
+				}
+			}
+			for _, env := range container.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					add(pod.Namespace, env.ValueFrom.SecretKeyRef.Name)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// redactSecret drops a secret's data, including the copy kubectl apply
+// keeps in the last-applied-configuration annotation
+func redactSecret(secret *corev1.Secret) {
+	secret.Data = nil
+	secret.StringData = nil
+	if _, ok := secret.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		annotations := make(map[string]string, len(secret.Annotations))
+		for key, value := range secret.Annotations {
+			if key != corev1.LastAppliedConfigAnnotation {
+				annotations[key] = value
+			}
+		}
+		secret.Annotations = annotations
+	}
+}
+
+// listAll pages through a List call until the server has no more results.
+// An expired continue token restarts the listing from the beginning once.
+func listAll[T any](ctx context.Context, list func(opts metav1.ListOptions) ([]T, string, error)) ([]T, error) {
+	opts := metav1.ListOptions{Limit: snapshotPageSize}
+	restarted := false
+
+	var all []T
+	for {
+		items, next, err := list(opts)
+		if err != nil {
+			if apierrors.IsResourceExpired(err) && opts.Continue != "" && !restarted {
+				restarted = true
+				opts.Continue = ""
+				all = nil
+				continue
+			}
+			return nil, err
+		}
+
+		all = append(all, items...)
+		if next == "" {
+			return all, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		opts.Continue = next
+	}
+}
+
+// index builds the lookup tables used by the snapshot accessors
+func (s *Snapshot) index() {
+	s.pods = make(map[string]*corev1.Pod, len(s.Pods))
+	for i := range s.Pods {
+		pod := &s.Pods[i]
+		s.pods[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	s.events = make(map[string][]*corev1.Event)
+	for i := range s.Events {
+		event := &s.Events[i]
+		namespace := event.InvolvedObject.Namespace
+		if namespace == "" {
+			namespace = event.Namespace
+		}
+		key := objectKey(event.InvolvedObject.Kind, namespace, event.InvolvedObject.Name)
+		s.events[key] = append(s.events[key], event)
+	}
+}
+
+// objectKey identifies an object within the snapshot
+func objectKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// Pod returns the named pod, or nil if it is not in the snapshot
+func (s *Snapshot) Pod(namespace, name string) *corev1.Pod {
+	return s.pods[namespace+"/"+name]
+}
+
+// Deployment returns the named deployment, or nil if it is not in the snapshot
+func (s *Snapshot) Deployment(namespace, name string) *appsv1.Deployment {
+	for i := range s.Deployments {
+		if s.Deployments[i].Namespace == namespace && s.Deployments[i].Name == name {
+			return &s.Deployments[i]
+		}
+	}
+	return nil
+}
+
+// StatefulSet returns the named statefulset, or nil if it is not in the snapshot
+func (s *Snapshot) StatefulSet(namespace, name string) *appsv1.StatefulSet {
+	for i := range s.StatefulSets {
+		if s.StatefulSets[i].Namespace == namespace && s.StatefulSets[i].Name == name {
+			return &s.StatefulSets[i]
+		}
+	}
+	return nil
+}
+
+// DaemonSet returns the named daemonset, or nil if it is not in the snapshot
+func (s *Snapshot) DaemonSet(namespace, name string) *appsv1.DaemonSet {
+	for i := range s.DaemonSets {
+		if s.DaemonSets[i].Namespace == namespace && s.DaemonSets[i].Name == name {
+			return &s.DaemonSets[i]
+		}
+	}
+	return nil
+}
+
+// Job returns the named job, or nil if it is not in the snapshot
+func (s *Snapshot) Job(namespace, name string) *batchv1.Job {
+	for i := range s.Jobs {
+		if s.Jobs[i].Namespace == namespace && s.Jobs[i].Name == name {
+			return &s.Jobs[i]
+		}
+	}
+	return nil
+}
+
+// CronJob returns the named cronjob, or nil if it is not in the snapshot
+func (s *Snapshot) CronJob(namespace, name string) *batchv1.CronJob {
+	for i := range s.CronJobs {
+		if s.CronJobs[i].Namespace == namespace && s.CronJobs[i].Name == name {
+			return &s.CronJobs[i]
+		}
+	}
+	return nil
+}
+
+// Service returns the named service, or nil if it is not in the snapshot
+func (s *Snapshot) Service(namespace, name string) *corev1.Service {
+	for i := range s.Services {
+		if s.Services[i].Namespace == namespace && s.Services[i].Name == name {
+			return &s.Services[i]
+		}
+	}
+	return nil
+}
+
+// Ingress returns the named ingress, or nil if it is not in the snapshot
+func (s *Snapshot) Ingress(namespace, name string) *networkingv1.Ingress {
+	for i := range s.Ingresses {
+		if s.Ingresses[i].Namespace == namespace && s.Ingresses[i].Name == name {
+			return &s.Ingresses[i]
+		}
+	}
+	return nil
+}
+
+// Secret returns the named secret's metadata, or nil if it does not exist
+// or could not be fetched; see SecretErr
+func (s *Snapshot) Secret(namespace, name string) *corev1.Secret {
+	s.lookupSecret(namespace, name)
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	for i := range s.Secrets {
+		if s.Secrets[i].Namespace == namespace && s.Secrets[i].Name == name {
+			return &s.Secrets[i]
+		}
+	}
+	return nil
+}
+
+// EndpointSlicesFor returns the endpoint slices of a service
+func (s *Snapshot) EndpointSlicesFor(namespace, service string) []*discoveryv1.EndpointSlice {
+	var slices []*discoveryv1.EndpointSlice
+	for i := range s.EndpointSlices {
+		slice := &s.EndpointSlices[i]
+		if slice.Namespace == namespace && slice.Labels[discoveryv1.LabelServiceName] == service {
+			slices = append(slices, slice)
+		}
+	}
+	return slices
+}
+
+// PV returns the named persistent volume, or nil if it is not in the
+// snapshot
+func (s *Snapshot) PV(name string) *corev1.PersistentVolume {
+	for i := range s.PVs {
+		if s.PVs[i].Name == name {
+			return &s.PVs[i]
+		}
+	}
+	return nil
+}
+
+// Node returns the named node, or nil if it is not in the snapshot
+func (s *Snapshot) Node(name string) *corev1.Node {
+	for i := range s.Nodes {
+		if s.Nodes[i].Name == name {
+			return &s.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// StorageClass returns the named storage class, or nil if it is not in the
+// snapshot
+func (s *Snapshot) StorageClass(name string) *storagev1.StorageClass {
+	for i := range s.StorageClasses {
+		if s.StorageClasses[i].Name == name {
+			return &s.StorageClasses[i]
+		}
+	}
+	return nil
+}
+
+// DefaultStorageClasses returns the storage classes annotated as the
+// cluster default
+func (s *Snapshot) DefaultStorageClasses() []*storagev1.StorageClass {
+	var defaults []*storagev1.StorageClass
+	for i := range s.StorageClasses {
+		annotations := s.StorageClasses[i].Annotations
+		if annotations["storageclass.kubernetes.io/is-default-class"] == "true" ||
+			annotations["storageclass.beta.kubernetes.io/is-default-class"] == "true" {
+			defaults = append(defaults, &s.StorageClasses[i])
+		}
+	}
+	return defaults
+}
+
+// CSINode returns the CSI registration of a node, or nil if it is not in
+// the snapshot
+func (s *Snapshot) CSINode(name string) *storagev1.CSINode {
+	for i := range s.CSINodes {
+		if s.CSINodes[i].Name == name {
+			return &s.CSINodes[i]
+		}
+	}
+	return nil
+}
+
+// PVC returns the named claim, or nil if it is not in the snapshot
+func (s *Snapshot) PVC(namespace, name string) *corev1.PersistentVolumeClaim {
+	for i := range s.PVCs {
+		if s.PVCs[i].Namespace == namespace && s.PVCs[i].Name == name {
+			return &s.PVCs[i]
+		}
+	}
+	return nil
+}
+
+// JobsOwnedBy returns the jobs controlled by an owner
+func (s *Snapshot) JobsOwnedBy(owner metav1.Object) []*batchv1.Job {
+	var owned []*batchv1.Job
+	for i := range s.Jobs {
+		if IsControlledBy(&s.Jobs[i], owner) {
+			owned = append(owned, &s.Jobs[i])
+		}
+	}
+	return owned
+}
+
+// ReplicaSetsOwnedBy returns the replicasets controlled by an owner
+func (s *Snapshot) ReplicaSetsOwnedBy(owner metav1.Object) []*appsv1.ReplicaSet {
+	var owned []*appsv1.ReplicaSet
+	for i := range s.ReplicaSets {
+		if IsControlledBy(&s.ReplicaSets[i], owner) {
+			owned = append(owned, &s.ReplicaSets[i])
+		}
+	}
+	return owned
+}
+
+// PodsOwnedBy returns the pods controlled by an owner
+func (s *Snapshot) PodsOwnedBy(owner metav1.Object) []*corev1.Pod {
+	var owned []*corev1.Pod
+	for i := range s.Pods {
+		if IsControlledBy(&s.Pods[i], owner) {
+			owned = append(owned, &s.Pods[i])
+		}
+	}
+	return owned
+}
+
+// IsControlledBy reports whether obj's controller reference points at owner
+func IsControlledBy(obj, owner metav1.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+	return ref != nil && ref.UID == owner.GetUID() && obj.GetNamespace() == owner.GetNamespace()
+}
+
+// EventsFor returns the events involving an object, oldest first
+func (s *Snapshot) EventsFor(kind, namespace, name string) []corev1.Event {
+	matched := s.events[objectKey(kind, namespace, name)]
+
+	events := make([]corev1.Event, 0, len(matched))
+	for _, event := range matched {
+		events = append(events, *event)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	return events
+}
+
+// eventTime returns the most recent time an event was observed
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// Err returns the error that prevented reading a resource, if any
+func (s *Snapshot) Err(resource string) error {
+	return s.Errors[resource]
+}
+
+// SecretErr returns the error that kept the named secret from being
+// fetched, if any
+func (s *Snapshot) SecretErr(namespace, name string) error {
+	if err := s.Err(ResourceSecrets); err != nil {
+		return err
+	}
+	s.lookupSecret(namespace, name)
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	return s.secretErrors[namespace+"/"+name]
+}
+
+// Summary describes the size of the snapshot
+func (s *Snapshot) Summary() string {
+	parts := []string{
+		fmt.Sprintf("%d pods", len(s.Pods)),
+		fmt.Sprintf("%d events", len(s.Events)),
+		fmt.Sprintf("%d nodes", len(s.Nodes)),
+		fmt.Sprintf("%d deployments", len(s.Deployments)),
+		fmt.Sprintf("%d replicasets", len(s.ReplicaSets)),
+		fmt.Sprintf("%d PVCs", len(s.PVCs)),
+		fmt.Sprintf("%d services", len(s.Services)),
+		fmt.Sprintf("%d endpoints", len(s.Endpoints)),
+		fmt.Sprintf("%d statefulsets", len(s.StatefulSets)),
+		fmt.Sprintf("%d daemonsets", len(s.DaemonSets)),
+		fmt.Sprintf("%d jobs", len(s.Jobs)),
+		fmt.Sprintf("%d cronjobs", len(s.CronJobs)),
+		fmt.Sprintf("%d endpointslices", len(s.EndpointSlices)),
+		fmt.Sprintf("%d ingresses", len(s.Ingresses)),
+		fmt.Sprintf("%d networkpolicies", len(s.NetworkPolicies)),
+		fmt.Sprintf("%d secrets", len(s.Secrets)),
+		fmt.Sprintf("%d persistentvolumes", len(s.PVs)),
+		fmt.Sprintf("%d storageclasses", len(s.StorageClasses)),
+		fmt.Sprintf("%d csinodes", len(s.CSINodes)),
+	}
+	return strings.Join(parts, ", ")
+}
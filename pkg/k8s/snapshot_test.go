@@ -0,0 +1,293 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func snapshotObjects() []runtime.Object {
+	return []runtime.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-0", Namespace: "payments"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "frontend"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-tls", Namespace: "payments"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.key": []byte("private")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "deploy-token", Namespace: "payments"},
+			Data:       map[string][]byte{"token": []byte("unreferenced")},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"},
+			Spec: networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"api.example.com"}, SecretName: "api-tls"},
+				{Hosts: []string{"www.example.com"}, SecretName: "www-tls"},
+			}},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "api-0.2", Namespace: "payments"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api-0", Namespace: "payments"},
+			Reason:         "BackOff",
+			LastTimestamp:  metav1.NewTime(time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)),
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "api-0.1", Namespace: "payments"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api-0"},
+			Reason:         "Pulled",
+			LastTimestamp:  metav1.NewTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+		},
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	clientset := fake.NewSimpleClientset(snapshotObjects()...)
+	client := NewClientFromInterface(clientset, "payments")
+
+	snapshot, err := client.Snapshot(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	if len(snapshot.Pods) != 1 || snapshot.Pod("payments", "api-0") == nil {
+		t.Errorf("Pods = %v, want only payments/api-0", snapshot.Pods)
+	}
+	if len(snapshot.Nodes) != 1 || len(snapshot.Deployments) != 1 {
+		t.Errorf("unexpected snapshot contents: %s", snapshot.Summary())
+	}
+
+	events := snapshot.EventsFor("Pod", "payments", "api-0")
+	if len(events) != 2 || events[0].Reason != "Pulled" || events[1].Reason != "BackOff" {
+		t.Errorf("EventsFor = %v, want Pulled then BackOff", events)
+	}
+
+	if gets := secretGets(clientset); gets != 0 {
+		t.Errorf("%d secrets fetched before any was looked up", gets)
+	}
+	secret := snapshot.Secret("payments", "api-tls")
+	if secret == nil || secret.Type != corev1.SecretTypeTLS || secret.Data != nil {
+		t.Errorf("Secret = %+v, want TLS secret metadata without data", secret)
+	}
+	if snapshot.Secret("payments", "www-tls") != nil || snapshot.SecretErr("payments", "www-tls") != nil {
+		t.Error("missing secret www-tls should be absent, not an error")
+	}
+	snapshot.Secret("payments", "api-tls")
+	if gets := secretGets(clientset); gets != 2 || len(snapshot.Secrets) != 1 {
+		t.Errorf("fetched %d secrets into %v, want api-tls and www-tls fetched once", gets, snapshot.Secrets)
+	}
+	assertSecretsNotListed(t, clientset)
+}
+
+// secretGets counts the secrets fetched by name
+func secretGets(clientset *fake.Clientset) int {
+	gets := 0
+	for _, action := range clientset.Actions() {
+		if action.GetResource().Resource == "secrets" && action.GetVerb() == "get" {
+			gets++
+		}
+	}
+	return gets
+}
+
+// assertSecretsNotListed fails if secrets were listed or watched rather
+// than fetched by name
+func assertSecretsNotListed(t *testing.T, clientset *fake.Clientset) {
+	t.Helper()
+	for _, action := range clientset.Actions() {
+		if action.GetResource().Resource == "secrets" && action.GetVerb() != "get" {
+			t.Errorf("secrets were read with %s, want only gets by name", action.GetVerb())
+		}
+	}
+}
+
+func TestSnapshotForbiddenSecret(t *testing.T) {
+	clientset := fake.NewSimpleClientset(snapshotObjects()...)
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "api-tls" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "api-tls", fmt.Errorf("denied"))
+	})
+	client := NewClientFromInterface(clientset, "payments")
+
+	snapshot, err := client.Snapshot(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if snapshot.Secret("payments", "api-tls") != nil || !IsForbidden(snapshot.SecretErr("payments", "api-tls")) {
+		t.Errorf("SecretErr(api-tls) = %v, want forbidden", snapshot.SecretErr("payments", "api-tls"))
+	}
+	// Other secrets are still inspected
+	if snapshot.Err(ResourceSecrets) != nil || snapshot.SecretErr("payments", "deploy-token") != nil || snapshot.Secret("payments", "deploy-token") == nil {
+		t.Errorf("only api-tls should fail, got Err(secrets) = %v", snapshot.Err(ResourceSecrets))
+	}
+}
+
+func TestSnapshotAllNamespaces(t *testing.T) {
+	client := NewClientFromInterface(fake.NewSimpleClientset(snapshotObjects()...), "payments")
+
+	snapshot, err := client.Snapshot(context.Background(), metav1.NamespaceAll)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snapshot.Pods) != 2 {
+		t.Errorf("captured %d pods, want 2", len(snapshot.Pods))
+	}
+}
+
+func TestSnapshotForbiddenResource(t *testing.T) {
+	clientset := fake.NewSimpleClientset(snapshotObjects()...)
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", fmt.Errorf("denied"))
+	})
+	client := NewClientFromInterface(clientset, "payments")
+
+	snapshot, err := client.Snapshot(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !IsForbidden(snapshot.Err(ResourceNodes)) {
+		t.Errorf("Err(nodes) = %v, want forbidden", snapshot.Err(ResourceNodes))
+	}
+	if len(snapshot.Pods) != 1 {
+		t.Errorf("pods should still be captured")
+	}
+}
+
+func TestListAllPaginates(t *testing.T) {
+	pages := map[string][]int{"": {1, 2}, "page2": {3, 4}, "page3": {5}}
+	next := map[string]string{"": "page2", "page2": "page3"}
+
+	calls := 0
+	items, err := listAll(context.Background(), func(opts metav1.ListOptions) ([]int, string, error) {
+		calls++
+		if opts.Limit != snapshotPageSize {
+			t.Errorf("Limit = %d, want %d", opts.Limit, snapshotPageSize)
+		}
+		return pages[opts.Continue], next[opts.Continue], nil
+	})
+	if err != nil {
+		t.Fatalf("listAll: %v", err)
+	}
+	if calls != 3 || len(items) != 5 {
+		t.Errorf("got %v in %d calls, want 5 items in 3 calls", items, calls)
+	}
+}
+
+func TestListAllRestartsExpiredContinue(t *testing.T) {
+	expired := true
+	items, err := listAll(context.Background(), func(opts metav1.ListOptions) ([]int, string, error) {
+		switch {
+		case opts.Continue == "":
+			return []int{1}, "next", nil
+		case expired:
+			expired = false
+			return nil, "", apierrors.NewResourceExpired("continue token expired")
+		default:
+			return []int{2}, "", nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("listAll: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("items = %v, want [1 2]", items)
+	}
+}
+
+func TestSnapshotWatcher(t *testing.T) {
+	clientset := fake.NewSimpleClientset(snapshotObjects()...)
+	client := NewClientFromInterface(clientset, "payments")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	watcher := client.NewSnapshotWatcher("payments", 0)
+	changed := make(chan string, 100)
+	if err := watcher.OnChange(func(resource string) { changed <- resource }); err != nil {
+		t.Fatalf("OnChange: %v", err)
+	}
+	if err := watcher.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	snapshot, err := watcher.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if snapshot.Pod("payments", "api-0") == nil || len(snapshot.EventsFor("Pod", "payments", "api-0")) != 2 {
+		t.Errorf("unexpected watcher snapshot: %s", snapshot.Summary())
+	}
+	if secret := snapshot.Secret("payments", "api-tls"); secret == nil || secret.Data != nil {
+		t.Errorf("Secret = %+v, want metadata without data", secret)
+	}
+	assertSecretsNotListed(t, clientset)
+
+	// Later snapshots reuse the fetched secret
+	snapshot, err = watcher.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if snapshot.Secret("payments", "api-tls") == nil || secretGets(clientset) != 1 {
+		t.Errorf("fetched secrets %d times, want the cached api-tls reused", secretGets(clientset))
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "payments"}}
+	if _, err := clientset.CoreV1().Pods("payments").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		snapshot, _ := watcher.Snapshot(ctx)
+		if snapshot.Pod("payments", "api-1") != nil {
+			break
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatal("watcher did not observe the new pod")
+		}
+	}
+}
+
+func TestSnapshotWatcherForbiddenResource(t *testing.T) {
+	clientset := fake.NewSimpleClientset(snapshotObjects()...)
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", fmt.Errorf("denied"))
+	})
+	client := NewClientFromInterface(clientset, "payments")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	watcher := client.NewSnapshotWatcher("payments", 0)
+	if err := watcher.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	snapshot, err := watcher.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !IsForbidden(snapshot.Err(ResourceNodes)) {
+		t.Errorf("Err(nodes) = %v, want forbidden", snapshot.Err(ResourceNodes))
+	}
+	if snapshot.Pod("payments", "api-0") == nil {
+		t.Errorf("unexpected watcher snapshot: %s", snapshot.Summary())
+	}
+}
@@ -0,0 +1,305 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/informers"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SnapshotWatcher keeps the snapshot resources up to date with shared
+// informers, so repeated snapshots cost no API calls. Secrets are never
+// watched; the ones analyzers look up are fetched and cached for a while.
+type SnapshotWatcher struct {
+	namespace string
+	factory   informers.SharedInformerFactory
+	informers map[string]cache.SharedIndexInformer
+	discovery discovery.ServerVersionInterface
+	secrets   corev1client.SecretsGetter
+
+	serverVersion string
+	versionErr    error
+
+	// errors holds the resources that can't be listed, such as those the
+	// user is not permitted to read; their informers never sync
+	mu     sync.Mutex
+	errors map[string]error
+
+	// secrets fetched by earlier snapshots, by namespace/name
+	secretMu    sync.Mutex
+	secretCache map[string]cachedSecret
+}
+
+// secretCacheTTL is how long a fetched secret is reused by later snapshots,
+// which bounds how stale an unwatched secret can be
+const secretCacheTTL = time.Minute
+
+// cachedSecret is a secret, or its absence, fetched by a watcher snapshot
+type cachedSecret struct {
+	secret    *corev1.Secret
+	err       error
+	fetchedAt time.Time
+}
+
+// NewSnapshotWatcher creates a watcher for a namespace, or for every
+// namespace when namespace is metav1.NamespaceAll ("")
+func (c *Client) NewSnapshotWatcher(namespace string, resync time.Duration) *SnapshotWatcher {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, resync, informers.WithNamespace(namespace))
+	core := factory.Core().V1()
+	apps := factory.Apps().V1()
+	batch := factory.Batch().V1()
+	networking := factory.Networking().V1()
+
+	w := &SnapshotWatcher{
+		namespace:   namespace,
+		factory:     factory,
+		discovery:   c.clientset.Discovery(),
+		secrets:     c.clientset.CoreV1(),
+		errors:      map[string]error{},
+		secretCache: map[string]cachedSecret{},
+		informers: map[string]cache.SharedIndexInformer{
+			ResourcePods:            core.Pods().Informer(),
+			ResourceEvents:          core.Events().Informer(),
+			ResourceNodes:           core.Nodes().Informer(),
+			ResourceDeployments:     apps.Deployments().Informer(),
+			ResourceReplicaSets:     apps.ReplicaSets().Informer(),
+			ResourcePVCs:            core.PersistentVolumeClaims().Informer(),
+			ResourceServices:        core.Services().Informer(),
+			ResourceEndpoints:       core.Endpoints().Informer(),
+			ResourceStatefulSets:    apps.StatefulSets().Informer(),
+			ResourceDaemonSets:      apps.DaemonSets().Informer(),
+			ResourceJobs:            batch.Jobs().Informer(),
+			ResourceCronJobs:        batch.CronJobs().Informer(),
+			ResourceEndpointSlices:  factory.Discovery().V1().EndpointSlices().Informer(),
+			ResourceIngresses:       networking.Ingresses().Informer(),
+			ResourceNetworkPolicies: networking.NetworkPolicies().Informer(),
+			ResourcePVs:             core.PersistentVolumes().Informer(),
+			ResourceStorageClasses:  factory.Storage().V1().StorageClasses().Informer(),
+			ResourceCSINodes:        factory.Storage().V1().CSINodes().Informer(),
+		},
+	}
+
+	for resource, informer := range w.informers {
+		resource := resource
+		// Forbidden and unserved resources would retry forever and never
+		// sync; record them instead, as Client.Snapshot does
+		_ = informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+			if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) || apierrors.IsNotFound(err) {
+				w.mu.Lock()
+				w.errors[resource] = err
+				w.mu.Unlock()
+			}
+		})
+	}
+	return w
+}
+
+// Err returns the error that keeps a resource from being watched, if any
+func (w *SnapshotWatcher) Err(resource string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if informer, ok := w.informers[resource]; ok && informer.HasSynced() {
+		return nil
+	}
+	return w.errors[resource]
+}
+
+// OnChange registers a callback invoked whenever a watched object is added,
+// updated or deleted. Register callbacks before calling Start.
+func (w *SnapshotWatcher) OnChange(callback func(resource string)) error {
+	for resource, informer := range w.informers {
+		resource := resource
+		notify := func(interface{}) { callback(resource) }
+		_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    notify,
+			UpdateFunc: func(_, obj interface{}) { notify(obj) },
+			DeleteFunc: notify,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+// Start starts the informers and waits until their caches are synced, or
+// until listing a resource failed for good. Pods are required. The
+// informers stop when ctx is cancelled.
+func (w *SnapshotWatcher) Start(ctx context.Context) error {
+	if info, err := w.discovery.ServerVersion(); err != nil {
+		w.versionErr = err
+	} else {
+		w.serverVersion = info.GitVersion
+	}
+	w.factory.Start(ctx.Done())
+
+	for resource, informer := range w.informers {
+		informer := informer
+		resource := resource
+		synced := func() bool { return informer.HasSynced() || w.Err(resource) != nil }
+		if !cache.WaitForCacheSync(ctx.Done(), synced) {
+			return fmt.Errorf("failed to sync %s cache", resource)
+		}
+	}
+	if err := w.Err(ResourcePods); err != nil {
+		return fmt.Errorf("failed to watch pods: %w", err)
+	}
+	return nil
+}
+
+// Snapshot returns the current contents of the informer caches. Secrets
+// are fetched when analyzers look them up, through the watcher's cache.
+func (w *SnapshotWatcher) Snapshot(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{Namespace: w.namespace, ServerVersion: w.serverVersion, CapturedAt: time.Now(), Errors: map[string]error{}}
+	if w.versionErr != nil {
+		snapshot.Errors[ResourceServerVersion] = w.versionErr
+	}
+	for resource := range w.informers {
+		if err := w.Err(resource); err != nil {
+			snapshot.Errors[resource] = err
+		}
+	}
+	core := w.factory.Core().V1()
+	apps := w.factory.Apps().V1()
+	batch := w.factory.Batch().V1()
+	networking := w.factory.Networking().V1()
+
+	pods, err := core.Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	snapshot.Pods = derefAll(pods)
+
+	if events, err := core.Events().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceEvents] = err
+	} else {
+		snapshot.Events = derefAll(events)
+	}
+	if nodes, err := core.Nodes().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceNodes] = err
+	} else {
+		snapshot.Nodes = derefAll(nodes)
+	}
+	if deployments, err := apps.Deployments().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceDeployments] = err
+	} else {
+		snapshot.Deployments = derefAll(deployments)
+	}
+	if replicaSets, err := apps.ReplicaSets().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceReplicaSets] = err
+	} else {
+		snapshot.ReplicaSets = derefAll(replicaSets)
+	}
+	if pvcs, err := core.PersistentVolumeClaims().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourcePVCs] = err
+	} else {
+		snapshot.PVCs = derefAll(pvcs)
+	}
+	if services, err := core.Services().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceServices] = err
+	} else {
+		snapshot.Services = derefAll(services)
+	}
+	if endpoints, err := core.Endpoints().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceEndpoints] = err
+	} else {
+		snapshot.Endpoints = derefAll(endpoints)
+	}
+	if statefulSets, err := apps.StatefulSets().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceStatefulSets] = err
+	} else {
+		snapshot.StatefulSets = derefAll(statefulSets)
+	}
+	if daemonSets, err := apps.DaemonSets().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceDaemonSets] = err
+	} else {
+		snapshot.DaemonSets = derefAll(daemonSets)
+	}
+	if jobs, err := batch.Jobs().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceJobs] = err
+	} else {
+		snapshot.Jobs = derefAll(jobs)
+	}
+	if cronJobs, err := batch.CronJobs().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceCronJobs] = err
+	} else {
+		snapshot.CronJobs = derefAll(cronJobs)
+	}
+	if slices, err := w.factory.Discovery().V1().EndpointSlices().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceEndpointSlices] = err
+	} else {
+		snapshot.EndpointSlices = derefAll(slices)
+	}
+	if ingresses, err := networking.Ingresses().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceIngresses] = err
+	} else {
+		snapshot.Ingresses = derefAll(ingresses)
+	}
+	if policies, err := networking.NetworkPolicies().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceNetworkPolicies] = err
+	} else {
+		snapshot.NetworkPolicies = derefAll(policies)
+	}
+	if pvs, err := core.PersistentVolumes().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourcePVs] = err
+	} else {
+		snapshot.PVs = derefAll(pvs)
+	}
+	if classes, err := w.factory.Storage().V1().StorageClasses().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceStorageClasses] = err
+	} else {
+		snapshot.StorageClasses = derefAll(classes)
+	}
+	if csiNodes, err := w.factory.Storage().V1().CSINodes().Lister().List(labels.Everything()); err != nil {
+		snapshot.Errors[ResourceCSINodes] = err
+	} else {
+		snapshot.CSINodes = derefAll(csiNodes)
+	}
+
+	snapshot.getSecret = w.cachedSecretGetter(ctx)
+
+	snapshot.index()
+	return snapshot, nil
+}
+
+// cachedSecretGetter returns a function that gets a secret by name, reusing
+// the ones fetched within secretCacheTTL. Other failures than a missing
+// secret are not cached.
+func (w *SnapshotWatcher) cachedSecretGetter(ctx context.Context) func(namespace, name string) (*corev1.Secret, error) {
+	get := secretGetter(ctx, w.secrets)
+	return func(namespace, name string) (*corev1.Secret, error) {
+		key := namespace + "/" + name
+		w.secretMu.Lock()
+		cached, ok := w.secretCache[key]
+		w.secretMu.Unlock()
+		if ok && time.Since(cached.fetchedAt) < secretCacheTTL {
+			return cached.secret, cached.err
+		}
+
+		secret, err := get(namespace, name)
+		if err == nil || apierrors.IsNotFound(err) {
+			w.secretMu.Lock()
+			w.secretCache[key] = cachedSecret{secret: secret, err: err, fetchedAt: time.Now()}
+			w.secretMu.Unlock()
+		}
+		return secret, err
+	}
+}
+
+// derefAll copies objects out of an informer cache, which must not be
+// mutated
+func derefAll[T any](items []*T) []T {
+	copied := make([]T, 0, len(items))
+	for _, item := range items {
+		copied = append(copied, *item)
+	}
+	return copied
+}
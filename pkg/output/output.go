@@ -0,0 +1,195 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion is the version of the machine-readable document schemas.
+// Fields are only added within a version; renaming or removing one bumps it.
+const APIVersion = "k8s-pilot/v1"
+
+// Format is an output format
+type Format string
+
+// Output formats
+const (
+	FormatText     Format = "text"
+	FormatJSON     Format = "json"
+	FormatYAML     Format = "yaml"
+	FormatMarkdown Format = "markdown"
+	FormatSARIF    Format = "sarif"
+	FormatJUnit    Format = "junit"
+)
+
+// ParseFormat parses an --output value, accepting only the given formats.
+// An empty value selects text.
+func ParseFormat(value string, supported ...Format) (Format, error) {
+	format := Format(strings.ToLower(value))
+	switch format {
+	case "":
+		return FormatText, nil
+	case "md":
+		format = FormatMarkdown
+	case "yml":
+		format = FormatYAML
+	}
+	for _, candidate := range supported {
+		if candidate == format {
+			return format, nil
+		}
+	}
+	names := make([]string, len(supported))
+	for i, candidate := range supported {
+		names[i] = string(candidate)
+	}
+	return "", fmt.Errorf("unsupported output format %q (want one of: %s)", value, strings.Join(names, ", "))
+}
+
+// Structured reports whether a format is meant for other tools rather than
+// people, so progress messages must be kept out of standard output
+func (f Format) Structured() bool {
+	return f != FormatText && f != FormatMarkdown
+}
+
+// Encode writes a document as JSON, YAML, SARIF (JSON) or JUnit (XML)
+func Encode(w io.Writer, format Format, document interface{}) error {
+	if format == FormatJUnit {
+		data, err := xml.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode junit output: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+		return err
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s output: %w", format, err)
+	}
+
+	switch format {
+	case FormatJSON, FormatSARIF:
+		data = append(data, '\n')
+	case FormatYAML:
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return fmt.Errorf("failed to encode yaml output: %w", err)
+		}
+	default:
+		return fmt.Errorf("format %s is not a document format", format)
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// Stdout is where text output is written. When standard output is not a
+// terminal, or NO_COLOR is set, emoji are dropped so the text can be piped
+// into other tools.
+var Stdout io.Writer = Text(os.Stdout)
+
+// Text returns a writer for text output to f, plain unless f is a terminal
+func Text(f *os.File) io.Writer {
+	if os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(f.Fd())) {
+		return f
+	}
+	return Plain(f)
+}
+
+// Plain returns a writer that drops emoji and ANSI escape sequences
+func Plain(w io.Writer) io.Writer {
+	return &plainWriter{w: w, lineStart: true}
+}
+
+// plainWriter strips decorations from text as it is written
+type plainWriter struct {
+	w io.Writer
+	// lineStart is set while only blanks were written on the current line
+	lineStart bool
+	// trimSpace drops the blanks following a dropped emoji
+	trimSpace bool
+	last      rune
+}
+
+// replacements swaps symbols whose meaning would be lost for plain ones
+var replacements = strings.NewReplacer("🚫", "✗", "⚠️", "⚠")
+
+func (p *plainWriter) Write(data []byte) (int, error) {
+	text := replacements.Replace(string(data))
+	var out bytes.Buffer
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			i += escapeLength(text[i:])
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		if isDecoration(r) {
+			p.trimSpace = p.lineStart || p.last == ' '
+			continue
+		}
+		if p.trimSpace && r == ' ' {
+			continue
+		}
+		p.trimSpace = false
+
+		out.WriteRune(r)
+		p.last = r
+		switch {
+		case r == '\n':
+			p.lineStart = true
+		case !unicode.IsSpace(r):
+			p.lineStart = false
+		}
+	}
+	if _, err := p.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// escapeLength returns the length of the ANSI escape sequence s starts with
+func escapeLength(s string) int {
+	if len(s) < 2 || s[1] != '[' {
+		return 1
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// isDecoration reports whether r is an emoji or one of its modifiers.
+// Plain symbols such as ✓, ✗, ⚠ and box drawing are kept.
+func isDecoration(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r == 0xFE0F || r == 0x200D || r == 0x20E3:
+		return true
+	case r == 0x2139 || r == 0x23F1 || r == 0x23F3:
+		return true
+	case r == 0x2638 || r == 0x26A1 || r == 0x2705 || r == 0x274C || r == 0x2728:
+		return true
+	}
+	return false
+}
+
+// Cell escapes text for a markdown table cell
+func Cell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
+}
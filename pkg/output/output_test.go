@@ -0,0 +1,68 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	supported := []Format{FormatText, FormatJSON, FormatYAML, FormatMarkdown}
+	for value, want := range map[string]Format{"": FormatText, "JSON": FormatJSON, "yml": FormatYAML, "md": FormatMarkdown} {
+		if got, err := ParseFormat(value, supported...); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("sarif", supported...); err == nil || !strings.Contains(err.Error(), "json, yaml") {
+		t.Errorf("ParseFormat(sarif) = %v, want an error listing the formats", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	document := struct {
+		APIVersion string            `json:"api_version"`
+		Details    map[string]string `json:"details"`
+	}{APIVersion, map[string]string{"pod": "api-0"}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatYAML, document); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if want := "api_version: " + APIVersion + "\ndetails:\n  pod: api-0\n"; buf.String() != want {
+		t.Errorf("yaml = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, FormatJSON, document); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "{\n  \"api_version\"") {
+		t.Errorf("json = %q, want indented output", buf.String())
+	}
+
+	if err := Encode(&buf, FormatMarkdown, document); err == nil {
+		t.Error("Encode(markdown) = nil, want an error")
+	}
+}
+
+func TestPlainDropsEmoji(t *testing.T) {
+	var buf bytes.Buffer
+	w := Plain(&buf)
+	fmt.Fprintln(w, "\n🔍 Diagnostic Report:")
+	fmt.Fprintln(w, "⚠️  Limited diagnostics:")
+	fmt.Fprintf(w, "1. 🔴 [critical] CrashLoopBackOff\n")
+	fmt.Fprintf(w, "[🚫] kubectl delete pod api\n")
+	fmt.Fprintf(w, "\x1b[31mred\x1b[0m ✓ done\n")
+
+	want := "\nDiagnostic Report:\n⚠  Limited diagnostics:\n1. [critical] CrashLoopBackOff\n[✗] kubectl delete pod api\nred ✓ done\n"
+	if buf.String() != want {
+		t.Errorf("plain = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCell(t *testing.T) {
+	if got := Cell("a | b\nc"); got != `a \| b c` {
+		t.Errorf("Cell = %q", got)
+	}
+}
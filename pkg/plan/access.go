@@ -0,0 +1,639 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s"
+)
+
+// AccessChecker verifies whether the current user may perform an action
+type AccessChecker interface {
+	CanI(ctx context.Context, check k8s.AccessCheck) (*k8s.AccessDecision, error)
+}
+
+// ResourceResolver maps resource names the planner doesn't know, such as
+// custom resources, to their API group and scope. *k8s.Client implements it
+// with discovery.
+type ResourceResolver interface {
+	ResolveResource(name string) (*meta.RESTMapping, error)
+}
+
+// resourceRef identifies an API resource by group and plural name
+type resourceRef struct {
+	group      string
+	resource   string
+	namespaced bool
+}
+
+// resourceAliases maps kubectl resource names and short names to API resources
+var resourceAliases = map[string]resourceRef{
+	"po": {"", "pods", true}, "pod": {"", "pods", true}, "pods": {"", "pods", true},
+	"svc": {"", "services", true}, "service": {"", "services", true}, "services": {"", "services", true},
+	"cm": {"", "configmaps", true}, "configmap": {"", "configmaps", true}, "configmaps": {"", "configmaps", true},
+	"secret": {"", "secrets", true}, "secrets": {"", "secrets", true},
+	"sa": {"", "serviceaccounts", true}, "serviceaccount": {"", "serviceaccounts", true}, "serviceaccounts": {"", "serviceaccounts", true},
+	"ev": {"", "events", true}, "event": {"", "events", true}, "events": {"", "events", true},
+	"ep": {"", "endpoints", true}, "endpoints": {"", "endpoints", true},
+	"pvc": {"", "persistentvolumeclaims", true}, "persistentvolumeclaim": {"", "persistentvolumeclaims", true}, "persistentvolumeclaims": {"", "persistentvolumeclaims", true},
+	"pv": {"", "persistentvolumes", false}, "persistentvolume": {"", "persistentvolumes", false}, "persistentvolumes": {"", "persistentvolumes", false},
+	"no": {"", "nodes", false}, "node": {"", "nodes", false}, "nodes": {"", "nodes", false},
+	"ns": {"", "namespaces", false}, "namespace": {"", "namespaces", false}, "namespaces": {"", "namespaces", false},
+	"deploy": {"apps", "deployments", true}, "deployment": {"apps", "deployments", true}, "deployments": {"apps", "deployments", true},
+	"rs": {"apps", "replicasets", true}, "replicaset": {"apps", "replicasets", true}, "replicasets": {"apps", "replicasets", true},
+	"sts": {"apps", "statefulsets", true}, "statefulset": {"apps", "statefulsets", true}, "statefulsets": {"apps", "statefulsets", true},
+	"ds": {"apps", "daemonsets", true}, "daemonset": {"apps", "daemonsets", true}, "daemonsets": {"apps", "daemonsets", true},
+	"job": {"batch", "jobs", true}, "jobs": {"batch", "jobs", true},
+	"cj": {"batch", "cronjobs", true}, "cronjob": {"batch", "cronjobs", true}, "cronjobs": {"batch", "cronjobs", true},
+	"ing": {"networking.k8s.io", "ingresses", true}, "ingress": {"networking.k8s.io", "ingresses", true}, "ingresses": {"networking.k8s.io", "ingresses", true},
+	"netpol": {"networking.k8s.io", "networkpolicies", true}, "networkpolicy": {"networking.k8s.io", "networkpolicies", true}, "networkpolicies": {"networking.k8s.io", "networkpolicies", true},
+	"hpa": {"autoscaling", "horizontalpodautoscalers", true}, "horizontalpodautoscaler": {"autoscaling", "horizontalpodautoscalers", true}, "horizontalpodautoscalers": {"autoscaling", "horizontalpodautoscalers", true},
+	"pdb": {"policy", "poddisruptionbudgets", true}, "poddisruptionbudget": {"policy", "poddisruptionbudgets", true}, "poddisruptionbudgets": {"policy", "poddisruptionbudgets", true},
+	"sc": {"storage.k8s.io", "storageclasses", false}, "storageclass": {"storage.k8s.io", "storageclasses", false}, "storageclasses": {"storage.k8s.io", "storageclasses", false},
+}
+
+// flagsWithValues are kubectl flags whose value is a separate argument
+var flagsWithValues = map[string]bool{
+	"-n": true, "--namespace": true, "-l": true, "--selector": true, "-o": true, "--output": true,
+	"-c": true, "--container": true, "--field-selector": true, "--context": true, "--replicas": true,
+	"-f": true, "--filename": true, "-p": true, "--patch": true, "--type": true, "--timeout": true,
+	"--tail": true, "--since": true, "--image": true, "--port": true, "--target-port": true,
+	"-k": true, "--kustomize": true, "--as": true, "--as-group": true, "--kubeconfig": true,
+	"--cluster": true, "--user": true, "--min": true, "--max": true, "--cpu-percent": true,
+	"--to-revision": true,
+}
+
+// kubectlArgs separates a kubectl command into positional arguments and flags
+type kubectlArgs struct {
+	positional    []string
+	namespace     string
+	allNamespaces bool
+	// files are the -f manifests and kustomize the -k directories
+	files     []string
+	kustomize []string
+	force     bool
+}
+
+// parseKubectlArgs parses the arguments after "kubectl"
+func parseKubectlArgs(fields []string) kubectlArgs {
+	var args kubectlArgs
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case f == "-A" || f == "--all-namespaces":
+			args.allNamespaces = true
+		case f == "-n" || f == "--namespace":
+			if i+1 < len(fields) {
+				args.namespace = fields[i+1]
+			}
+			i++
+		case strings.HasPrefix(f, "--namespace="):
+			args.namespace = strings.TrimPrefix(f, "--namespace=")
+		case f == "-f" || f == "--filename":
+			if i+1 < len(fields) {
+				args.files = append(args.files, fields[i+1])
+			}
+			i++
+		case strings.HasPrefix(f, "--filename=") || strings.HasPrefix(f, "-f="):
+			_, file, _ := strings.Cut(f, "=")
+			args.files = append(args.files, file)
+		case f == "-k" || f == "--kustomize":
+			if i+1 < len(fields) {
+				args.kustomize = append(args.kustomize, fields[i+1])
+			}
+			i++
+		case strings.HasPrefix(f, "--kustomize=") || strings.HasPrefix(f, "-k="):
+			_, dir, _ := strings.Cut(f, "=")
+			args.kustomize = append(args.kustomize, dir)
+		case f == "--force" || f == "--force=true":
+			args.force = true
+		case f == "--":
+			// The rest is the command run in a container
+			return args
+		case strings.HasPrefix(f, "-"):
+			if flagsWithValues[f] {
+				i++
+			}
+		default:
+			args.positional = append(args.positional, f)
+		}
+	}
+	return args
+}
+
+// readOnlyVerbs are kubectl subcommands that never modify cluster state
+var readOnlyVerbs = map[string]bool{
+	"get": true, "describe": true, "logs": true, "top": true, "explain": true,
+	"api-resources": true, "api-versions": true, "version": true, "cluster-info": true,
+	"events": true, "auth can-i": true, "auth whoami": true, "rollout status": true,
+	"rollout history": true,
+}
+
+// IsReadOnly reports whether every command in the plan is a read-only
+// kubectl invocation (or a comment)
+func (p *Plan) IsReadOnly() bool {
+	for _, cmd := range p.AllCommands() {
+		command := strings.TrimSpace(cmd.Command)
+		if command == "" || strings.HasPrefix(command, "#") {
+			continue
+		}
+
+		fields := strings.Fields(command)
+		if fields[0] != "kubectl" {
+			return false
+		}
+		args := parseKubectlArgs(fields[1:])
+		if len(args.positional) == 0 {
+			return false
+		}
+		// Only some rollout and auth subcommands read state; auth reconcile
+		// writes RBAC
+		if !readOnlyVerbs[commandName(args.positional)] {
+			return false
+		}
+	}
+	return true
+}
+
+// unprivilegedCommands are kubectl subcommands that need no access beyond
+// discovery and reviews of the user's own permissions, which every user has
+var unprivilegedCommands = map[string]bool{
+	"version": true, "api-resources": true, "api-versions": true, "explain": true,
+	"config": true, "completion": true, "plugin": true, "kustomize": true, "help": true,
+	"options": true, "auth can-i": true, "auth whoami": true,
+}
+
+// commandName returns a kubectl command's subcommand, including the
+// sub-subcommand of commands like rollout and auth whose effect depends on it
+func commandName(positional []string) string {
+	if len(positional) > 1 && (positional[0] == "rollout" || positional[0] == "auth") {
+		return positional[0] + " " + positional[1]
+	}
+	return positional[0]
+}
+
+// withoutAssignments drops key=value arguments, such as labels and images,
+// from resource arguments
+func withoutAssignments(args []string) []string {
+	var targets []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "=") {
+			targets = append(targets, arg)
+		}
+	}
+	return targets
+}
+
+// ParseAccessChecks derives the permissions a kubectl command needs. Commands
+// that are not kubectl invocations or need no API access return nil; any
+// other command whose permissions can't be derived returns an unverifiable
+// check, so it fails closed.
+func ParseAccessChecks(command, defaultNamespace string) []k8s.AccessCheck {
+	fields := strings.Fields(command)
+	if len(fields) < 2 || fields[0] != "kubectl" {
+		return nil
+	}
+
+	args := parseKubectlArgs(fields[1:])
+	if len(args.positional) == 0 {
+		return nil
+	}
+
+	namespace := args.namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	if args.allNamespaces {
+		namespace = ""
+	}
+
+	verb, targets := args.positional[0], args.positional[1:]
+	if unprivilegedCommands[commandName(args.positional)] {
+		return nil
+	}
+
+	if len(args.files) > 0 || len(args.kustomize) > 0 {
+		switch verb {
+		case "apply":
+			return manifestChecks(args, namespace, "create", "patch")
+		case "create":
+			return manifestChecks(args, namespace, "create")
+		case "replace":
+			if args.force {
+				return manifestChecks(args, namespace, "delete", "create")
+			}
+			return manifestChecks(args, namespace, "update")
+		case "delete":
+			return manifestChecks(args, namespace, "delete")
+		}
+	}
+
+	switch verb {
+	case "get", "describe":
+		if len(targets) == 0 {
+			return unverifiable(verb)
+		}
+		kubeVerb := "list"
+		if len(targets) > 1 || strings.Contains(targets[0], "/") {
+			kubeVerb = "get"
+		}
+		return resourceChecks(kubeVerb, "", targets, namespace)
+	case "top":
+		if len(targets) == 0 {
+			return unverifiable(verb)
+		}
+		check := resourceChecks("list", "", targets[:1], namespace)
+		for i := range check {
+			check[i].Group = "metrics.k8s.io"
+		}
+		return check
+	case "wait":
+		if len(targets) == 0 {
+			return unverifiable(verb)
+		}
+		return resourceChecks("get", "", targets, namespace)
+	case "events":
+		return []k8s.AccessCheck{{Verb: "list", Resource: "events", Namespace: namespace}}
+	case "cluster-info":
+		return []k8s.AccessCheck{{Verb: "list", Resource: "services", Namespace: "kube-system"}}
+	case "logs":
+		return []k8s.AccessCheck{{Verb: "get", Resource: "pods", Subresource: "log", Namespace: namespace}}
+	case "exec", "attach", "port-forward":
+		sub := map[string]string{"exec": "exec", "attach": "attach", "port-forward": "portforward"}[verb]
+		return []k8s.AccessCheck{{Verb: "create", Resource: "pods", Subresource: sub, Namespace: namespace}}
+	case "delete":
+		if len(targets) == 0 {
+			return unverifiable(verb)
+		}
+		return resourceChecks("delete", "", targets, namespace)
+	case "scale":
+		return resourceChecks("patch", "scale", targets, namespace)
+	case "edit", "patch", "label", "annotate":
+		if len(targets) == 0 {
+			return unverifiable(verb)
+		}
+		return resourceChecks("patch", "", withoutAssignments(targets), namespace)
+	case "set":
+		if len(targets) < 2 {
+			return unverifiable(verb)
+		}
+		return resourceChecks("patch", "", withoutAssignments(targets[1:]), namespace)
+	case "rollout":
+		if len(targets) < 2 {
+			return unverifiable(verb)
+		}
+		switch targets[0] {
+		case "status", "history":
+			return resourceChecks("get", "", targets[1:], namespace)
+		case "undo":
+			// Undo reads the previous revision from the workload's
+			// replicasets or controller revisions, then patches it back
+			checks := resourceChecks("patch", "", targets[1:], namespace)
+			revisions := "controllerrevisions"
+			if len(checks) > 0 && checks[0].Resource == "deployments" {
+				revisions = "replicasets"
+			}
+			return append(checks, k8s.AccessCheck{Verb: "list", Group: "apps", Resource: revisions, Namespace: namespace})
+		}
+		return resourceChecks("patch", "", targets[1:], namespace)
+	case "cordon", "uncordon", "taint":
+		node := nodeName(targets)
+		if node == "" {
+			return unverifiable(verb)
+		}
+		return []k8s.AccessCheck{{Verb: "patch", Resource: "nodes", Name: node}}
+	case "drain":
+		node := nodeName(targets)
+		if node == "" {
+			return unverifiable(verb)
+		}
+		return []k8s.AccessCheck{
+			{Verb: "patch", Resource: "nodes", Name: node},
+			{Verb: "create", Resource: "pods", Subresource: "eviction"},
+		}
+	case "create":
+		if len(targets) == 0 {
+			return unverifiable(verb)
+		}
+		return resourceChecks("create", "", targets[:1], namespace)
+	case "expose":
+		return []k8s.AccessCheck{{Verb: "create", Resource: "services", Namespace: namespace}}
+	case "run":
+		return []k8s.AccessCheck{{Verb: "create", Resource: "pods", Namespace: namespace}}
+	case "autoscale":
+		checks := []k8s.AccessCheck{{Verb: "create", Group: "autoscaling", Resource: "horizontalpodautoscalers", Namespace: namespace}}
+		return append(checks, resourceChecks("get", "", targets, namespace)...)
+	case "apply":
+		switch {
+		case len(targets) > 0 && targets[0] == "view-last-applied":
+			return resourceChecks("get", "", targets[1:], namespace)
+		case len(targets) > 0 && targets[0] == "edit-last-applied":
+			return resourceChecks("patch", "", targets[1:], namespace)
+		}
+		return []k8s.AccessCheck{{Unverifiable: "kubectl apply without a manifest"}}
+	case "replace":
+		return []k8s.AccessCheck{{Unverifiable: "kubectl replace without a manifest"}}
+	}
+
+	return unverifiable(verb)
+}
+
+// nodeName returns the node a node command targets, given as "node-1",
+// "node/node-1" or "nodes node-1"
+func nodeName(targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	if kind, name, ok := strings.Cut(targets[0], "/"); ok && resourceAliases[kind].resource == "nodes" {
+		return name
+	}
+	if resourceAliases[targets[0]].resource == "nodes" {
+		if len(targets) < 2 {
+			return ""
+		}
+		return targets[1]
+	}
+	return targets[0]
+}
+
+// unverifiable is the check of a command whose permissions can't be derived
+func unverifiable(verb string) []k8s.AccessCheck {
+	return []k8s.AccessCheck{{Unverifiable: fmt.Sprintf("kubectl %s is not inspected", verb)}}
+}
+
+// manifestChecks builds access checks for every object of the -f manifests
+// of a command. Manifests that can't be read locally, such as URLs, stdin or
+// kustomizations, give an unverifiable check so the command fails closed.
+func manifestChecks(args kubectlArgs, namespace string, verbs ...string) []k8s.AccessCheck {
+	var checks []k8s.AccessCheck
+	for _, dir := range args.kustomize {
+		checks = append(checks, k8s.AccessCheck{Unverifiable: fmt.Sprintf("kustomization %s is not inspected", dir)})
+	}
+	for _, file := range args.files {
+		if file == "-" || strings.Contains(file, "://") {
+			checks = append(checks, k8s.AccessCheck{Unverifiable: fmt.Sprintf("manifest %s is not a local file", file)})
+			continue
+		}
+
+		objects, err := k8s.LoadObjects(file)
+		if err != nil {
+			checks = append(checks, k8s.AccessCheck{Unverifiable: fmt.Sprintf("manifest %s could not be read: %v", file, err)})
+			continue
+		}
+		if len(objects) == 0 {
+			checks = append(checks, k8s.AccessCheck{Unverifiable: fmt.Sprintf("manifest %s has no objects", file)})
+			continue
+		}
+		for _, obj := range objects {
+			checks = append(checks, objectChecks(obj, namespace, verbs)...)
+		}
+	}
+	return checks
+}
+
+// objectChecks builds access checks for a manifest object, in its own
+// namespace if it sets one
+func objectChecks(obj runtime.Object, namespace string, verbs []string) []k8s.AccessCheck {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return []k8s.AccessCheck{{Unverifiable: fmt.Sprintf("manifest object has no metadata: %v", err)}}
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil || len(kinds) == 0 {
+			return []k8s.AccessCheck{{Unverifiable: fmt.Sprintf("manifest object %s has no kind", accessor.GetName())}}
+		}
+		gvk = kinds[0]
+	}
+
+	ref, ok := resourceAliases[strings.ToLower(gvk.Kind)]
+	if !ok || ref.group != gvk.Group {
+		// Unknown kinds (e.g. CRDs) are checked by their guessed plural name
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		ref = resourceRef{group: gvk.Group, resource: plural.Resource, namespaced: true}
+	}
+	if ns := accessor.GetNamespace(); ns != "" {
+		namespace = ns
+	}
+
+	checks := make([]k8s.AccessCheck, 0, len(verbs))
+	for _, verb := range verbs {
+		check := k8s.AccessCheck{Verb: verb, Group: ref.group, Resource: ref.resource, Name: accessor.GetName()}
+		if ref.namespaced {
+			check.Namespace = namespace
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// resourceChecks builds access checks for kubectl resource arguments in
+// either "type name" or "type/name" form
+func resourceChecks(verb, subresource string, targets []string, namespace string) []k8s.AccessCheck {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var checks []k8s.AccessCheck
+	add := func(kind, name string) {
+		for _, t := range strings.Split(kind, ",") {
+			ref, ok := resourceAliases[strings.ToLower(t)]
+			if !ok {
+				// Unknown kinds (e.g. CRDs) are checked by their plural name
+				ref = resourceRef{resource: strings.ToLower(t), namespaced: true}
+			}
+			check := k8s.AccessCheck{
+				Verb:        verb,
+				Group:       ref.group,
+				Resource:    ref.resource,
+				Subresource: subresource,
+				Name:        name,
+			}
+			if ref.namespaced {
+				check.Namespace = namespace
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	if strings.Contains(targets[0], "/") {
+		for _, target := range targets {
+			kind, name, _ := strings.Cut(target, "/")
+			add(kind, name)
+		}
+		return checks
+	}
+
+	if len(targets) == 1 {
+		add(targets[0], "")
+		return checks
+	}
+	for _, name := range targets[1:] {
+		add(targets[0], name)
+	}
+	return checks
+}
+
+// resolveCheck fills in the API group and scope of resources missing from
+// resourceAliases, such as custom resources, using discovery
+func resolveCheck(check k8s.AccessCheck, resolver ResourceResolver) k8s.AccessCheck {
+	if check.Group != "" || isAliasedResource(check.Resource) {
+		return check
+	}
+
+	mapping, err := resolver.ResolveResource(check.Resource)
+	if err != nil {
+		return check
+	}
+	check.Group = mapping.Resource.Group
+	check.Resource = mapping.Resource.Resource
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		check.Namespace = ""
+	}
+	return check
+}
+
+// isAliasedResource reports whether resource is a plural name in resourceAliases
+func isAliasedResource(resource string) bool {
+	for _, ref := range resourceAliases {
+		if ref.resource == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionsUnverifiedWarning is added to plans whose permissions could not
+// be reviewed
+const PermissionsUnverifiedWarning = "Permissions could not be verified for this plan"
+
+// deniedWarningPrefix starts the warnings CheckPermissions adds for steps
+// the user can't perform
+const deniedWarningPrefix = "Insufficient permissions for: "
+
+// CheckPermissions runs access reviews for every step in the plan. Steps the
+// user can't perform are annotated, and the AI is asked for a permitted
+// alternative for each of them. The results of an earlier check, such as
+// one recorded in history, are cleared first.
+func (p *Planner) CheckPermissions(ctx context.Context, plan *Plan, checker AccessChecker) error {
+	plan.resetPermissions()
+	for _, cmds := range [][]Command{plan.Preconditions, plan.Commands, plan.Verifications} {
+		for i := range cmds {
+			cmd := &cmds[i]
+
+			denied, err := p.deniedChecks(ctx, cmd.Command, checker)
+			if err != nil {
+				return err
+			}
+			if len(denied) == 0 {
+				continue
+			}
+
+			reasons := make([]string, 0, len(denied))
+			for _, check := range denied {
+				reasons = append(reasons, "cannot "+check.String())
+			}
+			cmd.Denied = true
+			cmd.DeniedReason = strings.Join(reasons, "; ")
+			plan.RequiresAuth = true
+			plan.AddWarning(fmt.Sprintf("%s%s (%s)", deniedWarningPrefix, cmd.Command, cmd.DeniedReason))
+
+			cmd.Alternative = p.suggestAlternative(ctx, plan.Query, cmd, checker)
+		}
+	}
+
+	return nil
+}
+
+// resetPermissions clears the denials, alternatives and warnings of an
+// earlier permission check
+func (p *Plan) resetPermissions() {
+	p.RequiresAuth = false
+	for _, cmds := range [][]Command{p.Preconditions, p.Commands, p.Verifications} {
+		for i := range cmds {
+			cmds[i].Denied = false
+			cmds[i].DeniedReason = ""
+			cmds[i].Alternative = ""
+		}
+	}
+
+	warnings := p.Warnings[:0]
+	for _, warning := range p.Warnings {
+		if warning != PermissionsUnverifiedWarning && !strings.HasPrefix(warning, deniedWarningPrefix) {
+			warnings = append(warnings, warning)
+		}
+	}
+	p.Warnings = warnings
+}
+
+// deniedChecks returns the access checks of a command that are not allowed
+func (p *Planner) deniedChecks(ctx context.Context, command string, checker AccessChecker) ([]k8s.AccessCheck, error) {
+	var denied []k8s.AccessCheck
+	resolver, _ := checker.(ResourceResolver)
+	for _, check := range ParseAccessChecks(command, p.namespace) {
+		if check.Unverifiable != "" {
+			denied = append(denied, check)
+			continue
+		}
+		if resolver != nil {
+			check = resolveCheck(check, resolver)
+		}
+		decision, err := checker.CanI(ctx, check)
+		if err != nil {
+			return nil, err
+		}
+		if !decision.Allowed {
+			denied = append(denied, check)
+		}
+	}
+	return denied, nil
+}
+
+// suggestAlternative asks the AI for a command achieving the same goal that
+// the user is permitted to run. It returns "" if none is found.
+func (p *Planner) suggestAlternative(ctx context.Context, query string, cmd *Command, checker AccessChecker) string {
+	prompt := fmt.Sprintf(`The user asked: %s
+
+The planned step is not permitted for this user.
+Step: %s
+Command: %s
+Reason: %s
+
+Suggest a single alternative kubectl command that achieves the same goal (or
+the closest read-only equivalent) using only permissions the user has.
+Respond with the command on its own line.`, query, cmd.Description, cmd.Command, cmd.DeniedReason)
+
+	response, err := p.aiProvider.Generate(ctx, prompt, ai.DefaultOptions())
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(response.Content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.) "))
+		line = strings.Trim(line, "`")
+		if !strings.HasPrefix(line, "kubectl ") || line == cmd.Command {
+			continue
+		}
+		if strings.Contains(line, "<") {
+			// Skip templated placeholders like <pod-name>
+			continue
+		}
+
+		denied, err := p.deniedChecks(ctx, line, checker)
+		if err == nil && len(denied) == 0 {
+			return line
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,295 @@
+package plan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/k8s/k8stest"
+)
+
+func TestParseAccessChecks(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []k8s.AccessCheck
+	}{
+		{
+			command: "kubectl get pods -n payments",
+			want:    []k8s.AccessCheck{{Verb: "list", Resource: "pods", Namespace: "payments"}},
+		},
+		{
+			command: "kubectl scale deployment api --replicas=5",
+			want:    []k8s.AccessCheck{{Verb: "patch", Group: "apps", Resource: "deployments", Subresource: "scale", Name: "api", Namespace: "default"}},
+		},
+		{
+			command: "kubectl rollout restart deploy/api -n shop",
+			want:    []k8s.AccessCheck{{Verb: "patch", Group: "apps", Resource: "deployments", Name: "api", Namespace: "shop"}},
+		},
+		{
+			command: "kubectl logs mypod -c app",
+			want:    []k8s.AccessCheck{{Verb: "get", Resource: "pods", Subresource: "log", Namespace: "default"}},
+		},
+		{
+			command: "kubectl cordon worker-1",
+			want:    []k8s.AccessCheck{{Verb: "patch", Resource: "nodes", Name: "worker-1"}},
+		},
+		{
+			command: "kubectl get nodes -A",
+			want:    []k8s.AccessCheck{{Verb: "list", Resource: "nodes"}},
+		},
+		{
+			command: "kubectl --as jane --kubeconfig /tmp/admin.conf delete pod api-123 -n payments",
+			want:    []k8s.AccessCheck{{Verb: "delete", Resource: "pods", Name: "api-123", Namespace: "payments"}},
+		},
+		{
+			command: "kubectl run debug --image=busybox -n shop -- sleep 3600",
+			want:    []k8s.AccessCheck{{Verb: "create", Resource: "pods", Namespace: "shop"}},
+		},
+		{
+			command: "kubectl autoscale deployment api --min 2 --max 10",
+			want: []k8s.AccessCheck{
+				{Verb: "create", Group: "autoscaling", Resource: "horizontalpodautoscalers", Namespace: "default"},
+				{Verb: "get", Group: "apps", Resource: "deployments", Name: "api", Namespace: "default"},
+			},
+		},
+		{
+			command: "kubectl rollout undo deployment/api --to-revision 3 -n shop",
+			want: []k8s.AccessCheck{
+				{Verb: "patch", Group: "apps", Resource: "deployments", Name: "api", Namespace: "shop"},
+				{Verb: "list", Group: "apps", Resource: "replicasets", Namespace: "shop"},
+			},
+		},
+		{
+			command: "kubectl apply -f https://example.com/app.yaml",
+			want:    []k8s.AccessCheck{{Unverifiable: "manifest https://example.com/app.yaml is not a local file"}},
+		},
+		{
+			command: "kubectl apply -k overlays/prod",
+			want:    []k8s.AccessCheck{{Unverifiable: "kustomization overlays/prod is not inspected"}},
+		},
+		{
+			command: "kubectl set image deployment/api api=example.com/api:1.5.0",
+			want:    []k8s.AccessCheck{{Verb: "patch", Group: "apps", Resource: "deployments", Name: "api", Namespace: "default"}},
+		},
+		{
+			command: "kubectl taint nodes worker-2 dedicated=batch:NoSchedule",
+			want:    []k8s.AccessCheck{{Verb: "patch", Resource: "nodes", Name: "worker-2"}},
+		},
+		{
+			command: "kubectl cp payments/api-0:/tmp/dump ./dump",
+			want:    []k8s.AccessCheck{{Unverifiable: "kubectl cp is not inspected"}},
+		},
+		{
+			command: "kubectl certificate approve csr-1",
+			want:    []k8s.AccessCheck{{Unverifiable: "kubectl certificate is not inspected"}},
+		},
+		{
+			command: "kubectl create",
+			want:    []k8s.AccessCheck{{Unverifiable: "kubectl create is not inspected"}},
+		},
+		{
+			command: "kubectl auth can-i delete pods",
+			want:    nil,
+		},
+		{
+			command: "# Generated from: restart pods",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got := ParseAccessChecks(tt.command, "")
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d checks, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Check %d: expected %+v, got %+v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{command: "kubectl get pods -n payments", want: true},
+		{command: "kubectl rollout status deployment/api", want: true},
+		{command: "kubectl auth can-i delete pods", want: true},
+		{command: "kubectl auth whoami", want: true},
+		{command: "kubectl auth reconcile -f rbac.yaml", want: false},
+		{command: "kubectl rollout restart deployment/api", want: false},
+		{command: "kubectl delete pod api-123", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			p := &Plan{Commands: []Command{{Command: tt.command}}}
+			if got := p.IsReadOnly(); got != tt.want {
+				t.Errorf("IsReadOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAccessChecksManifest(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "app.yaml")
+	data := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api-config
+  namespace: shared
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: api-tls
+`
+	if err := os.WriteFile(manifest, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := ParseAccessChecks("kubectl replace -f "+manifest+" -n payments", "")
+	want := []k8s.AccessCheck{
+		{Verb: "update", Group: "apps", Resource: "deployments", Name: "api", Namespace: "payments"},
+		{Verb: "update", Resource: "configmaps", Name: "api-config", Namespace: "shared"},
+		{Verb: "update", Group: "cert-manager.io", Resource: "certificates", Name: "api-tls", Namespace: "payments"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("checks = %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Check %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	if got := ParseAccessChecks("kubectl apply -f "+filepath.Join(t.TempDir(), "missing.yaml"), ""); len(got) != 1 || got[0].Unverifiable == "" {
+		t.Errorf("expected a missing manifest to be unverifiable, got %+v", got)
+	}
+}
+
+// fakeChecker allows every action except the listed verbs
+type fakeChecker struct {
+	deniedVerbs map[string]bool
+}
+
+func (f *fakeChecker) CanI(ctx context.Context, check k8s.AccessCheck) (*k8s.AccessDecision, error) {
+	return &k8s.AccessDecision{Allowed: !f.deniedVerbs[check.Verb]}, nil
+}
+
+func TestCheckPermissions(t *testing.T) {
+	planner := NewPlanner("payments", true)
+	p := &Plan{
+		Query: "restart api",
+		Commands: []Command{
+			{Command: "kubectl get pods -n payments", Description: "List pods"},
+			{Command: "kubectl delete pod api-123 -n payments", Description: "Delete pod"},
+		},
+	}
+
+	if err := planner.CheckPermissions(context.Background(), p, &fakeChecker{deniedVerbs: map[string]bool{"delete": true}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if p.Commands[0].Denied {
+		t.Error("Expected list pods to be allowed")
+	}
+	if !p.Commands[1].Denied || p.Commands[1].DeniedReason == "" {
+		t.Error("Expected delete pod to be denied with a reason")
+	}
+	if !p.RequiresAuth {
+		t.Error("Expected plan to require additional permissions")
+	}
+	if len(p.DeniedCommands()) != 1 {
+		t.Errorf("Expected 1 denied command, got %d", len(p.DeniedCommands()))
+	}
+}
+
+func TestCheckPermissionsRechecksRecordedPlan(t *testing.T) {
+	planner := NewPlanner("payments", true)
+	p := &Plan{
+		Query:    "restart api",
+		Commands: []Command{{Command: "kubectl delete pod api-123 -n payments", Description: "Delete pod"}},
+		Warnings: []string{"Deletes a running pod"},
+	}
+
+	denyDelete := &fakeChecker{deniedVerbs: map[string]bool{"delete": true}}
+	for i := 0; i < 2; i++ {
+		if err := planner.CheckPermissions(context.Background(), p, denyDelete); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if !p.Commands[0].Denied || len(p.Warnings) != 2 {
+		t.Fatalf("Expected one denied step and one permission warning, got %+v, warnings %v", p.Commands[0], p.Warnings)
+	}
+
+	// Access granted since the plan was recorded
+	if err := planner.CheckPermissions(context.Background(), p, &fakeChecker{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Commands[0].Denied || p.Commands[0].DeniedReason != "" || p.Commands[0].Alternative != "" || p.RequiresAuth {
+		t.Errorf("Expected the earlier denial to be cleared, got %+v", p.Commands[0])
+	}
+	if len(p.Warnings) != 1 || p.Warnings[0] != "Deletes a running pod" {
+		t.Errorf("Expected only the unrelated warning to remain, got %v", p.Warnings)
+	}
+}
+
+func TestCheckPermissionsDeniesUnverifiableCommands(t *testing.T) {
+	planner := NewPlanner("payments", true)
+	p := &Plan{Commands: []Command{{Command: "kubectl apply -f https://example.com/app.yaml", Description: "Apply manifest"}}}
+
+	if err := planner.CheckPermissions(context.Background(), p, &fakeChecker{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !p.Commands[0].Denied || !strings.Contains(p.Commands[0].DeniedReason, "cannot verify permissions") {
+		t.Errorf("Expected a remote manifest to be denied as unverifiable, got %+v", p.Commands[0])
+	}
+}
+
+// resolvingChecker allows everything, records the checks it sees and
+// resolves resources through a fake cluster's discovery
+type resolvingChecker struct {
+	*k8s.Client
+	checks []k8s.AccessCheck
+}
+
+func (r *resolvingChecker) CanI(ctx context.Context, check k8s.AccessCheck) (*k8s.AccessDecision, error) {
+	r.checks = append(r.checks, check)
+	return &k8s.AccessDecision{Allowed: true}, nil
+}
+
+func TestCheckPermissionsResolvesCustomResources(t *testing.T) {
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": "api-tls", "namespace": "payments"},
+	}}
+	checker := &resolvingChecker{Client: k8stest.NewClientFromObjects("payments", certificate)}
+	p := &Plan{Commands: []Command{{Command: "kubectl delete certificate api-tls -n payments"}}}
+
+	if err := NewPlanner("payments", true).CheckPermissions(context.Background(), p, checker); err != nil {
+		t.Fatalf("CheckPermissions: %v", err)
+	}
+
+	want := k8s.AccessCheck{Verb: "delete", Group: "cert-manager.io", Resource: "certificates", Name: "api-tls", Namespace: "payments"}
+	if len(checker.checks) != 1 || checker.checks[0] != want {
+		t.Errorf("checks = %+v, want %+v", checker.checks, want)
+	}
+}
@@ -0,0 +1,180 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+
+	"k8s-pilot/pkg/output"
+)
+
+// Document kinds
+const (
+	KindPlan   = "Plan"
+	KindResult = "Result"
+)
+
+// PlanDocument is the versioned machine-readable form of a Plan
+type PlanDocument struct {
+	APIVersion       string            `json:"api_version"`
+	Kind             string            `json:"kind"`
+	Query            string            `json:"query"`
+	Summary          string            `json:"summary"`
+	Source           string            `json:"source,omitempty"`
+	Target           *TargetDocument   `json:"target,omitempty"`
+	Preconditions    []CommandDocument `json:"preconditions,omitempty"`
+	Commands         []CommandDocument `json:"commands"`
+	Verifications    []CommandDocument `json:"verifications,omitempty"`
+	Warnings         []string          `json:"warnings,omitempty"`
+	RequiresAuth     bool              `json:"requires_auth"`
+	DryRun           bool              `json:"dry_run"`
+	SuggestedRunbook string            `json:"suggested_runbook,omitempty"`
+}
+
+// TargetDocument is the cluster a plan runs against
+type TargetDocument struct {
+	Context   string `json:"context"`
+	Cluster   string `json:"cluster"`
+	Server    string `json:"server,omitempty"`
+	User      string `json:"user,omitempty"`
+	Namespace string `json:"namespace"`
+}
+
+// CommandDocument is a plan step
+type CommandDocument struct {
+	Command      string `json:"command"`
+	Description  string `json:"description"`
+	Safe         bool   `json:"safe"`
+	DryRun       bool   `json:"dry_run"`
+	Undo         string `json:"undo,omitempty"`
+	Denied       bool   `json:"denied,omitempty"`
+	DeniedReason string `json:"denied_reason,omitempty"`
+	Alternative  string `json:"alternative,omitempty"`
+}
+
+// ResultDocument is the versioned machine-readable form of a Result
+type ResultDocument struct {
+	APIVersion       string   `json:"api_version"`
+	Kind             string   `json:"kind"`
+	Context          string   `json:"context,omitempty"`
+	ExecutedCommands []string `json:"executed_commands"`
+	Errors           []string `json:"errors,omitempty"`
+}
+
+// Document returns the plan's machine-readable form
+func (p *Plan) Document() *PlanDocument {
+	doc := &PlanDocument{
+		APIVersion:       output.APIVersion,
+		Kind:             KindPlan,
+		Query:            p.Query,
+		Summary:          p.Summary,
+		Source:           p.Source,
+		Preconditions:    commandDocuments(p.Preconditions),
+		Commands:         commandDocuments(p.Commands),
+		Verifications:    commandDocuments(p.Verifications),
+		Warnings:         p.Warnings,
+		RequiresAuth:     p.RequiresAuth,
+		DryRun:           p.DryRun,
+		SuggestedRunbook: p.SuggestedRunbook,
+	}
+	if doc.Commands == nil {
+		doc.Commands = []CommandDocument{}
+	}
+	if t := p.Target; t != nil {
+		doc.Target = &TargetDocument{Context: t.Context, Cluster: t.Cluster, Server: t.Server, User: t.User, Namespace: t.Namespace}
+	}
+	return doc
+}
+
+// Document returns the result's machine-readable form
+func (r *Result) Document() *ResultDocument {
+	executed := r.ExecutedCommands
+	if executed == nil {
+		executed = []string{}
+	}
+	return &ResultDocument{
+		APIVersion:       output.APIVersion,
+		Kind:             KindResult,
+		ExecutedCommands: executed,
+		Errors:           r.Errors,
+	}
+}
+
+// commandDocuments converts plan steps
+func commandDocuments(commands []Command) []CommandDocument {
+	var docs []CommandDocument
+	for _, cmd := range commands {
+		docs = append(docs, CommandDocument{
+			Command:      cmd.Command,
+			Description:  cmd.Description,
+			Safe:         cmd.Safe,
+			DryRun:       cmd.DryRun,
+			Undo:         cmd.Undo,
+			Denied:       cmd.Denied,
+			DeniedReason: cmd.DeniedReason,
+			Alternative:  cmd.Alternative,
+		})
+	}
+	return docs
+}
+
+// Markdown writes the plan as markdown
+func (p *Plan) Markdown(w io.Writer) {
+	fmt.Fprintf(w, "# Execution Plan\n\n%s\n", p.Summary)
+	if p.Query != "" {
+		fmt.Fprintf(w, "\n**Query:** %s\n", p.Query)
+	}
+	if p.Target != nil {
+		fmt.Fprintf(w, "\n**Target:** %s\n", p.Target)
+	}
+
+	if len(p.Warnings) > 0 {
+		fmt.Fprint(w, "\n## Warnings\n\n")
+		for _, warning := range p.Warnings {
+			fmt.Fprintf(w, "- %s\n", warning)
+		}
+	}
+	markdownCommands(w, "Preconditions", p.Preconditions)
+	markdownCommands(w, "Commands", p.Commands)
+	markdownCommands(w, "Verifications", p.Verifications)
+
+	if p.SuggestedRunbook != "" {
+		fmt.Fprintf(w, "\nMatching runbook: `%s`\n", p.SuggestedRunbook)
+	}
+	if p.DryRun {
+		fmt.Fprintln(w, "\n_Dry-run mode: no changes will be applied._")
+	}
+}
+
+// markdownCommands writes a section of plan steps as a table
+func markdownCommands(w io.Writer, title string, commands []Command) {
+	if len(commands) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n## %s\n\n", title)
+	fmt.Fprintln(w, "| # | Step | Command | Safe |")
+	fmt.Fprintln(w, "|---|---|---|---|")
+	for i, cmd := range commands {
+		safe := "yes"
+		switch {
+		case cmd.Denied:
+			safe = "denied: " + cmd.DeniedReason
+		case !cmd.Safe:
+			safe = "no"
+		}
+		fmt.Fprintf(w, "| %d | %s | `%s` | %s |\n", i+1, output.Cell(cmd.Description), output.Cell(cmd.Command), output.Cell(safe))
+	}
+}
+
+// Markdown writes the result as markdown
+func (r *Result) Markdown(w io.Writer) {
+	fmt.Fprint(w, "\n## Executed commands\n\n")
+	for i, cmd := range r.ExecutedCommands {
+		fmt.Fprintf(w, "%d. `%s`\n", i+1, cmd)
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprint(w, "\n## Errors\n\n")
+		for _, err := range r.Errors {
+			fmt.Fprintf(w, "- %s\n", err)
+		}
+	}
+}
@@ -7,13 +7,23 @@ import (
 	"strings"
 
 	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s"
+	"k8s-pilot/pkg/output"
 )
 
 // Planner generates execution plans from natural language
 type Planner struct {
 	aiProvider ai.Provider
 	namespace  string
-	dryRun     bool
+	dryRun      bool
+	runbooks    RunbookSuggester
+	clusterType k8s.ClusterType
+}
+
+// RunbookSuggester finds a predefined runbook matching a natural language query
+type RunbookSuggester interface {
+	// Suggest returns the name of the best matching runbook, or "" if none match
+	Suggest(query string) string
 }
 
 // NewPlanner creates a new planner
@@ -36,6 +46,11 @@ func NewPlanner(namespace string, dryRun bool) *Planner {
 		provider, _ = ai.NewMockProvider(aiConfig)
 	}
 	
+	return NewPlannerWithProvider(provider, namespace, dryRun)
+}
+
+// NewPlannerWithProvider creates a planner on an existing AI provider
+func NewPlannerWithProvider(provider ai.Provider, namespace string, dryRun bool) *Planner {
 	return &Planner{
 		aiProvider: provider,
 		namespace:  namespace,
@@ -43,21 +58,50 @@ func NewPlanner(namespace string, dryRun bool) *Planner {
 	}
 }
 
+// SetRunbooks sets the catalog used to suggest runbooks for queries
+func (p *Planner) SetRunbooks(runbooks RunbookSuggester) {
+	p.runbooks = runbooks
+}
+
+// SetClusterType sets the detected distribution so generated plans use its
+// tooling, e.g. eksctl on EKS or gcloud on GKE
+func (p *Planner) SetClusterType(clusterType k8s.ClusterType) {
+	p.clusterType = clusterType
+}
+
 // Plan represents an execution plan
 type Plan struct {
-	Summary      string
-	Commands     []Command
-	Warnings     []string
-	RequiresAuth bool
-	DryRun       bool
+	Query            string
+	Summary          string
+	Source           string
+	Preconditions    []Command
+	Commands         []Command
+	Verifications    []Command
+	Warnings         []string
+	RequiresAuth     bool
+	DryRun           bool
+	SuggestedRunbook string
+	Target           *k8s.Target
 }
 
+// Plan sources
+const (
+	SourceAI      = "ai"
+	SourceRunbook = "runbook"
+)
+
 // Command represents a kubectl command
 type Command struct {
 	Command     string
 	Description string
 	Safe        bool
 	DryRun      bool
+	Undo        string
+
+	// Denied is set when an access review shows the user can't run the command
+	Denied       bool
+	DeniedReason string
+	Alternative  string
 }
 
 // Generate generates an execution plan from natural language
@@ -77,6 +121,10 @@ func (p *Planner) Generate(query string) (*Plan, error) {
 	plan := p.parseResponse(response.Content, query)
 	plan.DryRun = p.dryRun
 	
+	if p.runbooks != nil {
+		plan.SuggestedRunbook = p.runbooks.Suggest(query)
+	}
+	
 	return plan, nil
 }
 
@@ -108,8 +156,18 @@ WARNINGS:
 	
 	userPrompt := fmt.Sprintf(`Namespace: %s
 Query: %s
-
-Generate a safe execution plan for this query.`, namespace, query)
+`, namespace, query)
+	
+	if p.clusterType.IsKnown() {
+		userPrompt += fmt.Sprintf("Cluster type: %s\n", p.clusterType)
+		if scale := p.clusterType.NodeScaleCommand(); scale != "" {
+			userPrompt += fmt.Sprintf("Use %s tooling for node and cluster operations, e.g.: %s\n", p.clusterType, scale)
+		} else if hint := p.clusterType.NodeScaleHint(); hint != "" {
+			userPrompt += fmt.Sprintf("To add nodes: %s\n", hint)
+		}
+	}
+	
+	userPrompt += "\nGenerate a safe execution plan for this query."
 	
 	return systemPrompt + "\n\n" + userPrompt
 }
@@ -117,7 +175,9 @@ Generate a safe execution plan for this query.`, namespace, query)
 // parseResponse parses the AI response into a Plan
 func (p *Planner) parseResponse(response string, originalQuery string) *Plan {
 	plan := &Plan{
+		Query:    originalQuery,
 		Summary:  "Execution plan for: " + originalQuery,
+		Source:   SourceAI,
 		Commands: []Command{},
 		Warnings: []string{},
 		DryRun:   p.dryRun,
@@ -190,30 +250,202 @@ func (p *Planner) parseResponse(response string, originalQuery string) *Plan {
 
 // Display displays the plan
 func (p *Plan) Display() {
-	fmt.Printf("\n%s\n\n", p.Summary)
+	if p.Target != nil {
+		fmt.Fprintf(output.Stdout, "\n🎯 Target: %s\n", p.Target)
+	}
+	fmt.Fprintf(output.Stdout, "\n%s\n\n", p.Summary)
 	
 	if len(p.Warnings) > 0 {
-		fmt.Println("⚠️  Warnings:")
+		fmt.Fprintln(output.Stdout, "⚠️  Warnings:")
 		for _, warning := range p.Warnings {
-			fmt.Printf("  • %s\n", warning)
+			fmt.Fprintf(output.Stdout, "  • %s\n", warning)
 		}
-		fmt.Println()
+		fmt.Fprintln(output.Stdout)
 	}
 	
-	fmt.Println("Commands to execute:")
-	for i, cmd := range p.Commands {
+	if len(p.Preconditions) > 0 {
+		fmt.Fprintln(output.Stdout, "Preconditions:")
+		displayCommands(p.Preconditions)
+		fmt.Fprintln(output.Stdout)
+	}
+	
+	fmt.Fprintln(output.Stdout, "Commands to execute:")
+	displayCommands(p.Commands)
+	
+	if len(p.Verifications) > 0 {
+		fmt.Fprintln(output.Stdout, "\nVerifications:")
+		displayCommands(p.Verifications)
+	}
+	
+	if p.SuggestedRunbook != "" {
+		fmt.Fprintf(output.Stdout, "\n📓 Matching runbook: %s (kubectl-pilot runbook show %s)\n", p.SuggestedRunbook, p.SuggestedRunbook)
+	}
+	
+	if p.DryRun {
+		fmt.Fprintln(output.Stdout, "\n(Dry-run mode - no changes will be applied)")
+	}
+}
+
+// displayCommands prints a numbered list of commands with safety indicators
+func displayCommands(commands []Command) {
+	for i, cmd := range commands {
 		safetyIndicator := "✓"
 		if !cmd.Safe {
 			safetyIndicator = "⚠"
 		}
 		
-		fmt.Printf("\n%d. [%s] %s\n", i+1, safetyIndicator, cmd.Description)
-		fmt.Printf("   %s\n", cmd.Command)
+		if cmd.Denied {
+			safetyIndicator = "🚫"
+		}
+		
+		fmt.Fprintf(output.Stdout, "\n%d. [%s] %s\n", i+1, safetyIndicator, cmd.Description)
+		fmt.Fprintf(output.Stdout, "   %s\n", cmd.Command)
+		if cmd.Denied {
+			fmt.Fprintf(output.Stdout, "   Not permitted: %s\n", cmd.DeniedReason)
+			if cmd.Alternative != "" {
+				fmt.Fprintf(output.Stdout, "   Alternative: %s\n", cmd.Alternative)
+			}
+		}
 	}
+}
+
+// AddWarning adds a warning unless the plan already has it, so replayed
+// plans don't accumulate duplicates
+func (p *Plan) AddWarning(warning string) {
+	for _, existing := range p.Warnings {
+		if existing == warning {
+			return
+		}
+	}
+	p.Warnings = append(p.Warnings, warning)
+}
+
+// DeniedCommands returns the commands the user is not permitted to run
+func (p *Plan) DeniedCommands() []Command {
+	var denied []Command
+	for _, cmd := range p.AllCommands() {
+		if cmd.Denied {
+			denied = append(denied, cmd)
+		}
+	}
+	return denied
+}
+
+// AllCommands returns preconditions, commands and verifications in execution order
+func (p *Plan) AllCommands() []Command {
+	all := make([]Command, 0, len(p.Preconditions)+len(p.Commands)+len(p.Verifications))
+	all = append(all, p.Preconditions...)
+	all = append(all, p.Commands...)
+	all = append(all, p.Verifications...)
+	return all
+}
+
+// SetDryRun switches the plan and all of its commands in or out of dry-run mode
+func (p *Plan) SetDryRun(dryRun bool) {
+	p.DryRun = dryRun
+	for _, cmds := range [][]Command{p.Preconditions, p.Commands, p.Verifications} {
+		for i := range cmds {
+			cmds[i].DryRun = dryRun
+		}
+	}
+}
+
+// ForContext returns a copy of the plan whose kubectl commands target the
+// named kubeconfig context
+func (p *Plan) ForContext(name string) *Plan {
+	clone := *p
+	clone.Warnings = append([]string{}, p.Warnings...)
+	clone.Target = nil
 	
-	if p.DryRun {
-		fmt.Println("\n(Dry-run mode - no changes will be applied)")
+	withContext := func(cmds []Command) []Command {
+		out := make([]Command, len(cmds))
+		for i, cmd := range cmds {
+			if strings.HasPrefix(cmd.Command, "kubectl ") && !strings.Contains(cmd.Command, "--context") {
+				cmd.Command = "kubectl --context " + name + strings.TrimPrefix(cmd.Command, "kubectl")
+			}
+			out[i] = cmd
+		}
+		return out
+	}
+	clone.Preconditions = withContext(p.Preconditions)
+	clone.Commands = withContext(p.Commands)
+	clone.Verifications = withContext(p.Verifications)
+	
+	return &clone
+}
+
+// UndoCommands returns the commands that revert the plan's changes, in
+// reverse order. Commands without a known inverse are skipped.
+func (p *Plan) UndoCommands() []string {
+	var undo []string
+	for i := len(p.Commands) - 1; i >= 0; i-- {
+		cmd := p.Commands[i]
+		inverse := cmd.Undo
+		if inverse == "" {
+			inverse = InferUndo(cmd.Command)
+		}
+		if inverse != "" {
+			undo = append(undo, inverse)
+		}
+	}
+	return undo
+}
+
+// InferUndo derives the inverse of common mutating kubectl commands
+func InferUndo(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 3 || fields[0] != "kubectl" {
+		return ""
+	}
+	
+	switch fields[1] {
+	case "cordon", "drain":
+		return "kubectl uncordon " + fields[2]
+	case "uncordon":
+		return "kubectl cordon " + fields[2]
+	case "rollout":
+		// kubectl rollout restart deployment/app [-n ns]
+		if fields[2] == "restart" && len(fields) > 3 {
+			return strings.TrimSpace("kubectl rollout undo " + resourceArgs(fields[3:]) + namespaceFlag(fields))
+		}
+	case "set":
+		// kubectl set image deployment/app app=image:tag [-n ns]
+		if len(fields) > 3 && strings.Contains(fields[3], "/") {
+			return "kubectl rollout undo " + fields[3] + namespaceFlag(fields)
+		}
+	case "create":
+		// kubectl create <type> <name> ...
+		if len(fields) > 3 && !strings.HasPrefix(fields[2], "-") && !strings.HasPrefix(fields[3], "-") {
+			return "kubectl delete " + fields[2] + " " + fields[3] + namespaceFlag(fields)
+		}
+	}
+	
+	return ""
+}
+
+// resourceArgs returns the leading resource arguments up to the first flag
+func resourceArgs(fields []string) string {
+	var args []string
+	for _, f := range fields {
+		if strings.HasPrefix(f, "-") {
+			break
+		}
+		args = append(args, f)
+	}
+	return strings.Join(args, " ")
+}
+
+// namespaceFlag returns the namespace flag of a command, with a leading space
+func namespaceFlag(fields []string) string {
+	for i, f := range fields {
+		if (f == "-n" || f == "--namespace") && i+1 < len(fields) {
+			return " -n " + fields[i+1]
+		}
+		if strings.HasPrefix(f, "--namespace=") {
+			return " -n " + strings.TrimPrefix(f, "--namespace=")
+		}
 	}
+	return ""
 }
 
 // Execute executes the plan
@@ -223,7 +455,7 @@ func (p *Plan) Execute() (*Result, error) {
 		Errors:           []string{},
 	}
 	
-	for _, cmd := range p.Commands {
+	for _, cmd := range p.AllCommands() {
 		if p.DryRun && !cmd.DryRun {
 			result.ExecutedCommands = append(result.ExecutedCommands, 
 				fmt.Sprintf("[DRY-RUN] %s", cmd.Command))
@@ -246,15 +478,15 @@ type Result struct {
 
 // Display displays the result
 func (r *Result) Display() {
-	fmt.Println("\nExecuted commands:")
+	fmt.Fprintln(output.Stdout, "\nExecuted commands:")
 	for i, cmd := range r.ExecutedCommands {
-		fmt.Printf("%d. %s\n", i+1, cmd)
+		fmt.Fprintf(output.Stdout, "%d. %s\n", i+1, cmd)
 	}
 	
 	if len(r.Errors) > 0 {
-		fmt.Println("\nErrors encountered:")
+		fmt.Fprintln(output.Stdout, "\nErrors encountered:")
 		for _, err := range r.Errors {
-			fmt.Printf("  ✗ %s\n", err)
+			fmt.Fprintf(output.Stdout, "  ✗ %s\n", err)
 		}
 	}
 }
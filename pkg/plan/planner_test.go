@@ -0,0 +1,136 @@
+package plan
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/ai"
+	"k8s-pilot/pkg/k8s"
+)
+
+// stubProvider returns a canned response for every prompt and records the
+// last prompt
+type stubProvider struct {
+	content string
+	prompt  string
+}
+
+func (s *stubProvider) Generate(ctx context.Context, prompt string, options *ai.Options) (*ai.Response, error) {
+	s.prompt = prompt
+	return &ai.Response{Content: s.content}, nil
+}
+
+func (s *stubProvider) GenerateStructured(ctx context.Context, prompt string, schema interface{}, options *ai.Options) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *stubProvider) Name() string {
+	return "stub"
+}
+
+func TestGenerate(t *testing.T) {
+	provider := &stubProvider{content: `SUMMARY: Restart the api deployment
+COMMANDS:
+- kubectl rollout restart deployment/api -n payments | Restart pods | true
+- kubectl delete pod api-0 -n payments | Delete the stuck pod | false
+
+WARNINGS:
+- Pods will be recreated
+`}
+	planner := NewPlannerWithProvider(provider, "payments", true)
+
+	plan, err := planner.Generate("restart api")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if plan.Summary != "Restart the api deployment" {
+		t.Errorf("Summary = %q", plan.Summary)
+	}
+	if plan.Source != SourceAI || plan.Query != "restart api" || !plan.DryRun {
+		t.Errorf("unexpected plan metadata: %+v", plan)
+	}
+	if len(plan.Commands) != 2 {
+		t.Fatalf("parsed %d commands, want 2", len(plan.Commands))
+	}
+	if !plan.Commands[0].Safe || plan.Commands[1].Safe {
+		t.Errorf("safety flags = %v/%v, want true/false", plan.Commands[0].Safe, plan.Commands[1].Safe)
+	}
+	if len(plan.Warnings) != 1 || plan.Warnings[0] != "Pods will be recreated" {
+		t.Errorf("Warnings = %v", plan.Warnings)
+	}
+}
+
+func TestInferUndo(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"kubectl cordon node-1", "kubectl uncordon node-1"},
+		{"kubectl drain node-1 --ignore-daemonsets", "kubectl uncordon node-1"},
+		{"kubectl rollout restart deployment/api -n payments", "kubectl rollout undo deployment/api -n payments"},
+		{"kubectl set image deployment/api api=api:2 -n payments", "kubectl rollout undo deployment/api -n payments"},
+		{"kubectl create configmap settings --from-literal=a=b", "kubectl delete configmap settings"},
+		{"kubectl get pods", ""},
+	}
+
+	for _, tt := range tests {
+		if got := InferUndo(tt.command); got != tt.want {
+			t.Errorf("InferUndo(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateUsesClusterTooling(t *testing.T) {
+	provider := &stubProvider{}
+	planner := NewPlannerWithProvider(provider, "payments", true)
+	planner.SetClusterType(k8s.ClusterTypeEKS)
+
+	if _, err := planner.Generate("add two more nodes"); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !strings.Contains(provider.prompt, "Cluster type: EKS") || !strings.Contains(provider.prompt, "eksctl scale nodegroup") {
+		t.Errorf("prompt does not mention EKS tooling:\n%s", provider.prompt)
+	}
+
+	planner.SetClusterType(k8s.ClusterTypeGeneric)
+	if _, err := planner.Generate("add two more nodes"); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if strings.Contains(provider.prompt, "Cluster type") {
+		t.Errorf("generic clusters should not add a cluster type hint:\n%s", provider.prompt)
+	}
+}
+
+func TestPlanDocument(t *testing.T) {
+	p := &Plan{
+		Query:   "restart api",
+		Summary: "Restart the api deployment",
+		Commands: []Command{
+			{Command: "kubectl rollout restart deployment/api", Description: "Restart api", Undo: "kubectl rollout undo deployment/api"},
+			{Command: "kubectl delete pod api-0", Description: "Delete pod", Denied: true, DeniedReason: "cannot delete pods"},
+		},
+		DryRun: true,
+		Target: &k8s.Target{Context: "prod", Cluster: "eu", Namespace: "shop"},
+	}
+
+	doc := p.Document()
+	if doc.Kind != KindPlan || doc.Target == nil || doc.Target.Context != "prod" {
+		t.Errorf("document = %+v, want a plan for the prod context", doc)
+	}
+	if len(doc.Commands) != 2 || doc.Commands[0].Undo == "" || !doc.Commands[1].Denied {
+		t.Errorf("commands = %+v, want the undo and denial kept", doc.Commands)
+	}
+	if doc.Preconditions != nil {
+		t.Errorf("preconditions = %+v, want none", doc.Preconditions)
+	}
+
+	var buf strings.Builder
+	p.Markdown(&buf)
+	for _, want := range []string{"# Execution Plan", "| 1 | Restart api | `kubectl rollout restart deployment/api` | no |", "| 2 | Delete pod | `kubectl delete pod api-0` | denied: cannot delete pods |"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("markdown missing %q:\n%s", want, buf.String())
+		}
+	}
+}
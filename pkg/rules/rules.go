@@ -0,0 +1,486 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+
+	"k8s-pilot/pkg/k8s"
+)
+
+// Severities a rule may assign to its issues
+var severities = map[string]bool{"critical": true, "high": true, "medium": true, "low": true}
+
+// Rule is a user-defined diagnostic check: when its JSONPath expression
+// selects anything from an object of the matched kind, it reports an issue
+type Rule struct {
+	Name         string        `yaml:"name"`
+	Description  string        `yaml:"description"`
+	Match        Match         `yaml:"match"`
+	Severity     string        `yaml:"severity"`
+	Type         string        `yaml:"type"`
+	Message      string        `yaml:"message"`
+	Remediations []Remediation `yaml:"remediations"`
+	Tests        []Test        `yaml:"tests"`
+
+	// Path is the file the rule was loaded from
+	Path string `yaml:"-"`
+}
+
+// Match selects the objects a rule applies to. The JSONPath expression is
+// evaluated against the object with its events added under "events" and
+// the object itself as a one-element list under "self", so scalar fields
+// can be filtered (.self[?(@.spec.replicas==1)].metadata.name). Every
+// non-empty value it selects (other than "false") is a match.
+type Match struct {
+	Kind     string `yaml:"kind"`
+	JSONPath string `yaml:"jsonpath"`
+}
+
+// Remediation is a templated remediation command
+type Remediation struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Command     string `yaml:"command"`
+	Confidence  string `yaml:"confidence"`
+	Safe        bool   `yaml:"safe"`
+}
+
+// Test checks a rule against a fixture file. Expect lists the names of the
+// objects that should match; an empty list expects none.
+type Test struct {
+	Fixture string   `yaml:"fixture"`
+	Expect  []string `yaml:"expect"`
+}
+
+// Finding is a rule match on one object, with its templates rendered
+type Finding struct {
+	Rule         *Rule
+	Kind         string
+	Namespace    string
+	Name         string
+	Value        string
+	Message      string
+	Remediations []Remediation
+}
+
+// templateData is available to message and remediation templates
+type templateData struct {
+	Name      string
+	Namespace string
+	Kind      string
+	Value     string
+	Object    map[string]interface{}
+}
+
+// Load reads the rules in a YAML file; a file may hold several rules
+// separated by "---"
+func Load(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule file: %w", err)
+	}
+
+	var rules []*Rule
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var rule Rule
+		if err := decoder.Decode(&rule); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse rule file %s: %w", path, err)
+		}
+		rule.Path = path
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule in %s: %w", path, err)
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// Validate checks that the rule is complete and its expression and
+// templates parse
+func (r *Rule) Validate() error {
+	switch {
+	case r.Name == "":
+		return fmt.Errorf("rule without a name")
+	case r.Match.Kind == "":
+		return fmt.Errorf("rule %s has no match.kind", r.Name)
+	case r.Match.JSONPath == "":
+		return fmt.Errorf("rule %s has no match.jsonpath", r.Name)
+	case r.Type == "":
+		return fmt.Errorf("rule %s has no type", r.Name)
+	case r.Message == "":
+		return fmt.Errorf("rule %s has no message", r.Name)
+	case !severities[r.Severity]:
+		return fmt.Errorf("rule %s has invalid severity %q (expected critical, high, medium or low)", r.Name, r.Severity)
+	}
+
+	if _, err := r.compile(); err != nil {
+		return err
+	}
+	texts := []string{r.Message}
+	for _, remediation := range r.Remediations {
+		if strings.TrimSpace(remediation.Command) == "" {
+			return fmt.Errorf("rule %s has a remediation without a command", r.Name)
+		}
+		texts = append(texts, remediation.Title, remediation.Description, remediation.Command)
+	}
+	for _, text := range texts {
+		if _, err := template.New(r.Name).Parse(text); err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// compile parses the rule's JSONPath expression; the surrounding braces
+// are optional
+func (r *Rule) compile() (*jsonpath.JSONPath, error) {
+	expression := strings.TrimSpace(r.Match.JSONPath)
+	if !strings.HasPrefix(expression, "{") {
+		expression = "{" + expression + "}"
+	}
+	path := jsonpath.New(r.Name).AllowMissingKeys(true)
+	if err := path.Parse(expression); err != nil {
+		return nil, fmt.Errorf("rule %s: invalid jsonpath: %w", r.Name, err)
+	}
+	return path, nil
+}
+
+// Matches reports whether the rule applies to a kind
+func (r *Rule) Matches(kind string) bool {
+	return strings.EqualFold(r.Match.Kind, kind)
+}
+
+// Evaluate runs the rule against an object (as produced by
+// runtime.DefaultUnstructuredConverter) and its events, returning a finding
+// per distinct selected value
+func (r *Rule) Evaluate(obj map[string]interface{}, events []map[string]interface{}) ([]Finding, error) {
+	path, err := r.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{}, len(obj)+2)
+	for key, value := range obj {
+		data[key] = value
+	}
+	items := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		items = append(items, event)
+	}
+	data["events"] = items
+	data["self"] = []interface{}{obj}
+
+	results, err := path.FindResults(data)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: failed to evaluate jsonpath: %w", r.Name, err)
+	}
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+
+	var findings []Finding
+	seen := map[string]bool{}
+	for _, values := range results {
+		for _, value := range values {
+			text := formatValue(value)
+			if text == "" || text == "false" || seen[text] {
+				continue
+			}
+			seen[text] = true
+
+			finding, err := r.render(templateData{Name: name, Namespace: namespace, Kind: r.Match.Kind, Value: text, Object: obj})
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+// render executes the rule's templates for a match
+func (r *Rule) render(data templateData) (Finding, error) {
+	finding := Finding{Rule: r, Kind: data.Kind, Namespace: data.Namespace, Name: data.Name, Value: data.Value}
+
+	var err error
+	if finding.Message, err = renderTemplate(r.Message, data); err != nil {
+		return Finding{}, fmt.Errorf("rule %s: failed to render message: %w", r.Name, err)
+	}
+	for _, remediation := range r.Remediations {
+		rendered := remediation
+		for _, field := range []*string{&rendered.Title, &rendered.Description, &rendered.Command} {
+			if *field, err = renderTemplate(*field, data); err != nil {
+				return Finding{}, fmt.Errorf("rule %s: failed to render remediation: %w", r.Name, err)
+			}
+		}
+		finding.Remediations = append(finding.Remediations, rendered)
+	}
+	return finding, nil
+}
+
+// renderTemplate executes a text/template; missing object fields render
+// as empty
+func renderTemplate(text string, data templateData) (string, error) {
+	tmpl, err := template.New("rule").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", "")), nil
+}
+
+// formatValue formats a selected value; maps and lists are not matches by
+// themselves unless non-empty
+func formatValue(value reflect.Value) string {
+	if !value.IsValid() {
+		return ""
+	}
+	if value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice:
+		if value.Len() == 0 {
+			return ""
+		}
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// Set is the collection of rules loaded from a directory
+type Set struct {
+	rules []*Rule
+}
+
+// NewSet returns a set of rules
+func NewSet(rules ...*Rule) *Set {
+	return &Set{rules: rules}
+}
+
+// LoadDir loads every *.yaml and *.yml rule file in a directory. A missing
+// directory yields an empty set.
+func LoadDir(dir string) (*Set, error) {
+	set := &Set{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return set, nil
+		}
+		return nil, fmt.Errorf("failed to read rule directory: %w", err)
+	}
+
+	names := map[string]string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		rules, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if existing, ok := names[rule.Name]; ok {
+				return nil, fmt.Errorf("rule %s defined in both %s and %s", rule.Name, existing, rule.Path)
+			}
+			names[rule.Name] = rule.Path
+			set.rules = append(set.rules, rule)
+		}
+	}
+
+	sort.Slice(set.rules, func(i, j int) bool {
+		return set.rules[i].Name < set.rules[j].Name
+	})
+	return set, nil
+}
+
+// List returns all rules sorted by name
+func (s *Set) List() []*Rule {
+	if s == nil {
+		return nil
+	}
+	return s.rules
+}
+
+// ForKind returns the rules that apply to a kind
+func (s *Set) ForKind(kind string) []*Rule {
+	var rules []*Rule
+	for _, rule := range s.List() {
+		if rule.Matches(kind) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Kinds returns the distinct kinds the rules match, as written in the rules
+func (s *Set) Kinds() []string {
+	var kinds []string
+	seen := map[string]bool{}
+	for _, rule := range s.List() {
+		key := strings.ToLower(rule.Match.Kind)
+		if !seen[key] {
+			seen[key] = true
+			kinds = append(kinds, rule.Match.Kind)
+		}
+	}
+	return kinds
+}
+
+// Object is a fixture object prepared for rule evaluation
+type Object struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Content   map[string]interface{}
+	Events    []map[string]interface{}
+}
+
+// LoadFixtures reads objects from YAML or JSON files and attaches each
+// Event in them to the object it involves
+func LoadFixtures(paths ...string) ([]Object, error) {
+	decoded, err := k8s.LoadObjects(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	var events []corev1.Event
+	for _, obj := range decoded {
+		if event, ok := obj.(*corev1.Event); ok {
+			events = append(events, *event)
+			continue
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object metadata: %w", err)
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", accessor.GetName(), err)
+		}
+		objects = append(objects, Object{
+			Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: accessor.GetNamespace(),
+			Name:      accessor.GetName(),
+			Content:   content,
+		})
+	}
+
+	for _, event := range events {
+		event := event
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert event %s: %w", event.Name, err)
+		}
+		involved := event.InvolvedObject
+		for i := range objects {
+			if objects[i].Kind == involved.Kind && objects[i].Name == involved.Name && objects[i].Namespace == involved.Namespace {
+				objects[i].Events = append(objects[i].Events, content)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// EvaluateObjects runs the rules against every object of their kind
+func EvaluateObjects(rules []*Rule, objects []Object) ([]Finding, error) {
+	var findings []Finding
+	for _, obj := range objects {
+		for _, rule := range rules {
+			if !rule.Matches(obj.Kind) {
+				continue
+			}
+			matched, err := rule.Evaluate(obj.Content, obj.Events)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, matched...)
+		}
+	}
+	return findings, nil
+}
+
+// TestResult is the outcome of one rule test
+type TestResult struct {
+	Rule     *Rule
+	Fixture  string
+	Expected []string
+	Matched  []string
+	Err      error
+}
+
+// Passed reports whether the rule matched exactly the expected objects
+func (t TestResult) Passed() bool {
+	return t.Err == nil && reflect.DeepEqual(t.Expected, t.Matched)
+}
+
+// RunTests evaluates the rule against its test fixtures. Fixture paths are
+// relative to the rule file.
+func (r *Rule) RunTests() []TestResult {
+	var results []TestResult
+	for _, test := range r.Tests {
+		fixture := test.Fixture
+		if !filepath.IsAbs(fixture) && r.Path != "" {
+			fixture = filepath.Join(filepath.Dir(r.Path), fixture)
+		}
+		result := TestResult{Rule: r, Fixture: fixture, Expected: sortedNames(test.Expect)}
+
+		objects, err := LoadFixtures(fixture)
+		if err == nil {
+			var findings []Finding
+			if findings, err = EvaluateObjects([]*Rule{r}, objects); err == nil {
+				var names []string
+				for _, finding := range findings {
+					names = append(names, finding.Name)
+				}
+				result.Matched = sortedNames(names)
+			}
+		}
+		result.Err = err
+		results = append(results, result)
+	}
+	return results
+}
+
+// sortedNames returns the distinct names, sorted
+func sortedNames(names []string) []string {
+	seen := map[string]bool{}
+	sorted := []string{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuleTests(t *testing.T) {
+	rules, err := Load("testdata/restarts.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+
+	for _, rule := range rules {
+		for _, result := range rule.RunTests() {
+			if !result.Passed() {
+				t.Errorf("rule %s on %s: expected %v, matched %v (err %v)",
+					rule.Name, result.Fixture, result.Expected, result.Matched, result.Err)
+			}
+		}
+	}
+}
+
+func TestEvaluateRendersTemplates(t *testing.T) {
+	rules, err := Load("testdata/restarts.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	objects, err := LoadFixtures("testdata/fixtures/pods.yaml")
+	if err != nil {
+		t.Fatalf("LoadFixtures: %v", err)
+	}
+
+	findings, err := EvaluateObjects(rules, objects)
+	if err != nil {
+		t.Fatalf("EvaluateObjects: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+
+	restarts := findings[0]
+	if restarts.Value != "api" || restarts.Message != "Container api of pod api-7d9f8-x2k4p restarted more than five times" {
+		t.Errorf("unexpected finding %+v", restarts)
+	}
+	if len(restarts.Remediations) != 1 || restarts.Remediations[0].Command != "kubectl logs api-7d9f8-x2k4p -n shop -c api --previous" {
+		t.Errorf("unexpected remediations %+v", restarts.Remediations)
+	}
+	if backoff := findings[1]; backoff.Message != "Pod api-7d9f8-x2k4p is backing off (image shop/api:1.4.2)" {
+		t.Errorf("unexpected message %q", backoff.Message)
+	}
+}
+
+func TestLoadRejectsInvalidRules(t *testing.T) {
+	tests := map[string]string{
+		"severity": "name: a\nmatch: {kind: Pod, jsonpath: .metadata.name}\nseverity: urgent\ntype: T\nmessage: m\n",
+		"jsonpath": "name: a\nmatch: {kind: Pod, jsonpath: '.status[?(@.x'}\nseverity: low\ntype: T\nmessage: m\n",
+		"template": "name: a\nmatch: {kind: Pod, jsonpath: .metadata.name}\nseverity: low\ntype: T\nmessage: '{{ .Name'\n",
+		"kind":     "name: a\nmatch: {jsonpath: .metadata.name}\nseverity: low\ntype: T\nmessage: m\n",
+	}
+
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "rule.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	set, err := LoadDir("testdata")
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if len(set.ForKind("POD")) != 2 || len(set.Kinds()) != 1 {
+		t.Errorf("expected two pod rules, got %+v", set.List())
+	}
+
+	missing, err := LoadDir(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(missing.List()) != 0 {
+		t.Errorf("expected an empty set for a missing directory, got %v, %v", missing, err)
+	}
+}
+
+func TestRuleTestFailure(t *testing.T) {
+	rules, err := Load("testdata/restarts.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	rule := *rules[0]
+	rule.Tests = []Test{{Fixture: "fixtures/pods.yaml", Expect: []string{"web-5c6b7-q8z2m"}}}
+
+	results := rule.RunTests()
+	if len(results) != 1 || results[0].Passed() {
+		t.Fatalf("expected a failing test, got %+v", results)
+	}
+	if !strings.HasSuffix(results[0].Fixture, filepath.Join("testdata", "fixtures", "pods.yaml")) {
+		t.Errorf("expected the fixture to resolve next to the rule, got %s", results[0].Fixture)
+	}
+}
@@ -0,0 +1,344 @@
+package runbook
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"k8s-pilot/pkg/plan"
+)
+
+// Runbook is a reusable, parameterized operational procedure
+type Runbook struct {
+	Name          string      `yaml:"name"`
+	Description   string      `yaml:"description"`
+	Keywords      []string    `yaml:"keywords"`
+	Parameters    []Parameter `yaml:"parameters"`
+	Preconditions []Step      `yaml:"preconditions"`
+	Steps         []Step      `yaml:"steps"`
+	Verifications []Step      `yaml:"verify"`
+	Warnings      []string    `yaml:"warnings"`
+
+	// Path is the file the runbook was loaded from
+	Path string `yaml:"-"`
+}
+
+// Parameter is a variable that can be set when rendering a runbook
+type Parameter struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Default     string `yaml:"default"`
+	Required    bool   `yaml:"required"`
+}
+
+// Step is a single templated command in a runbook
+type Step struct {
+	Description string `yaml:"description"`
+	Command     string `yaml:"command"`
+	Safe        bool   `yaml:"safe"`
+	Undo        string `yaml:"undo"`
+}
+
+// Load reads a runbook from a YAML file
+func Load(path string) (*Runbook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runbook: %w", err)
+	}
+
+	var rb Runbook
+	if err := yaml.Unmarshal(data, &rb); err != nil {
+		return nil, fmt.Errorf("failed to parse runbook %s: %w", path, err)
+	}
+
+	if rb.Name == "" {
+		rb.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	rb.Path = path
+
+	if err := rb.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid runbook %s: %w", path, err)
+	}
+
+	return &rb, nil
+}
+
+// Validate checks that the runbook is well formed and its templates parse
+func (r *Runbook) Validate() error {
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("runbook %s has no steps", r.Name)
+	}
+
+	seen := make(map[string]bool)
+	for _, param := range r.Parameters {
+		if param.Name == "" {
+			return fmt.Errorf("parameter without a name")
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("duplicate parameter %s", param.Name)
+		}
+		seen[param.Name] = true
+	}
+
+	for _, step := range r.allSteps() {
+		if strings.TrimSpace(step.Command) == "" {
+			return fmt.Errorf("step %q has no command", step.Description)
+		}
+		for _, text := range []string{step.Command, step.Description, step.Undo} {
+			if _, err := template.New("step").Parse(text); err != nil {
+				return fmt.Errorf("step %q: %w", step.Description, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Render substitutes the given values into the runbook and returns an
+// execution plan that goes through the same pipeline as generated plans
+func (r *Runbook) Render(values map[string]string, namespace string, dryRun bool) (*plan.Plan, error) {
+	vars, err := r.resolve(values, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{
+		Summary:  r.summary(),
+		Source:   plan.SourceRunbook,
+		Warnings: append([]string{}, r.Warnings...),
+		DryRun:   dryRun,
+	}
+
+	if p.Preconditions, err = renderSteps(r.Preconditions, vars, dryRun); err != nil {
+		return nil, err
+	}
+	if p.Commands, err = renderSteps(r.Steps, vars, dryRun); err != nil {
+		return nil, err
+	}
+	if p.Verifications, err = renderSteps(r.Verifications, vars, dryRun); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// resolve merges user values with parameter defaults and checks required ones
+func (r *Runbook) resolve(values map[string]string, namespace string) (map[string]string, error) {
+	vars := make(map[string]string)
+	known := make(map[string]bool)
+
+	for _, param := range r.Parameters {
+		known[param.Name] = true
+		if param.Default != "" {
+			vars[param.Name] = param.Default
+		}
+	}
+
+	for key, value := range values {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown parameter %q for runbook %s", key, r.Name)
+		}
+		vars[key] = value
+	}
+
+	var missing []string
+	for _, param := range r.Parameters {
+		if param.Required && vars[param.Name] == "" {
+			missing = append(missing, param.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required parameter(s) for runbook %s: %s", r.Name, strings.Join(missing, ", "))
+	}
+
+	// namespace is always available to templates unless explicitly declared
+	if _, ok := vars["namespace"]; !ok {
+		if namespace == "" {
+			namespace = "default"
+		}
+		vars["namespace"] = namespace
+	}
+
+	return vars, nil
+}
+
+// summary returns the plan summary for the runbook
+func (r *Runbook) summary() string {
+	if r.Description != "" {
+		return fmt.Sprintf("Runbook %s: %s", r.Name, r.Description)
+	}
+	return fmt.Sprintf("Runbook %s", r.Name)
+}
+
+// allSteps returns preconditions, steps and verifications together
+func (r *Runbook) allSteps() []Step {
+	steps := append([]Step{}, r.Preconditions...)
+	steps = append(steps, r.Steps...)
+	return append(steps, r.Verifications...)
+}
+
+// renderSteps renders templated steps into plan commands
+func renderSteps(steps []Step, vars map[string]string, dryRun bool) ([]plan.Command, error) {
+	commands := make([]plan.Command, 0, len(steps))
+	for _, step := range steps {
+		command, err := renderTemplate(step.Command, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render step %q: %w", step.Description, err)
+		}
+		description, err := renderTemplate(step.Description, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render step %q: %w", step.Description, err)
+		}
+
+		undo, err := renderTemplate(step.Undo, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render undo for step %q: %w", step.Description, err)
+		}
+
+		commands = append(commands, plan.Command{
+			Command:     command,
+			Description: description,
+			Safe:        step.Safe,
+			DryRun:      dryRun,
+			Undo:        undo,
+		})
+	}
+	return commands, nil
+}
+
+// renderTemplate executes a text/template with missing keys treated as errors
+func renderTemplate(text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New("step").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Catalog is a set of runbooks loaded from a directory
+type Catalog struct {
+	runbooks map[string]*Runbook
+}
+
+// LoadDir loads every *.yaml and *.yml runbook in a directory. A missing
+// directory yields an empty catalog.
+func LoadDir(dir string) (*Catalog, error) {
+	catalog := &Catalog{runbooks: make(map[string]*Runbook)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return catalog, nil
+		}
+		return nil, fmt.Errorf("failed to read runbook directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		rb, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := catalog.runbooks[rb.Name]; ok {
+			return nil, fmt.Errorf("runbook %s defined in both %s and %s", rb.Name, existing.Path, rb.Path)
+		}
+		catalog.runbooks[rb.Name] = rb
+	}
+
+	return catalog, nil
+}
+
+// Get returns a runbook by name
+func (c *Catalog) Get(name string) (*Runbook, error) {
+	rb, ok := c.runbooks[name]
+	if !ok {
+		return nil, fmt.Errorf("runbook %s not found", name)
+	}
+	return rb, nil
+}
+
+// List returns all runbooks sorted by name
+func (c *Catalog) List() []*Runbook {
+	list := make([]*Runbook, 0, len(c.runbooks))
+	for _, rb := range c.runbooks {
+		list = append(list, rb)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Suggest returns the name of the runbook that best matches a natural
+// language query, scored by keyword and name overlap (implements
+// plan.RunbookSuggester)
+func (c *Catalog) Suggest(query string) string {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return ""
+	}
+
+	best, bestScore := "", 0
+	for _, rb := range c.List() {
+		score := 0
+		for _, kw := range rb.Keywords {
+			if strings.Contains(strings.ToLower(query), strings.ToLower(kw)) {
+				score += 3
+			}
+		}
+		for word := range tokenize(strings.ReplaceAll(rb.Name, "-", " ")) {
+			if words[word] {
+				score += 2
+			}
+		}
+		for word := range tokenize(rb.Description) {
+			if words[word] {
+				score++
+			}
+		}
+
+		if score > bestScore {
+			best, bestScore = rb.Name, score
+		}
+	}
+
+	// Require more than an incidental single-word description hit
+	if bestScore < 3 {
+		return ""
+	}
+	return best
+}
+
+// stopWords are ignored when matching queries against runbooks
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "in": true, "on": true,
+	"of": true, "to": true, "for": true, "my": true, "with": true, "from": true,
+}
+
+// tokenize lowercases text and splits it into a set of significant words
+func tokenize(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if len(word) > 1 && !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
@@ -0,0 +1,128 @@
+package runbook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s-pilot/pkg/plan"
+)
+
+const drainRunbook = `name: drain-and-replace-node
+description: Cordon and drain a node so it can be replaced
+keywords:
+  - drain node
+parameters:
+  - name: node
+    required: true
+  - name: timeout
+    default: "5m"
+preconditions:
+  - description: Node {{ .node }} exists
+    command: kubectl get node {{ .node }}
+    safe: true
+steps:
+  - description: Drain {{ .node }}
+    command: kubectl drain {{ .node }} --timeout={{ .timeout }}
+verify:
+  - description: Check pods in {{ .namespace }}
+    command: kubectl get pods -n {{ .namespace }}
+    safe: true
+`
+
+func writeRunbook(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write runbook: %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	writeRunbook(t, dir, "drain.yaml", drainRunbook)
+
+	catalog, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to load runbooks: %v", err)
+	}
+
+	rb, err := catalog.Get("drain-and-replace-node")
+	if err != nil {
+		t.Fatalf("Failed to get runbook: %v", err)
+	}
+
+	p, err := rb.Render(map[string]string{"node": "worker-3"}, "ops", true)
+	if err != nil {
+		t.Fatalf("Failed to render runbook: %v", err)
+	}
+
+	if p.Source != plan.SourceRunbook {
+		t.Errorf("Expected source %q, got %q", plan.SourceRunbook, p.Source)
+	}
+	if !p.DryRun {
+		t.Error("Expected dry-run plan")
+	}
+
+	if got := p.Preconditions[0].Command; got != "kubectl get node worker-3" {
+		t.Errorf("Unexpected precondition: %s", got)
+	}
+	if got := p.Commands[0].Command; got != "kubectl drain worker-3 --timeout=5m" {
+		t.Errorf("Unexpected command: %s", got)
+	}
+	if p.Commands[0].Safe {
+		t.Error("Expected drain step to be unsafe")
+	}
+	if got := p.Verifications[0].Command; got != "kubectl get pods -n ops" {
+		t.Errorf("Unexpected verification: %s", got)
+	}
+	if len(p.AllCommands()) != 3 {
+		t.Errorf("Expected 3 commands in execution order, got %d", len(p.AllCommands()))
+	}
+}
+
+func TestRenderParameterErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeRunbook(t, dir, "drain.yaml", drainRunbook)
+
+	catalog, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to load runbooks: %v", err)
+	}
+	rb, _ := catalog.Get("drain-and-replace-node")
+
+	if _, err := rb.Render(nil, "", true); err == nil || !strings.Contains(err.Error(), "node") {
+		t.Errorf("Expected missing parameter error, got %v", err)
+	}
+
+	if _, err := rb.Render(map[string]string{"node": "a", "bogus": "b"}, "", true); err == nil {
+		t.Error("Expected unknown parameter error")
+	}
+}
+
+func TestLoadDirMissing(t *testing.T) {
+	catalog, err := LoadDir(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(catalog.List()) != 0 {
+		t.Error("Expected empty catalog")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	dir := t.TempDir()
+	writeRunbook(t, dir, "drain.yaml", drainRunbook)
+
+	catalog, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to load runbooks: %v", err)
+	}
+
+	if got := catalog.Suggest("please drain node worker-3 and replace it"); got != "drain-and-replace-node" {
+		t.Errorf("Expected drain runbook suggestion, got %q", got)
+	}
+	if got := catalog.Suggest("scale deployment api to 5 replicas"); got != "" {
+		t.Errorf("Expected no suggestion, got %q", got)
+	}
+}